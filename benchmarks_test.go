@@ -1,10 +1,15 @@
 package fastjsonrpc_test
 
 import (
+	"net"
 	"testing"
+	"time"
 
 	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+	"github.com/valyala/fastjson"
 	. "github.com/zc310/fastjsonrpc"
+	"github.com/zc310/fastjsonrpc/ws"
 )
 
 func BenchmarkEchoHandler(b *testing.B) {
@@ -112,3 +117,85 @@ func BenchmarkParamsUnmarshalHandler(b *testing.B) {
 		s.Handler(ctx)
 	}
 }
+
+func BenchmarkCachedHandler(b *testing.B) {
+	b.ReportAllocs()
+
+	s := new(ServerMap)
+	s.RegisterHandler("sum", func(c *RequestCtx) {
+		c.Result = c.Params.GetInt("a") + c.Params.GetInt("b")
+	}, WithCache(NewCache(time.Minute, 1024)))
+
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+	ctx.Request.SetBodyString(`{"jsonrpc":"2.0","method":"sum","params":{"a":3,"b":6},"id":9}`)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		ctx.Response.ResetBody()
+		s.Handler(ctx)
+	}
+}
+
+// benchmarkEndToEnd serves h on an in-memory listener and posts body
+// from parallel clients, measuring the full HTTP round trip.
+func benchmarkEndToEnd(b *testing.B, h fasthttp.RequestHandler, body string) {
+	ln := fasthttputil.NewInmemoryListener()
+	go func() { _ = fasthttp.Serve(ln, h) }()
+	defer ln.Close()
+	c := &fasthttp.Client{Dial: func(string) (net.Conn, error) { return ln.Dial() }}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		req := fasthttp.AcquireRequest()
+		resp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseRequest(req)
+		defer fasthttp.ReleaseResponse(resp)
+		req.SetRequestURI("http://bench/")
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.Header.SetContentType("application/json")
+		req.SetBodyString(body)
+		for pb.Next() {
+			if err := c.Do(req, resp); err != nil {
+				b.Fatal(err)
+			}
+			if resp.StatusCode() != fasthttp.StatusOK {
+				b.Fatalf("status %d: %s", resp.StatusCode(), resp.Body())
+			}
+		}
+	})
+}
+
+func BenchmarkEndToEnd(b *testing.B) {
+	const body = `{"jsonrpc":"2.0","method":"sum","params":{"a":3,"b":6},"id":9}`
+	sum := func(c *RequestCtx) { c.Result = c.Params.GetInt("a") + c.Params.GetInt("b") }
+
+	s := new(ServerMap)
+	s.RegisterHandler("sum", sum)
+	j := ws.NewJSONRPC2()
+	j.RegisterMethod("sum", func(_ *fastjson.Arena, params *fastjson.Value) (interface{}, error) {
+		return params.GetInt("a") + params.GetInt("b"), nil
+	})
+
+	b.Run("ServerMap.Handler", func(b *testing.B) { benchmarkEndToEnd(b, s.Handler, body) })
+	b.Run("Rpc", func(b *testing.B) { benchmarkEndToEnd(b, Rpc(sum), body) })
+	b.Run("ws.HTTPHandler", func(b *testing.B) { benchmarkEndToEnd(b, ws.HTTPHandler(j), body) })
+}
+
+func BenchmarkEndToEndBatch(b *testing.B) {
+	const body = `[{"jsonrpc":"2.0","method":"sum","params":{"a":3,"b":3},"id":3},` +
+		`{"jsonrpc":"2.0","method":"sum","params":{"a":6,"b":6},"id":6},` +
+		`{"jsonrpc":"2.0","method":"sum","params":{"a":9,"b":9}}]`
+
+	s := new(ServerMap)
+	s.RegisterHandler("sum", func(c *RequestCtx) { c.Result = c.Params.GetInt("a") + c.Params.GetInt("b") })
+	j := ws.NewJSONRPC2()
+	j.RegisterMethod("sum", func(_ *fastjson.Arena, params *fastjson.Value) (interface{}, error) {
+		return params.GetInt("a") + params.GetInt("b"), nil
+	})
+
+	b.Run("ServerMap.Handler", func(b *testing.B) { benchmarkEndToEnd(b, s.Handler, body) })
+	b.Run("ws.HTTPHandler", func(b *testing.B) { benchmarkEndToEnd(b, ws.HTTPHandler(j), body) })
+}
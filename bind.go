@@ -0,0 +1,245 @@
+package fastjsonrpc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+
+	"github.com/goccy/go-json"
+	"github.com/valyala/bytebufferpool"
+	"github.com/valyala/fastjson"
+)
+
+var (
+	typeOfError      = reflect.TypeOf((*error)(nil)).Elem()
+	typeOfStdContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+)
+
+// Func binds JSON-RPC params to the arguments of a plain Go function such
+// as
+//
+//	func(ctx context.Context, a int, b string, opts *Opts) (R, error)
+//
+// The optional leading context.Context receives the call context. Array
+// params bind positionally; object params bind by the declared names, or
+// decode into the only argument when no names are declared. Trailing
+// pointer arguments are optional and stay nil when omitted. The function
+// may return nothing, a result, an error, or a result and an error.
+type Func struct {
+	fn       reflect.Value
+	names    []string
+	args     []reflect.Type
+	withCtx  bool
+	required int
+	result   reflect.Type
+	hasError bool
+	validate bool
+}
+
+// NewFunc wraps fn. names, if given, declare the params names of the
+// arguments following the optional context.
+func NewFunc(fn any, names ...string) (*Func, error) {
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+	if t.Kind() != reflect.Func {
+		return nil, errors.New("rpc: " + t.String() + " is not a function")
+	}
+	if t.IsVariadic() {
+		return nil, errors.New("rpc: variadic functions are not supported")
+	}
+
+	p := &Func{fn: v, names: names}
+	for i := 0; i < t.NumIn(); i++ {
+		in := t.In(i)
+		if i == 0 && in == typeOfStdContext {
+			p.withCtx = true
+			continue
+		}
+		p.args = append(p.args, in)
+	}
+	if len(names) > 0 && len(names) != len(p.args) {
+		return nil, errors.New("rpc: " + strconv.Itoa(len(names)) + " params names for " + strconv.Itoa(len(p.args)) + " arguments")
+	}
+	p.required = len(p.args)
+	for p.required > 0 && p.args[p.required-1].Kind() == reflect.Pointer {
+		p.required--
+	}
+
+	switch t.NumOut() {
+	case 0:
+	case 1:
+		if t.Out(0) == typeOfError {
+			p.hasError = true
+		} else {
+			p.result = t.Out(0)
+		}
+	case 2:
+		if t.Out(1) != typeOfError {
+			return nil, errors.New("rpc: second result of " + t.String() + " must be error")
+		}
+		p.result, p.hasError = t.Out(0), true
+	default:
+		return nil, errors.New("rpc: " + t.String() + " has too many results")
+	}
+	return p, nil
+}
+
+// MustFunc is like NewFunc but panics on error.
+func MustFunc(fn any, names ...string) *Func {
+	p, err := NewFunc(fn, names...)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Names returns the declared params names.
+func (p *Func) Names() []string { return p.names }
+
+// Args returns the types of the bound arguments.
+func (p *Func) Args() []reflect.Type { return p.args }
+
+// Required returns the number of arguments that must be supplied.
+func (p *Func) Required() int { return p.required }
+
+// Result returns the result type, or nil if the function returns none.
+func (p *Func) Result() reflect.Type { return p.result }
+
+// Call binds params and invokes the function. Binding failures are
+// reported as Invalid params errors.
+func (p *Func) Call(ctx context.Context, params *fastjson.Value) (any, error) {
+	in := make([]reflect.Value, 0, len(p.args)+1)
+	if p.withCtx {
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		in = append(in, reflect.ValueOf(ctx))
+	}
+
+	args, err := p.bind(params)
+	if err != nil {
+		return nil, err
+	}
+	in = append(in, args...)
+
+	out := p.fn.Call(in)
+	if p.hasError {
+		if e := out[len(out)-1]; !e.IsNil() {
+			return nil, e.Interface().(error)
+		}
+	}
+	if p.result != nil {
+		return out[0].Interface(), nil
+	}
+	return nil, nil
+}
+
+func (p *Func) bind(params *fastjson.Value) ([]reflect.Value, error) {
+	values := make([]*fastjson.Value, len(p.args))
+	paths := make([]string, len(p.args))
+	for i := range paths {
+		paths[i] = paramsRoot + "[" + strconv.Itoa(i) + "]"
+	}
+
+	switch {
+	case params == nil || params.Type() == fastjson.TypeNull:
+	case params.Type() == fastjson.TypeArray:
+		a := params.GetArray()
+		if len(a) > len(p.args) {
+			return nil, invalidParams([]FieldError{{Field: paramsRoot, Rule: "maxItems",
+				Message: "expected at most " + strconv.Itoa(len(p.args)) + " params"}})
+		}
+		copy(values, a)
+	case params.Type() == fastjson.TypeObject && len(p.names) > 0:
+		o := params.GetObject()
+		var errs []FieldError
+		o.Visit(func(key []byte, _ *fastjson.Value) {
+			for _, n := range p.names {
+				if n == string(key) {
+					return
+				}
+			}
+			errs = append(errs, FieldError{Field: paramsRoot + "." + string(key), Rule: "additionalProperties", Message: "is not allowed"})
+		})
+		if len(errs) > 0 {
+			return nil, invalidParams(errs)
+		}
+		for i, n := range p.names {
+			values[i] = o.Get(n)
+			paths[i] = paramsRoot + "." + n
+		}
+	case params.Type() == fastjson.TypeObject && len(p.args) == 1:
+		values[0] = params
+		paths[0] = paramsRoot
+	default:
+		return nil, invalidParams([]FieldError{{Field: paramsRoot, Rule: "type", Message: "must be array"}})
+	}
+
+	b := bytebufferpool.Get()
+	defer bytebufferpool.Put(b)
+
+	args := make([]reflect.Value, len(p.args))
+	var errs []FieldError
+	for i, t := range p.args {
+		if values[i] == nil {
+			if i < p.required {
+				errs = append(errs, FieldError{Field: paths[i], Rule: "required", Message: "is required"})
+			}
+			args[i] = reflect.Zero(t)
+			continue
+		}
+
+		v := reflect.New(t)
+		b.B = values[i].MarshalTo(b.B[:0])
+		if err := json.Unmarshal(b.B, v.Interface()); err != nil {
+			errs = append(errs, FieldError{Field: paths[i], Rule: "type", Message: "must be " + t.String()})
+			continue
+		}
+		if p.validate {
+			var verr []FieldError
+			validateValue(v, paths[i], &verr)
+			errs = append(errs, verr...)
+		}
+		args[i] = v.Elem()
+	}
+	if len(errs) > 0 {
+		return nil, invalidParams(errs)
+	}
+	return args, nil
+}
+
+// EnableValidation makes Call check the `validate` struct tags of the
+// arguments, see Validate. It fails if the tags use unknown rules.
+func (p *Func) EnableValidation() error {
+	for _, t := range p.args {
+		if err := checkRules(t); err != nil {
+			return err
+		}
+	}
+	p.validate = true
+	return nil
+}
+
+// RegisterFunc registers fn, bound with NewFunc, as method. Use
+// WithParamNames to accept named params and WithValidation to check
+// their `validate` struct tags.
+func (p *ServerMap) RegisterFunc(method string, fn any, opts ...Option) error {
+	o := p.newOptions(opts)
+	f, err := NewFunc(fn, o.paramNames...)
+	if err != nil {
+		return err
+	}
+	if o.validate {
+		if err := f.EnableValidation(); err != nil {
+			return err
+		}
+	}
+	p.RegisterHandler(method, func(c *RequestCtx) {
+		c.Result, c.Error = f.Call(c.Context(), c.Params)
+		if c.Error != nil {
+			c.Result = nil
+		}
+	}, append(opts[:len(opts):len(opts)], withFunc(f))...)
+	return nil
+}
@@ -0,0 +1,78 @@
+package fastjsonrpc_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/pretty"
+	"github.com/valyala/fasthttp"
+	. "github.com/zc310/fastjsonrpc"
+)
+
+func TestRegisterFunc(t *testing.T) {
+	t.Parallel()
+
+	type Opts struct {
+		Scale int `json:"scale" validate:"min=1"`
+	}
+
+	s := new(ServerMap)
+	assert.NoError(t, s.RegisterFunc("subtract", func(ctx context.Context, a, b int, opts *Opts) (int, error) {
+		if ctx == nil {
+			return 0, errors.New("no context")
+		}
+		r := a - b
+		if opts != nil {
+			r *= opts.Scale
+		}
+		return r, nil
+	}, WithParamNames("minuend", "subtrahend", "opts"), WithValidation()))
+	assert.NoError(t, s.RegisterFunc("greet", func(name string) string { return "hello " + name }))
+	assert.NoError(t, s.RegisterFunc("fail", func() error { return NewError(-32000, "failed") }))
+	assert.Error(t, s.RegisterFunc("bad", func(a int) {}, WithParamNames("a", "b")))
+	type Contact struct {
+		Email string `validate:"email"`
+	}
+	assert.Error(t, s.RegisterFunc("contact", func(c Contact) {}, WithValidation()))
+	assert.Error(t, s.RegisterFunc("bad", 1))
+
+	f := func(request, response string) {
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+		ctx.Request.SetBodyString(request)
+
+		s.Handler(ctx)
+
+		assert.Equal(t, string(pretty.Ugly([]byte(response))), string(pretty.Ugly(ctx.Response.Body())))
+	}
+
+	f(`{"jsonrpc":"2.0","method":"subtract","params":[42,23],"id":1}`,
+		`{"jsonrpc":"2.0","result":19,"id":1}`)
+	f(`{"jsonrpc":"2.0","method":"subtract","params":[42,23,{"scale":2}],"id":2}`,
+		`{"jsonrpc":"2.0","result":38,"id":2}`)
+	f(`{"jsonrpc":"2.0","method":"subtract","params":{"subtrahend":23,"minuend":42},"id":3}`,
+		`{"jsonrpc":"2.0","result":19,"id":3}`)
+	f(`{"jsonrpc":"2.0","method":"greet","params":["bob"],"id":4}`,
+		`{"jsonrpc":"2.0","result":"hello bob","id":4}`)
+	f(`{"jsonrpc":"2.0","method":"fail","id":5}`,
+		`{"jsonrpc":"2.0","error":{"code":-32000,"message":"failed"},"id":5}`)
+	f(`{"jsonrpc":"2.0","method":"subtract","params":[42],"id":6}`,
+		`{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params","data":[
+			{"field":"params[1]","rule":"required","message":"is required"}
+		]},"id":6}`)
+	f(`{"jsonrpc":"2.0","method":"subtract","params":{"minuend":"x","subtrahend":1,"opts":{"scale":0},"z":0},"id":7}`,
+		`{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params","data":[
+			{"field":"params.z","rule":"additionalProperties","message":"is not allowed"}
+		]},"id":7}`)
+	f(`{"jsonrpc":"2.0","method":"subtract","params":{"minuend":"x","subtrahend":1,"opts":{"scale":0}},"id":8}`,
+		`{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params","data":[
+			{"field":"params.minuend","rule":"type","message":"must be int"},
+			{"field":"params.opts.scale","rule":"min","message":"must be at least 1"}
+		]},"id":8}`)
+	f(`{"jsonrpc":"2.0","method":"greet","params":["a","b"],"id":9}`,
+		`{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params","data":[
+			{"field":"params","rule":"maxItems","message":"expected at most 1 params"}
+		]},"id":9}`)
+}
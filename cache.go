@@ -0,0 +1,247 @@
+package fastjsonrpc
+
+import (
+	"container/list"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/valyala/bytebufferpool"
+	"github.com/valyala/fastjson"
+)
+
+// CacheStore is the backend of a Cache. Values are encoded results and
+// must not be modified once stored or returned.
+type CacheStore interface {
+	Get(key []byte) ([]byte, bool)
+	Set(key []byte, value []byte, ttl time.Duration)
+	// DeletePrefix removes the entries whose key starts with prefix and
+	// returns their number.
+	DeletePrefix(prefix string) int
+}
+
+// Cache stores the results of idempotent methods. Successful results are
+// kept for TTL under a key made of the method name, the principal, the
+// selected headers and the canonical params, so that params differing
+// only in key order or whitespace share an entry. Cached results are
+// spliced into responses with the id of each caller.
+//
+// Keys are made of the method, the principal and each header value, each
+// prefixed by its length, followed by the params, so that different
+// segments never produce the same key. CacheKeyPrefix builds the prefixes
+// InvalidatePrefix takes.
+type Cache struct {
+	TTL   time.Duration
+	Store CacheStore
+	// Headers lists the request headers that take part in the key.
+	Headers []string
+	// Principal returns the identity the result belongs to, such as the
+	// authenticated user. Results are shared by all callers when nil.
+	Principal func(c *RequestCtx) string
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// CacheStats is a snapshot of the Cache counters.
+type CacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+// NewCache returns a cache keeping up to maxEntries results for ttl in
+// an LRU store.
+func NewCache(ttl time.Duration, maxEntries int) *Cache {
+	return &Cache{TTL: ttl, Store: NewLRUStore(maxEntries)}
+}
+
+// Invalidate removes the results of method.
+func (p *Cache) Invalidate(method string) int { return p.Store.DeletePrefix(CacheKeyPrefix(method)) }
+
+// InvalidatePrefix removes the results whose key starts with prefix, such
+// as CacheKeyPrefix("user.get", "alice") for the results of one principal.
+func (p *Cache) InvalidatePrefix(prefix string) int { return p.Store.DeletePrefix(prefix) }
+
+// CacheKeyPrefix returns the start of the cache keys made of segments, in
+// key order: the method, then the principal, then the header values.
+func CacheKeyPrefix(segments ...string) string {
+	var b []byte
+	for _, s := range segments {
+		b = appendSegment(b, s)
+	}
+	return string(b)
+}
+
+// appendSegment appends s prefixed by its length.
+func appendSegment[T string | []byte](dst []byte, s T) []byte {
+	dst = strconv.AppendInt(dst, int64(len(s)), 10)
+	dst = append(dst, ':')
+	return append(dst, s...)
+}
+
+func (p *Cache) Stats() CacheStats {
+	return CacheStats{Hits: p.hits.Load(), Misses: p.misses.Load()}
+}
+
+func (p *Cache) appendKey(dst []byte, c *RequestCtx) []byte {
+	dst = appendSegment(dst, c.Method)
+	var principal string
+	if p.Principal != nil {
+		principal = p.Principal(c)
+	}
+	dst = appendSegment(dst, principal)
+	for _, h := range p.Headers {
+		var v []byte
+		if c.Ctx != nil {
+			v = c.Ctx.Request.Header.Peek(h)
+		}
+		dst = appendSegment(dst, v)
+	}
+	if c.Params != nil {
+		dst = appendCanonical(dst, c.Params)
+	}
+	return dst
+}
+
+// WithCache caches the results of the registered method, or of every
+// method of the registered service, in c.
+func WithCache(c *Cache) Option { return func(o *options) { o.cache = c } }
+
+func cacheHandler(cache *Cache, h Handler) Handler {
+	return func(c *RequestCtx) {
+		if len(c.id) == 0 {
+			// Notifications have no result to reuse.
+			h(c)
+			return
+		}
+
+		key := bytebufferpool.Get()
+		defer bytebufferpool.Put(key)
+		key.B = cache.appendKey(key.B, c)
+
+		if v, ok := cache.Store.Get(key.B); ok {
+			cache.hits.Add(1)
+			c.SetRawResult(append(c.ResultBuffer(), v...))
+			return
+		}
+		cache.misses.Add(1)
+
+		h(c)
+		if c.Error != nil {
+			return
+		}
+		b, err := c.appendResult(c.ResultBuffer())
+		if err != nil {
+			c.Error = err
+			return
+		}
+		c.SetRawResult(b)
+		cache.Store.Set(key.B, append([]byte(nil), b...), cache.TTL)
+	}
+}
+
+// appendResult appends the encoded result to dst.
+func (p *RequestCtx) appendResult(dst []byte) ([]byte, error) {
+	if p.rawSet {
+		return p.raw, nil
+	}
+	switch v := p.Result.(type) {
+	case *fastjson.Value:
+		return v.MarshalTo(dst), nil
+	case []byte:
+		return append(dst, v...), nil
+	case FastMarshaler:
+		return v.MarshalFastJSON(dst), nil
+	}
+	b, err := json.Marshal(p.Result)
+	return append(dst, b...), err
+}
+
+// LRUStore is an in-memory CacheStore that evicts the least recently used
+// entry beyond its capacity. Expired entries are dropped when read.
+type LRUStore struct {
+	mu    sync.Mutex
+	max   int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewLRUStore returns a store of up to maxEntries entries; 0 means no
+// limit.
+func NewLRUStore(maxEntries int) *LRUStore {
+	return &LRUStore{max: maxEntries, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (p *LRUStore) Get(key []byte) ([]byte, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e, ok := p.items[string(key)]
+	if !ok {
+		return nil, false
+	}
+	ent := e.Value.(*lruEntry)
+	if !ent.expires.IsZero() && time.Now().After(ent.expires) {
+		p.remove(e)
+		return nil, false
+	}
+	p.ll.MoveToFront(e)
+	return ent.value, true
+}
+
+func (p *LRUStore) Set(key []byte, value []byte, ttl time.Duration) {
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e, ok := p.items[string(key)]; ok {
+		ent := e.Value.(*lruEntry)
+		ent.value, ent.expires = value, expires
+		p.ll.MoveToFront(e)
+		return
+	}
+	k := string(key)
+	p.items[k] = p.ll.PushFront(&lruEntry{key: k, value: value, expires: expires})
+	if p.max > 0 && p.ll.Len() > p.max {
+		p.remove(p.ll.Back())
+	}
+}
+
+func (p *LRUStore) DeletePrefix(prefix string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var n int
+	for k, e := range p.items {
+		if strings.HasPrefix(k, prefix) {
+			p.remove(e)
+			n++
+		}
+	}
+	return n
+}
+
+// Len returns the number of entries, including expired ones not read
+// since they expired.
+func (p *LRUStore) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.ll.Len()
+}
+
+func (p *LRUStore) remove(e *list.Element) {
+	p.ll.Remove(e)
+	delete(p.items, e.Value.(*lruEntry).key)
+}
+
+var _ CacheStore = (*LRUStore)(nil)
@@ -0,0 +1,99 @@
+package fastjsonrpc_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/pretty"
+	"github.com/valyala/fasthttp"
+	. "github.com/zc310/fastjsonrpc"
+)
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+
+	var calls int
+	cache := NewCache(time.Minute, 0)
+	cache.Headers = []string{"X-Tenant"}
+	cache.Principal = func(c *RequestCtx) string { return string(c.Ctx.Request.Header.Peek("X-User")) }
+
+	s := new(ServerMap)
+	s.RegisterHandler("get", func(c *RequestCtx) {
+		calls++
+		if c.Params.GetInt("id") < 0 {
+			c.Error = NewError(1, "bad id")
+			return
+		}
+		c.Result = map[string]int{"id": c.Params.GetInt("id"), "calls": calls}
+	}, WithCache(cache))
+
+	call := func(request, user, tenant string) string {
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+		ctx.Request.Header.Set("X-User", user)
+		ctx.Request.Header.Set("X-Tenant", tenant)
+		ctx.Request.SetBodyString(request)
+		s.Handler(ctx)
+		return string(pretty.Ugly(ctx.Response.Body()))
+	}
+
+	assert.Equal(t, `{"jsonrpc":"2.0","result":{"calls":1,"id":1},"id":1}`,
+		call(`{"jsonrpc":"2.0","method":"get","params":{"id":1,"x":[1, 2]},"id":1}`, "alice", "a"))
+	// Key order and whitespace do not matter; the caller's id is used.
+	assert.Equal(t, `{"jsonrpc":"2.0","result":{"calls":1,"id":1},"id":"two"}`,
+		call(`{"jsonrpc":"2.0","method":"get","params":{"x":[1,2], "id":1},"id":"two"}`, "alice", "a"))
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1}, cache.Stats())
+
+	// Principals and selected headers have their own entries.
+	assert.Contains(t, call(`{"jsonrpc":"2.0","method":"get","params":{"id":1,"x":[1,2]},"id":3}`, "bob", "a"), `"calls":2`)
+	assert.Contains(t, call(`{"jsonrpc":"2.0","method":"get","params":{"id":1,"x":[1,2]},"id":4}`, "alice", "b"), `"calls":3`)
+
+	// Errors are not cached.
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, `{"jsonrpc":"2.0","error":{"code":1,"message":"bad id"},"id":5}`,
+			call(`{"jsonrpc":"2.0","method":"get","params":{"id":-1},"id":5}`, "alice", "a"))
+	}
+	assert.Equal(t, 5, calls)
+
+	// Large integers are not rounded into the same key.
+	assert.Contains(t, call(`{"jsonrpc":"2.0","method":"get","params":{"id":9007199254740993},"id":6}`, "", ""), `"calls":6`)
+	assert.Contains(t, call(`{"jsonrpc":"2.0","method":"get","params":{"id":9007199254740992},"id":7}`, "", ""), `"calls":7`)
+
+	// Batches share the cache.
+	assert.Equal(t, `[{"jsonrpc":"2.0","result":{"calls":6,"id":9007199254740993},"id":8}]`,
+		call(`[{"jsonrpc":"2.0","method":"get","params":{"id":9007199254740993},"id":8},{"jsonrpc":"2.0","method":"get","params":{"id":1}}]`, "", ""))
+	assert.Equal(t, 8, calls)
+
+	assert.Equal(t, 1, cache.InvalidatePrefix(CacheKeyPrefix("get", "bob")))
+	assert.Contains(t, call(`{"jsonrpc":"2.0","method":"get","params":{"id":1,"x":[1,2]},"id":9}`, "bob", "a"), `"calls":9`)
+	assert.Equal(t, 5, cache.Invalidate("get"))
+	assert.Contains(t, call(`{"jsonrpc":"2.0","method":"get","params":{"x":[1,2],"id":1},"id":10}`, "alice", "a"), `"calls":10`)
+
+	// Separators inside principals and header values do not merge keys.
+	assert.Contains(t, call(`{"jsonrpc":"2.0","method":"get","params":{"id":2},"id":11}`, "a|b", "c"), `"calls":11`)
+	assert.Contains(t, call(`{"jsonrpc":"2.0","method":"get","params":{"id":2},"id":12}`, "a", "b|c"), `"calls":12`)
+}
+
+func TestLRUStore(t *testing.T) {
+	t.Parallel()
+
+	s := NewLRUStore(2)
+	s.Set([]byte("a"), []byte("1"), 0)
+	s.Set([]byte("b"), []byte("2"), 0)
+	_, ok := s.Get([]byte("a"))
+	assert.True(t, ok)
+	s.Set([]byte("c"), []byte("3"), 0)
+	_, ok = s.Get([]byte("b"))
+	assert.False(t, ok, "least recently used entry is evicted")
+	assert.Equal(t, 2, s.Len())
+
+	s.Set([]byte("d"), []byte("4"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	_, ok = s.Get([]byte("d"))
+	assert.False(t, ok, "expired")
+
+	v, ok := s.Get([]byte("c"))
+	assert.True(t, ok)
+	assert.Equal(t, "3", string(v))
+}
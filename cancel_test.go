@@ -0,0 +1,106 @@
+package fastjsonrpc_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fastjson"
+	"github.com/zc310/fastjsonrpc/rpctest"
+	"github.com/zc310/fastjsonrpc/ws"
+)
+
+func TestCancelRequest(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name   string
+		opts   []ws.Option
+		method string
+	}{
+		{"default", nil, ws.DefaultCancelMethod},
+		{"custom", []ws.Option{ws.WithCancelMethod("cancel")}, "cancel"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			started := make(chan string, 2)
+			j := ws.NewJSONRPC2(tc.opts...)
+			j.RegisterMethodContext("slow", func(ctx context.Context, _ *fastjson.Arena, params *fastjson.Value) (interface{}, error) {
+				started <- string(params.GetStringBytes("0"))
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(time.Duration(params.GetInt("1")) * time.Millisecond):
+					return "finished", nil
+				}
+			})
+
+			h := rpctest.NewJSONRPC2(t, j)
+			send := func(m string) { h.Write(t, []byte(m)) }
+
+			send(`{"jsonrpc":"2.0","method":"slow","params":["a",10000],"id":"a"}`)
+			send(`{"jsonrpc":"2.0","method":"slow","params":["b",50],"id":2}`)
+			<-started
+			<-started
+			send(`{"jsonrpc":"2.0","method":"` + tc.method + `","params":{"id":"a"}}`)
+			// Unknown ids are ignored.
+			send(`{"jsonrpc":"2.0","method":"` + tc.method + `","params":{"id":3}}`)
+
+			var responses []string
+			for range 2 {
+				responses = append(responses, string(h.Read(t)))
+			}
+			assert.Equal(t, []string{
+				`{"jsonrpc":"2.0","id":"a","error":{"code":-32800,"message":"Request cancelled"}}`,
+				`{"jsonrpc":"2.0","id":2,"result":"finished"}`,
+			}, responses)
+		})
+	}
+}
+
+func TestCancelRequestDisabled(t *testing.T) {
+	t.Parallel()
+
+	j := ws.NewJSONRPC2(ws.WithCancelMethod(""))
+	started := make(chan struct{})
+	j.RegisterMethodContext("slow", func(ctx context.Context, _ *fastjson.Arena, _ *fastjson.Value) (interface{}, error) {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		return ctx.Err() == nil, nil
+	})
+
+	h := rpctest.NewJSONRPC2(t, j)
+	h.Write(t, []byte(`{"jsonrpc":"2.0","method":"slow","id":1}`))
+	<-started
+	h.Notify(t, "$/cancelRequest", map[string]int{"id": 1})
+	assert.Equal(t, `{"jsonrpc":"2.0","id":1,"result":true}`, string(h.Read(t)))
+}
+
+func TestCancelRequestSaturated(t *testing.T) {
+	t.Parallel()
+
+	// Both calls hold the session's slots, the second one queued behind the
+	// first; cancellations still get through.
+	j := ws.NewJSONRPC2(ws.WithSessionConcurrency(2), ws.WithExecution(ws.Sequential), ws.WithWriteQueue(2, ws.CloseOnFull))
+	started := make(chan struct{}, 2)
+	j.RegisterMethodContext("wait", func(ctx context.Context, _ *fastjson.Arena, _ *fastjson.Value) (interface{}, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	h := rpctest.NewJSONRPC2(t, j)
+	h.Write(t, []byte(`{"jsonrpc":"2.0","method":"wait","id":1}`))
+	h.Write(t, []byte(`{"jsonrpc":"2.0","method":"wait","id":2}`))
+	<-started
+	for id := 1; id <= 2; id++ {
+		h.Notify(t, ws.DefaultCancelMethod, map[string]int{"id": id})
+		assert.Equal(t, `{"jsonrpc":"2.0","id":`+strconv.Itoa(id)+`,"error":{"code":-32800,"message":"Request cancelled"}}`, string(h.Read(t)))
+		if id == 1 {
+			<-started
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fastjson"
+)
+
+// ErrNoEndpoints is returned by a Balancer without endpoints.
+var ErrNoEndpoints = errors.New("jsonrpc: no endpoints")
+
+// Strategy selects the endpoint of a Balancer for each request.
+type Strategy int
+
+const (
+	// RoundRobin cycles through the endpoints.
+	RoundRobin Strategy = iota
+	// LeastInFlight picks the endpoint with the fewest pending requests.
+	LeastInFlight
+	// ConsistentHash picks the endpoint by a param of the request, see
+	// WithHashParam, so that equal keys reach the same endpoint while the
+	// set is stable.
+	ConsistentHash
+)
+
+// Balancer spreads requests over the endpoints reported by a Resolver.
+// A batch is always sent to a single endpoint. Endpoints failing with
+// consecutive transport errors are ejected for a while; when every
+// endpoint is ejected all of them are used again.
+type Balancer struct {
+	strategy  Strategy
+	hashParam []string
+	dial      func(endpoint string) (Transport, error)
+	failures  int
+	ejectFor  time.Duration
+
+	mu        sync.RWMutex
+	endpoints []*endpoint
+	ring      []ringNode
+	err       error
+	ready     chan struct{}
+	readyOnce sync.Once
+
+	next   atomic.Uint64
+	cancel context.CancelFunc
+	closed bool
+}
+
+type endpoint struct {
+	url      string
+	t        Transport
+	inFlight atomic.Int64
+
+	mu       sync.Mutex
+	failures int
+	ejected  time.Time
+}
+
+type ringNode struct {
+	hash uint64
+	e    *endpoint
+}
+
+// virtualNodes is the number of ring points per endpoint.
+const virtualNodes = 64
+
+// BalancerOption configures a Balancer.
+type BalancerOption func(*Balancer)
+
+func WithStrategy(s Strategy) BalancerOption { return func(p *Balancer) { p.strategy = s } }
+
+// WithHashParam sets the param hashed by ConsistentHash, as a path such
+// as "user", "id" or "0" for positional params. The params of the first
+// call of a batch are used; requests without the param are sent round
+// robin.
+func WithHashParam(path ...string) BalancerOption {
+	return func(p *Balancer) { p.hashParam = path }
+}
+
+// WithEjection ejects an endpoint for d after failures consecutive
+// transport errors. The default is 5 errors for 30 seconds; zero
+// failures disables ejection.
+func WithEjection(failures int, d time.Duration) BalancerOption {
+	return func(p *Balancer) { p.failures, p.ejectFor = failures, d }
+}
+
+// WithEndpointTransport sets the transport of each endpoint. The default
+// is an HTTPTransport posting to the endpoint.
+func WithEndpointTransport(dial func(endpoint string) (Transport, error)) BalancerOption {
+	return func(p *Balancer) { p.dial = dial }
+}
+
+// NewBalancer returns a balancer over the endpoints of r, which it
+// resolves until Close.
+func NewBalancer(r Resolver, opts ...BalancerOption) *Balancer {
+	p := &Balancer{
+		dial:     func(url string) (Transport, error) { return &HTTPTransport{URL: url}, nil },
+		failures: 5,
+		ejectFor: 30 * time.Second,
+		ready:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	var ctx context.Context
+	ctx, p.cancel = context.WithCancel(context.Background())
+	go func() {
+		err := r.Resolve(ctx, p.update)
+		if err != nil && ctx.Err() == nil {
+			p.mu.Lock()
+			p.err = err
+			p.mu.Unlock()
+		}
+		p.readyOnce.Do(func() { close(p.ready) })
+	}()
+	return p
+}
+
+// Endpoints returns the current endpoints.
+func (p *Balancer) Endpoints() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	s := make([]string, len(p.endpoints))
+	for i, e := range p.endpoints {
+		s[i] = e.url
+	}
+	return s
+}
+
+// update replaces the endpoints, keeping the transports and health of
+// those still listed.
+func (p *Balancer) update(urls []string) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	old := p.endpoints
+	var endpoints []*endpoint
+	for _, url := range urls {
+		if slices.ContainsFunc(endpoints, func(e *endpoint) bool { return e.url == url }) {
+			continue
+		}
+		i := slices.IndexFunc(old, func(e *endpoint) bool { return e.url == url })
+		if i >= 0 {
+			endpoints = append(endpoints, old[i])
+			old = slices.Delete(old, i, i+1)
+			continue
+		}
+		t, err := p.dial(url)
+		if err != nil {
+			continue
+		}
+		endpoints = append(endpoints, &endpoint{url: url, t: t})
+	}
+	p.endpoints = endpoints
+
+	p.ring = p.ring[:0]
+	for _, e := range endpoints {
+		for i := 0; i < virtualNodes; i++ {
+			p.ring = append(p.ring, ringNode{hash: hash(e.url + "#" + strconv.Itoa(i)), e: e})
+		}
+	}
+	sort.Slice(p.ring, func(i, j int) bool { return p.ring[i].hash < p.ring[j].hash })
+	p.mu.Unlock()
+
+	for _, e := range old {
+		_ = e.t.Close()
+	}
+	p.readyOnce.Do(func() { close(p.ready) })
+}
+
+func (p *Balancer) RoundTrip(ctx context.Context, request []byte) ([]byte, error) {
+	select {
+	case <-p.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	e, err := p.pick(request)
+	if err != nil {
+		return nil, err
+	}
+	e.inFlight.Add(1)
+	b, err := e.t.RoundTrip(ctx, request)
+	e.inFlight.Add(-1)
+	if ctx.Err() == nil {
+		p.record(e, err == nil)
+	}
+	return b, err
+}
+
+func (p *Balancer) pick(request []byte) (*endpoint, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return nil, ErrClosed
+	}
+	if len(p.endpoints) == 0 {
+		if p.err != nil {
+			return nil, p.err
+		}
+		return nil, ErrNoEndpoints
+	}
+
+	now := time.Now()
+	healthy := func(e *endpoint) bool {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		return now.After(e.ejected)
+	}
+	if !slices.ContainsFunc(p.endpoints, healthy) {
+		healthy = func(*endpoint) bool { return true }
+	}
+
+	if p.strategy == ConsistentHash {
+		if key := p.hashKey(request); key != nil {
+			h := hash(string(key))
+			i := sort.Search(len(p.ring), func(i int) bool { return p.ring[i].hash >= h })
+			for n := range p.ring {
+				if e := p.ring[(i+n)%len(p.ring)].e; healthy(e) {
+					return e, nil
+				}
+			}
+		}
+	}
+
+	start := int(p.next.Add(1) % uint64(len(p.endpoints)))
+	var best *endpoint
+	for n := range p.endpoints {
+		e := p.endpoints[(start+n)%len(p.endpoints)]
+		if !healthy(e) {
+			continue
+		}
+		if p.strategy != LeastInFlight {
+			return e, nil
+		}
+		if best == nil || e.inFlight.Load() < best.inFlight.Load() {
+			best = e
+		}
+	}
+	return best, nil
+}
+
+// hashKey returns the hashed param of request, nil if missing.
+func (p *Balancer) hashKey(request []byte) []byte {
+	if len(p.hashParam) == 0 {
+		return nil
+	}
+	var pr fastjson.Parser
+	v, err := pr.ParseBytes(request)
+	if err != nil {
+		return nil
+	}
+	if v.Type() == fastjson.TypeArray {
+		if v = v.Get("0"); v == nil {
+			return nil
+		}
+	}
+	key := v.Get(append([]string{"params"}, p.hashParam...)...)
+	if key == nil {
+		return nil
+	}
+	if key.Type() == fastjson.TypeString {
+		return key.GetStringBytes()
+	}
+	return key.MarshalTo(nil)
+}
+
+// record updates the health of e after a request.
+func (p *Balancer) record(e *endpoint, ok bool) {
+	if p.failures <= 0 {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if ok {
+		e.failures = 0
+		return
+	}
+	if e.failures++; e.failures >= p.failures {
+		e.failures = 0
+		e.ejected = time.Now().Add(p.ejectFor)
+	}
+}
+
+// Close stops resolving and closes the transports of all endpoints.
+func (p *Balancer) Close() error {
+	p.cancel()
+	p.mu.Lock()
+	p.closed = true
+	endpoints := p.endpoints
+	p.endpoints, p.ring = nil, nil
+	p.mu.Unlock()
+	for _, e := range endpoints {
+		_ = e.t.Close()
+	}
+	return nil
+}
+
+func hash(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	return h.Sum64()
+}
@@ -0,0 +1,175 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	. "github.com/zc310/fastjsonrpc/client"
+)
+
+// replicas serves every endpoint with fn and counts the requests each
+// of them received.
+type replicas struct {
+	mu    sync.Mutex
+	count map[string]int
+	fn    func(ctx context.Context, endpoint string, request []byte) ([]byte, error)
+}
+
+func (p *replicas) transport(endpoint string) (Transport, error) {
+	return transportFunc(func(ctx context.Context, request []byte) ([]byte, error) {
+		p.mu.Lock()
+		p.count[endpoint]++
+		p.mu.Unlock()
+		if p.fn != nil {
+			return p.fn(ctx, endpoint, request)
+		}
+		return reply(request, `"result":"`+endpoint+`"`), nil
+	}), nil
+}
+
+func (p *replicas) counts() map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m := make(map[string]int, len(p.count))
+	for k, v := range p.count {
+		m[k] = v
+	}
+	return m
+}
+
+func newBalancer(t *testing.T, r Resolver, opts ...BalancerOption) (*Client, *replicas) {
+	rs := &replicas{count: make(map[string]int)}
+	b := NewBalancer(r, append([]BalancerOption{WithEndpointTransport(rs.transport)}, opts...)...)
+	c := New(b)
+	t.Cleanup(func() { _ = c.Close() })
+	return c, rs
+}
+
+func TestBalancerRoundRobin(t *testing.T) {
+	t.Parallel()
+
+	c, rs := newBalancer(t, StaticResolver{"a", "b", "c"})
+	for i := 0; i < 9; i++ {
+		require.NoError(t, c.Call(context.Background(), "m", nil, nil))
+	}
+	assert.Equal(t, map[string]int{"a": 3, "b": 3, "c": 3}, rs.counts())
+
+	// A batch goes to one endpoint.
+	require.NoError(t, c.Batch(context.Background(), []*Call{{Method: "m"}, {Method: "m"}, {Method: "m"}}))
+	assert.Len(t, rs.counts(), 3)
+	var total int
+	for _, n := range rs.counts() {
+		total += n
+	}
+	assert.Equal(t, 10, total)
+}
+
+func TestBalancerLeastInFlight(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	c, rs := newBalancer(t, StaticResolver{"a", "b"}, WithStrategy(LeastInFlight))
+	rs.fn = func(ctx context.Context, endpoint string, request []byte) ([]byte, error) {
+		if endpoint == "a" {
+			<-release
+		}
+		return reply(request, `"result":"`+endpoint+`"`), nil
+	}
+
+	// Keep one request pending on a; then everything goes to b.
+	done := make(chan struct{})
+	var first string
+	go func() {
+		defer close(done)
+		for first != "a" {
+			_ = c.Call(context.Background(), "m", nil, &first)
+		}
+	}()
+	require.Eventually(t, func() bool { return rs.counts()["a"] == 1 }, time.Second, time.Millisecond)
+	for i := 0; i < 5; i++ {
+		var s string
+		require.NoError(t, c.Call(context.Background(), "m", nil, &s))
+		assert.Equal(t, "b", s)
+	}
+	close(release)
+	<-done
+}
+
+func TestBalancerConsistentHash(t *testing.T) {
+	t.Parallel()
+
+	c, rs := newBalancer(t, StaticResolver{"a", "b", "c", "d"}, WithStrategy(ConsistentHash), WithHashParam("user"))
+	owners := make(map[int]string)
+	for round := 0; round < 3; round++ {
+		for user := 0; user < 20; user++ {
+			var s string
+			require.NoError(t, c.Call(context.Background(), "m", map[string]int{"user": user}, &s))
+			if round == 0 {
+				owners[user] = s
+			}
+			assert.Equal(t, owners[user], s)
+		}
+	}
+	assert.Greater(t, len(rs.counts()), 1)
+}
+
+func TestBalancerEjection(t *testing.T) {
+	t.Parallel()
+
+	c, rs := newBalancer(t, StaticResolver{"a", "b"}, WithEjection(2, time.Minute))
+	rs.fn = func(ctx context.Context, endpoint string, request []byte) ([]byte, error) {
+		if endpoint == "a" {
+			return nil, errors.New("connection refused")
+		}
+		return reply(request, `"result":"`+endpoint+`"`), nil
+	}
+	for i := 0; i < 10; i++ {
+		_ = c.Call(context.Background(), "m", nil, nil)
+	}
+	assert.Equal(t, map[string]int{"a": 2, "b": 8}, rs.counts())
+}
+
+func TestFileResolver(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "endpoints")
+	require.NoError(t, os.WriteFile(path, []byte("# replicas\na\n\nb\n"), 0o644))
+	c, rs := newBalancer(t, &FileResolver{Path: path, Interval: 5 * time.Millisecond})
+	b := c.Transport().(*Balancer)
+
+	require.NoError(t, c.Call(context.Background(), "m", nil, nil))
+	assert.Equal(t, []string{"a", "b"}, b.Endpoints())
+
+	require.NoError(t, os.WriteFile(path, []byte("b\nc\nd\n"), 0o644))
+	require.Eventually(t, func() bool { return len(b.Endpoints()) == 3 }, time.Second, time.Millisecond)
+	for i := 0; i < 6; i++ {
+		require.NoError(t, c.Call(context.Background(), "m", nil, nil))
+	}
+	counts := rs.counts()
+	for _, e := range []string{"b", "c", "d"} {
+		assert.Positive(t, counts[e], e+" unused: "+strconv.Itoa(counts[e]))
+	}
+
+	require.NoError(t, c.Close())
+	assert.ErrorIs(t, c.Call(context.Background(), "m", nil, nil), ErrClosed)
+}
+
+func TestFileResolverMissing(t *testing.T) {
+	t.Parallel()
+
+	// A missing file fails calls at once rather than leaving them waiting.
+	path := filepath.Join(t.TempDir(), "endpoints")
+	c, _ := newBalancer(t, &FileResolver{Path: path, Interval: 5 * time.Millisecond})
+	assert.ErrorIs(t, c.Call(context.Background(), "m", nil, nil), ErrNoEndpoints)
+
+	require.NoError(t, os.WriteFile(path, []byte("a\n"), 0o644))
+	require.Eventually(t, func() bool { return c.Call(context.Background(), "m", nil, nil) == nil }, time.Second, time.Millisecond)
+}
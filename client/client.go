@@ -0,0 +1,178 @@
+// Package client implements a JSON-RPC 2.0 client over pluggable
+// transports. HTTP, WebSocket and stream (TCP, Unix socket) transports
+// are provided.
+package client
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/goccy/go-json"
+	"github.com/zc310/fastjsonrpc"
+)
+
+// ErrNoResponse is returned when the server sent no response for a call.
+var ErrNoResponse = errors.New("jsonrpc: no response")
+
+// Transport sends an encoded request, single or batch, and returns the
+// encoded response. A request made only of notifications yields a nil
+// response.
+type Transport interface {
+	RoundTrip(ctx context.Context, request []byte) ([]byte, error)
+	Close() error
+}
+
+// Client issues calls over a Transport. It is safe for concurrent use.
+// Errors returned by the server are reported as *fastjsonrpc.Error.
+type Client struct {
+	t  Transport
+	id atomic.Uint64
+}
+
+func New(t Transport) *Client { return &Client{t: t} }
+
+// NewHTTP returns a client posting to url.
+func NewHTTP(url string) *Client { return New(&HTTPTransport{URL: url}) }
+
+func (p *Client) Transport() Transport { return p.t }
+
+func (p *Client) Close() error { return p.t.Close() }
+
+// Call invokes method with params and decodes the result into result,
+// which may be nil to discard it.
+func (p *Client) Call(ctx context.Context, method string, params, result any) error {
+	c := &Call{Method: method, Params: params, Result: result}
+	if err := p.Batch(ctx, []*Call{c}); err != nil {
+		return err
+	}
+	return c.Error
+}
+
+// Notify sends a notification.
+func (p *Client) Notify(ctx context.Context, method string, params any) error {
+	return p.Batch(ctx, []*Call{{Method: method, Params: params, Notify: true}})
+}
+
+// Call is a single call of a batch.
+type Call struct {
+	Method string
+	Params any
+	Result any  // decoded result destination, may be nil
+	Notify bool // send as a notification, no response expected
+	Error  error
+
+	id uint64
+}
+
+type request struct {
+	JSONRPC string  `json:"jsonrpc"`
+	Method  string  `json:"method"`
+	Params  any     `json:"params,omitempty"`
+	ID      *uint64 `json:"id,omitempty"`
+}
+
+type response struct {
+	ID     json.RawMessage    `json:"id"`
+	Result json.RawMessage    `json:"result"`
+	Error  *fastjsonrpc.Error `json:"error"`
+}
+
+// Batch sends calls as a batch request, or as a single request if there
+// is only one, and stores the outcome of each call in its Error and
+// Result fields. The returned error reports transport and protocol
+// failures only.
+func (p *Client) Batch(ctx context.Context, calls []*Call) error {
+	if len(calls) == 0 {
+		return nil
+	}
+	b, err := p.encode(calls)
+	if err != nil {
+		return err
+	}
+	resp, err := p.t.RoundTrip(ctx, b)
+	if err != nil {
+		return err
+	}
+	return decode(calls, resp)
+}
+
+func (p *Client) encode(calls []*Call) ([]byte, error) {
+	reqs := make([]request, len(calls))
+	for i, c := range calls {
+		reqs[i] = request{JSONRPC: "2.0", Method: c.Method, Params: c.Params}
+		if !c.Notify {
+			c.id = p.id.Add(1)
+			reqs[i].ID = &c.id
+		}
+	}
+	if len(reqs) == 1 {
+		return json.Marshal(reqs[0])
+	}
+	return json.Marshal(reqs)
+}
+
+func decode(calls []*Call, b []byte) error {
+	var pending int
+	for _, c := range calls {
+		if !c.Notify {
+			pending++
+		}
+	}
+	if pending == 0 {
+		return nil
+	}
+	if len(b) == 0 {
+		return ErrNoResponse
+	}
+
+	var resps []response
+	if b[0] == '[' {
+		if err := json.Unmarshal(b, &resps); err != nil {
+			return err
+		}
+	} else {
+		var r response
+		if err := json.Unmarshal(b, &r); err != nil {
+			return err
+		}
+		resps = []response{r}
+	}
+
+	byID := make(map[string]*response, len(resps))
+	var orphan *response
+	for i := range resps {
+		r := &resps[i]
+		if len(r.ID) == 0 || string(r.ID) == "null" {
+			orphan = r
+			continue
+		}
+		byID[string(r.ID)] = r
+	}
+
+	for _, c := range calls {
+		if c.Notify {
+			continue
+		}
+		r := byID[strconv.FormatUint(c.id, 10)]
+		if r == nil {
+			// A request rejected before its id was read, for example a
+			// parse error, is answered with a null id.
+			if orphan != nil && orphan.Error != nil {
+				c.Error = orphan.Error
+			} else {
+				c.Error = ErrNoResponse
+			}
+			continue
+		}
+		if r.Error != nil {
+			c.Error = r.Error
+			continue
+		}
+		if c.Result != nil && len(r.Result) > 0 {
+			c.Error = json.Unmarshal(r.Result, c.Result)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package client_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+	"github.com/valyala/fastjson"
+	"github.com/zc310/fastjsonrpc"
+	. "github.com/zc310/fastjsonrpc/client"
+	"github.com/zc310/fastjsonrpc/ws"
+)
+
+func newClient(t *testing.T, s *fastjsonrpc.ServerMap) *Client {
+	ln := fasthttputil.NewInmemoryListener()
+	go func() { _ = fasthttp.Serve(ln, s.Handler) }()
+	t.Cleanup(func() { _ = ln.Close() })
+	return New(&HTTPTransport{
+		URL:    "http://test/",
+		Client: &fasthttp.Client{Dial: func(string) (net.Conn, error) { return ln.Dial() }},
+	})
+}
+
+func TestBatch(t *testing.T) {
+	var s fastjsonrpc.ServerMap
+	s.RegisterHandler("sum", func(c *fastjsonrpc.RequestCtx) {
+		c.Result = c.Params.GetInt("a") + c.Params.GetInt("b")
+	})
+	c := newClient(t, &s)
+
+	var sum int
+	calls := []*Call{
+		{Method: "sum", Params: map[string]int{"a": 1, "b": 2}, Result: &sum},
+		{Method: "sum", Params: map[string]int{"a": 1}, Notify: true},
+		{Method: "missing"},
+	}
+	require.NoError(t, c.Batch(context.Background(), calls))
+	assert.NoError(t, calls[0].Error)
+	assert.Equal(t, 3, sum)
+	assert.NoError(t, calls[1].Error)
+
+	var e *fastjsonrpc.Error
+	require.True(t, errors.As(calls[2].Error, &e))
+	assert.Equal(t, -32601, e.Code)
+
+	require.NoError(t, c.Call(context.Background(), "sum", map[string]int{"a": 2, "b": 2}, &sum))
+	assert.Equal(t, 4, sum)
+}
+
+func TestContextCancel(t *testing.T) {
+	var s fastjsonrpc.ServerMap
+	release := make(chan struct{})
+	s.RegisterHandler("block", func(c *fastjsonrpc.RequestCtx) { <-release })
+	c := newClient(t, &s)
+	t.Cleanup(func() { close(release) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, c.Call(ctx, "block", nil, nil), context.Canceled)
+}
+
+func TestStream(t *testing.T) {
+	j := ws.NewJSONRPC2()
+	j.RegisterMethodFunc("double", func(params *fastjson.Value) (interface{}, error) {
+		return params.GetInt("a") * 2, nil
+	})
+
+	server, conn := net.Pipe()
+	t.Cleanup(func() { _ = server.Close() })
+	go func() {
+		r := bufio.NewScanner(server)
+		for r.Scan() {
+			resp, _ := j.HandleMessage(r.Bytes())
+			// Concatenated messages without newlines are accepted.
+			_, _ = server.Write([]byte(`{"jsonrpc":"2.0","method":"tick","params":[1]}`))
+			_, _ = server.Write(resp)
+		}
+	}()
+
+	ticks := make(chan json.RawMessage, 10)
+	tr := NewStream(conn, func(method string, params json.RawMessage) { ticks <- params })
+	c := New(tr)
+	defer c.Close()
+
+	var n int
+	require.NoError(t, c.Call(context.Background(), "double", map[string]int{"a": 21}, &n))
+	assert.Equal(t, 42, n)
+	assert.JSONEq(t, `[1]`, string(<-ticks))
+
+	var e *fastjsonrpc.Error
+	require.ErrorAs(t, c.Call(context.Background(), "missing", nil, nil), &e)
+	assert.Equal(t, -32601, e.Code)
+
+	_ = server.Close()
+	<-tr.Done()
+	assert.Error(t, c.Call(context.Background(), "double", nil, nil))
+}
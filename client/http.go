@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// HTTPTransport posts requests to URL.
+type HTTPTransport struct {
+	URL string
+	// Client performs the requests. A default client is used when nil.
+	Client *fasthttp.Client
+	// Header is added to every request.
+	Header map[string]string
+	// Timeout bounds each round trip when ctx has no deadline.
+	Timeout time.Duration
+}
+
+var defaultHTTPClient = &fasthttp.Client{}
+
+func (p *HTTPTransport) RoundTrip(ctx context.Context, request []byte) ([]byte, error) {
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	abandoned := false
+	defer func() {
+		// Abandoned requests and responses are still in use by the
+		// goroutine and left to the garbage collector.
+		if !abandoned {
+			fasthttp.ReleaseRequest(req)
+			fasthttp.ReleaseResponse(resp)
+		}
+	}()
+
+	req.SetRequestURI(p.URL)
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentType("application/json")
+	for k, v := range p.Header {
+		req.Header.Set(k, v)
+	}
+	req.SetBodyRaw(request)
+
+	c := p.Client
+	if c == nil {
+		c = defaultHTTPClient
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok && p.Timeout > 0 {
+		deadline, ok = time.Now().Add(p.Timeout), true
+	}
+
+	errc := make(chan error, 1)
+	go func(req *fasthttp.Request, resp *fasthttp.Response) {
+		if ok {
+			errc <- c.DoDeadline(req, resp, deadline)
+		} else {
+			errc <- c.Do(req, resp)
+		}
+	}(req, resp)
+
+	select {
+	case err := <-errc:
+		if err != nil {
+			return nil, err
+		}
+	case <-ctx.Done():
+		abandoned = true
+		return nil, ctx.Err()
+	}
+
+	switch resp.StatusCode() {
+	case fasthttp.StatusOK:
+		return append([]byte(nil), resp.Body()...), nil
+	case fasthttp.StatusNoContent:
+		return nil, nil
+	}
+	if len(resp.Body()) > 0 && resp.Body()[0] == '{' {
+		// Some servers answer protocol errors with a non-200 status.
+		return append([]byte(nil), resp.Body()...), nil
+	}
+	return nil, errors.New("jsonrpc: unexpected HTTP status " + strconv.Itoa(resp.StatusCode()))
+}
+
+func (p *HTTPTransport) Close() error { return nil }
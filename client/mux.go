@@ -0,0 +1,181 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/goccy/go-json"
+	"github.com/valyala/fastjson"
+)
+
+// ErrClosed is returned by calls on a closed connection.
+var ErrClosed = errors.New("jsonrpc: connection closed")
+
+// NotifyHandler receives notifications pushed by the server over a
+// persistent connection.
+type NotifyHandler func(method string, params json.RawMessage)
+
+// mux multiplexes requests over a persistent, message-oriented connection
+// and routes responses back by id.
+type mux struct {
+	write  func([]byte) error
+	notify NotifyHandler
+
+	mu      sync.Mutex // serializes writes and guards the fields below
+	pending map[string]*waiter
+	err     error
+	done    chan struct{}
+}
+
+type waiter struct {
+	ids []string
+	ch  chan []byte
+}
+
+func newMux(write func([]byte) error, notify NotifyHandler) *mux {
+	return &mux{write: write, notify: notify, pending: make(map[string]*waiter), done: make(chan struct{})}
+}
+
+func (p *mux) roundTrip(ctx context.Context, request []byte) ([]byte, error) {
+	ids := requestIDs(request)
+	var w *waiter
+	if len(ids) > 0 {
+		w = &waiter{ids: ids, ch: make(chan []byte, 1)}
+	}
+
+	p.mu.Lock()
+	if p.err != nil {
+		p.mu.Unlock()
+		return nil, p.err
+	}
+	for _, id := range ids {
+		p.pending[id] = w
+	}
+	err := p.write(request)
+	if err != nil {
+		p.forget(w)
+	}
+	p.mu.Unlock()
+	if err != nil || w == nil {
+		return nil, err
+	}
+
+	select {
+	case b := <-w.ch:
+		return b, nil
+	case <-p.done:
+		return nil, p.err
+	case <-ctx.Done():
+		p.mu.Lock()
+		p.forget(w)
+		p.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// forget removes w. It must be called with mu held.
+func (p *mux) forget(w *waiter) {
+	if w == nil {
+		return
+	}
+	for _, id := range w.ids {
+		if p.pending[id] == w {
+			delete(p.pending, id)
+		}
+	}
+}
+
+// dispatch routes an incoming message to its waiter or to the
+// notification handler.
+func (p *mux) dispatch(b []byte) {
+	var pr fastjson.Parser
+	v, err := pr.ParseBytes(b)
+	if err != nil {
+		return
+	}
+	first := v
+	if v.Type() == fastjson.TypeArray {
+		a := v.GetArray()
+		if len(a) == 0 {
+			return
+		}
+		first = a[0]
+		// Any element with a known id identifies the waiter.
+		for _, item := range a {
+			if id := item.Get("id"); id != nil && id.Type() != fastjson.TypeNull {
+				first = item
+				break
+			}
+		}
+	}
+
+	if m := first.GetStringBytes("method"); m != nil {
+		if p.notify != nil && v.Type() == fastjson.TypeObject {
+			var params json.RawMessage
+			if pv := first.Get("params"); pv != nil {
+				params = pv.MarshalTo(nil)
+			}
+			p.notify(string(m), params)
+		}
+		return
+	}
+
+	id := first.Get("id")
+	p.mu.Lock()
+	var w *waiter
+	if id != nil && id.Type() != fastjson.TypeNull {
+		w = p.pending[string(id.MarshalTo(nil))]
+	} else if len(p.pending) > 0 {
+		// A null id answers a request that could not be parsed. Deliver it
+		// to the only waiter if there is exactly one.
+		for _, x := range p.pending {
+			if w != nil && w != x {
+				w = nil
+				break
+			}
+			w = x
+		}
+	}
+	p.forget(w)
+	p.mu.Unlock()
+
+	if w != nil {
+		w.ch <- append([]byte(nil), b...)
+	}
+}
+
+// fail terminates every pending call with err.
+func (p *mux) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return
+	}
+	if err == nil {
+		err = ErrClosed
+	}
+	p.err = err
+	p.pending = nil
+	close(p.done)
+}
+
+// requestIDs returns the encoded ids of the calls in request.
+func requestIDs(request []byte) []string {
+	var pr fastjson.Parser
+	v, err := pr.ParseBytes(request)
+	if err != nil {
+		return nil
+	}
+	items := []*fastjson.Value{v}
+	if v.Type() == fastjson.TypeArray {
+		items = v.GetArray()
+	}
+	var ids []string
+	for _, item := range items {
+		if id := item.Get("id"); id != nil && id.Type() != fastjson.TypeNull {
+			ids = append(ids, string(id.MarshalTo(nil)))
+		}
+	}
+	return ids
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// RedialTransport dials a connection, such as a WebSocketTransport, on
+// first use and again after it closed, so that a ResilientTransport can
+// retry over a fresh connection.
+type RedialTransport struct {
+	Dial func(ctx context.Context) (Transport, error)
+
+	mu     sync.Mutex
+	t      Transport
+	closed bool
+}
+
+func (p *RedialTransport) conn(ctx context.Context) (Transport, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, ErrClosed
+	}
+	if p.t != nil && !isDone(p.t) {
+		return p.t, nil
+	}
+	if p.t != nil {
+		_ = p.t.Close()
+		p.t = nil
+	}
+	t, err := p.Dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.t = t
+	return t, nil
+}
+
+func (p *RedialTransport) RoundTrip(ctx context.Context, request []byte) ([]byte, error) {
+	t, err := p.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b, err := t.RoundTrip(ctx, request)
+	if errors.Is(err, ErrClosed) {
+		// Drop the connection even if it has no Done channel.
+		p.mu.Lock()
+		if p.t == t {
+			p.t = nil
+		}
+		p.mu.Unlock()
+		_ = t.Close()
+	}
+	return b, err
+}
+
+func (p *RedialTransport) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	if p.t == nil {
+		return nil
+	}
+	err := p.t.Close()
+	p.t = nil
+	return err
+}
+
+func isDone(t Transport) bool {
+	d, ok := t.(interface{ Done() <-chan struct{} })
+	if !ok {
+		return false
+	}
+	select {
+	case <-d.Done():
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,399 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/valyala/fastjson"
+	"github.com/zc310/fastjsonrpc"
+)
+
+// ResilientTransport wraps a Transport, such as an HTTPTransport or a
+// WebSocketTransport, with retries, hedging and a circuit breaker.
+//
+// Retries and hedges only apply to requests without notifications whose
+// methods are all idempotent according to Retry. A hedge sends the same
+// request again when no response arrived within HedgeDelay, and the
+// first response wins; over a multiplexed connection the hedge reuses
+// the ids of the request.
+type ResilientTransport struct {
+	Transport Transport
+	// Name identifies the endpoint in errors and hooks.
+	Name  string
+	Retry *RetryPolicy
+	// HedgeDelay enables hedging when positive. MaxHedges bounds the
+	// extra requests of an attempt and defaults to 1.
+	HedgeDelay time.Duration
+	MaxHedges  int
+	// Breaker, if set, fails requests fast while the endpoint is failing.
+	Breaker *CircuitBreaker
+	Hooks   Hooks
+}
+
+// RetryPolicy decides which requests are retried and when.
+type RetryPolicy struct {
+	// MaxAttempts counts the first attempt. Zero means 3.
+	MaxAttempts int
+	// Idempotent reports whether method may run more than once. Requests
+	// with any other method are neither retried nor hedged.
+	Idempotent func(method string) bool
+	// Codes lists the error codes that are retried, besides transport
+	// errors, such as -32001 for a busy server.
+	Codes []int
+	// The delay before attempt n+1 is random between zero and
+	// BaseDelay*2^(n-1), capped at MaxDelay. Zero values mean 50ms and 5s.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// IdempotentMethods returns an Idempotent function accepting methods.
+func IdempotentMethods(methods ...string) func(string) bool {
+	return func(m string) bool { return slices.Contains(methods, m) }
+}
+
+// Hooks receive events for metrics. Any of them may be nil.
+type Hooks struct {
+	// Attempt is called after every attempt with its latency and error.
+	Attempt func(name string, d time.Duration, err error)
+	// Retry is called before waiting delay for the next attempt. err is
+	// the transport error or the *fastjsonrpc.Error that was returned.
+	Retry func(name string, attempt int, err error, delay time.Duration)
+	// Hedge is called when a hedged request is sent.
+	Hedge func(name string)
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts <= 0 {
+		return 3
+	}
+	return p.MaxAttempts
+}
+
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	base, limit := p.BaseDelay, p.MaxDelay
+	if base <= 0 {
+		base = 50 * time.Millisecond
+	}
+	if limit <= 0 {
+		limit = 5 * time.Second
+	}
+	d := limit
+	if attempt < 32 && base<<(attempt-1) < limit {
+		d = base << (attempt - 1)
+	}
+	return rand.N(d + 1)
+}
+
+func (p *ResilientTransport) RoundTrip(ctx context.Context, request []byte) ([]byte, error) {
+	retryable := p.retryable(request)
+	attempts := 1
+	if retryable && p.Retry != nil {
+		attempts = p.Retry.maxAttempts()
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := p.attempt(ctx, request, retryable)
+		var codes []int
+		if p.Retry != nil {
+			codes = p.Retry.Codes
+		}
+		if err == nil {
+			err = responseError(resp, codes)
+			if err == nil {
+				return resp, nil
+			}
+		}
+
+		var open *CircuitOpenError
+		if attempt >= attempts || ctx.Err() != nil || errors.As(err, &open) {
+			if resp != nil {
+				return resp, nil
+			}
+			return nil, err
+		}
+		d := p.Retry.delay(attempt)
+		if p.Hooks.Retry != nil {
+			p.Hooks.Retry(p.Name, attempt, err, d)
+		}
+		t := time.NewTimer(d)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			if resp != nil {
+				return resp, nil
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// attempt sends request once, with hedges if enabled, through the
+// breaker.
+func (p *ResilientTransport) attempt(ctx context.Context, request []byte, hedge bool) ([]byte, error) {
+	if p.Breaker != nil {
+		if err := p.Breaker.allow(p.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	start := time.Now()
+	var resp []byte
+	var err error
+	if hedge && p.HedgeDelay > 0 {
+		resp, err = p.hedged(ctx, request)
+	} else {
+		resp, err = p.Transport.RoundTrip(ctx, request)
+	}
+
+	failed := err
+	if failed == nil && p.Breaker != nil {
+		failed = responseError(resp, p.Breaker.Codes)
+	}
+	if p.Hooks.Attempt != nil {
+		p.Hooks.Attempt(p.Name, time.Since(start), failed)
+	}
+	if p.Breaker != nil {
+		if ctx.Err() == nil || failed == nil {
+			p.Breaker.record(p.Name, failed == nil)
+		} else {
+			// The caller gave up: the attempt says nothing about the
+			// backend, but a probe must not hold the half-open slot.
+			p.Breaker.release()
+		}
+	}
+	return resp, err
+}
+
+func (p *ResilientTransport) hedged(ctx context.Context, request []byte) ([]byte, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		b   []byte
+		err error
+	}
+	hedges := p.MaxHedges
+	if hedges <= 0 {
+		hedges = 1
+	}
+	results := make(chan result, hedges+1)
+	send := func() {
+		go func() {
+			b, err := p.Transport.RoundTrip(ctx, request)
+			results <- result{b, err}
+		}()
+	}
+
+	send()
+	inFlight := 1
+	t := time.NewTimer(p.HedgeDelay)
+	defer t.Stop()
+	for {
+		select {
+		case r := <-results:
+			inFlight--
+			if r.err == nil || (inFlight == 0 && hedges == 0) {
+				return r.b, r.err
+			}
+			if inFlight == 0 {
+				// Everything sent failed; hedge right away.
+				t.Reset(0)
+			}
+		case <-t.C:
+			if hedges > 0 {
+				hedges--
+				inFlight++
+				if p.Hooks.Hedge != nil {
+					p.Hooks.Hedge(p.Name)
+				}
+				send()
+				t.Reset(p.HedgeDelay)
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// retryable reports whether request has no notifications and only
+// idempotent methods.
+func (p *ResilientTransport) retryable(request []byte) bool {
+	if p.Retry == nil || p.Retry.Idempotent == nil {
+		return false
+	}
+	var pr fastjson.Parser
+	v, err := pr.ParseBytes(request)
+	if err != nil {
+		return false
+	}
+	items := []*fastjson.Value{v}
+	if v.Type() == fastjson.TypeArray {
+		items = v.GetArray()
+	}
+	for _, item := range items {
+		if item.Get("id") == nil || !p.Retry.Idempotent(string(item.GetStringBytes("method"))) {
+			return false
+		}
+	}
+	return len(items) > 0
+}
+
+// responseError returns the first error of resp with one of codes.
+func responseError(resp []byte, codes []int) error {
+	if len(codes) == 0 || len(resp) == 0 {
+		return nil
+	}
+	var pr fastjson.Parser
+	v, err := pr.ParseBytes(resp)
+	if err != nil {
+		return nil
+	}
+	items := []*fastjson.Value{v}
+	if v.Type() == fastjson.TypeArray {
+		items = v.GetArray()
+	}
+	for _, item := range items {
+		e := item.Get("error")
+		if e == nil {
+			continue
+		}
+		if code := e.GetInt("code"); slices.Contains(codes, code) {
+			return fastjsonrpc.NewError(code, string(e.GetStringBytes("message")))
+		}
+	}
+	return nil
+}
+
+func (p *ResilientTransport) Close() error { return p.Transport.Close() }
+
+// BreakerState is the state of a CircuitBreaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets requests through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fails requests fast until the cooldown elapses.
+	BreakerOpen
+	// BreakerHalfOpen lets a single probe through; its outcome closes or
+	// reopens the breaker.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "BreakerState(" + strconv.Itoa(int(s)) + ")"
+}
+
+// CircuitOpenError is returned while a circuit breaker is open.
+type CircuitOpenError struct {
+	Name string
+	// RetryAfter is the remaining cooldown.
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	s := "jsonrpc: circuit open"
+	if e.Name != "" {
+		s += " for " + e.Name
+	}
+	return s + ", retry after " + e.RetryAfter.String()
+}
+
+// CircuitBreaker opens after Failures consecutive failed attempts and
+// fails requests with *CircuitOpenError for Cooldown. A failure is a
+// transport error or an error response with one of Codes.
+type CircuitBreaker struct {
+	Failures int
+	Cooldown time.Duration
+	Codes    []int
+	// StateChange, if set, is called on every transition.
+	StateChange func(name string, from, to BreakerState)
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewCircuitBreaker returns a breaker opening after failures consecutive
+// failures for cooldown.
+func NewCircuitBreaker(failures int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{Failures: failures, Cooldown: cooldown}
+}
+
+func (p *CircuitBreaker) State() BreakerState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state
+}
+
+func (p *CircuitBreaker) allow(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch p.state {
+	case BreakerOpen:
+		if wait := p.Cooldown - time.Since(p.openedAt); wait > 0 {
+			return &CircuitOpenError{Name: name, RetryAfter: wait}
+		}
+		p.set(name, BreakerHalfOpen)
+		p.probing = true
+		return nil
+	case BreakerHalfOpen:
+		if p.probing {
+			return &CircuitOpenError{Name: name}
+		}
+		p.probing = true
+	}
+	return nil
+}
+
+func (p *CircuitBreaker) record(name string, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.probing = false
+	if ok {
+		p.failures = 0
+		if p.state != BreakerClosed {
+			p.set(name, BreakerClosed)
+		}
+		return
+	}
+	p.failures++
+	if p.state == BreakerHalfOpen || p.failures >= max(p.Failures, 1) {
+		p.openedAt = time.Now()
+		if p.state != BreakerOpen {
+			p.set(name, BreakerOpen)
+		}
+	}
+}
+
+// release ends a probe without recording its outcome, letting the next
+// request probe.
+func (p *CircuitBreaker) release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.probing = false
+}
+
+// set changes the state. It must be called with mu held.
+func (p *CircuitBreaker) set(name string, s BreakerState) {
+	from := p.state
+	p.state = s
+	if p.StateChange != nil {
+		p.StateChange(name, from, s)
+	}
+}
@@ -0,0 +1,222 @@
+package client_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fastjson"
+	"github.com/zc310/fastjsonrpc"
+	. "github.com/zc310/fastjsonrpc/client"
+	"github.com/zc310/fastjsonrpc/ws"
+)
+
+type transportFunc func(ctx context.Context, request []byte) ([]byte, error)
+
+func (f transportFunc) RoundTrip(ctx context.Context, request []byte) ([]byte, error) {
+	return f(ctx, request)
+}
+
+func (f transportFunc) Close() error { return nil }
+
+func TestRetry(t *testing.T) {
+	t.Parallel()
+
+	var n atomic.Int64
+	flaky := transportFunc(func(ctx context.Context, request []byte) ([]byte, error) {
+		switch n.Add(1) {
+		case 1:
+			return nil, errors.New("connection reset")
+		case 2:
+			return reply(request, `"error":{"code":-32001,"message":"Server busy"}`), nil
+		}
+		return reply(request, `"result":1`), nil
+	})
+	var retries []error
+	tr := &ResilientTransport{
+		Transport: flaky,
+		Retry: &RetryPolicy{
+			Idempotent: IdempotentMethods("get"),
+			Codes:      []int{-32001},
+			BaseDelay:  time.Millisecond,
+		},
+		Hooks: Hooks{Retry: func(_ string, _ int, err error, _ time.Duration) { retries = append(retries, err) }},
+	}
+	c := New(tr)
+
+	var v int
+	require.NoError(t, c.Call(context.Background(), "get", nil, &v))
+	assert.Equal(t, 1, v)
+	assert.Equal(t, int64(3), n.Load())
+	require.Len(t, retries, 2)
+	var e *fastjsonrpc.Error
+	require.ErrorAs(t, retries[1], &e)
+	assert.Equal(t, -32001, e.Code)
+
+	// Non-idempotent methods and notifications are sent once.
+	n.Store(0)
+	assert.Error(t, c.Call(context.Background(), "set", nil, nil))
+	assert.Equal(t, int64(1), n.Load())
+	n.Store(0)
+	assert.Error(t, c.Notify(context.Background(), "get", nil))
+	assert.Equal(t, int64(1), n.Load())
+	n.Store(0)
+	assert.Error(t, c.Batch(context.Background(), []*Call{{Method: "get"}, {Method: "get", Notify: true}}))
+	assert.Equal(t, int64(1), n.Load())
+
+	// The last response is returned when attempts run out.
+	n.Store(1)
+	tr.Retry.MaxAttempts = 1
+	require.ErrorAs(t, c.Call(context.Background(), "get", nil, nil), &e)
+	assert.Equal(t, -32001, e.Code)
+}
+
+func TestHedge(t *testing.T) {
+	t.Parallel()
+
+	var n atomic.Int64
+	slow := transportFunc(func(ctx context.Context, request []byte) ([]byte, error) {
+		if n.Add(1) == 1 {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return reply(request, `"result":2`), nil
+	})
+	var hedges atomic.Int64
+	c := New(&ResilientTransport{
+		Transport:  slow,
+		Retry:      &RetryPolicy{Idempotent: func(string) bool { return true }},
+		HedgeDelay: 10 * time.Millisecond,
+		Hooks:      Hooks{Hedge: func(string) { hedges.Add(1) }},
+	})
+
+	var v int
+	require.NoError(t, c.Call(context.Background(), "get", nil, &v))
+	assert.Equal(t, 2, v)
+	assert.Equal(t, int64(1), hedges.Load())
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	var fail atomic.Bool
+	fail.Store(true)
+	var n atomic.Int64
+	down := transportFunc(func(ctx context.Context, request []byte) ([]byte, error) {
+		n.Add(1)
+		if fail.Load() {
+			return nil, errors.New("connection refused")
+		}
+		return reply(request, `"result":true`), nil
+	})
+	var states []BreakerState
+	b := NewCircuitBreaker(2, 20*time.Millisecond)
+	b.StateChange = func(_ string, _, to BreakerState) { states = append(states, to) }
+	c := New(&ResilientTransport{Transport: down, Name: "a", Breaker: b})
+
+	assert.Error(t, c.Call(context.Background(), "m", nil, nil))
+	assert.Error(t, c.Call(context.Background(), "m", nil, nil))
+	assert.Equal(t, BreakerOpen, b.State())
+
+	var open *CircuitOpenError
+	require.ErrorAs(t, c.Call(context.Background(), "m", nil, nil), &open)
+	assert.Equal(t, "a", open.Name)
+	assert.Positive(t, open.RetryAfter)
+	assert.Equal(t, int64(2), n.Load())
+
+	// A failed probe reopens the breaker, a successful one closes it.
+	time.Sleep(25 * time.Millisecond)
+	assert.NotErrorAs(t, c.Call(context.Background(), "m", nil, nil), &open)
+	assert.Equal(t, BreakerOpen, b.State())
+	time.Sleep(25 * time.Millisecond)
+	fail.Store(false)
+	require.NoError(t, c.Call(context.Background(), "m", nil, nil))
+	assert.Equal(t, BreakerClosed, b.State())
+	assert.Equal(t, []BreakerState{BreakerOpen, BreakerHalfOpen, BreakerOpen, BreakerHalfOpen, BreakerClosed}, states)
+}
+
+func TestCircuitBreakerCancelledProbe(t *testing.T) {
+	t.Parallel()
+
+	tr := transportFunc(func(ctx context.Context, request []byte) ([]byte, error) {
+		switch fastjson.GetString(request, "method") {
+		case "fail":
+			return nil, errors.New("connection refused")
+		case "slow":
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return reply(request, `"result":true`), nil
+	})
+	b := NewCircuitBreaker(1, time.Millisecond)
+	c := New(&ResilientTransport{Transport: tr, Breaker: b})
+
+	assert.Error(t, c.Call(context.Background(), "fail", nil, nil))
+	require.Equal(t, BreakerOpen, b.State())
+	time.Sleep(2 * time.Millisecond)
+
+	// The probe is abandoned by its caller; the next request probes again.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, c.Call(ctx, "slow", nil, nil), context.DeadlineExceeded)
+	assert.Equal(t, BreakerHalfOpen, b.State())
+	require.NoError(t, c.Call(context.Background(), "fast", nil, nil))
+	assert.Equal(t, BreakerClosed, b.State())
+}
+
+func TestRedial(t *testing.T) {
+	t.Parallel()
+
+	j := ws.NewJSONRPC2()
+	j.RegisterMethodFunc("ping", func(*fastjson.Value) (interface{}, error) { return "pong", nil })
+
+	var servers []net.Conn
+	r := &RedialTransport{Dial: func(context.Context) (Transport, error) {
+		server, conn := net.Pipe()
+		servers = append(servers, server)
+		go serveStream(j, server)
+		return NewStream(conn, nil), nil
+	}}
+	c := New(&ResilientTransport{
+		Transport: r,
+		Retry:     &RetryPolicy{Idempotent: IdempotentMethods("ping"), BaseDelay: time.Millisecond},
+	})
+	defer c.Close()
+
+	var s string
+	require.NoError(t, c.Call(context.Background(), "ping", nil, &s))
+	_ = servers[0].Close()
+	require.NoError(t, c.Call(context.Background(), "ping", nil, &s))
+	assert.Equal(t, "pong", s)
+	assert.Len(t, servers, 2)
+}
+
+func serveStream(j *ws.JSONRPC2, conn net.Conn) {
+	r := bufio.NewScanner(conn)
+	for r.Scan() {
+		resp, _ := j.HandleMessage(r.Bytes())
+		_, _ = conn.Write(append(resp, '\n'))
+	}
+}
+
+// reply answers every call of request with member, such as `"result":1`.
+func reply(request []byte, member string) []byte {
+	v := fastjson.MustParseBytes(request)
+	if v.Type() != fastjson.TypeArray {
+		return []byte(`{"jsonrpc":"2.0",` + member + `,"id":` + v.Get("id").String() + `}`)
+	}
+	b := []byte{'['}
+	for i, c := range v.GetArray() {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(b, `{"jsonrpc":"2.0",`+member+`,"id":`+c.Get("id").String()+`}`...)
+	}
+	return append(b, ']')
+}
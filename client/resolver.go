@@ -0,0 +1,142 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Resolver discovers the endpoints of a service.
+type Resolver interface {
+	// Resolve calls update with the complete list of endpoints, first as
+	// soon as it is known and then whenever it changes, until ctx is done.
+	// When the first attempt fails it calls update with an empty list, so
+	// that callers fail fast instead of waiting for endpoints.
+	Resolve(ctx context.Context, update func(endpoints []string)) error
+}
+
+// StaticResolver is a fixed list of endpoints.
+type StaticResolver []string
+
+func (p StaticResolver) Resolve(ctx context.Context, update func([]string)) error {
+	update(p)
+	<-ctx.Done()
+	return nil
+}
+
+// FileResolver reads endpoints from a file, one per line, and rereads it
+// when its modification time or size changes. Blank lines and lines
+// starting with # are skipped. The last list is kept while the file
+// cannot be read; a file missing at startup resolves to no endpoints
+// until it appears.
+type FileResolver struct {
+	Path string
+	// Interval between checks of the file. Zero means one second.
+	Interval time.Duration
+}
+
+func (p *FileResolver) Resolve(ctx context.Context, update func([]string)) error {
+	interval := p.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	var mod time.Time
+	var size int64 = -1
+	resolved := false
+	for {
+		fi, err := os.Stat(p.Path)
+		if err == nil && (!fi.ModTime().Equal(mod) || fi.Size() != size) {
+			var b []byte
+			if b, err = os.ReadFile(p.Path); err == nil {
+				mod, size = fi.ModTime(), fi.Size()
+				update(parseEndpoints(b))
+				resolved = true
+			}
+		}
+		if err != nil && !resolved {
+			update(nil)
+			resolved = true
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tick.C:
+		}
+	}
+}
+
+func parseEndpoints(b []byte) []string {
+	var endpoints []string
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line != "" && line[0] != '#' {
+			endpoints = append(endpoints, line)
+		}
+	}
+	return endpoints
+}
+
+// SRVResolver looks up DNS SRV records, such as _rpc._tcp.example.com,
+// and reports an endpoint Scheme://target:port/Path for each of them.
+type SRVResolver struct {
+	Service, Proto, Name string
+	// Scheme defaults to "http".
+	Scheme string
+	Path   string
+	// Interval between lookups. Zero means 30 seconds.
+	Interval time.Duration
+	// Resolver performs the lookups; net.DefaultResolver if nil.
+	Resolver *net.Resolver
+}
+
+func (p *SRVResolver) Resolve(ctx context.Context, update func([]string)) error {
+	interval := p.Interval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	r := p.Resolver
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	scheme := p.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	var last []string
+	for {
+		_, srvs, err := r.LookupSRV(ctx, p.Service, p.Proto, p.Name)
+		if err == nil {
+			endpoints := make([]string, 0, len(srvs))
+			for _, s := range srvs {
+				host := strings.TrimSuffix(s.Target, ".")
+				endpoints = append(endpoints, scheme+"://"+net.JoinHostPort(host, strconv.Itoa(int(s.Port)))+p.Path)
+			}
+			slices.Sort(endpoints)
+			if last == nil || !slices.Equal(endpoints, last) {
+				last = endpoints
+				update(endpoints)
+			}
+		} else if last == nil {
+			last = []string{}
+			update(nil)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tick.C:
+		}
+	}
+}
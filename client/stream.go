@@ -0,0 +1,73 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"net"
+
+	"github.com/goccy/go-json"
+)
+
+// StreamTransport sends requests as newline-delimited JSON over a single
+// stream connection, such as TCP or a Unix socket. Incoming messages may
+// be separated by newlines or simply concatenated. Concurrent calls are
+// multiplexed and matched to responses by id.
+type StreamTransport struct {
+	conn net.Conn
+	mux  *mux
+}
+
+// DialStream connects to address on network, such as "tcp" or "unix".
+// notify, if not nil, receives server notifications.
+func DialStream(ctx context.Context, network, address string, notify NotifyHandler) (*StreamTransport, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewStream(conn, notify), nil
+}
+
+// NewStream returns a transport over conn.
+func NewStream(conn net.Conn, notify NotifyHandler) *StreamTransport {
+	p := &StreamTransport{conn: conn}
+	p.mux = newMux(p.write, notify)
+	go p.read()
+	return p
+}
+
+// write sends one message. It is serialized by the mux.
+func (p *StreamTransport) write(b []byte) error {
+	if bytes.IndexByte(b, '\n') >= 0 {
+		var c bytes.Buffer
+		if err := json.Compact(&c, b); err == nil {
+			b = c.Bytes()
+		}
+	}
+	_, err := p.conn.Write(append(b[:len(b):len(b)], '\n'))
+	return err
+}
+
+func (p *StreamTransport) read() {
+	d := json.NewDecoder(p.conn)
+	for {
+		var m json.RawMessage
+		if err := d.Decode(&m); err != nil {
+			p.mux.fail(err)
+			return
+		}
+		p.mux.dispatch(m)
+	}
+}
+
+func (p *StreamTransport) RoundTrip(ctx context.Context, request []byte) ([]byte, error) {
+	return p.mux.roundTrip(ctx, request)
+}
+
+// Done is closed when the connection is lost.
+func (p *StreamTransport) Done() <-chan struct{} { return p.mux.done }
+
+func (p *StreamTransport) Close() error {
+	p.mux.fail(ErrClosed)
+	return p.conn.Close()
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/fasthttp/websocket"
+)
+
+// WebSocketTransport sends requests over a single WebSocket connection.
+// Concurrent calls are multiplexed and matched to responses by id.
+type WebSocketTransport struct {
+	conn *websocket.Conn
+	mux  *mux
+}
+
+// WebSocketOption configures DialWebSocket.
+type WebSocketOption func(*wsOptions)
+
+type wsOptions struct {
+	dialer *websocket.Dialer
+	header http.Header
+	notify NotifyHandler
+}
+
+// WithDialer dials with d instead of websocket.DefaultDialer.
+func WithDialer(d *websocket.Dialer) WebSocketOption { return func(o *wsOptions) { o.dialer = d } }
+
+// WithHeader sends h with the handshake request.
+func WithHeader(h http.Header) WebSocketOption { return func(o *wsOptions) { o.header = h } }
+
+// WithNotifyHandler delivers server notifications to h.
+func WithNotifyHandler(h NotifyHandler) WebSocketOption {
+	return func(o *wsOptions) { o.notify = h }
+}
+
+// DialWebSocket connects to url, such as "ws://host/ws".
+func DialWebSocket(ctx context.Context, url string, opts ...WebSocketOption) (*WebSocketTransport, error) {
+	o := &wsOptions{dialer: websocket.DefaultDialer}
+	for _, opt := range opts {
+		opt(o)
+	}
+	conn, _, err := o.dialer.DialContext(ctx, url, o.header)
+	if err != nil {
+		return nil, err
+	}
+	p := &WebSocketTransport{conn: conn}
+	p.mux = newMux(func(b []byte) error { return conn.WriteMessage(websocket.TextMessage, b) }, o.notify)
+	go p.read()
+	return p, nil
+}
+
+func (p *WebSocketTransport) read() {
+	for {
+		_, b, err := p.conn.ReadMessage()
+		if err != nil {
+			p.mux.fail(err)
+			return
+		}
+		p.mux.dispatch(b)
+	}
+}
+
+func (p *WebSocketTransport) RoundTrip(ctx context.Context, request []byte) ([]byte, error) {
+	return p.mux.roundTrip(ctx, request)
+}
+
+// Done is closed when the connection is lost.
+func (p *WebSocketTransport) Done() <-chan struct{} { return p.mux.done }
+
+func (p *WebSocketTransport) Close() error {
+	p.mux.fail(ErrClosed)
+	return p.conn.Close()
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	paramsDirective = "//rpc:params "
+	resultDirective = "//rpc:result "
+	rawMessage      = "json.RawMessage"
+)
+
+func (g *generator) run(w io.Writer) error {
+	if err := g.load(); err != nil {
+		return err
+	}
+	if g.pkg == "" {
+		g.pkg = g.srcPkg
+	}
+	if g.pkg != g.srcPkg && g.importPath == "" {
+		return errors.New("-import is required when -pkg differs from the scanned package")
+	}
+	services, err := g.services()
+	if err != nil {
+		return err
+	}
+	if len(services) == 0 {
+		return errors.New("no registered services found in " + g.dir)
+	}
+	if g.pkg != g.srcPkg {
+		g.imports[g.srcPkg] = strconv.Quote(g.importPath)
+	}
+
+	var b bytes.Buffer
+	g.write(&b, services)
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return fmt.Errorf("format generated code: %v\n%s", err, b.Bytes())
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// signature fills the params and result types of m.
+func (g *generator) signature(e engine, m *goMethod, rm *rpcMethod) error {
+	var params, result ast.Expr
+	var hasParams, noParams bool
+	if m.decl.Doc != nil {
+		for _, c := range m.decl.Doc.List {
+			var err error
+			switch {
+			case strings.HasPrefix(c.Text, paramsDirective):
+				s := strings.TrimSpace(strings.TrimPrefix(c.Text, paramsDirective))
+				hasParams = true
+				if s == "-" {
+					noParams = true
+					continue
+				}
+				params, err = parser.ParseExpr(s)
+			case strings.HasPrefix(c.Text, resultDirective):
+				result, err = parser.ParseExpr(strings.TrimSpace(strings.TrimPrefix(c.Text, resultDirective)))
+			case strings.HasPrefix(c.Text, "//rpc:"):
+			default:
+				rm.doc = append(rm.doc, c.Text)
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %v", g.fset.Position(c.Pos()), err)
+			}
+		}
+	}
+	if e == httpEngine {
+		if !hasParams {
+			params = inferParams(m.decl)
+		}
+		if result == nil {
+			result = inferResult(m.decl)
+		}
+	}
+
+	switch {
+	case noParams:
+	case params != nil:
+		rm.params = g.typeString(m.file, params)
+	default:
+		rm.params = "any"
+	}
+	if result != nil {
+		rm.result = g.typeString(m.file, result)
+	} else {
+		rm.result = rawMessage
+		g.imports["json"] = `"encoding/json"`
+	}
+	return nil
+}
+
+// inferParams finds the type of x in a call to ParamsUnmarshal(&x), or
+// to the generated x.UnmarshalParams(c).
+func inferParams(d *ast.FuncDecl) ast.Expr {
+	var t ast.Expr
+	ast.Inspect(d, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || t != nil {
+			return t == nil
+		}
+		if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "ParamsUnmarshal" && len(call.Args) == 1 {
+			if u, ok := call.Args[0].(*ast.UnaryExpr); ok && u.Op == token.AND {
+				t = localType(u.X)
+			}
+		} else if ok && sel.Sel.Name == "UnmarshalParams" && len(call.Args) == 1 {
+			t = localType(sel.X)
+		}
+		return true
+	})
+	return t
+}
+
+// inferResult finds the type of the value assigned to Result, or of x in
+// a call to the generated x.SetResult(c).
+func inferResult(d *ast.FuncDecl) ast.Expr {
+	var t ast.Expr
+	ast.Inspect(d, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok && t == nil {
+			if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "SetResult" && len(call.Args) == 1 {
+				t = localType(sel.X)
+			}
+		}
+		as, ok := n.(*ast.AssignStmt)
+		if !ok || t != nil || len(as.Lhs) != len(as.Rhs) {
+			return t == nil
+		}
+		for i, l := range as.Lhs {
+			if sel, ok := l.(*ast.SelectorExpr); ok && sel.Sel.Name == "Result" {
+				t = localType(as.Rhs[i])
+			}
+		}
+		return true
+	})
+	return t
+}
+
+// localType returns the declared type of a local variable or composite
+// literal.
+func localType(e ast.Expr) ast.Expr {
+	switch x := e.(type) {
+	case *ast.CompositeLit:
+		return x.Type
+	case *ast.UnaryExpr:
+		if x.Op == token.AND {
+			if t := localType(x.X); t != nil {
+				return &ast.StarExpr{X: t}
+			}
+		}
+	case *ast.Ident:
+		if x.Obj == nil {
+			return nil
+		}
+		switch d := x.Obj.Decl.(type) {
+		case *ast.ValueSpec:
+			if d.Type != nil {
+				return d.Type
+			}
+			for i, n := range d.Names {
+				if n.Name == x.Name && i < len(d.Values) {
+					return localType(d.Values[i])
+				}
+			}
+		case *ast.AssignStmt:
+			for i, l := range d.Lhs {
+				if id, ok := l.(*ast.Ident); ok && id.Name == x.Name && len(d.Lhs) == len(d.Rhs) {
+					return localType(d.Rhs[i])
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// typeString prints e for the output package, qualifying types of the
+// scanned package and recording the imports it needs.
+func (g *generator) typeString(f *ast.File, e ast.Expr) string {
+	e = g.qualify(f, e)
+	var b bytes.Buffer
+	_ = printer.Fprint(&b, token.NewFileSet(), e)
+	return b.String()
+}
+
+func (g *generator) qualify(f *ast.File, e ast.Expr) ast.Expr {
+	switch x := e.(type) {
+	case *ast.Ident:
+		if g.pkg != g.srcPkg && g.decls[x.Name] {
+			return &ast.SelectorExpr{X: ast.NewIdent(g.srcPkg), Sel: ast.NewIdent(x.Name)}
+		}
+		return ast.NewIdent(x.Name)
+	case *ast.SelectorExpr:
+		if id, ok := x.X.(*ast.Ident); ok && f != nil {
+			if path := importPath(f, id.Name); path != "" {
+				g.imports[id.Name] = g.importSpec(id.Name, path)
+			}
+		}
+		return x
+	case *ast.StarExpr:
+		return &ast.StarExpr{X: g.qualify(f, x.X)}
+	case *ast.ArrayType:
+		return &ast.ArrayType{Len: x.Len, Elt: g.qualify(f, x.Elt)}
+	case *ast.MapType:
+		return &ast.MapType{Key: g.qualify(f, x.Key), Value: g.qualify(f, x.Value)}
+	case *ast.InterfaceType:
+		if x.Methods == nil || len(x.Methods.List) == 0 {
+			return ast.NewIdent("any")
+		}
+	}
+	return e
+}
+
+func (g *generator) importSpec(name, path string) string {
+	if packageName(path) == name {
+		return strconv.Quote(path)
+	}
+	return name + " " + strconv.Quote(path)
+}
+
+func (g *generator) write(b *bytes.Buffer, services []*service) {
+	b.WriteString("// Code generated by fastjsonrpc-gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(b, "package %s\n\n", g.pkg)
+
+	specs := []string{`"context"`, strconv.Quote(clientPath)}
+	for _, s := range g.imports {
+		specs = append(specs, s)
+	}
+	sort.Strings(specs)
+	b.WriteString("import (\n")
+	for _, std := range []bool{true, false} {
+		for _, s := range specs {
+			path, _ := strconv.Unquote(s[strings.IndexByte(s, '"'):])
+			if !strings.Contains(strings.Split(path, "/")[0], ".") == std {
+				b.WriteString(s + "\n")
+			}
+		}
+		if std {
+			b.WriteString("\n")
+		}
+	}
+	b.WriteString(")\n")
+
+	for _, s := range services {
+		fmt.Fprintf(b, "\n// %s calls the methods of %s.\n", s.client, s.typ)
+		fmt.Fprintf(b, "type %s struct {\n\tc *client.Client\n}\n\n", s.client)
+		fmt.Fprintf(b, "// New%s returns a client of %s using c.\n", s.client, s.typ)
+		fmt.Fprintf(b, "func New%s(c *client.Client) *%s { return &%s{c: c} }\n", s.client, s.client, s.client)
+
+		for _, m := range s.methods {
+			b.WriteString("\n")
+			for _, line := range m.doc {
+				b.WriteString(line + "\n")
+			}
+			if len(m.doc) > 0 {
+				b.WriteString("//\n")
+			}
+			fmt.Fprintf(b, "// %s calls %q.\n", m.goName, m.name)
+			if m.deprecated {
+				b.WriteString("//\n// Deprecated: ")
+				if m.deprecation != "" {
+					b.WriteString(m.deprecation + "\n")
+				} else {
+					b.WriteString(m.name + " is deprecated.\n")
+				}
+			}
+			params, arg := "", "nil"
+			if m.params != "" {
+				params, arg = ", params "+m.params, "params"
+			}
+			fmt.Fprintf(b, "func (p *%s) %s(ctx context.Context%s) (%s, error) {\n", s.client, m.goName, params, m.result)
+			fmt.Fprintf(b, "\tvar result %s\n", m.result)
+			fmt.Fprintf(b, "\terr := p.c.Call(ctx, %q, %s, &result)\n", m.name, arg)
+			b.WriteString("\treturn result, err\n}\n")
+		}
+	}
+}
@@ -0,0 +1,455 @@
+// Code generated by fastjsonrpc-gen -server. DO NOT EDIT.
+
+package shapes
+
+import (
+	"strconv"
+
+	"github.com/goccy/go-json"
+	"github.com/valyala/bytebufferpool"
+	"github.com/valyala/fastjson"
+	"github.com/valyala/quicktemplate"
+	"github.com/zc310/fastjsonrpc"
+)
+
+// RegisterService registers rcvr like s.Register(rcvr, opts...), dispatching
+// through a static method table instead of reflection.
+func RegisterService(s *fastjsonrpc.ServerMap, rcvr *Service, opts ...fastjsonrpc.Option) error {
+	return s.RegisterMethods("Service", map[string]fastjsonrpc.Handler{
+		"Echo": rcvr.Echo,
+	}, opts...)
+}
+
+// UnmarshalFastJSON decodes v into p without reflection.
+func (p *Base) UnmarshalFastJSON(v *fastjson.Value) error { return p.unmarshalFastJSON(v, "params") }
+
+func (p *Base) unmarshalFastJSON(v *fastjson.Value, path string) error {
+	if v == nil || v.Type() == fastjson.TypeNull {
+		return nil
+	}
+	if v.Type() != fastjson.TypeObject {
+		return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path, Rule: "type", Message: "must be object"})
+	}
+	if f1 := v.Get("id"); f1 != nil && f1.Type() != fastjson.TypeNull {
+		x2, err := f1.Uint64()
+		if err != nil {
+			return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path + ".id", Rule: "type", Message: "must be integer"})
+		}
+		p.ID = x2
+	}
+	if f3 := v.Get("note"); f3 != nil && f3.Type() != fastjson.TypeNull {
+		x4, err := f3.StringBytes()
+		if err != nil {
+			return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path + ".note", Rule: "type", Message: "must be string"})
+		}
+		p.Note = string(x4)
+	}
+	return nil
+}
+
+// StreamFastJSON writes the JSON encoding of p to qw without reflection.
+func (p Base) StreamFastJSON(qw *quicktemplate.Writer) {
+	qw.N().S(`{`)
+	qw.N().S(`"id":`)
+	qw.N().DUL(uint64(p.ID))
+	if p.Note != "" {
+		qw.N().S(`,"note":`)
+		qw.N().Q(string(p.Note))
+	}
+	qw.N().S(`}`)
+}
+
+// MarshalFastJSON appends the JSON encoding of p to dst without reflection.
+func (p Base) MarshalFastJSON(dst []byte) []byte {
+	b := bytebufferpool.Get()
+	qw := quicktemplate.AcquireWriter(b)
+	p.StreamFastJSON(qw)
+	quicktemplate.ReleaseWriter(qw)
+	dst = append(dst, b.B...)
+	bytebufferpool.Put(b)
+	return dst
+}
+
+// UnmarshalFastJSON decodes v into p without reflection.
+func (p *Point) UnmarshalFastJSON(v *fastjson.Value) error { return p.unmarshalFastJSON(v, "params") }
+
+func (p *Point) unmarshalFastJSON(v *fastjson.Value, path string) error {
+	if v == nil || v.Type() == fastjson.TypeNull {
+		return nil
+	}
+	if v.Type() != fastjson.TypeObject {
+		return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path, Rule: "type", Message: "must be object"})
+	}
+	if f5 := v.Get("X"); f5 != nil && f5.Type() != fastjson.TypeNull {
+		x6, err := f5.Float64()
+		if err != nil {
+			return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path + ".X", Rule: "type", Message: "must be number"})
+		}
+		p.X = x6
+	}
+	if f7 := v.Get("Y"); f7 != nil && f7.Type() != fastjson.TypeNull {
+		x8, err := f7.Float64()
+		if err != nil {
+			return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path + ".Y", Rule: "type", Message: "must be number"})
+		}
+		p.Y = x8
+	}
+	return nil
+}
+
+// StreamFastJSON writes the JSON encoding of p to qw without reflection.
+func (p Point) StreamFastJSON(qw *quicktemplate.Writer) {
+	qw.N().S(`{`)
+	qw.N().S(`"X":`)
+	qw.N().F(float64(p.X))
+	qw.N().S(`,"Y":`)
+	qw.N().F(float64(p.Y))
+	qw.N().S(`}`)
+}
+
+// MarshalFastJSON appends the JSON encoding of p to dst without reflection.
+func (p Point) MarshalFastJSON(dst []byte) []byte {
+	b := bytebufferpool.Get()
+	qw := quicktemplate.AcquireWriter(b)
+	p.StreamFastJSON(qw)
+	quicktemplate.ReleaseWriter(qw)
+	dst = append(dst, b.B...)
+	bytebufferpool.Put(b)
+	return dst
+}
+
+// UnmarshalFastJSON decodes v into p without reflection.
+func (p *Shape) UnmarshalFastJSON(v *fastjson.Value) error { return p.unmarshalFastJSON(v, "params") }
+
+func (p *Shape) unmarshalFastJSON(v *fastjson.Value, path string) error {
+	if v == nil || v.Type() == fastjson.TypeNull {
+		return nil
+	}
+	if v.Type() != fastjson.TypeObject {
+		return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path, Rule: "type", Message: "must be object"})
+	}
+	if err := p.Base.unmarshalFastJSON(v, path); err != nil {
+		return err
+	}
+	if f9 := v.Get("name"); f9 != nil && f9.Type() != fastjson.TypeNull {
+		x10, err := f9.StringBytes()
+		if err != nil {
+			return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path + ".name", Rule: "type", Message: "must be string"})
+		}
+		p.Name = string(x10)
+	}
+	if f11 := v.Get("level"); f11 != nil && f11.Type() != fastjson.TypeNull {
+		x12, err := f11.Int64()
+		if err != nil {
+			return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path + ".level", Rule: "type", Message: "must be integer"})
+		}
+		p.Level = Level(x12)
+	}
+	if f13 := v.Get("visible"); f13 != nil && f13.Type() != fastjson.TypeNull {
+		x14, err := f13.Bool()
+		if err != nil {
+			return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path + ".visible", Rule: "type", Message: "must be boolean"})
+		}
+		p.Visible = x14
+	}
+	if f15 := v.Get("scale"); f15 != nil && f15.Type() != fastjson.TypeNull {
+		x16, err := f15.Float64()
+		if err != nil {
+			return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path + ".scale", Rule: "type", Message: "must be number"})
+		}
+		p.Scale = float32(x16)
+	}
+	if f17 := v.Get("tags"); f17 != nil && f17.Type() != fastjson.TypeNull {
+		a18, err := f17.Array()
+		if err != nil {
+			return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path + ".tags", Rule: "type", Message: "must be array"})
+		}
+		p.Tags = make(Tags, len(a18))
+		for i19, item20 := range a18 {
+			if item20.Type() == fastjson.TypeNull {
+				continue
+			}
+			{
+				x21, err := item20.StringBytes()
+				if err != nil {
+					return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path + ".tags" + "[" + strconv.Itoa(i19) + "]", Rule: "type", Message: "must be string"})
+				}
+				p.Tags[i19] = string(x21)
+			}
+		}
+	}
+	if f22 := v.Get("points"); f22 != nil && f22.Type() != fastjson.TypeNull {
+		a23, err := f22.Array()
+		if err != nil {
+			return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path + ".points", Rule: "type", Message: "must be array"})
+		}
+		p.Points = make([]Point, len(a23))
+		for i24, item25 := range a23 {
+			if item25.Type() == fastjson.TypeNull {
+				continue
+			}
+			{
+				if err := p.Points[i24].unmarshalFastJSON(item25, path+".points"+"["+strconv.Itoa(i24)+"]"); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if f26 := v.Get("style"); f26 != nil && f26.Type() != fastjson.TypeNull {
+		if err := p.Style.unmarshalFastJSON(f26, path+".style"); err != nil {
+			return err
+		}
+	}
+	if f27 := v.Get("parent"); f27 != nil && f27.Type() != fastjson.TypeNull {
+		p.Parent = new(Shape)
+		if err := (*p.Parent).unmarshalFastJSON(f27, path+".parent"); err != nil {
+			return err
+		}
+	}
+	if f28 := v.Get("weights"); f28 != nil && f28.Type() != fastjson.TypeNull {
+		a29, err := f28.Array()
+		if err != nil {
+			return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path + ".weights", Rule: "type", Message: "must be array"})
+		}
+		p.Weights = make([]*int, len(a29))
+		for i30, item31 := range a29 {
+			if item31.Type() == fastjson.TypeNull {
+				continue
+			}
+			{
+				p.Weights[i30] = new(int)
+				x32, err := item31.Int64()
+				if err != nil {
+					return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path + ".weights" + "[" + strconv.Itoa(i30) + "]", Rule: "type", Message: "must be integer"})
+				}
+				(*p.Weights[i30]) = int(x32)
+			}
+		}
+	}
+	if f33 := v.Get("meta"); f33 != nil && f33.Type() != fastjson.TypeNull {
+		if err := json.Unmarshal(f33.MarshalTo(nil), &p.Meta); err != nil {
+			return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path + ".meta", Rule: "type", Message: "must be map[string]string"})
+		}
+	}
+	if f34 := v.Get("at"); f34 != nil && f34.Type() != fastjson.TypeNull {
+		if err := json.Unmarshal(f34.MarshalTo(nil), &p.At); err != nil {
+			return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path + ".at", Rule: "type", Message: "must be time.Time"})
+		}
+	}
+	if f35 := v.Get("raw"); f35 != nil && f35.Type() != fastjson.TypeNull {
+		if err := json.Unmarshal(f35.MarshalTo(nil), &p.Raw); err != nil {
+			return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path + ".raw", Rule: "type", Message: "must be []byte"})
+		}
+	}
+	return nil
+}
+
+// UnmarshalParams decodes the params of c into p like c.ParamsUnmarshal(p),
+// without allocating unless the method validates its params.
+func (p *Shape) UnmarshalParams(c *fastjsonrpc.RequestCtx) error {
+	if v, ok := c.FastParams(); ok {
+		return p.UnmarshalFastJSON(v)
+	}
+	q := new(Shape)
+	err := c.ParamsUnmarshal(q)
+	*p = *q
+	return err
+}
+
+// StreamFastJSON writes the JSON encoding of p to qw without reflection.
+func (p Shape) StreamFastJSON(qw *quicktemplate.Writer) {
+	qw.N().S(`{`)
+	qw.N().S(`"id":`)
+	qw.N().DUL(uint64(p.Base.ID))
+	if p.Base.Note != "" {
+		qw.N().S(`,"note":`)
+		qw.N().Q(string(p.Base.Note))
+	}
+	qw.N().S(`,"name":`)
+	qw.N().Q(string(p.Name))
+	if p.Level != 0 {
+		qw.N().S(`,"level":`)
+		qw.N().DL(int64(p.Level))
+	}
+	qw.N().S(`,"visible":`)
+	if p.Visible {
+		qw.N().S(`true`)
+	} else {
+		qw.N().S(`false`)
+	}
+	if p.Scale != 0 {
+		qw.N().S(`,"scale":`)
+		qw.N().F(float64(p.Scale))
+	}
+	if len(p.Tags) > 0 {
+		qw.N().S(`,"tags":`)
+		if p.Tags == nil {
+			qw.N().S(`null`)
+		} else {
+			qw.N().S(`[`)
+			for i36, x37 := range p.Tags {
+				if i36 > 0 {
+					qw.N().S(`,`)
+				}
+				qw.N().Q(string(x37))
+			}
+			qw.N().S(`]`)
+		}
+	}
+	qw.N().S(`,"points":`)
+	if p.Points == nil {
+		qw.N().S(`null`)
+	} else {
+		qw.N().S(`[`)
+		for i38, x39 := range p.Points {
+			if i38 > 0 {
+				qw.N().S(`,`)
+			}
+			x39.StreamFastJSON(qw)
+		}
+		qw.N().S(`]`)
+	}
+	qw.N().S(`,"style":`)
+	p.Style.StreamFastJSON(qw)
+	if p.Parent != nil {
+		qw.N().S(`,"parent":`)
+		if p.Parent == nil {
+			qw.N().S(`null`)
+		} else {
+			(*p.Parent).StreamFastJSON(qw)
+		}
+	}
+	if len(p.Weights) > 0 {
+		qw.N().S(`,"weights":`)
+		if p.Weights == nil {
+			qw.N().S(`null`)
+		} else {
+			qw.N().S(`[`)
+			for i40, x41 := range p.Weights {
+				if i40 > 0 {
+					qw.N().S(`,`)
+				}
+				if x41 == nil {
+					qw.N().S(`null`)
+				} else {
+					qw.N().DL(int64((*x41)))
+				}
+			}
+			qw.N().S(`]`)
+		}
+	}
+	if len(p.Meta) > 0 {
+		qw.N().S(`,"meta":`)
+		if b42, err := json.Marshal(p.Meta); err == nil {
+			qw.N().Z(b42)
+		} else {
+			qw.N().S(`null`)
+		}
+	}
+	qw.N().S(`,"at":`)
+	if b43, err := json.Marshal(p.At); err == nil {
+		qw.N().Z(b43)
+	} else {
+		qw.N().S(`null`)
+	}
+	if len(p.Raw) > 0 {
+		qw.N().S(`,"raw":`)
+		if b44, err := json.Marshal(p.Raw); err == nil {
+			qw.N().Z(b44)
+		} else {
+			qw.N().S(`null`)
+		}
+	}
+	qw.N().S(`}`)
+}
+
+// MarshalFastJSON appends the JSON encoding of p to dst without reflection.
+func (p Shape) MarshalFastJSON(dst []byte) []byte {
+	b := bytebufferpool.Get()
+	qw := quicktemplate.AcquireWriter(b)
+	p.StreamFastJSON(qw)
+	quicktemplate.ReleaseWriter(qw)
+	dst = append(dst, b.B...)
+	bytebufferpool.Put(b)
+	return dst
+}
+
+// SetResult sets p as the result of c, encoded into the result buffer of c
+// without allocating.
+func (p Shape) SetResult(c *fastjsonrpc.RequestCtx) {
+	c.SetRawResult(p.MarshalFastJSON(c.ResultBuffer()))
+}
+
+// UnmarshalFastJSON decodes v into p without reflection.
+func (p *Style) UnmarshalFastJSON(v *fastjson.Value) error { return p.unmarshalFastJSON(v, "params") }
+
+func (p *Style) unmarshalFastJSON(v *fastjson.Value, path string) error {
+	if v == nil || v.Type() == fastjson.TypeNull {
+		return nil
+	}
+	if v.Type() != fastjson.TypeObject {
+		return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path, Rule: "type", Message: "must be object"})
+	}
+	if f45 := v.Get("color"); f45 != nil && f45.Type() != fastjson.TypeNull {
+		x46, err := f45.StringBytes()
+		if err != nil {
+			return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path + ".color", Rule: "type", Message: "must be string"})
+		}
+		p.Color = string(x46)
+	}
+	if f47 := v.Get("width"); f47 != nil && f47.Type() != fastjson.TypeNull {
+		x48, err := f47.Int64()
+		if err != nil {
+			return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path + ".width", Rule: "type", Message: "must be integer"})
+		}
+		p.Width = int(x48)
+	}
+	if f49 := v.Get("dash"); f49 != nil && f49.Type() != fastjson.TypeNull {
+		x50, err := f49.Bool()
+		if err != nil {
+			return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path + ".dash", Rule: "type", Message: "must be boolean"})
+		}
+		p.Dash = x50
+	}
+	return nil
+}
+
+// StreamFastJSON writes the JSON encoding of p to qw without reflection.
+func (p Style) StreamFastJSON(qw *quicktemplate.Writer) {
+	qw.N().S(`{`)
+	comma := false
+	if p.Color != "" {
+		qw.N().S(`"color":`)
+		qw.N().Q(string(p.Color))
+		comma = true
+	}
+	if p.Width != 0 {
+		if comma {
+			qw.N().S(`,`)
+		}
+		qw.N().S(`"width":`)
+		qw.N().DL(int64(p.Width))
+		comma = true
+	}
+	if comma {
+		qw.N().S(`,`)
+	}
+	qw.N().S(`"dash":`)
+	if p.Dash {
+		qw.N().S(`true`)
+	} else {
+		qw.N().S(`false`)
+	}
+	qw.N().S(`}`)
+}
+
+// MarshalFastJSON appends the JSON encoding of p to dst without reflection.
+func (p Style) MarshalFastJSON(dst []byte) []byte {
+	b := bytebufferpool.Get()
+	qw := quicktemplate.AcquireWriter(b)
+	p.StreamFastJSON(qw)
+	quicktemplate.ReleaseWriter(qw)
+	dst = append(dst, b.B...)
+	bytebufferpool.Put(b)
+	return dst
+}
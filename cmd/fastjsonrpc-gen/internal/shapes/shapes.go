@@ -0,0 +1,59 @@
+// Package shapes exercises the codecs generated by fastjsonrpc-gen -server.
+package shapes
+
+//go:generate go run ../.. -server -type Service
+
+import (
+	"time"
+
+	"github.com/zc310/fastjsonrpc"
+)
+
+type Level int
+
+type Tags []string
+
+type Base struct {
+	ID   uint64 `json:"id"`
+	Note string `json:"note,omitempty"`
+}
+
+type Point struct {
+	X, Y float64
+}
+
+type Style struct {
+	Color string `json:"color,omitempty"`
+	Width int    `json:"width,omitempty"`
+	Dash  bool   `json:"dash"`
+}
+
+type Shape struct {
+	Base
+	Name    string            `json:"name"`
+	Level   Level             `json:"level,omitempty"`
+	Visible bool              `json:"visible"`
+	Scale   float32           `json:"scale,omitempty"`
+	Tags    Tags              `json:"tags,omitempty"`
+	Points  []Point           `json:"points"`
+	Style   Style             `json:"style"`
+	Parent  *Shape            `json:"parent,omitempty"`
+	Weights []*int            `json:"weights,omitempty"`
+	Meta    map[string]string `json:"meta,omitempty"`
+	At      time.Time         `json:"at"`
+	Raw     []byte            `json:"raw,omitempty"`
+	Skip    string            `json:"-"`
+	hidden  int
+}
+
+type Service struct{}
+
+// Echo returns its params.
+//
+//rpc:result Shape
+func (Service) Echo(c *fastjsonrpc.RequestCtx) {
+	var s Shape
+	if c.Error = s.UnmarshalParams(c); c.Error == nil {
+		s.SetResult(c)
+	}
+}
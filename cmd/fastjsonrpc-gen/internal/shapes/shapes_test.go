@@ -0,0 +1,87 @@
+package shapes_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fastjson"
+	"github.com/zc310/fastjsonrpc"
+	"github.com/zc310/fastjsonrpc/cmd/fastjsonrpc-gen/internal/shapes"
+)
+
+func sample() shapes.Shape {
+	w := 7
+	return shapes.Shape{
+		Base:    shapes.Base{ID: 42, Note: "a \"note\"\n"},
+		Name:    "triängle <&>",
+		Level:   -3,
+		Visible: true,
+		Scale:   1.5,
+		Tags:    shapes.Tags{"x", "y"},
+		Points:  []shapes.Point{{X: 1, Y: 2.25}, {X: -1e21, Y: 0}},
+		Style:   shapes.Style{Width: 2},
+		Parent:  &shapes.Shape{Name: "root", Points: []shapes.Point{}},
+		Weights: []*int{&w, nil},
+		Meta:    map[string]string{"k": "v"},
+		At:      time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC),
+		Raw:     []byte{1, 2, 3},
+	}
+}
+
+func TestMarshalFastJSON(t *testing.T) {
+	for _, s := range []shapes.Shape{sample(), {}} {
+		want, err := json.Marshal(s)
+		require.NoError(t, err)
+		assert.JSONEq(t, string(want), string(s.MarshalFastJSON(nil)))
+	}
+}
+
+func TestUnmarshalFastJSON(t *testing.T) {
+	want := sample()
+	b, err := json.Marshal(want)
+	require.NoError(t, err)
+
+	var got shapes.Shape
+	require.NoError(t, got.UnmarshalFastJSON(fastjson.MustParseBytes(b)))
+	assert.Equal(t, want, got)
+}
+
+func TestUnmarshalFastJSONErrors(t *testing.T) {
+	for params, field := range map[string]string{
+		`[]`:                              "params",
+		`{"name":1}`:                      "params.name",
+		`{"points":[{"X":1},{"Y":"a"}]}`:  "params.points[1].Y",
+		`{"parent":{"weights":[1,true]}}`: "params.parent.weights[1]",
+		`{"id":-1}`:                       "params.id",
+	} {
+		var s shapes.Shape
+		err := s.UnmarshalFastJSON(fastjson.MustParse(params))
+		var e *fastjsonrpc.Error
+		require.ErrorAs(t, err, &e, params)
+		assert.Equal(t, -32602, e.Code)
+		assert.Equal(t, field, e.Data.([]fastjsonrpc.FieldError)[0].Field, params)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	var s fastjsonrpc.ServerMap
+	require.NoError(t, shapes.RegisterService(&s, new(shapes.Service)))
+	assert.Equal(t, []string{"Service.Echo"}, s.Methods())
+	require.Error(t, shapes.RegisterService(&s, new(shapes.Service)))
+
+	// UnmarshalParams falls back to ParamsUnmarshal for validated methods.
+	var v fastjsonrpc.ServerMap
+	require.NoError(t, shapes.RegisterService(&v, new(shapes.Service), fastjsonrpc.WithValidation()))
+	params, err := json.Marshal(sample())
+	require.NoError(t, err)
+	for _, m := range []*fastjsonrpc.ServerMap{&s, &v} {
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Request.SetBodyString(`{"jsonrpc":"2.0","method":"Service.Echo","params":` + string(params) + `,"id":1}`)
+		m.Handler(ctx)
+		assert.JSONEq(t, `{"jsonrpc":"2.0","result":`+string(params)+`,"id":1}`, string(ctx.Response.Body()))
+	}
+}
@@ -0,0 +1,87 @@
+// Command fastjsonrpc-gen generates typed clients for services registered
+// with fastjsonrpc.ServerMap or ws.JSONRPC2.
+//
+// It scans the Go package in -dir for calls to Register, RegisterName,
+// Replace and ReplaceName (ServerMap) and RegisterObject and
+// RegisterService (JSONRPC2), resolves the registered type and literal
+// naming options, and writes one client type per service with one method
+// per RPC method:
+//
+//	//go:generate go run github.com/zc310/fastjsonrpc/cmd/fastjsonrpc-gen
+//
+// Method names follow the server rules: ServerMap uses "Type.Method"
+// unless WithNaming, WithSeparator or WithMethodName say otherwise, and
+// JSONRPC2 uses snake_case with a "type." prefix.
+//
+// Params and result types are taken from directives in the method doc
+// comment:
+//
+//	//rpc:params Args
+//	//rpc:result int
+//
+// Without directives, the params type of a ServerMap method is inferred
+// from a call to ParamsUnmarshal on a local variable, and the result type
+// from an assignment of a variable or composite literal to Result. Calls to
+// the UnmarshalParams and SetResult methods generated by -server count too. Params
+// default to any and results to json.RawMessage. "//rpc:params -" declares
+// a method without params.
+//
+// With -server, it instead writes, for each -type T, a RegisterT function
+// that registers T through ServerMap.RegisterMethods with a static method
+// table, and codecs for the local struct types used as params and
+// results: UnmarshalFastJSON decodes with fastjson, and StreamFastJSON and
+// MarshalFastJSON encode with quicktemplate. ParamsUnmarshal and result
+// encoding use the codecs instead of reflection. Params types also get
+// UnmarshalParams and result types SetResult, with which handlers do not
+// allocate:
+//
+//	//go:generate go run github.com/zc310/fastjsonrpc/cmd/fastjsonrpc-gen -server -type Arith
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	var g generator
+	var types string
+	flag.StringVar(&g.dir, "dir", ".", "package directory to scan")
+	out := flag.String("out", "", `output file, relative to -dir (default "rpc_client.go", or "rpc_server.go" with -server)`)
+	flag.StringVar(&g.pkg, "pkg", "", "package name of the output (default: the scanned package)")
+	flag.StringVar(&g.importPath, "import", "", "import path of the scanned package, required when -pkg differs")
+	flag.StringVar(&g.naming, "naming", "", "default naming strategy: go, snake, camel or kebab (default: the engine default)")
+	flag.StringVar(&types, "type", "", "comma-separated Go types to generate code for (default: all registered)")
+	flag.BoolVar(&g.server, "server", false, "generate static dispatch and params codecs for the -type services")
+	flag.Parse()
+
+	if *out == "" {
+		*out = "rpc_client.go"
+		if g.server {
+			*out = "rpc_server.go"
+		}
+	}
+
+	if types != "" {
+		g.types = strings.Split(types, ",")
+	}
+	g.skip = filepath.Base(*out)
+
+	run := g.run
+	if g.server {
+		run = g.runServer
+	}
+	var b bytes.Buffer
+	if err := run(&b); err != nil {
+		fmt.Fprintln(os.Stderr, "fastjsonrpc-gen:", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(filepath.Join(g.dir, *out), b.Bytes(), 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, "fastjsonrpc-gen:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const exampleDir = "../../example/arith"
+
+func TestGolden(t *testing.T) {
+	g := generator{dir: exampleDir, skip: "rpc_client.go"}
+	var b bytes.Buffer
+	require.NoError(t, g.run(&b))
+
+	want, err := os.ReadFile(exampleDir + "/rpc_client.go")
+	require.NoError(t, err)
+	assert.Equal(t, string(want), b.String(), "run go generate in example/arith")
+}
+
+func TestOtherPackage(t *testing.T) {
+	g := generator{dir: exampleDir, skip: "rpc_client.go", pkg: "arithclient",
+		importPath: "github.com/zc310/fastjsonrpc/example/arith", types: []string{"Arith"}}
+	var b bytes.Buffer
+	require.NoError(t, g.run(&b))
+
+	out := b.String()
+	assert.Contains(t, out, "package arithclient")
+	assert.Contains(t, out, `"github.com/zc310/fastjsonrpc/example/arith"`)
+	assert.Contains(t, out, "params arith.Args) (arith.Quotient, error)")
+	assert.False(t, strings.Contains(out, "CalcClient"))
+
+	g = generator{dir: exampleDir, skip: "rpc_client.go", pkg: "arithclient"}
+	assert.Error(t, g.run(&b))
+}
+
+func TestServerGolden(t *testing.T) {
+	for dir, typ := range map[string]string{exampleDir: "Arith", "internal/shapes": "Service"} {
+		g := generator{dir: dir, skip: "rpc_server.go", server: true, types: []string{typ}}
+		var b bytes.Buffer
+		require.NoError(t, g.runServer(&b))
+
+		want, err := os.ReadFile(dir + "/rpc_server.go")
+		require.NoError(t, err)
+		assert.Equal(t, string(want), b.String(), "run go generate in "+dir)
+	}
+
+	g := generator{dir: exampleDir, skip: "rpc_server.go", server: true}
+	assert.Error(t, g.runServer(new(bytes.Buffer)))
+}
@@ -0,0 +1,494 @@
+package main
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/iancoleman/strcase"
+)
+
+const (
+	rootPath     = "github.com/zc310/fastjsonrpc"
+	clientPath   = "github.com/zc310/fastjsonrpc/client"
+	fastjsonPath = "github.com/valyala/fastjson"
+)
+
+type engine int
+
+const (
+	httpEngine engine = iota // fastjsonrpc.ServerMap
+	wsEngine                 // ws.JSONRPC2
+)
+
+type generator struct {
+	dir        string
+	pkg        string
+	importPath string
+	naming     string
+	types      []string
+	skip       string // file name of the previous output
+	server     bool   // generate server dispatch instead of clients
+
+	fset    *token.FileSet
+	srcPkg  string
+	files   []*ast.File
+	decls   map[string]bool           // package-level type names
+	specs   map[string]*ast.TypeSpec  // package-level type declarations
+	vars    map[string]*ast.ValueSpec // package-level variables
+	methods map[string][]*goMethod    // methods by receiver type name
+	imports map[string]string         // import specs used by the output, by name
+}
+
+type goMethod struct {
+	decl *ast.FuncDecl
+	file *ast.File
+	ptr  bool // pointer receiver
+}
+
+type registration struct {
+	engine  engine
+	rcvr    ast.Expr
+	name    string // RegisterName name or RegisterService prefix
+	hasName bool
+	opts    []ast.Expr
+}
+
+type service struct {
+	client  string
+	typ     string
+	methods []*rpcMethod
+}
+
+type rpcMethod struct {
+	goName      string
+	name        string
+	params      string // empty for a method without params
+	result      string
+	doc         []string
+	deprecated  bool
+	deprecation string
+}
+
+func (g *generator) load() error {
+	g.fset = token.NewFileSet()
+	g.decls = make(map[string]bool)
+	g.specs = make(map[string]*ast.TypeSpec)
+	g.vars = make(map[string]*ast.ValueSpec)
+	g.methods = make(map[string][]*goMethod)
+	g.imports = make(map[string]string)
+
+	names, err := filepath.Glob(filepath.Join(g.dir, "*.go"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		base := filepath.Base(name)
+		if strings.HasSuffix(base, "_test.go") || base == g.skip {
+			continue
+		}
+		src, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		f, err := parser.ParseFile(g.fset, name, src, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		if g.srcPkg == "" {
+			g.srcPkg = f.Name.Name
+		} else if g.srcPkg != f.Name.Name {
+			continue
+		}
+		g.files = append(g.files, f)
+	}
+	if len(g.files) == 0 {
+		return errors.New("no Go files in " + g.dir)
+	}
+
+	for _, f := range g.files {
+		for _, d := range f.Decls {
+			switch d := d.(type) {
+			case *ast.GenDecl:
+				for _, s := range d.Specs {
+					switch s := s.(type) {
+					case *ast.TypeSpec:
+						g.decls[s.Name.Name] = true
+						g.specs[s.Name.Name] = s
+					case *ast.ValueSpec:
+						for _, n := range s.Names {
+							g.vars[n.Name] = s
+						}
+					}
+				}
+			case *ast.FuncDecl:
+				if d.Recv == nil || len(d.Recv.List) != 1 {
+					continue
+				}
+				name, ptr := typeName(d.Recv.List[0].Type)
+				if name != "" {
+					g.methods[name] = append(g.methods[name], &goMethod{decl: d, file: f, ptr: ptr})
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// registrations finds the Register calls of the package.
+func (g *generator) registrations() []*registration {
+	var regs []*registration
+	for _, f := range g.files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			if id, ok := call.Fun.(*ast.Ident); ok {
+				// RegisterT(s, rcvr, opts...) generated by -server.
+				if t := strings.TrimPrefix(id.Name, "Register"); t != id.Name && g.decls[t] && len(call.Args) >= 2 {
+					regs = append(regs, &registration{engine: httpEngine, rcvr: call.Args[1], opts: call.Args[2:]})
+				}
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			a := call.Args
+			var r *registration
+			switch sel.Sel.Name {
+			case "Register", "Replace":
+				if len(a) >= 1 {
+					r = &registration{engine: httpEngine, rcvr: a[0], opts: a[1:]}
+				}
+			case "RegisterName", "ReplaceName":
+				if len(a) >= 2 {
+					if name, ok := stringLit(a[0]); ok {
+						r = &registration{engine: httpEngine, rcvr: a[1], name: name, hasName: true, opts: a[2:]}
+					}
+				}
+			case "RegisterObject":
+				if len(a) >= 1 {
+					r = &registration{engine: wsEngine, rcvr: a[0]}
+					if len(a) >= 2 {
+						r.name, r.hasName = stringLit(a[1])
+					}
+				}
+			case "RegisterService":
+				if len(a) >= 2 {
+					r = &registration{engine: wsEngine, rcvr: a[0], opts: a[2:]}
+					r.name, r.hasName = stringLit(a[1])
+				}
+			}
+			if r != nil {
+				regs = append(regs, r)
+			}
+			return true
+		})
+	}
+	return regs
+}
+
+// resolve returns the type registered by e, such as &T{}, new(T) or a
+// variable of type T or *T.
+func (g *generator) resolve(e ast.Expr) (name string, ptr bool) {
+	switch x := e.(type) {
+	case *ast.ParenExpr:
+		return g.resolve(x.X)
+	case *ast.UnaryExpr:
+		if x.Op == token.AND {
+			name, _ = g.resolve(x.X)
+			return name, true
+		}
+	case *ast.CompositeLit:
+		return typeName(x.Type)
+	case *ast.CallExpr:
+		if id, ok := x.Fun.(*ast.Ident); ok && id.Name == "new" && len(x.Args) == 1 {
+			name, _ = typeName(x.Args[0])
+			return name, true
+		}
+	case *ast.Ident:
+		var decl any
+		if x.Obj != nil {
+			decl = x.Obj.Decl
+		} else if s := g.vars[x.Name]; s != nil {
+			decl = s
+		}
+		switch d := decl.(type) {
+		case *ast.ValueSpec:
+			if d.Type != nil {
+				return typeName(d.Type)
+			}
+			for i, n := range d.Names {
+				if n.Name == x.Name && i < len(d.Values) {
+					return g.resolve(d.Values[i])
+				}
+			}
+		case *ast.AssignStmt:
+			for i, l := range d.Lhs {
+				if id, ok := l.(*ast.Ident); ok && id.Name == x.Name && len(d.Lhs) == len(d.Rhs) {
+					return g.resolve(d.Rhs[i])
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+func typeName(e ast.Expr) (string, bool) {
+	switch x := e.(type) {
+	case *ast.Ident:
+		return x.Name, false
+	case *ast.StarExpr:
+		name, _ := typeName(x.X)
+		return name, true
+	case *ast.ParenExpr:
+		return typeName(x.X)
+	}
+	return "", false
+}
+
+func stringLit(e ast.Expr) (string, bool) {
+	lit, ok := e.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
+
+// regOptions holds the literal options of a registration.
+type regOptions struct {
+	naming      func(string) string
+	separator   string
+	version     string
+	methodNames map[string]string
+	deprecated  bool
+	deprecation string
+}
+
+var namings = map[string]func(string) string{
+	"go":    func(s string) string { return s },
+	"snake": strcase.ToSnake,
+	"camel": strcase.ToLowerCamel,
+	"kebab": strcase.ToKebab,
+
+	"GoCase":    func(s string) string { return s },
+	"SnakeCase": strcase.ToSnake,
+	"CamelCase": strcase.ToLowerCamel,
+	"KebabCase": strcase.ToKebab,
+}
+
+func (g *generator) options(r *registration) *regOptions {
+	o := &regOptions{naming: namings["go"], separator: ".", methodNames: make(map[string]string)}
+	if r.engine == wsEngine {
+		o.naming = namings["snake"]
+	}
+	if n, ok := namings[g.naming]; ok {
+		o.naming = n
+	}
+	for _, e := range r.opts {
+		call, ok := e.(*ast.CallExpr)
+		if !ok {
+			continue
+		}
+		var fn string
+		switch x := call.Fun.(type) {
+		case *ast.SelectorExpr:
+			fn = x.Sel.Name
+		case *ast.Ident:
+			fn = x.Name
+		}
+		var args []string
+		for _, a := range call.Args {
+			s, _ := stringLit(a)
+			args = append(args, s)
+		}
+		switch {
+		case fn == "WithNaming" && len(call.Args) == 1:
+			if sel, ok := call.Args[0].(*ast.SelectorExpr); ok && namings[sel.Sel.Name] != nil {
+				o.naming = namings[sel.Sel.Name]
+			}
+		case fn == "WithSeparator" && len(args) == 1:
+			o.separator = args[0]
+		case fn == "WithVersion" && len(args) == 1:
+			o.version = args[0]
+		case fn == "WithMethodName" && len(args) == 2:
+			o.methodNames[args[0]] = args[1]
+		case fn == "Deprecated":
+			o.deprecated = true
+			if len(args) == 1 {
+				o.deprecation = args[0]
+			}
+		}
+	}
+	return o
+}
+
+func (g *generator) selected(typ string) bool {
+	if len(g.types) == 0 {
+		return true
+	}
+	for _, t := range g.types {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// services builds the services of the package, sorted by client name.
+func (g *generator) services() ([]*service, error) {
+	var out []*service
+	seen := make(map[string]bool)
+	for _, r := range g.registrations() {
+		typ, ptr := g.resolve(r.rcvr)
+		if typ == "" || !g.decls[typ] || !g.selected(typ) {
+			continue
+		}
+		o := g.options(r)
+
+		var prefix, base string
+		switch r.engine {
+		case httpEngine:
+			base = typ
+			if r.hasName {
+				base = r.name
+				prefix = r.name + o.separator
+			} else {
+				prefix = o.naming(typ) + o.separator
+			}
+		case wsEngine:
+			base = typ
+			prefix = o.naming(typ) + "."
+			if r.hasName && r.name != "" {
+				prefix = r.name
+				base = strings.TrimRight(r.name, "._-/:")
+			}
+		}
+
+		s := &service{typ: typ, client: strcase.ToCamel(base) + strcase.ToCamel(o.version) + "Client"}
+		if seen[s.client] {
+			continue
+		}
+		seen[s.client] = true
+
+		for _, m := range g.methods[typ] {
+			if m.ptr && !ptr || !m.decl.Name.IsExported() || !g.suitable(r.engine, m) {
+				continue
+			}
+			goName := m.decl.Name.Name
+			name, ok := o.methodNames[goName]
+			if !ok {
+				name = o.naming(goName)
+			}
+			name = prefix + name
+			if o.version != "" {
+				name += "@" + o.version
+			}
+			rm := &rpcMethod{goName: goName, name: name, deprecated: o.deprecated, deprecation: o.deprecation}
+			if err := g.signature(r.engine, m, rm); err != nil {
+				return nil, err
+			}
+			s.methods = append(s.methods, rm)
+		}
+		if len(s.methods) == 0 {
+			continue
+		}
+		sort.Slice(s.methods, func(i, j int) bool { return s.methods[i].goName < s.methods[j].goName })
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].client < out[j].client })
+	return out, nil
+}
+
+// suitable reports whether m has the handler signature of the engine.
+func (g *generator) suitable(e engine, m *goMethod) bool {
+	t := m.decl.Type
+	params := flatten(t.Params)
+	switch e {
+	case httpEngine:
+		return len(params) == 1 && g.isPointerTo(m.file, params[0], rootPath, "RequestCtx")
+	case wsEngine:
+		if m.decl.Name.Name == "RegisterMethod" || m.decl.Name.Name == "RegisterObject" {
+			return false
+		}
+		results := flatten(t.Results)
+		if len(params) != 2 || len(results) != 2 {
+			return false
+		}
+		id, ok := results[1].(*ast.Ident)
+		return ok && id.Name == "error" &&
+			g.isPointerTo(m.file, params[0], fastjsonPath, "Arena") &&
+			g.isPointerTo(m.file, params[1], fastjsonPath, "Value")
+	}
+	return false
+}
+
+func flatten(l *ast.FieldList) []ast.Expr {
+	if l == nil {
+		return nil
+	}
+	var a []ast.Expr
+	for _, f := range l.List {
+		n := len(f.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			a = append(a, f.Type)
+		}
+	}
+	return a
+}
+
+func (g *generator) isPointerTo(f *ast.File, e ast.Expr, path, name string) bool {
+	star, ok := e.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := star.X.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != name {
+		return false
+	}
+	x, ok := sel.X.(*ast.Ident)
+	return ok && importPath(f, x.Name) == path
+}
+
+// importPath returns the path imported as name by f.
+func importPath(f *ast.File, name string) string {
+	for _, s := range f.Imports {
+		path, _ := strconv.Unquote(s.Path.Value)
+		if s.Name != nil {
+			if s.Name.Name == name {
+				return path
+			}
+			continue
+		}
+		if packageName(path) == name {
+			return path
+		}
+	}
+	return ""
+}
+
+// packageName guesses the package name of path from its last element.
+func packageName(path string) string {
+	base := path[strings.LastIndex(path, "/")+1:]
+	if strings.HasPrefix(base, "v") && len(base) > 1 && strings.Trim(base[1:], "0123456789") == "" {
+		if i := strings.LastIndex(path, "/"); i > 0 {
+			return packageName(path[:i])
+		}
+	}
+	base = strings.TrimPrefix(base, "go-")
+	base = strings.TrimSuffix(base, ".go")
+	return strings.ReplaceAll(base, "-", "_")
+}
@@ -0,0 +1,598 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// kind classifies the types handled by generated codecs.
+type kind int
+
+const (
+	kindOther kind = iota // decoded and encoded with go-json
+	kindString
+	kindBool
+	kindInt
+	kindUint
+	kindFloat32
+	kindFloat64
+	kindStruct // local struct type with generated codecs
+	kindPointer
+	kindSlice
+)
+
+var basicKinds = map[string]kind{
+	"string": kindString, "bool": kindBool,
+	"int": kindInt, "int8": kindInt, "int16": kindInt, "int32": kindInt, "int64": kindInt, "rune": kindInt,
+	"uint": kindUint, "uint8": kindUint, "uint16": kindUint, "uint32": kindUint, "uint64": kindUint, "byte": kindUint, "uintptr": kindUint,
+	"float32": kindFloat32, "float64": kindFloat64,
+}
+
+// codecs holds the local struct types that get generated codecs.
+type codecs map[string]*ast.StructType
+
+func (g *generator) kindOf(c codecs, t ast.Expr) kind {
+	switch x := t.(type) {
+	case *ast.ParenExpr:
+		return g.kindOf(c, x.X)
+	case *ast.Ident:
+		if k, ok := basicKinds[x.Name]; ok {
+			return k
+		}
+		if c[x.Name] != nil {
+			return kindStruct
+		}
+		if s := g.specs[x.Name]; s != nil && s.TypeParams == nil {
+			switch k := g.kindOf(c, s.Type); k {
+			case kindStruct, kindPointer, kindOther:
+			default:
+				return k
+			}
+		}
+	case *ast.StarExpr:
+		if g.kindOf(c, x.X) != kindOther {
+			return kindPointer
+		}
+	case *ast.ArrayType:
+		if x.Len == nil && g.kindOf(c, x.Elt) != kindOther && !isByte(x.Elt) {
+			return kindSlice
+		}
+	}
+	return kindOther
+}
+
+func isByte(t ast.Expr) bool {
+	id, ok := t.(*ast.Ident)
+	return ok && (id.Name == "byte" || id.Name == "uint8")
+}
+
+// elem returns the element type of a pointer or slice type, resolving
+// local named slice types.
+func (g *generator) elem(t ast.Expr) ast.Expr {
+	switch x := t.(type) {
+	case *ast.ParenExpr:
+		return g.elem(x.X)
+	case *ast.StarExpr:
+		return x.X
+	case *ast.ArrayType:
+		return x.Elt
+	case *ast.Ident:
+		if s := g.specs[x.Name]; s != nil {
+			return g.elem(s.Type)
+		}
+	}
+	return nil
+}
+
+type field struct {
+	goName    string
+	name      string
+	typ       ast.Expr
+	omitEmpty bool
+	embedded  bool // embedded struct whose fields are promoted
+}
+
+// fields lists the JSON fields of s, or reports false if s has fields
+// the generated codecs cannot handle.
+func (g *generator) fields(s *ast.StructType) ([]field, bool) {
+	var a []field
+	for _, f := range s.Fields.List {
+		var tag reflect.StructTag
+		if f.Tag != nil {
+			v, _ := strconv.Unquote(f.Tag.Value)
+			tag = reflect.StructTag(v)
+		}
+		name, opts, _ := strings.Cut(tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		if strings.Contains(opts, "string") {
+			return nil, false
+		}
+		omit := strings.Contains(opts, "omitempty")
+
+		if len(f.Names) == 0 {
+			id, ok := f.Type.(*ast.Ident)
+			if !ok {
+				return nil, false
+			}
+			if name == "" {
+				if g.structType(id.Name) == nil {
+					return nil, false
+				}
+				a = append(a, field{goName: id.Name, typ: f.Type, embedded: true})
+				continue
+			}
+			if ast.IsExported(id.Name) {
+				a = append(a, field{goName: id.Name, name: name, typ: f.Type, omitEmpty: omit})
+			}
+			continue
+		}
+		for _, n := range f.Names {
+			if !n.IsExported() {
+				continue
+			}
+			jn := name
+			if jn == "" {
+				jn = n.Name
+			}
+			a = append(a, field{goName: n.Name, name: jn, typ: f.Type, omitEmpty: omit})
+		}
+	}
+	return a, true
+}
+
+func (g *generator) structType(name string) *ast.StructType {
+	if s := g.specs[name]; s != nil && s.TypeParams == nil {
+		st, _ := s.Type.(*ast.StructType)
+		return st
+	}
+	return nil
+}
+
+// hasCustomJSON reports whether the type name defines its own encoding.
+func (g *generator) hasCustomJSON(name string) bool {
+	for _, m := range g.methods[name] {
+		switch m.decl.Name.Name {
+		case "MarshalJSON", "UnmarshalJSON", "MarshalFastJSON", "UnmarshalFastJSON", "MarshalText", "UnmarshalText":
+			return true
+		}
+	}
+	return false
+}
+
+// collect returns the local struct types reachable from roots that can get
+// generated codecs.
+func (g *generator) collect(roots []ast.Expr) codecs {
+	c := make(codecs)
+	var visit func(t ast.Expr)
+	visit = func(t ast.Expr) {
+		switch x := t.(type) {
+		case *ast.ParenExpr:
+			visit(x.X)
+		case *ast.StarExpr:
+			visit(x.X)
+		case *ast.ArrayType:
+			visit(x.Elt)
+		case *ast.Ident:
+			st := g.structType(x.Name)
+			if st == nil {
+				if s := g.specs[x.Name]; s != nil {
+					visit(s.Type)
+				}
+				return
+			}
+			if _, seen := c[x.Name]; seen || g.hasCustomJSON(x.Name) {
+				return
+			}
+			c[x.Name] = st
+			fs, ok := g.fields(st)
+			if !ok {
+				c[x.Name] = nil
+				return
+			}
+			for _, f := range fs {
+				visit(f.typ)
+			}
+		}
+	}
+	for _, t := range roots {
+		visit(t)
+	}
+
+	// Drop types with embedded structs that have no codecs, until stable.
+	for changed := true; changed; {
+		changed = false
+		for name, st := range c {
+			if st == nil {
+				delete(c, name)
+				changed = true
+				continue
+			}
+			fs, _ := g.fields(st)
+			for _, f := range fs {
+				if f.embedded && c[f.goName] == nil {
+					delete(c, name)
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return c
+}
+
+func (g *generator) runServer(w io.Writer) error {
+	if err := g.load(); err != nil {
+		return err
+	}
+	if len(g.types) == 0 {
+		return errors.New("-server requires -type")
+	}
+	g.pkg = g.srcPkg
+
+	var b bytes.Buffer
+	var roots []ast.Expr
+	// struct types handlers decode params into and return, which get
+	// UnmarshalParams and SetResult
+	paramTypes, resultTypes := make(map[string]bool), make(map[string]bool)
+	for _, typ := range g.types {
+		if !g.decls[typ] {
+			return errors.New("type " + typ + " not found in " + g.dir)
+		}
+		var names []string
+		for _, m := range g.methods[typ] {
+			if !m.decl.Name.IsExported() || !g.suitable(httpEngine, m) {
+				continue
+			}
+			names = append(names, m.decl.Name.Name)
+			params, result := g.methodTypes(m)
+			roots = append(roots, params, result)
+			if id, ok := params.(*ast.Ident); ok {
+				paramTypes[id.Name] = true
+			}
+			if id, ok := result.(*ast.Ident); ok {
+				resultTypes[id.Name] = true
+			}
+		}
+		if len(names) == 0 {
+			return errors.New("type " + typ + " has no methods of the form func(*fastjsonrpc.RequestCtx)")
+		}
+		sort.Strings(names)
+
+		fmt.Fprintf(&b, "\n// Register%s registers rcvr like s.Register(rcvr, opts...), dispatching\n", typ)
+		b.WriteString("// through a static method table instead of reflection.\n")
+		fmt.Fprintf(&b, "func Register%s(s *fastjsonrpc.ServerMap, rcvr *%s, opts ...fastjsonrpc.Option) error {\n", typ, typ)
+		fmt.Fprintf(&b, "\treturn s.RegisterMethods(%q, map[string]fastjsonrpc.Handler{\n", typ)
+		for _, n := range names {
+			fmt.Fprintf(&b, "\t\t%q: rcvr.%s,\n", n, n)
+		}
+		b.WriteString("\t}, opts...)\n}\n")
+	}
+
+	c := g.collect(roots)
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	e := &emitter{g: g, c: c, b: &b, imports: make(map[string]bool)}
+	for _, name := range names {
+		e.unmarshaler(name)
+		if paramTypes[name] {
+			e.unmarshalParams(name)
+		}
+		e.marshaler(name)
+		if resultTypes[name] {
+			e.setResult(name)
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString("// Code generated by fastjsonrpc-gen -server. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n\nimport (\n", g.srcPkg)
+	if e.imports["strconv"] {
+		out.WriteString("\t\"strconv\"\n\n")
+	}
+	for _, path := range []string{"github.com/goccy/go-json", "github.com/valyala/bytebufferpool", fastjsonPath, "github.com/valyala/quicktemplate", rootPath} {
+		if e.imports[path] || path == rootPath || path == fastjsonPath && len(names) > 0 {
+			fmt.Fprintf(&out, "\t%q\n", path)
+		}
+	}
+	out.WriteString(")\n")
+	out.Write(b.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return fmt.Errorf("format generated code: %v\n%s", err, out.Bytes())
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// methodTypes returns the params and result types of m, which may be nil.
+func (g *generator) methodTypes(m *goMethod) (params, result ast.Expr) {
+	params, result = inferParams(m.decl), inferResult(m.decl)
+	if m.decl.Doc != nil {
+		for _, c := range m.decl.Doc.List {
+			switch {
+			case strings.HasPrefix(c.Text, paramsDirective):
+				params, _ = parser.ParseExpr(strings.TrimSpace(strings.TrimPrefix(c.Text, paramsDirective)))
+			case strings.HasPrefix(c.Text, resultDirective):
+				result, _ = parser.ParseExpr(strings.TrimSpace(strings.TrimPrefix(c.Text, resultDirective)))
+			}
+		}
+	}
+	return params, result
+}
+
+type emitter struct {
+	g       *generator
+	c       codecs
+	b       *bytes.Buffer
+	n       int
+	imports map[string]bool
+	checked bool // the fields read comma
+}
+
+func (e *emitter) tmp(prefix string) string {
+	e.n++
+	return prefix + strconv.Itoa(e.n)
+}
+
+func (e *emitter) printf(format string, a ...any) { fmt.Fprintf(e.b, format, a...) }
+
+func (e *emitter) typeString(t ast.Expr) string { return e.g.typeString(nil, t) }
+
+func (e *emitter) unmarshaler(name string) {
+	fs, _ := e.g.fields(e.c[name])
+	e.printf("\n// UnmarshalFastJSON decodes v into p without reflection.\n")
+	e.printf("func (p *%s) UnmarshalFastJSON(v *fastjson.Value) error { return p.unmarshalFastJSON(v, %q) }\n\n", name, "params")
+	e.printf("func (p *%s) unmarshalFastJSON(v *fastjson.Value, path string) error {\n", name)
+	e.printf("if v == nil || v.Type() == fastjson.TypeNull {\nreturn nil\n}\n")
+	e.printf("if v.Type() != fastjson.TypeObject {\n%s\n}\n", typeError("path", "object"))
+	for _, f := range fs {
+		if f.embedded {
+			e.printf("if err := p.%s.unmarshalFastJSON(v, path); err != nil {\nreturn err\n}\n", f.goName)
+			continue
+		}
+		src := e.tmp("f")
+		e.printf("if %s := v.Get(%q); %s != nil && %s.Type() != fastjson.TypeNull {\n", src, f.name, src, src)
+		e.decode("p."+f.goName, f.typ, src, "path + "+strconv.Quote("."+f.name))
+		e.printf("}\n")
+	}
+	e.printf("return nil\n}\n")
+}
+
+func typeError(path, what string) string {
+	return fmt.Sprintf("return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: %s, Rule: \"type\", Message: %q})", path, "must be "+what)
+}
+
+// decode emits statements decoding the non-null value src into dst.
+func (e *emitter) decode(dst string, t ast.Expr, src, path string) {
+	k := e.g.kindOf(e.c, t)
+	switch k {
+	case kindString, kindBool, kindInt, kindUint, kindFloat32, kindFloat64:
+		getter, what := map[kind][2]string{
+			kindString: {"StringBytes", "string"}, kindBool: {"Bool", "boolean"},
+			kindInt: {"Int64", "integer"}, kindUint: {"Uint64", "integer"},
+			kindFloat32: {"Float64", "number"}, kindFloat64: {"Float64", "number"},
+		}[k][0], map[kind]string{
+			kindString: "string", kindBool: "boolean", kindInt: "integer", kindUint: "integer",
+			kindFloat32: "number", kindFloat64: "number",
+		}[k]
+		x := e.tmp("x")
+		e.printf("%s, err := %s.%s()\nif err != nil {\n%s\n}\n", x, src, getter, typeError(path, what))
+		if ts := e.typeString(t); ts == map[kind]string{kindBool: "bool", kindInt: "int64", kindUint: "uint64", kindFloat64: "float64"}[k] {
+			e.printf("%s = %s\n", dst, x)
+		} else {
+			e.printf("%s = %s(%s)\n", dst, ts, x)
+		}
+	case kindStruct:
+		e.printf("if err := %s.unmarshalFastJSON(%s, %s); err != nil {\nreturn err\n}\n", dst, src, path)
+	case kindPointer:
+		elem := e.g.elem(t)
+		e.printf("%s = new(%s)\n", dst, e.typeString(elem))
+		e.decode("(*"+dst+")", elem, src, path)
+	case kindSlice:
+		e.imports["strconv"] = true
+		a, i, item := e.tmp("a"), e.tmp("i"), e.tmp("item")
+		e.printf("%s, err := %s.Array()\nif err != nil {\n%s\n}\n", a, src, typeError(path, "array"))
+		e.printf("%s = make(%s, len(%s))\n", dst, e.typeString(t), a)
+		e.printf("for %s, %s := range %s {\nif %s.Type() == fastjson.TypeNull {\ncontinue\n}\n", i, item, a, item)
+		e.printf("{\n")
+		e.decode(dst+"["+i+"]", e.g.elem(t), item, path+` + "[" + strconv.Itoa(`+i+`) + "]"`)
+		e.printf("}\n}\n")
+	default:
+		e.imports["github.com/goccy/go-json"] = true
+		e.printf("if err := json.Unmarshal(%s.MarshalTo(nil), &%s); err != nil {\n%s\n}\n", src, dst, typeError(path, e.typeString(t)))
+	}
+}
+
+func (e *emitter) marshaler(name string) {
+	e.imports["github.com/valyala/bytebufferpool"] = true
+	e.imports["github.com/valyala/quicktemplate"] = true
+
+	e.printf("\n// StreamFastJSON writes the JSON encoding of p to qw without reflection.\n")
+	e.printf("func (p %s) StreamFastJSON(qw *quicktemplate.Writer) {\n", name)
+	e.printf("qw.N().S(`{`)\n")
+	body := e.b
+	var fields bytes.Buffer
+	e.b = &fields
+	sep := sepNone
+	e.fieldsOf("p", name, &sep)
+	e.b = body
+	if e.checked {
+		e.printf("comma := false\n")
+		e.b.Write(fields.Bytes())
+		e.checked = false
+	} else {
+		// comma is never read, drop its assignments
+		e.b.Write(bytes.ReplaceAll(fields.Bytes(), []byte(commaSet), nil))
+	}
+	e.printf("qw.N().S(`}`)\n}\n")
+
+	e.printf("\n// MarshalFastJSON appends the JSON encoding of p to dst without reflection.\n")
+	e.printf("func (p %s) MarshalFastJSON(dst []byte) []byte {\n", name)
+	e.printf("b := bytebufferpool.Get()\nqw := quicktemplate.AcquireWriter(b)\np.StreamFastJSON(qw)\nquicktemplate.ReleaseWriter(qw)\n")
+	e.printf("dst = append(dst, b.B...)\nbytebufferpool.Put(b)\nreturn dst\n}\n")
+}
+
+// unmarshalParams emits the params decoder of handlers, which avoids the
+// allocations of ParamsUnmarshal.
+func (e *emitter) unmarshalParams(name string) {
+	e.printf("\n// UnmarshalParams decodes the params of c into p like c.ParamsUnmarshal(p),\n")
+	e.printf("// without allocating unless the method validates its params.\n")
+	e.printf("func (p *%s) UnmarshalParams(c *fastjsonrpc.RequestCtx) error {\n", name)
+	e.printf("if v, ok := c.FastParams(); ok {\nreturn p.UnmarshalFastJSON(v)\n}\n")
+	e.printf("q := new(%s)\nerr := c.ParamsUnmarshal(q)\n*p = *q\nreturn err\n}\n", name)
+}
+
+// setResult emits the result setter of handlers, which avoids the
+// allocation of storing p in c.Result.
+func (e *emitter) setResult(name string) {
+	e.printf("\n// SetResult sets p as the result of c, encoded into the result buffer of c\n")
+	e.printf("// without allocating.\n")
+	e.printf("func (p %s) SetResult(c *fastjsonrpc.RequestCtx) {\n", name)
+	e.printf("c.SetRawResult(p.MarshalFastJSON(c.ResultBuffer()))\n}\n")
+}
+
+// rawString returns s as a Go string literal, raw when possible like the
+// literals of qtc.
+func rawString(s string) string {
+	if strings.ContainsAny(s, "`\r") {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
+
+// commaSet records that a field was written, for the fields that follow.
+const commaSet = "comma = true\n"
+
+// Separator states while encoding the fields of an object.
+const (
+	sepNone    = iota // no field written yet
+	sepAlways         // a field was written
+	sepRuntime        // fields may have been written, check at run time
+)
+
+// fieldsOf emits the fields of the struct src of type name.
+func (e *emitter) fieldsOf(src, name string, sep *int) {
+	fs, _ := e.g.fields(e.c[name])
+	for _, f := range fs {
+		v := src + "." + f.goName
+		if f.embedded {
+			e.fieldsOf(v, f.goName, sep)
+			continue
+		}
+		cond := ""
+		if f.omitEmpty {
+			cond = e.nonEmpty(v, f.typ)
+		}
+		if cond != "" {
+			e.printf("if %s {\n", cond)
+		}
+		key := strconv.Quote(f.name) + ":"
+		switch *sep {
+		case sepAlways:
+			key = "," + key
+		case sepRuntime:
+			e.checked = true
+			e.printf("if comma {\nqw.N().S(`,`)\n}\n")
+		}
+		e.printf("qw.N().S(%s)\n", rawString(key))
+		e.encode(v, f.typ)
+		if cond != "" {
+			if *sep != sepAlways {
+				e.printf(commaSet)
+				*sep = sepRuntime
+			}
+			e.printf("}\n")
+		} else {
+			*sep = sepAlways
+		}
+	}
+}
+
+// nonEmpty returns the condition under which an omitempty field is
+// encoded, or "" if it always is.
+func (e *emitter) nonEmpty(v string, t ast.Expr) string {
+	switch e.g.kindOf(e.c, t) {
+	case kindString:
+		return v + ` != ""`
+	case kindBool:
+		return v
+	case kindInt, kindUint, kindFloat32, kindFloat64:
+		return v + " != 0"
+	case kindPointer:
+		return v + " != nil"
+	case kindSlice:
+		return "len(" + v + ") > 0"
+	}
+	switch e.g.underlying(t).(type) {
+	case *ast.MapType, *ast.ArrayType:
+		return "len(" + v + ") > 0"
+	case *ast.StarExpr, *ast.InterfaceType:
+		return v + " != nil"
+	}
+	return ""
+}
+
+// underlying resolves parentheses and local named types in t.
+func (g *generator) underlying(t ast.Expr) ast.Expr {
+	for {
+		switch x := t.(type) {
+		case *ast.ParenExpr:
+			t = x.X
+		case *ast.Ident:
+			s := g.specs[x.Name]
+			if s == nil || s.TypeParams != nil || s.Type == t {
+				return t
+			}
+			t = s.Type
+		default:
+			return t
+		}
+	}
+}
+
+// encode emits statements writing the JSON encoding of src to qw.
+func (e *emitter) encode(src string, t ast.Expr) {
+	switch e.g.kindOf(e.c, t) {
+	case kindString:
+		e.printf("qw.N().Q(string(%s))\n", src)
+	case kindBool:
+		e.printf("if %s {\nqw.N().S(`true`)\n} else {\nqw.N().S(`false`)\n}\n", src)
+	case kindInt:
+		e.printf("qw.N().DL(int64(%s))\n", src)
+	case kindUint:
+		e.printf("qw.N().DUL(uint64(%s))\n", src)
+	case kindFloat32, kindFloat64:
+		e.printf("qw.N().F(float64(%s))\n", src)
+	case kindStruct:
+		e.printf("%s.StreamFastJSON(qw)\n", src)
+	case kindPointer:
+		e.printf("if %s == nil {\nqw.N().S(`null`)\n} else {\n", src)
+		e.encode("(*"+src+")", e.g.elem(t))
+		e.printf("}\n")
+	case kindSlice:
+		i, x := e.tmp("i"), e.tmp("x")
+		e.printf("if %s == nil {\nqw.N().S(`null`)\n} else {\nqw.N().S(`[`)\n", src)
+		e.printf("for %s, %s := range %s {\nif %s > 0 {\nqw.N().S(`,`)\n}\n", i, x, src, i)
+		e.encode(x, e.g.elem(t))
+		e.printf("}\nqw.N().S(`]`)\n}\n")
+	default:
+		e.imports["github.com/goccy/go-json"] = true
+		b := e.tmp("b")
+		e.printf("if %s, err := json.Marshal(%s); err == nil {\nqw.N().Z(%s)\n} else {\nqw.N().S(`null`)\n}\n", b, src, b)
+	}
+}
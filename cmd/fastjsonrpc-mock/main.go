@@ -0,0 +1,70 @@
+// Command fastjsonrpc-mock serves an OpenRPC document with synthetic
+// results (see package openrpc/mock):
+//
+//	fastjsonrpc-mock -in openrpc.json -addr :8080
+//
+// The same address answers JSON-RPC over HTTP POST, with CORS headers for
+// browsers, and over WebSocket.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/fasthttp/websocket"
+	"github.com/valyala/fasthttp"
+	"github.com/zc310/fastjsonrpc/openrpc"
+	"github.com/zc310/fastjsonrpc/openrpc/mock"
+	"github.com/zc310/fastjsonrpc/ws"
+)
+
+func main() {
+	in := flag.String("in", "", `OpenRPC document file, "-" for stdin`)
+	addr := flag.String("addr", ":8080", "listen address")
+	flag.Parse()
+
+	h, err := handler(*in)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fastjsonrpc-mock:", err)
+		os.Exit(1)
+	}
+	log.Printf("serving %s on %s", *in, *addr)
+	log.Fatal(fasthttp.ListenAndServe(*addr, h))
+}
+
+func handler(in string) (fasthttp.RequestHandler, error) {
+	if in == "" {
+		return nil, fmt.Errorf("-in is required")
+	}
+	var b []byte
+	var err error
+	if in == "-" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(in)
+	}
+	if err != nil {
+		return nil, err
+	}
+	d, err := openrpc.Parse(b)
+	if err != nil {
+		return nil, err
+	}
+
+	j := ws.NewJSONRPC2()
+	if err := mock.RegisterWS(j, d); err != nil {
+		return nil, err
+	}
+	wsHandler := ws.Handler(j, &websocket.FastHTTPUpgrader{CheckOrigin: func(*fasthttp.RequestCtx) bool { return true }})
+	httpHandler := ws.HandlerWithCORS(j)
+	return func(ctx *fasthttp.RequestCtx) {
+		if websocket.FastHTTPIsWebSocketUpgrade(ctx) {
+			wsHandler(ctx)
+			return
+		}
+		httpHandler(ctx)
+	}, nil
+}
@@ -0,0 +1,83 @@
+// Command fastjsonrpc-replay replays a recording made with package record
+// against a running server and prints the responses that differ from the
+// recorded ones:
+//
+//	fastjsonrpc-replay -in traffic.jsonl -url http://localhost:8080/rpc
+//	fastjsonrpc-replay -in traffic.jsonl -url ws://localhost:8080/ws -ignore result.time,error.data
+//
+// Requests are sent one at a time in recorded order. HTTP requests carry
+// the recorded headers that were not redacted. It exits with status 1 if
+// any response differs.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/zc310/fastjsonrpc/client"
+	"github.com/zc310/fastjsonrpc/record"
+)
+
+func main() {
+	in := flag.String("in", "-", `recording file, "-" for stdin`)
+	url := flag.String("url", "", "server endpoint (http, https, ws or wss)")
+	ignore := flag.String("ignore", "", "comma-separated response paths left out of the comparison")
+	timeout := flag.Duration("timeout", time.Minute, "overall replay timeout")
+	flag.Parse()
+
+	ok, err := run(*in, *url, *ignore, *timeout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fastjsonrpc-replay:", err)
+		os.Exit(2)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+func run(in, url, ignore string, timeout time.Duration) (bool, error) {
+	if url == "" {
+		return false, fmt.Errorf("-url is required")
+	}
+	var r io.Reader = os.Stdin
+	if in != "-" {
+		f, err := os.Open(in)
+		if err != nil {
+			return false, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var paths []string
+	if ignore != "" {
+		paths = strings.Split(ignore, ",")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	target := record.HTTP(url, nil)
+	if strings.HasPrefix(url, "ws://") || strings.HasPrefix(url, "wss://") {
+		t, err := client.DialWebSocket(ctx, url)
+		if err != nil {
+			return false, err
+		}
+		defer t.Close()
+		target = record.Transport(t)
+	}
+
+	report, err := record.Replay(ctx, r, target, paths...)
+	if err != nil {
+		return false, err
+	}
+	for _, m := range report.Mismatches {
+		fmt.Println(m)
+	}
+	fmt.Printf("%d entries, %d mismatches\n", report.Entries, len(report.Mismatches))
+	return report.OK(), nil
+}
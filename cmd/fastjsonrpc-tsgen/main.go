@@ -0,0 +1,106 @@
+// Command fastjsonrpc-tsgen generates a TypeScript client from an OpenRPC
+// document.
+//
+// The document is read from a file, or fetched from a running server by
+// calling rpc.discover (see package openrpc) over HTTP or WebSocket:
+//
+//	fastjsonrpc-tsgen -in openrpc.json -out client.ts
+//	fastjsonrpc-tsgen -url http://localhost:8080/rpc -out client.ts
+//	fastjsonrpc-tsgen -url ws://localhost:8080/ws -out client.ts
+//
+// Programs that hold the registered services can produce the document
+// directly with openrpc.New and call typescript.Generate.
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/zc310/fastjsonrpc/client"
+	"github.com/zc310/fastjsonrpc/openrpc"
+	"github.com/zc310/fastjsonrpc/openrpc/typescript"
+)
+
+func main() {
+	in := flag.String("in", "", `OpenRPC document file, "-" for stdin`)
+	url := flag.String("url", "", "server endpoint to call rpc.discover on (http, https, ws or wss)")
+	out := flag.String("out", "", "output file (default stdout)")
+	timeout := flag.Duration("timeout", 10*time.Second, "rpc.discover timeout")
+	flag.Parse()
+
+	if err := run(*in, *url, *out, *timeout); err != nil {
+		fmt.Fprintln(os.Stderr, "fastjsonrpc-tsgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(in, url, out string, timeout time.Duration) error {
+	var d *openrpc.Document
+	var err error
+	switch {
+	case in != "" && url != "":
+		return fmt.Errorf("-in and -url are exclusive")
+	case in != "":
+		d, err = readDocument(in)
+	case url != "":
+		d, err = discover(url, timeout)
+	default:
+		return fmt.Errorf("one of -in or -url is required")
+	}
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	if err = typescript.Generate(&b, d); err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(b.Bytes())
+		return err
+	}
+	return os.WriteFile(out, b.Bytes(), 0o644)
+}
+
+func readDocument(name string) (*openrpc.Document, error) {
+	var b []byte
+	var err error
+	if name == "-" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return openrpc.Parse(b)
+}
+
+func discover(url string, timeout time.Duration) (*openrpc.Document, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var c *client.Client
+	if strings.HasPrefix(url, "ws://") || strings.HasPrefix(url, "wss://") {
+		t, err := client.DialWebSocket(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		c = client.New(t)
+	} else {
+		c = client.NewHTTP(url)
+	}
+	defer c.Close()
+
+	d := new(openrpc.Document)
+	if err := c.Call(ctx, openrpc.DiscoverMethod, nil, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/valyala/fastjson"
+	"github.com/zc310/fastjsonrpc/client"
+	"github.com/zc310/fastjsonrpc/internal/jsontime"
+)
+
+// Call is one entry of the request mix. Calls are drawn in proportion to
+// their Weight, 1 when unset.
+type Call struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Weight int             `json:"weight,omitempty"`
+}
+
+// Scenario describes a load test. Saved as JSON, it reproduces a run:
+// the same seed draws the same sequence of calls on every worker.
+type Scenario struct {
+	Calls       []Call            `json:"calls"`
+	Concurrency int               `json:"concurrency,omitempty"`
+	Duration    jsontime.Duration `json:"duration,omitempty"`
+	// Requests stops the run after this many requests when positive.
+	Requests int `json:"requests,omitempty"`
+	// Rate is the target of requests per second across all workers.
+	// Zero runs closed-loop: each worker sends as soon as the previous
+	// response arrives.
+	Rate float64 `json:"rate,omitempty"`
+	// Batch is the number of calls per request; 1 sends single requests.
+	Batch int `json:"batch,omitempty"`
+	// Notify is the ratio of calls, from 0 to 1, sent as notifications.
+	Notify  float64           `json:"notify,omitempty"`
+	Timeout jsontime.Duration `json:"timeout,omitempty"`
+	Seed    uint64            `json:"seed,omitempty"`
+}
+
+func (p *Scenario) validate() error {
+	if len(p.Calls) == 0 {
+		return errors.New("scenario has no calls")
+	}
+	for _, c := range p.Calls {
+		if c.Method == "" {
+			return errors.New("call without method")
+		}
+		if c.Weight < 0 {
+			return errors.New(c.Method + ": negative weight")
+		}
+		if len(c.Params) > 0 && !json.Valid(c.Params) {
+			return errors.New(c.Method + ": params are not valid JSON")
+		}
+	}
+	if p.Concurrency < 1 {
+		p.Concurrency = 1
+	}
+	if p.Batch < 1 {
+		p.Batch = 1
+	}
+	if p.Notify < 0 || p.Notify > 1 {
+		return errors.New("notify ratio must be between 0 and 1")
+	}
+	if p.Duration <= 0 && p.Requests <= 0 {
+		return errors.New("scenario needs a duration or a number of requests")
+	}
+	if p.Timeout <= 0 {
+		p.Timeout = jsontime.Duration(10 * time.Second)
+	}
+	return nil
+}
+
+// Dialer opens the transport of one worker.
+type Dialer func(ctx context.Context) (client.Transport, error)
+
+// Run executes s with transports from dial, one per worker.
+func Run(ctx context.Context, s Scenario, dial Dialer) (*Result, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	if s.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(s.Duration))
+		defer cancel()
+	}
+
+	transports := make([]client.Transport, s.Concurrency)
+	for i := range transports {
+		t, err := dial(ctx)
+		if err != nil {
+			for _, t := range transports[:i] {
+				_ = t.Close()
+			}
+			return nil, err
+		}
+		transports[i] = t
+	}
+
+	var total int
+	for _, c := range s.Calls {
+		total += max(c.Weight, 1)
+	}
+
+	var (
+		wg      sync.WaitGroup
+		results = make([]*Result, s.Concurrency)
+	)
+	start := time.Now()
+	for i := range transports {
+		w := &worker{
+			s:     &s,
+			t:     transports[i],
+			total: total,
+			rnd:   rand.New(rand.NewPCG(s.Seed, uint64(i))),
+			res:   newResult(),
+		}
+		if s.Rate > 0 {
+			// Workers share the rate and are spread evenly over one
+			// interval.
+			w.interval = time.Duration(float64(time.Second) * float64(s.Concurrency) / s.Rate)
+			w.next = start.Add(w.interval * time.Duration(i) / time.Duration(s.Concurrency))
+		}
+		// Requests are split evenly, so that every worker sends the
+		// same calls on each run.
+		n := -1
+		if s.Requests > 0 {
+			n = s.Requests / s.Concurrency
+			if i < s.Requests%s.Concurrency {
+				n++
+			}
+		}
+		results[i] = w.res
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer w.t.Close()
+			for ; n != 0 && ctx.Err() == nil; n-- {
+				w.request(ctx)
+			}
+		}()
+	}
+	wg.Wait()
+
+	r := newResult()
+	for _, w := range results {
+		r.merge(w)
+	}
+	r.Elapsed = time.Since(start)
+	r.finish()
+	return r, nil
+}
+
+type worker struct {
+	s     *Scenario
+	t     client.Transport
+	total int
+	rnd   *rand.Rand
+	id    uint64
+	buf   bytes.Buffer
+	pr    fastjson.Parser
+	res   *Result
+
+	interval time.Duration
+	next     time.Time
+}
+
+func (w *worker) pick() *Call {
+	n := w.rnd.IntN(w.total)
+	for i := range w.s.Calls {
+		c := &w.s.Calls[i]
+		if n -= max(c.Weight, 1); n < 0 {
+			return c
+		}
+	}
+	return &w.s.Calls[len(w.s.Calls)-1]
+}
+
+// encode writes the next request and returns the number of calls that
+// expect a response.
+func (w *worker) encode() int {
+	w.buf.Reset()
+	if w.s.Batch > 1 {
+		w.buf.WriteByte('[')
+	}
+	var expect int
+	for i := 0; i < w.s.Batch; i++ {
+		if i > 0 {
+			w.buf.WriteByte(',')
+		}
+		c := w.pick()
+		w.buf.WriteString(`{"jsonrpc":"2.0","method":`)
+		m, _ := json.Marshal(c.Method)
+		w.buf.Write(m)
+		if len(c.Params) > 0 {
+			w.buf.WriteString(`,"params":`)
+			w.buf.Write(c.Params)
+		}
+		if w.s.Notify == 0 || w.rnd.Float64() >= w.s.Notify {
+			w.id++
+			expect++
+			w.buf.WriteString(`,"id":`)
+			w.buf.WriteString(strconv.FormatUint(w.id, 10))
+		}
+		w.buf.WriteByte('}')
+	}
+	if w.s.Batch > 1 {
+		w.buf.WriteByte(']')
+	}
+	return expect
+}
+
+func (w *worker) request(ctx context.Context) {
+	expect := w.encode()
+
+	begin := time.Now()
+	if w.interval > 0 {
+		// Latency is measured from the scheduled time, so a slow server
+		// is not hidden by requests that were sent late.
+		if d := time.Until(w.next); d > 0 {
+			select {
+			case <-time.After(d):
+			case <-ctx.Done():
+				return
+			}
+		}
+		begin = w.next
+		w.next = w.next.Add(w.interval)
+	}
+
+	rctx, cancel := context.WithTimeout(context.Background(), time.Duration(w.s.Timeout))
+	resp, err := w.t.RoundTrip(rctx, w.buf.Bytes())
+	cancel()
+	w.res.Requests++
+	w.res.Calls += w.s.Batch
+	w.res.Latencies = append(w.res.Latencies, time.Since(begin))
+	if err != nil {
+		w.res.Failures[err.Error()]++
+		return
+	}
+	w.check(resp, expect)
+}
+
+// check counts the errors of a response.
+func (w *worker) check(resp []byte, expect int) {
+	if expect == 0 {
+		return
+	}
+	v, err := w.pr.ParseBytes(resp)
+	if err != nil {
+		w.res.Failures["invalid response"]++
+		return
+	}
+	items := []*fastjson.Value{v}
+	if v.Type() == fastjson.TypeArray {
+		items, _ = v.Array()
+	}
+	if len(items) != expect {
+		w.res.Failures["unexpected number of responses"]++
+	}
+	for _, item := range items {
+		if e := item.Get("error"); e != nil {
+			w.res.Errors[e.GetInt("code")]++
+		}
+	}
+}
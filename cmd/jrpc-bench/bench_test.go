@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+	"github.com/zc310/fastjsonrpc"
+	"github.com/zc310/fastjsonrpc/client"
+	"github.com/zc310/fastjsonrpc/internal/jsontime"
+)
+
+// serve returns a dialer for an in-memory server and the number of
+// calls of each method it received.
+func serve(t *testing.T) (Dialer, func() map[string]int) {
+	var (
+		mu     sync.Mutex
+		counts = make(map[string]int)
+	)
+	var s fastjsonrpc.ServerMap
+	for _, m := range []string{"a", "b"} {
+		s.RegisterHandler(m, func(c *fastjsonrpc.RequestCtx) {
+			mu.Lock()
+			counts[string(c.Method)]++
+			mu.Unlock()
+			c.Result = true
+		})
+	}
+
+	ln := fasthttputil.NewInmemoryListener()
+	go func() { _ = fasthttp.Serve(ln, s.Handler) }()
+	t.Cleanup(func() { _ = ln.Close() })
+	c := &fasthttp.Client{Dial: func(string) (net.Conn, error) { return ln.Dial() }}
+	dial := func(context.Context) (client.Transport, error) {
+		return &client.HTTPTransport{URL: "http://bench/", Client: c}, nil
+	}
+	return dial, func() map[string]int {
+		mu.Lock()
+		defer mu.Unlock()
+		m := make(map[string]int, len(counts))
+		for k, v := range counts {
+			m[k] = v
+		}
+		clear(counts)
+		return m
+	}
+}
+
+func TestRun(t *testing.T) {
+	dial, counts := serve(t)
+	s := Scenario{
+		Calls: []Call{
+			{Method: "a", Params: json.RawMessage(`{"x":1}`), Weight: 3},
+			{Method: "b"},
+			{Method: "missing"},
+		},
+		Concurrency: 4,
+		Requests:    100,
+		Batch:       3,
+		Notify:      0.25,
+		Seed:        7,
+	}
+
+	r, err := Run(context.Background(), s, dial)
+	require.NoError(t, err)
+	assert.Equal(t, 100, r.Requests)
+	assert.Equal(t, 300, r.Calls)
+	assert.Len(t, r.Latencies, 100)
+	assert.Empty(t, r.Failures)
+	assert.NotZero(t, r.Errors[-32601])
+	assert.LessOrEqual(t, r.Latency.P50, r.Latency.P99)
+	assert.LessOrEqual(t, r.Latency.P99, r.Latency.Max)
+
+	first := counts()
+	assert.Greater(t, first["a"], first["b"])
+
+	// The same seed replays the same mix.
+	r2, err := Run(context.Background(), s, dial)
+	require.NoError(t, err)
+	assert.Equal(t, r.Errors, r2.Errors)
+	assert.Equal(t, first, counts())
+}
+
+func TestRunRate(t *testing.T) {
+	dial, _ := serve(t)
+	s := Scenario{
+		Calls:       []Call{{Method: "a"}},
+		Concurrency: 2,
+		Duration:    jsontime.Duration(300 * time.Millisecond),
+		Rate:        100,
+	}
+	r, err := Run(context.Background(), s, dial)
+	require.NoError(t, err)
+	assert.InDelta(t, 30, r.Requests, 10)
+	assert.Empty(t, r.Errors)
+}
+
+func TestScenarioJSON(t *testing.T) {
+	var s Scenario
+	require.NoError(t, json.Unmarshal([]byte(`{"calls":[{"method":"a"}],"duration":"1m","rate":50}`), &s))
+	assert.Equal(t, jsontime.Duration(time.Minute), s.Duration)
+	require.NoError(t, s.validate())
+	assert.Equal(t, 1, s.Concurrency)
+	assert.Equal(t, 1, s.Batch)
+
+	for _, bad := range []Scenario{
+		{},
+		{Calls: []Call{{Method: "a"}}},
+		{Calls: []Call{{Method: "a", Params: json.RawMessage("{")}}, Requests: 1},
+		{Calls: []Call{{Method: "a"}}, Requests: 1, Notify: 2},
+	} {
+		assert.Error(t, bad.validate())
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	r := newResult()
+	for i := 1; i <= 1000; i++ {
+		r.Latencies = append(r.Latencies, time.Duration(i))
+	}
+	r.finish()
+	assert.Equal(t, time.Duration(500), r.Latency.P50)
+	assert.Equal(t, time.Duration(990), r.Latency.P99)
+	assert.Equal(t, time.Duration(999), r.Latency.P999)
+	assert.Equal(t, time.Duration(1000), r.Latency.Max)
+}
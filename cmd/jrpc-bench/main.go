@@ -0,0 +1,183 @@
+// Command jrpc-bench load-tests a JSON-RPC server over HTTP or WebSocket
+// and reports throughput, latency percentiles and error counts:
+//
+//	jrpc-bench -c 50 -d 30s -method Arith.Add -params '{"A":1,"B":2}' http://localhost:8080/rpc
+//	jrpc-bench -rate 2000 -batch 10 -notify 0.2 -method ping ws://localhost:8080/ws
+//	jrpc-bench -scenario checkout.json -json http://localhost:8080/rpc
+//
+// A scenario file holds a Scenario as JSON, with a weighted mix of calls:
+//
+//	{
+//	  "calls": [
+//	    {"method": "cart.get", "params": {"id": 1}, "weight": 8},
+//	    {"method": "cart.checkout", "params": {"id": 1}, "weight": 1}
+//	  ],
+//	  "concurrency": 50, "duration": "1m", "rate": 5000, "seed": 1
+//	}
+//
+// Flags given on the command line override the file. Runs with the same
+// scenario and seed send the same sequence of calls.
+//
+// Without -rate, each worker sends its next request when the previous
+// response arrives. With -rate, requests are sent on a fixed schedule and
+// latency is measured from the scheduled time, so it includes the time a
+// request waited for a busy worker. With -json the report is written as
+// JSON, with durations in nanoseconds.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/zc310/fastjsonrpc/client"
+	"github.com/zc310/fastjsonrpc/internal/jsontime"
+)
+
+type headers []string
+
+func (p *headers) String() string     { return strings.Join(*p, ", ") }
+func (p *headers) Set(v string) error { *p = append(*p, v); return nil }
+
+func main() {
+	var (
+		s        Scenario
+		header   headers
+		duration time.Duration
+		timeout  time.Duration
+	)
+	scenario := flag.String("scenario", "", "scenario file")
+	method := flag.String("method", "", "method to call, instead of the calls of the scenario")
+	params := flag.String("params", "", "params of -method, as JSON")
+	asJSON := flag.Bool("json", false, "write the report as JSON")
+	flag.IntVar(&s.Concurrency, "c", 10, "concurrent workers, each with its own connection")
+	flag.DurationVar(&duration, "d", 10*time.Second, "duration of the run")
+	flag.IntVar(&s.Requests, "n", 0, "stop after this many requests")
+	flag.Float64Var(&s.Rate, "rate", 0, "requests per second across workers; 0 sends as fast as responses arrive")
+	flag.IntVar(&s.Batch, "batch", 1, "calls per request")
+	flag.Float64Var(&s.Notify, "notify", 0, "ratio of calls sent as notifications, from 0 to 1")
+	flag.DurationVar(&timeout, "timeout", 10*time.Second, "request timeout")
+	flag.Uint64Var(&s.Seed, "seed", 1, "seed of the call mix")
+	flag.Var(&header, "H", `request header "Name: value", repeatable`)
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jrpc-bench [flags] endpoint")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	s.Duration, s.Timeout = jsontime.Duration(duration), jsontime.Duration(timeout)
+
+	if *scenario != "" {
+		b, err := os.ReadFile(*scenario)
+		if err != nil {
+			fatal(err)
+		}
+		var f Scenario
+		if err := json.Unmarshal(b, &f); err != nil {
+			fatal(fmt.Errorf("%s: %w", *scenario, err))
+		}
+		s = override(f, s)
+	}
+	if *method != "" {
+		c := Call{Method: *method}
+		if *params != "" {
+			c.Params = json.RawMessage(*params)
+		}
+		s.Calls = []Call{c}
+	}
+	if s.Requests > 0 && *scenario == "" && !flagSet("d") {
+		// -n alone runs until the requests are sent.
+		s.Duration = 0
+	}
+
+	dial, err := dialer(flag.Arg(0), header)
+	if err != nil {
+		fatal(err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	r, err := Run(ctx, s, dial)
+	if err != nil {
+		fatal(err)
+	}
+	if *asJSON {
+		b, _ := json.MarshalIndent(r, "", "  ")
+		fmt.Println(string(b))
+		return
+	}
+	r.Print(os.Stdout)
+}
+
+// override returns the scenario f with the flags set on the command line
+// taken from s.
+func override(f, s Scenario) Scenario {
+	flag.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "c":
+			f.Concurrency = s.Concurrency
+		case "d":
+			f.Duration = s.Duration
+		case "n":
+			f.Requests = s.Requests
+		case "rate":
+			f.Rate = s.Rate
+		case "batch":
+			f.Batch = s.Batch
+		case "notify":
+			f.Notify = s.Notify
+		case "timeout":
+			f.Timeout = s.Timeout
+		case "seed":
+			f.Seed = s.Seed
+		}
+	})
+	return f
+}
+
+func flagSet(name string) (set bool) {
+	flag.Visit(func(f *flag.Flag) { set = set || f.Name == name })
+	return set
+}
+
+// dialer returns a Dialer for an http(s) or ws(s) endpoint.
+func dialer(endpoint string, header headers) (Dialer, error) {
+	h := make(map[string]string, len(header))
+	for _, kv := range header {
+		k, v, ok := strings.Cut(kv, ":")
+		if !ok {
+			return nil, errors.New(`header must be "Name: value": ` + kv)
+		}
+		h[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+
+	switch {
+	case strings.HasPrefix(endpoint, "http://"), strings.HasPrefix(endpoint, "https://"):
+		return func(context.Context) (client.Transport, error) {
+			return &client.HTTPTransport{URL: endpoint, Header: h}, nil
+		}, nil
+	case strings.HasPrefix(endpoint, "ws://"), strings.HasPrefix(endpoint, "wss://"):
+		hh := make(http.Header, len(h))
+		for k, v := range h {
+			hh.Set(k, v)
+		}
+		return func(ctx context.Context) (client.Transport, error) {
+			return client.DialWebSocket(ctx, endpoint, client.WithHeader(hh))
+		}, nil
+	}
+	return nil, errors.New("endpoint must start with http://, https://, ws:// or wss://")
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "jrpc-bench:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Result summarizes a run.
+type Result struct {
+	Requests int           `json:"requests"`
+	Calls    int           `json:"calls"`
+	Elapsed  time.Duration `json:"elapsed"`
+	// Errors counts error responses by code.
+	Errors map[int]int `json:"errors,omitempty"`
+	// Failures counts requests without a usable response by reason.
+	Failures map[string]int `json:"failures,omitempty"`
+	Latency  Latency        `json:"latency"`
+
+	// Latencies are the sorted round-trip times of all requests.
+	Latencies []time.Duration `json:"-"`
+}
+
+// Latency holds round-trip percentiles.
+type Latency struct {
+	Mean time.Duration `json:"mean"`
+	P50  time.Duration `json:"p50"`
+	P90  time.Duration `json:"p90"`
+	P99  time.Duration `json:"p99"`
+	P999 time.Duration `json:"p999"`
+	Max  time.Duration `json:"max"`
+}
+
+func newResult() *Result {
+	return &Result{Errors: make(map[int]int), Failures: make(map[string]int)}
+}
+
+func (p *Result) merge(r *Result) {
+	p.Requests += r.Requests
+	p.Calls += r.Calls
+	p.Latencies = append(p.Latencies, r.Latencies...)
+	for k, v := range r.Errors {
+		p.Errors[k] += v
+	}
+	for k, v := range r.Failures {
+		p.Failures[k] += v
+	}
+}
+
+func (p *Result) finish() {
+	slices.Sort(p.Latencies)
+	if len(p.Latencies) == 0 {
+		return
+	}
+	var sum time.Duration
+	for _, d := range p.Latencies {
+		sum += d
+	}
+	p.Latency = Latency{
+		Mean: sum / time.Duration(len(p.Latencies)),
+		P50:  p.Percentile(50),
+		P90:  p.Percentile(90),
+		P99:  p.Percentile(99),
+		P999: p.Percentile(99.9),
+		Max:  p.Latencies[len(p.Latencies)-1],
+	}
+}
+
+// Percentile returns the latency below which q percent of requests
+// completed.
+func (p *Result) Percentile(q float64) time.Duration {
+	if len(p.Latencies) == 0 {
+		return 0
+	}
+	i := int(float64(len(p.Latencies))*q/100+0.5) - 1
+	return p.Latencies[min(max(i, 0), len(p.Latencies)-1)]
+}
+
+// Throughput returns the requests per second.
+func (p *Result) Throughput() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.Requests) / p.Elapsed.Seconds()
+}
+
+// Print writes a human-readable report.
+func (p *Result) Print(w io.Writer) {
+	fmt.Fprintf(w, "requests  %d in %s, %.1f/s, %d calls\n",
+		p.Requests, p.Elapsed.Round(time.Millisecond), p.Throughput(), p.Calls)
+	l := p.Latency
+	fmt.Fprintf(w, "latency   mean %s  p50 %s  p90 %s  p99 %s  p99.9 %s  max %s\n",
+		l.Mean, l.P50, l.P90, l.P99, l.P999, l.Max)
+
+	codes := make([]int, 0, len(p.Errors))
+	for k := range p.Errors {
+		codes = append(codes, k)
+	}
+	sort.Ints(codes)
+	for _, k := range codes {
+		fmt.Fprintf(w, "error     %s  %d\n", strconv.Itoa(k), p.Errors[k])
+	}
+	reasons := make([]string, 0, len(p.Failures))
+	for k := range p.Failures {
+		reasons = append(reasons, k)
+	}
+	sort.Strings(reasons)
+	for _, k := range reasons {
+		fmt.Fprintf(w, "failure   %s  %d\n", k, p.Failures[k])
+	}
+}
@@ -0,0 +1,47 @@
+// Command jrpc-gateway serves many JSON-RPC backends on one endpoint (see
+// package gateway):
+//
+//	jrpc-gateway -config gateway.json -addr :8080
+//
+// The config file is reloaded when it changes.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"github.com/zc310/fastjsonrpc"
+	"github.com/zc310/fastjsonrpc/gateway"
+)
+
+func main() {
+	config := flag.String("config", "gateway.json", "route config file")
+	addr := flag.String("addr", ":8080", "listen address")
+	interval := flag.Duration("reload", time.Second, "config check interval, 0 disables reloading")
+	maxBody := flag.Int("max-body", 4<<20, "max request size in bytes, 0 disables the check")
+	maxBatch := flag.Int("max-batch", 32, "max calls in a batch, 0 disables the check")
+	flag.Parse()
+
+	c, err := gateway.Load(*config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "jrpc-gateway:", err)
+		os.Exit(1)
+	}
+	g, err := gateway.New(c,
+		gateway.WithLimits(fastjsonrpc.Limits{MaxBodySize: *maxBody, MaxBatchSize: *maxBatch}),
+		gateway.WithErrorHandler(func(err error) { log.Print("reload: ", err) }))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "jrpc-gateway:", err)
+		os.Exit(1)
+	}
+	if *interval > 0 {
+		go g.Watch(context.Background(), *config, *interval)
+	}
+	log.Printf("serving %s on %s", *config, *addr)
+	log.Fatal(fasthttp.ListenAndServe(*addr, g.Handler))
+}
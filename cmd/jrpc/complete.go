@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/zc310/fastjsonrpc/client"
+	"github.com/zc310/fastjsonrpc/openrpc"
+)
+
+const bashCompletion = `_jrpc() {
+	local IFS=$'\n'
+	COMPREPLY=($(jrpc -complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+	if [[ ${#COMPREPLY[@]} -eq 1 && ${COMPREPLY[0]} == *= ]]; then
+		compopt -o nospace
+	fi
+}
+complete -F _jrpc jrpc
+`
+
+func printCompletion(w io.Writer, shell string) error {
+	if shell != "bash" {
+		return errors.New("unsupported shell " + shell)
+	}
+	_, err := io.WriteString(w, bashCompletion)
+	return err
+}
+
+// discover fetches the OpenRPC document of the endpoint.
+func discover(ctx context.Context, o *options, endpoint string) (*openrpc.Document, error) {
+	ctx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+	t, err := dial(ctx, o, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer t.Close()
+	var d openrpc.Document
+	if err := client.New(t).Call(ctx, openrpc.DiscoverMethod, nil, &d); err != nil {
+		return nil, err
+	}
+	sort.Slice(d.Methods, func(i, j int) bool { return d.Methods[i].Name < d.Methods[j].Name })
+	return &d, nil
+}
+
+// listMethods prints the methods of the endpoint with their params.
+func listMethods(ctx context.Context, o *options, args []string) error {
+	if len(args) != 1 {
+		return errors.New("-list takes the endpoint only")
+	}
+	d, err := discover(ctx, o, args[0])
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(o.stdout, 0, 4, 2, ' ', 0)
+	for _, m := range d.Methods {
+		params := make([]string, len(m.Params))
+		for i, p := range m.Params {
+			params[i] = p.Name
+			if !p.Required {
+				params[i] += "?"
+			}
+		}
+		fmt.Fprintf(w, "%s(%s)\t%s\n", m.Name, strings.Join(params, ", "), m.Summary)
+	}
+	return w.Flush()
+}
+
+// complete prints the candidates for the last of args, which are the
+// endpoint, the method and its params as typed so far. Failures print
+// nothing, so the shell falls back to no completion.
+func complete(ctx context.Context, o *options, args []string) {
+	for _, c := range candidates(ctx, o, args) {
+		fmt.Fprintln(o.stdout, c)
+	}
+}
+
+func candidates(ctx context.Context, o *options, args []string) []string {
+	if len(args) < 2 {
+		return nil
+	}
+	d, err := discover(ctx, o, args[0])
+	if err != nil {
+		return nil
+	}
+	word := args[len(args)-1]
+
+	var out []string
+	if len(args) == 2 {
+		for _, m := range d.Methods {
+			if strings.HasPrefix(m.Name, word) {
+				out = append(out, m.Name)
+			}
+		}
+		return out
+	}
+
+	given := make(map[string]bool)
+	for _, a := range args[2 : len(args)-1] {
+		if k, _, ok := strings.Cut(a, "="); ok {
+			given[k] = true
+		}
+	}
+	for _, m := range d.Methods {
+		if m.Name != args[1] {
+			continue
+		}
+		for _, p := range m.Params {
+			if !given[p.Name] && strings.HasPrefix(p.Name+"=", word) {
+				out = append(out, p.Name+"=")
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,276 @@
+// Command jrpc calls JSON-RPC methods from the command line.
+//
+//	jrpc http://localhost:8080/rpc Arith.Add a=1 b=2
+//	jrpc ws://localhost:8080/ws calc.multiply '{"a":2,"b":3}'
+//	jrpc tcp://localhost:9000 status
+//	jrpc unix:///run/app.sock status
+//	jrpc -unix /run/app.sock http://app/rpc status
+//	jrpc -batch calls.jsonl http://localhost:8080/rpc
+//	jrpc -subscribe ws://localhost:8080/ws subscribe topic=blocks
+//	jrpc -list http://localhost:8080/rpc
+//
+// Flags come before the endpoint. http and https endpoints are called
+// with POST, ws and wss over WebSocket, and tcp and unix endpoints with
+// newline-delimited JSON over a stream connection. With -unix, HTTP and
+// WebSocket endpoints are reached through a Unix socket.
+//
+// Params are given as a single JSON value, as @file, as key=value pairs
+// for named params, or as positional values. Values are JSON when valid
+// and strings otherwise.
+//
+// Results are pretty-printed, in color on terminals. Errors are printed
+// to stderr. jrpc exits with status 1 when the server returns a JSON-RPC
+// error, and with status 2 on usage, parse or transport errors.
+//
+// Methods and params names are completed from rpc.discover with:
+//
+//	eval "$(jrpc -completion bash)"
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/goccy/go-json"
+	"github.com/tidwall/pretty"
+	"github.com/valyala/fasthttp"
+	"github.com/zc310/fastjsonrpc"
+	"github.com/zc310/fastjsonrpc/client"
+)
+
+type headers []string
+
+func (p *headers) String() string     { return strings.Join(*p, ", ") }
+func (p *headers) Set(v string) error { *p = append(*p, v); return nil }
+
+type options struct {
+	header    headers
+	unix      string
+	timeout   time.Duration
+	raw       bool
+	color     bool
+	notify    bool
+	batch     string
+	subscribe bool
+	verbose   bool
+
+	stdout, stderr io.Writer
+}
+
+func main() {
+	o := &options{stdout: os.Stdout, stderr: os.Stderr, color: isTerminal(os.Stdout)}
+	flag.Var(&o.header, "H", `request header "Name: value", repeatable`)
+	flag.StringVar(&o.unix, "unix", "", "reach http and ws endpoints through this Unix socket")
+	flag.DurationVar(&o.timeout, "timeout", 30*time.Second, "call timeout")
+	flag.BoolVar(&o.raw, "raw", false, "print responses as received")
+	flag.BoolVar(&o.notify, "notify", false, "send a notification and expect no response")
+	flag.StringVar(&o.batch, "batch", "", `send the batch in file, a JSON array or one request per line; "-" for stdin`)
+	flag.BoolVar(&o.subscribe, "subscribe", false, "print notifications after the call until interrupted (ws, tcp and unix)")
+	flag.BoolVar(&o.verbose, "v", false, "print requests to stderr")
+	list := flag.Bool("list", false, "list the methods of the endpoint from rpc.discover")
+	doComplete := flag.Bool("complete", false, "print completions for the remaining arguments")
+	completion := flag.String("completion", "", "print the completion script for shell (bash)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: jrpc [flags] endpoint [method [params...]]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var err error
+	switch {
+	case *completion != "":
+		err = printCompletion(o.stdout, *completion)
+	case *doComplete:
+		complete(ctx, o, flag.Args())
+	case *list:
+		err = listMethods(ctx, o, flag.Args())
+	default:
+		err = run(ctx, o, flag.Args())
+	}
+	var e *fastjsonrpc.Error
+	switch {
+	case errors.As(err, &e):
+		os.Exit(1)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "jrpc:", err)
+		os.Exit(2)
+	}
+}
+
+func run(ctx context.Context, o *options, args []string) error {
+	if len(args) == 0 || (len(args) == 1 && o.batch == "") {
+		flag.Usage()
+		return errors.New("missing endpoint or method")
+	}
+	if o.subscribe && o.notify {
+		return errors.New("-subscribe and -notify are exclusive")
+	}
+
+	// The handler runs on the read loop of the connection and must not
+	// block it: notifications beyond the buffer are dropped and counted.
+	notifications := make(chan json.RawMessage, 64)
+	var dropped atomic.Int64
+	var notify client.NotifyHandler
+	if o.subscribe {
+		notify = func(method string, params json.RawMessage) {
+			b, _ := json.Marshal(struct {
+				Method string          `json:"method"`
+				Params json.RawMessage `json:"params,omitempty"`
+			}{method, params})
+			select {
+			case notifications <- b:
+			default:
+				dropped.Add(1)
+			}
+		}
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, o.timeout)
+	defer cancel()
+	t, err := dial(callCtx, o, args[0], notify)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+
+	if o.batch != "" {
+		b, err := batchRequest(o.batch)
+		if err != nil {
+			return err
+		}
+		o.logRequest(b)
+		resp, err := t.RoundTrip(callCtx, b)
+		if err != nil {
+			return err
+		}
+		if len(resp) > 0 {
+			o.print(o.stdout, resp)
+		}
+		return nil
+	}
+
+	method := args[1]
+	params, err := parseParams(args[2:])
+	if err != nil {
+		return err
+	}
+	o.logRequest(params)
+	var p any
+	if params != nil {
+		p = params
+	}
+	c := client.New(t)
+	if o.notify {
+		return c.Notify(callCtx, method, p)
+	}
+
+	var result json.RawMessage
+	if err := c.Call(callCtx, method, p, &result); err != nil {
+		var e *fastjsonrpc.Error
+		if errors.As(err, &e) {
+			b, _ := json.Marshal(e)
+			o.print(o.stderr, b)
+		}
+		return err
+	}
+	o.print(o.stdout, result)
+
+	if !o.subscribe {
+		return nil
+	}
+	done, ok := t.(interface{ Done() <-chan struct{} })
+	if !ok {
+		return errors.New("-subscribe needs a ws, tcp or unix endpoint")
+	}
+	for {
+		if n := dropped.Swap(0); n > 0 {
+			fmt.Fprintf(o.stderr, "jrpc: dropped %d notifications\n", n)
+		}
+		select {
+		case b := <-notifications:
+			o.print(o.stdout, b)
+		case <-done.Done():
+			return errors.New("connection closed")
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (p *options) logRequest(b []byte) {
+	if p.verbose && len(b) > 0 {
+		fmt.Fprintf(p.stderr, "> %s\n", b)
+	}
+}
+
+func (p *options) print(w io.Writer, b []byte) {
+	if p.raw {
+		fmt.Fprintf(w, "%s\n", b)
+		return
+	}
+	b = pretty.Pretty(b)
+	if p.color && w == p.stdout {
+		b = pretty.Color(b, nil)
+	}
+	_, _ = w.Write(b)
+}
+
+// dial returns a transport for endpoint.
+func dial(ctx context.Context, o *options, endpoint string, notify client.NotifyHandler) (client.Transport, error) {
+	scheme, rest, ok := strings.Cut(endpoint, "://")
+	if !ok {
+		return nil, errors.New("endpoint must start with http://, https://, ws://, wss://, tcp:// or unix://")
+	}
+
+	h := make(map[string]string, len(o.header))
+	for _, kv := range o.header {
+		k, v, ok := strings.Cut(kv, ":")
+		if !ok {
+			return nil, errors.New(`header must be "Name: value": ` + kv)
+		}
+		h[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+
+	switch scheme {
+	case "http", "https":
+		t := &client.HTTPTransport{URL: endpoint, Header: h}
+		if o.unix != "" {
+			t.Client = &fasthttp.Client{Dial: func(string) (net.Conn, error) { return net.Dial("unix", o.unix) }}
+		}
+		return t, nil
+	case "ws", "wss":
+		d := *websocket.DefaultDialer
+		if o.unix != "" {
+			d.NetDial = func(string, string) (net.Conn, error) { return net.Dial("unix", o.unix) }
+		}
+		header := make(http.Header, len(h))
+		for k, v := range h {
+			header.Set(k, v)
+		}
+		return client.DialWebSocket(ctx, endpoint, client.WithDialer(&d), client.WithHeader(header), client.WithNotifyHandler(notify))
+	case "tcp":
+		return client.DialStream(ctx, "tcp", rest, notify)
+	case "unix":
+		return client.DialStream(ctx, "unix", rest, notify)
+	}
+	return nil, errors.New("unsupported endpoint scheme " + scheme)
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/zc310/fastjsonrpc"
+	"github.com/zc310/fastjsonrpc/openrpc"
+	"github.com/zc310/fastjsonrpc/ws"
+)
+
+func TestParseParams(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{nil, ""},
+		{[]string{`{"a":1}`}, `{"a":1}`},
+		{[]string{` [1,2]`}, `[1,2]`},
+		{[]string{"a=1", "b=x", `c="2"`, "d=true", "e=[1]"}, `{"a":1,"b":"x","c":"2","d":true,"e":[1]}`},
+		{[]string{"1", "x y"}, `[1,"x y"]`},
+	} {
+		got, err := parseParams(tt.args)
+		require.NoError(t, err, tt.args)
+		assert.Equal(t, tt.want, string(got), tt.args)
+	}
+
+	for _, args := range [][]string{{"{"}, {"a=1", "2"}, {"1", "a=2"}, {"@missing.json"}} {
+		_, err := parseParams(args)
+		assert.Error(t, err, args)
+	}
+
+	name := filepath.Join(t.TempDir(), "params.json")
+	require.NoError(t, os.WriteFile(name, []byte("{\"a\": 1}\n"), 0o644))
+	got, err := parseParams([]string{"@" + name})
+	require.NoError(t, err)
+	assert.Equal(t, `{"a": 1}`, string(got))
+}
+
+func TestBatchRequest(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, s string) string {
+		name = filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(name, []byte(s), 0o644))
+		return name
+	}
+
+	b, err := batchRequest(write("lines", "{\"id\":1}\n\n{\"id\":2}\n"))
+	require.NoError(t, err)
+	assert.Equal(t, `[{"id":1},{"id":2}]`, string(b))
+
+	b, err = batchRequest(write("array", ` [{"id":1}] `))
+	require.NoError(t, err)
+	assert.Equal(t, `[{"id":1}]`, string(b))
+
+	_, err = batchRequest(write("empty", "\n"))
+	assert.Error(t, err)
+	_, err = batchRequest(write("bad", "{\n"))
+	assert.Error(t, err)
+}
+
+type Args struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+func testOptions() (*options, *bytes.Buffer, *bytes.Buffer) {
+	var stdout, stderr bytes.Buffer
+	return &options{timeout: 5 * time.Second, raw: true, stdout: &stdout, stderr: &stderr}, &stdout, &stderr
+}
+
+func serveHTTP(t *testing.T) string {
+	var s fastjsonrpc.ServerMap
+	require.NoError(t, s.RegisterFunc("add", func(a Args) int { return a.A + a.B }))
+	require.NoError(t, s.RegisterFunc("sub", func(a, b int) int { return a - b }))
+	openrpc.Register(&s, openrpc.Info{Title: "Test", Version: "1"})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = fasthttp.Serve(ln, s.Handler) }()
+	t.Cleanup(func() { _ = ln.Close() })
+	return "http://" + ln.Addr().String() + "/"
+}
+
+func TestRunHTTP(t *testing.T) {
+	url := serveHTTP(t)
+	ctx := context.Background()
+
+	o, stdout, _ := testOptions()
+	require.NoError(t, run(ctx, o, []string{url, "add", "a=1", "b=2"}))
+	assert.Equal(t, "3\n", stdout.String())
+
+	o, stdout, _ = testOptions()
+	require.NoError(t, run(ctx, o, []string{url, "sub", "5", "2"}))
+	assert.Equal(t, "3\n", stdout.String())
+
+	o, _, stderr := testOptions()
+	var e *fastjsonrpc.Error
+	require.ErrorAs(t, run(ctx, o, []string{url, "missing"}), &e)
+	assert.Equal(t, -32601, e.Code)
+	assert.Contains(t, stderr.String(), `"code":-32601`)
+
+	o, stdout, _ = testOptions()
+	o.batch = filepath.Join(t.TempDir(), "batch")
+	require.NoError(t, os.WriteFile(o.batch, []byte(`{"jsonrpc":"2.0","method":"sub","params":[3,1],"id":1}
+{"jsonrpc":"2.0","method":"add","params":{"a":1,"b":1},"id":2}`), 0o644))
+	require.NoError(t, run(ctx, o, []string{url}))
+	assert.JSONEq(t, `[{"jsonrpc":"2.0","result":2,"id":1},{"jsonrpc":"2.0","result":2,"id":2}]`, stdout.String())
+}
+
+func TestRunStream(t *testing.T) {
+	j := ws.NewJSONRPC2()
+	require.NoError(t, j.RegisterFunc("add", func(a, b int) int { return a + b }))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		sc := bufio.NewScanner(conn)
+		for sc.Scan() {
+			if resp, err := j.HandleMessage(sc.Bytes()); err == nil && len(resp) > 0 {
+				_, _ = conn.Write(append(resp, '\n'))
+			}
+		}
+	}()
+
+	o, stdout, _ := testOptions()
+	require.NoError(t, run(context.Background(), o, []string{"tcp://" + ln.Addr().String(), "add", "2", "3"}))
+	assert.Equal(t, "5\n", stdout.String())
+}
+
+func TestRunSubscribeOverflow(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		sc := bufio.NewScanner(conn)
+		if !sc.Scan() {
+			return
+		}
+		// Flood the client before answering the call.
+		for i := 0; i < 100; i++ {
+			_, _ = conn.Write([]byte(`{"jsonrpc":"2.0","method":"tick","params":[` + strconv.Itoa(i) + `]}` + "\n"))
+		}
+		_, _ = conn.Write([]byte(`{"jsonrpc":"2.0","result":"ok","id":1}` + "\n"))
+	}()
+
+	o, stdout, stderr := testOptions()
+	o.subscribe = true
+	assert.EqualError(t, run(context.Background(), o, []string{"tcp://" + ln.Addr().String(), "watch"}), "connection closed")
+	assert.True(t, strings.HasPrefix(stdout.String(), "\"ok\"\n"), stdout.String())
+	assert.Contains(t, stderr.String(), "jrpc: dropped 36 notifications")
+}
+
+func TestComplete(t *testing.T) {
+	url := serveHTTP(t)
+	ctx := context.Background()
+	o, _, _ := testOptions()
+
+	assert.Equal(t, []string{"add"}, candidates(ctx, o, []string{url, "a"}))
+	assert.Equal(t, []string{"add", "sub"}, candidates(ctx, o, []string{url, ""}))
+	assert.Equal(t, []string{"a=", "b="}, candidates(ctx, o, []string{url, "add", ""}))
+	assert.Equal(t, []string{"b="}, candidates(ctx, o, []string{url, "add", "a=1", ""}))
+	assert.Empty(t, candidates(ctx, o, []string{url}))
+
+	o, stdout, _ := testOptions()
+	require.NoError(t, listMethods(ctx, o, []string{url}))
+	assert.Contains(t, stdout.String(), "add(a, b)")
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+// parseParams builds params from command-line arguments:
+//
+//	(none)          no params
+//	'{"a":1}'       a JSON object or array, used as is
+//	@file           JSON read from file, or stdin for @-
+//	a=1 b=x         an object; values are JSON if valid and strings otherwise
+//	1 x             an array, with values typed the same way
+func parseParams(args []string) (json.RawMessage, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	if len(args) == 1 {
+		a := strings.TrimSpace(args[0])
+		switch {
+		case strings.HasPrefix(a, "{") || strings.HasPrefix(a, "["):
+			if !json.Valid([]byte(a)) {
+				return nil, errors.New("params are not valid JSON")
+			}
+			return json.RawMessage(a), nil
+		case strings.HasPrefix(a, "@"):
+			b, err := readFile(a[1:])
+			if err != nil {
+				return nil, err
+			}
+			if !json.Valid(b) {
+				return nil, errors.New(a[1:] + ": params are not valid JSON")
+			}
+			return bytes.TrimSpace(b), nil
+		}
+	}
+
+	named := strings.Contains(args[0], "=")
+	var b bytes.Buffer
+	if named {
+		b.WriteByte('{')
+	} else {
+		b.WriteByte('[')
+	}
+	for i, a := range args {
+		key, value, ok := strings.Cut(a, "=")
+		if ok != named {
+			return nil, errors.New("cannot mix key=value and positional params: " + a)
+		}
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if named {
+			k, _ := json.Marshal(key)
+			b.Write(k)
+			b.WriteByte(':')
+		} else {
+			value = a
+		}
+		b.Write(typed(value))
+	}
+	if named {
+		b.WriteByte('}')
+	} else {
+		b.WriteByte(']')
+	}
+	return b.Bytes(), nil
+}
+
+// typed returns s if it is a JSON value, and s as a JSON string otherwise.
+// Quote a value, as in name='"123"', to pass it as a string.
+func typed(s string) []byte {
+	if json.Valid([]byte(s)) {
+		return []byte(s)
+	}
+	b, _ := json.Marshal(s)
+	return b
+}
+
+func readFile(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(name)
+}
+
+// batchRequest reads a batch from a JSON array, or from one request
+// object per line.
+func batchRequest(name string) ([]byte, error) {
+	b, err := readFile(name)
+	if err != nil {
+		return nil, err
+	}
+	b = bytes.TrimSpace(b)
+	if bytes.HasPrefix(b, []byte("[")) {
+		if !json.Valid(b) {
+			return nil, errors.New(name + ": batch is not valid JSON")
+		}
+		return b, nil
+	}
+
+	var out bytes.Buffer
+	out.WriteByte('[')
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			return nil, errors.New(name + ": line is not valid JSON: " + string(line))
+		}
+		if out.Len() > 1 {
+			out.WriteByte(',')
+		}
+		out.Write(line)
+	}
+	out.WriteByte(']')
+	if out.Len() == 2 {
+		return nil, errors.New(name + ": empty batch")
+	}
+	return out.Bytes(), nil
+}
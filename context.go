@@ -1,6 +1,7 @@
 package fastjsonrpc
 
 import (
+	"context"
 	"io"
 	"sync"
 
@@ -18,6 +19,7 @@ type RequestCtx struct {
 	id      []byte
 	pr      *fastjson.Parser
 	w       *bytebufferpool.ByteBuffer
+	ctx     context.Context
 
 	Ctx   *fasthttp.RequestCtx
 	Arena *fastjson.Arena
@@ -27,13 +29,77 @@ type RequestCtx struct {
 
 	Error  any
 	Result any
+
+	raw      []byte
+	rawSet   bool
+	validate bool
+}
+
+// FastUnmarshaler is implemented by params types that decode themselves
+// from a parsed value, such as the decoders generated by fastjsonrpc-gen
+// -server. ParamsUnmarshal uses it instead of reflection.
+type FastUnmarshaler interface {
+	UnmarshalFastJSON(v *fastjson.Value) error
+}
+
+// FastMarshaler is implemented by result types that append their JSON
+// encoding to dst. Results implementing it are encoded without
+// reflection.
+type FastMarshaler interface {
+	MarshalFastJSON(dst []byte) []byte
+}
+
+// ResultBuffer returns an empty buffer owned by the context, to be filled
+// and passed to SetRawResult. Together they encode a result without
+// allocating.
+func (p *RequestCtx) ResultBuffer() []byte { return p.raw[:0] }
+
+// SetRawResult sets the result to the JSON encoded b. b must stay
+// unchanged until the handler returns.
+func (p *RequestCtx) SetRawResult(b []byte) {
+	p.raw, p.rawSet = b, true
+	p.Result = nil
+}
+
+// Context returns the server context. It is cancelled when a Shutdown
+// deadline expires before the handler returns.
+func (p *RequestCtx) Context() context.Context {
+	if p.ctx == nil {
+		return context.Background()
+	}
+	return p.ctx
 }
 
+// ParamsUnmarshal decodes params into v. For methods registered
+// WithValidation it also checks the `validate` struct tags of v, see
+// Validate.
 func (p *RequestCtx) ParamsUnmarshal(v any) error {
+	if err := p.paramsUnmarshal(v); err != nil {
+		return err
+	}
+	if p.validate {
+		return Validate(v)
+	}
+	return nil
+}
+
+// FastParams returns the params for a FastUnmarshaler to decode directly,
+// which unlike ParamsUnmarshal does not allocate. It reports false when
+// ParamsUnmarshal must be used instead: when the request has no params,
+// or for methods registered WithValidation.
+func (p *RequestCtx) FastParams() (*fastjson.Value, bool) {
+	return p.Params, p.Params != nil && !p.validate
+}
+
+func (p *RequestCtx) paramsUnmarshal(v any) error {
 	if p.Params == nil {
 		return json.Unmarshal(p.Ctx.PostBody(), v)
 	}
 
+	if u, ok := v.(FastUnmarshaler); ok {
+		return u.UnmarshalFastJSON(p.Params)
+	}
+
 	b := bytebufferpool.Get()
 	defer bytebufferpool.Put(b)
 
@@ -55,6 +121,10 @@ func (p *RequestCtx) writeResult(w io.Writer) {
 	if len(p.id) == 0 {
 		return
 	}
+	if p.rawSet {
+		writenewResult(w, p.id, p.raw)
+		return
+	}
 	switch v := p.Result.(type) {
 	case *fastjson.Value:
 		b := bytebufferpool.Get()
@@ -62,6 +132,11 @@ func (p *RequestCtx) writeResult(w io.Writer) {
 		bytebufferpool.Put(b)
 	case []byte:
 		writenewResult(w, p.id, v)
+	case FastMarshaler:
+		b := bytebufferpool.Get()
+		b.B = v.MarshalFastJSON(b.B)
+		writenewResult(w, p.id, b.B)
+		bytebufferpool.Put(b)
 	default:
 		b := bytebufferpool.Get()
 		if p.Error = json.NewEncoder(b).Encode(p.Result); p.Error != nil {
@@ -138,7 +213,11 @@ func putContext(p *RequestCtx) {
 	p.id = p.id[:0]
 	p.Error = nil
 	p.Result = nil
+	p.raw = p.raw[:0]
+	p.rawSet = false
+	p.validate = false
 	p.Ctx = nil
+	p.ctx = nil
 
 	_pool.Put(p)
 }
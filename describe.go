@@ -0,0 +1,72 @@
+package fastjsonrpc
+
+import (
+	"reflect"
+	"sort"
+)
+
+// MethodInfo describes a registered method for documentation generators
+// such as package openrpc.
+type MethodInfo struct {
+	Name    string
+	Summary string
+	// Params is the type params decode into, declared with WithParams or
+	// WithMethodTypes.
+	Params reflect.Type
+	// Result is the type of the result, declared with WithResult or
+	// WithMethodTypes, or taken from the function of RegisterFunc.
+	Result reflect.Type
+	// Func is the bound function of a method registered with RegisterFunc.
+	Func *Func
+	// Schema validates params, declared with WithSchema.
+	Schema      *Schema
+	Deprecated  bool
+	Deprecation string
+}
+
+// WithSummary sets a short description of the method.
+func WithSummary(summary string) Option { return func(o *options) { o.info.Summary = summary } }
+
+// WithParams declares the type params decode into, given as a value of
+// that type, for example WithParams(Args{}).
+func WithParams(v any) Option { return func(o *options) { o.info.Params = reflect.TypeOf(v) } }
+
+// WithResult declares the type of the result, given as a value of that
+// type.
+func WithResult(v any) Option { return func(o *options) { o.info.Result = reflect.TypeOf(v) } }
+
+// WithMethodTypes declares the params and result types of the Go method
+// goName of a registered service. Either value may be nil.
+func WithMethodTypes(goName string, params, result any) Option {
+	return func(o *options) {
+		if o.methodTypes == nil {
+			o.methodTypes = make(map[string][2]reflect.Type)
+		}
+		o.methodTypes[goName] = [2]reflect.Type{reflect.TypeOf(params), reflect.TypeOf(result)}
+	}
+}
+
+func withFunc(f *Func) Option {
+	return func(o *options) {
+		o.info.Func = f
+		if o.info.Result == nil {
+			o.info.Result = f.Result()
+		}
+	}
+}
+
+// Describe returns the registered methods sorted by name.
+func (p *ServerMap) Describe() []MethodInfo {
+	t := p.methods.Load()
+	if t == nil {
+		return nil
+	}
+	a := make([]MethodInfo, 0, len(*t))
+	for name, m := range *t {
+		info := m.info
+		info.Name = name
+		a = append(a, info)
+	}
+	sort.Slice(a, func(i, j int) bool { return a[i].Name < a[j].Name })
+	return a
+}
@@ -1,12 +1,17 @@
 package fastjsonrpc
 
-import "strconv"
+import (
+	"io"
+	"strconv"
+)
 
 var (
 	errParse          = []byte(`{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`)
 	errInvalidRequest = []byte(`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`)
 	errInternal       = []byte(`{"jsonrpc":"2.0","error":{"code":-32603,"message":"Internal error"},"id":null}`)
 	errMethodNotFound = NewError(-32601, "Method not found")
+	errServerBusy     = NewError(-32001, "Server busy")
+	errShuttingDown   = NewError(-32002, "Server shutting down")
 )
 
 type Error struct {
@@ -18,3 +23,7 @@ type Error struct {
 func (p *Error) Error() string { return strconv.Itoa(p.Code) + ": " + p.Message }
 
 func NewError(code int, message string) *Error { return &Error{Code: code, Message: message} }
+
+func writeLimitError(w io.Writer, err error) {
+	writenewError(w, null, -32600, "Invalid Request", strconv.AppendQuote(nil, err.Error()))
+}
@@ -0,0 +1,31 @@
+//go:build !race
+
+package arith_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/zc310/fastjsonrpc"
+	"github.com/zc310/fastjsonrpc/example/arith"
+)
+
+// The race detector drops pooled objects, so allocations are only checked
+// without it.
+func TestDivideGeneratedAllocs(t *testing.T) {
+	s := new(fastjsonrpc.ServerMap)
+	require.NoError(t, arith.RegisterArith(s, new(arith.Arith)))
+
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+	ctx.Request.SetBodyString(`{"jsonrpc":"2.0","method":"Arith.Divide","params":{"a":7,"b":2},"id":1}`)
+	s.Handler(ctx)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","result":{"quo":3,"rem":1},"id":1}`, string(ctx.Response.Body()))
+
+	assert.Zero(t, testing.AllocsPerRun(100, func() {
+		ctx.Response.ResetBody()
+		s.Handler(ctx)
+	}))
+}
@@ -0,0 +1,78 @@
+// Package arith is an example service. Its typed clients in rpc_client.go
+// and its static dispatch in rpc_server.go are generated by
+// fastjsonrpc-gen.
+package arith
+
+//go:generate go run ../../cmd/fastjsonrpc-gen -server -type Arith
+//go:generate go run ../../cmd/fastjsonrpc-gen
+
+import (
+	"github.com/valyala/fastjson"
+	"github.com/zc310/fastjsonrpc"
+	"github.com/zc310/fastjsonrpc/ws"
+)
+
+type Args struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+type Quotient struct {
+	Quo int `json:"quo"`
+	Rem int `json:"rem"`
+}
+
+var errDivideByZero = fastjsonrpc.NewError(1, "divide by zero")
+
+// Arith is served by fastjsonrpc.ServerMap.
+type Arith int
+
+// Add returns the sum of a and b.
+//
+//rpc:result int
+func (t *Arith) Add(c *fastjsonrpc.RequestCtx) {
+	var a Args
+	if c.Error = a.UnmarshalParams(c); c.Error == nil {
+		c.Result = a.A + a.B
+	}
+}
+
+// Divide returns the quotient and remainder of a and b.
+func (t *Arith) Divide(c *fastjsonrpc.RequestCtx) {
+	var a Args
+	if c.Error = a.UnmarshalParams(c); c.Error != nil {
+		return
+	}
+	if a.B == 0 {
+		c.Error = errDivideByZero
+		return
+	}
+	q := Quotient{Quo: a.A / a.B, Rem: a.A % a.B}
+	q.SetResult(c)
+}
+
+//rpc:params -
+//rpc:result string
+func (t *Arith) Ping(c *fastjsonrpc.RequestCtx) { c.Result = "pong" }
+
+// Calc is served by ws.JSONRPC2.
+type Calc struct{}
+
+// Multiply returns the product of a and b.
+//
+//rpc:params Args
+//rpc:result int
+func (Calc) Multiply(arena *fastjson.Arena, params *fastjson.Value) (interface{}, error) {
+	return arena.NewNumberInt(params.GetInt("a") * params.GetInt("b")), nil
+}
+
+// Register registers Arith as "Arith.*" and as "arith.*@v2".
+func Register(s *fastjsonrpc.ServerMap) error {
+	if err := RegisterArith(s, new(Arith)); err != nil {
+		return err
+	}
+	return s.RegisterName("arith", new(Arith), fastjsonrpc.WithNaming(fastjsonrpc.SnakeCase), fastjsonrpc.WithVersion("v2"))
+}
+
+// RegisterWS registers Calc as "calc.*".
+func RegisterWS(j *ws.JSONRPC2) error { return j.RegisterObject(Calc{}) }
@@ -0,0 +1,86 @@
+package arith_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/fasthttp/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+	"github.com/zc310/fastjsonrpc"
+	"github.com/zc310/fastjsonrpc/client"
+	"github.com/zc310/fastjsonrpc/example/arith"
+	"github.com/zc310/fastjsonrpc/ws"
+)
+
+func serve(t *testing.T, h fasthttp.RequestHandler) *fasthttputil.InmemoryListener {
+	ln := fasthttputil.NewInmemoryListener()
+	go func() { _ = fasthttp.Serve(ln, h) }()
+	t.Cleanup(func() { _ = ln.Close() })
+	return ln
+}
+
+func TestHTTPClient(t *testing.T) {
+	var s fastjsonrpc.ServerMap
+	require.NoError(t, arith.Register(&s))
+	ln := serve(t, s.Handler)
+
+	c := client.New(&client.HTTPTransport{
+		URL:    "http://arith/rpc",
+		Client: &fasthttp.Client{Dial: func(string) (net.Conn, error) { return ln.Dial() }},
+	})
+	ctx := context.Background()
+
+	a := arith.NewArithClient(c)
+	sum, err := a.Add(ctx, arith.Args{A: 1, B: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 3, sum)
+
+	q, err := a.Divide(ctx, arith.Args{A: 7, B: 2})
+	require.NoError(t, err)
+	assert.Equal(t, arith.Quotient{Quo: 3, Rem: 1}, q)
+
+	_, err = a.Divide(ctx, arith.Args{A: 7})
+	var e *fastjsonrpc.Error
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, 1, e.Code)
+
+	pong, err := a.Ping(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "pong", pong)
+
+	sum, err = arith.NewArithV2Client(c).Add(ctx, arith.Args{A: 2, B: 3})
+	require.NoError(t, err)
+	assert.Equal(t, 5, sum)
+}
+
+func TestWebSocketClient(t *testing.T) {
+	j := ws.NewJSONRPC2()
+	require.NoError(t, arith.RegisterWS(j))
+	ln := serve(t, ws.Handler(j, &websocket.FastHTTPUpgrader{}))
+
+	tr, err := client.DialWebSocket(context.Background(), "ws://arith/ws", client.WithDialer(&websocket.Dialer{
+		NetDial: func(string, string) (net.Conn, error) { return ln.Dial() },
+	}))
+	require.NoError(t, err)
+	c := client.New(tr)
+	defer c.Close()
+
+	calc := arith.NewCalcClient(c)
+	done := make(chan struct{})
+	for i := 0; i < 10; i++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			n, err := calc.Multiply(context.Background(), arith.Args{A: i, B: 3})
+			assert.NoError(t, err)
+			assert.Equal(t, i*3, n)
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		<-done
+	}
+}
@@ -0,0 +1,36 @@
+package arith_test
+
+import (
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"github.com/zc310/fastjsonrpc"
+	"github.com/zc310/fastjsonrpc/example/arith"
+)
+
+func benchmarkDivide(b *testing.B, register func(*fastjsonrpc.ServerMap) error) {
+	b.ReportAllocs()
+
+	s := new(fastjsonrpc.ServerMap)
+	if err := register(s); err != nil {
+		b.Fatal(err)
+	}
+
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+	ctx.Request.SetBodyString(`{"jsonrpc":"2.0","method":"Arith.Divide","params":{"a":7,"b":2},"id":1}`)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ctx.Response.ResetBody()
+		s.Handler(ctx)
+	}
+}
+
+func BenchmarkDivideReflect(b *testing.B) {
+	benchmarkDivide(b, func(s *fastjsonrpc.ServerMap) error { return s.Register(new(arith.Arith)) })
+}
+
+func BenchmarkDivideGenerated(b *testing.B) {
+	benchmarkDivide(b, func(s *fastjsonrpc.ServerMap) error { return arith.RegisterArith(s, new(arith.Arith)) })
+}
@@ -0,0 +1,92 @@
+// Code generated by fastjsonrpc-gen. DO NOT EDIT.
+
+package arith
+
+import (
+	"context"
+
+	"github.com/zc310/fastjsonrpc/client"
+)
+
+// ArithClient calls the methods of Arith.
+type ArithClient struct {
+	c *client.Client
+}
+
+// NewArithClient returns a client of Arith using c.
+func NewArithClient(c *client.Client) *ArithClient { return &ArithClient{c: c} }
+
+// Add returns the sum of a and b.
+//
+// Add calls "Arith.Add".
+func (p *ArithClient) Add(ctx context.Context, params Args) (int, error) {
+	var result int
+	err := p.c.Call(ctx, "Arith.Add", params, &result)
+	return result, err
+}
+
+// Divide returns the quotient and remainder of a and b.
+//
+// Divide calls "Arith.Divide".
+func (p *ArithClient) Divide(ctx context.Context, params Args) (Quotient, error) {
+	var result Quotient
+	err := p.c.Call(ctx, "Arith.Divide", params, &result)
+	return result, err
+}
+
+// Ping calls "Arith.Ping".
+func (p *ArithClient) Ping(ctx context.Context) (string, error) {
+	var result string
+	err := p.c.Call(ctx, "Arith.Ping", nil, &result)
+	return result, err
+}
+
+// ArithV2Client calls the methods of Arith.
+type ArithV2Client struct {
+	c *client.Client
+}
+
+// NewArithV2Client returns a client of Arith using c.
+func NewArithV2Client(c *client.Client) *ArithV2Client { return &ArithV2Client{c: c} }
+
+// Add returns the sum of a and b.
+//
+// Add calls "arith.add@v2".
+func (p *ArithV2Client) Add(ctx context.Context, params Args) (int, error) {
+	var result int
+	err := p.c.Call(ctx, "arith.add@v2", params, &result)
+	return result, err
+}
+
+// Divide returns the quotient and remainder of a and b.
+//
+// Divide calls "arith.divide@v2".
+func (p *ArithV2Client) Divide(ctx context.Context, params Args) (Quotient, error) {
+	var result Quotient
+	err := p.c.Call(ctx, "arith.divide@v2", params, &result)
+	return result, err
+}
+
+// Ping calls "arith.ping@v2".
+func (p *ArithV2Client) Ping(ctx context.Context) (string, error) {
+	var result string
+	err := p.c.Call(ctx, "arith.ping@v2", nil, &result)
+	return result, err
+}
+
+// CalcClient calls the methods of Calc.
+type CalcClient struct {
+	c *client.Client
+}
+
+// NewCalcClient returns a client of Calc using c.
+func NewCalcClient(c *client.Client) *CalcClient { return &CalcClient{c: c} }
+
+// Multiply returns the product of a and b.
+//
+// Multiply calls "calc.multiply".
+func (p *CalcClient) Multiply(ctx context.Context, params Args) (int, error) {
+	var result int
+	err := p.c.Call(ctx, "calc.multiply", params, &result)
+	return result, err
+}
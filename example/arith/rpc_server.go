@@ -0,0 +1,136 @@
+// Code generated by fastjsonrpc-gen -server. DO NOT EDIT.
+
+package arith
+
+import (
+	"github.com/valyala/bytebufferpool"
+	"github.com/valyala/fastjson"
+	"github.com/valyala/quicktemplate"
+	"github.com/zc310/fastjsonrpc"
+)
+
+// RegisterArith registers rcvr like s.Register(rcvr, opts...), dispatching
+// through a static method table instead of reflection.
+func RegisterArith(s *fastjsonrpc.ServerMap, rcvr *Arith, opts ...fastjsonrpc.Option) error {
+	return s.RegisterMethods("Arith", map[string]fastjsonrpc.Handler{
+		"Add":    rcvr.Add,
+		"Divide": rcvr.Divide,
+		"Ping":   rcvr.Ping,
+	}, opts...)
+}
+
+// UnmarshalFastJSON decodes v into p without reflection.
+func (p *Args) UnmarshalFastJSON(v *fastjson.Value) error { return p.unmarshalFastJSON(v, "params") }
+
+func (p *Args) unmarshalFastJSON(v *fastjson.Value, path string) error {
+	if v == nil || v.Type() == fastjson.TypeNull {
+		return nil
+	}
+	if v.Type() != fastjson.TypeObject {
+		return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path, Rule: "type", Message: "must be object"})
+	}
+	if f1 := v.Get("a"); f1 != nil && f1.Type() != fastjson.TypeNull {
+		x2, err := f1.Int64()
+		if err != nil {
+			return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path + ".a", Rule: "type", Message: "must be integer"})
+		}
+		p.A = int(x2)
+	}
+	if f3 := v.Get("b"); f3 != nil && f3.Type() != fastjson.TypeNull {
+		x4, err := f3.Int64()
+		if err != nil {
+			return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path + ".b", Rule: "type", Message: "must be integer"})
+		}
+		p.B = int(x4)
+	}
+	return nil
+}
+
+// UnmarshalParams decodes the params of c into p like c.ParamsUnmarshal(p),
+// without allocating unless the method validates its params.
+func (p *Args) UnmarshalParams(c *fastjsonrpc.RequestCtx) error {
+	if v, ok := c.FastParams(); ok {
+		return p.UnmarshalFastJSON(v)
+	}
+	q := new(Args)
+	err := c.ParamsUnmarshal(q)
+	*p = *q
+	return err
+}
+
+// StreamFastJSON writes the JSON encoding of p to qw without reflection.
+func (p Args) StreamFastJSON(qw *quicktemplate.Writer) {
+	qw.N().S(`{`)
+	qw.N().S(`"a":`)
+	qw.N().DL(int64(p.A))
+	qw.N().S(`,"b":`)
+	qw.N().DL(int64(p.B))
+	qw.N().S(`}`)
+}
+
+// MarshalFastJSON appends the JSON encoding of p to dst without reflection.
+func (p Args) MarshalFastJSON(dst []byte) []byte {
+	b := bytebufferpool.Get()
+	qw := quicktemplate.AcquireWriter(b)
+	p.StreamFastJSON(qw)
+	quicktemplate.ReleaseWriter(qw)
+	dst = append(dst, b.B...)
+	bytebufferpool.Put(b)
+	return dst
+}
+
+// UnmarshalFastJSON decodes v into p without reflection.
+func (p *Quotient) UnmarshalFastJSON(v *fastjson.Value) error {
+	return p.unmarshalFastJSON(v, "params")
+}
+
+func (p *Quotient) unmarshalFastJSON(v *fastjson.Value, path string) error {
+	if v == nil || v.Type() == fastjson.TypeNull {
+		return nil
+	}
+	if v.Type() != fastjson.TypeObject {
+		return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path, Rule: "type", Message: "must be object"})
+	}
+	if f5 := v.Get("quo"); f5 != nil && f5.Type() != fastjson.TypeNull {
+		x6, err := f5.Int64()
+		if err != nil {
+			return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path + ".quo", Rule: "type", Message: "must be integer"})
+		}
+		p.Quo = int(x6)
+	}
+	if f7 := v.Get("rem"); f7 != nil && f7.Type() != fastjson.TypeNull {
+		x8, err := f7.Int64()
+		if err != nil {
+			return fastjsonrpc.InvalidParams(fastjsonrpc.FieldError{Field: path + ".rem", Rule: "type", Message: "must be integer"})
+		}
+		p.Rem = int(x8)
+	}
+	return nil
+}
+
+// StreamFastJSON writes the JSON encoding of p to qw without reflection.
+func (p Quotient) StreamFastJSON(qw *quicktemplate.Writer) {
+	qw.N().S(`{`)
+	qw.N().S(`"quo":`)
+	qw.N().DL(int64(p.Quo))
+	qw.N().S(`,"rem":`)
+	qw.N().DL(int64(p.Rem))
+	qw.N().S(`}`)
+}
+
+// MarshalFastJSON appends the JSON encoding of p to dst without reflection.
+func (p Quotient) MarshalFastJSON(dst []byte) []byte {
+	b := bytebufferpool.Get()
+	qw := quicktemplate.AcquireWriter(b)
+	p.StreamFastJSON(qw)
+	quicktemplate.ReleaseWriter(qw)
+	dst = append(dst, b.B...)
+	bytebufferpool.Put(b)
+	return dst
+}
+
+// SetResult sets p as the result of c, encoded into the result buffer of c
+// without allocating.
+func (p Quotient) SetResult(c *fastjsonrpc.RequestCtx) {
+	c.SetRawResult(p.MarshalFastJSON(c.ResultBuffer()))
+}
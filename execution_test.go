@@ -0,0 +1,164 @@
+package fastjsonrpc_test
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fastjson"
+	"github.com/zc310/fastjsonrpc/rpctest"
+	"github.com/zc310/fastjsonrpc/ws"
+)
+
+// recorder registers "record", which sleeps for params.delay milliseconds
+// before recording params.v, and "log", which returns the recorded values.
+func recorder(j *ws.JSONRPC2, opts ...ws.MethodOption) {
+	var mu sync.Mutex
+	var log []string
+	j.RegisterMethodFunc("record", func(params *fastjson.Value) (interface{}, error) {
+		time.Sleep(time.Duration(params.GetInt("delay")) * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		log = append(log, string(params.GetStringBytes("v")))
+		return string(params.GetStringBytes("v")), nil
+	}, opts...)
+	j.RegisterMethodFunc("log", func(*fastjson.Value) (interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return strings.Join(log, ","), nil
+	}, opts...)
+}
+
+// exchange sends messages over a new connection to j and returns the
+// results of the first n responses in the order they arrived.
+func exchange(t *testing.T, j *ws.JSONRPC2, n int, messages ...string) []string {
+	h := rpctest.NewJSONRPC2(t, j)
+	for _, m := range messages {
+		h.Write(t, []byte(m))
+	}
+	results := make([]string, n)
+	for i := range results {
+		results[i] = fastjson.GetString(h.Read(t), "result")
+	}
+	return results
+}
+
+func TestExecution(t *testing.T) {
+	t.Parallel()
+
+	messages := []string{
+		`{"jsonrpc":"2.0","method":"record","params":{"v":"a","delay":50},"id":1}`,
+		`{"jsonrpc":"2.0","method":"record","params":{"v":"b"},"id":2}`,
+	}
+
+	t.Run("concurrent", func(t *testing.T) {
+		t.Parallel()
+		j := ws.NewJSONRPC2()
+		recorder(j)
+		assert.Equal(t, []string{"b", "a"}, exchange(t, j, 2, messages...))
+	})
+	t.Run("sequential", func(t *testing.T) {
+		t.Parallel()
+		j := ws.NewJSONRPC2(ws.WithExecution(ws.Sequential))
+		recorder(j)
+		assert.Equal(t, []string{"a", "b"}, exchange(t, j, 2, messages...))
+	})
+	t.Run("method", func(t *testing.T) {
+		t.Parallel()
+		j := ws.NewJSONRPC2()
+		recorder(j, ws.WithMethodExecution(ws.Sequential))
+		assert.Equal(t, []string{"a", "b"}, exchange(t, j, 2, messages...))
+	})
+	t.Run("notifications", func(t *testing.T) {
+		t.Parallel()
+		// Side effects of ordered notifications complete before later calls.
+		j := ws.NewJSONRPC2(ws.WithExecution(ws.Sequential))
+		recorder(j)
+		assert.Equal(t, []string{"a,b"}, exchange(t, j, 1,
+			`{"jsonrpc":"2.0","method":"record","params":{"v":"a","delay":50}}`,
+			`{"jsonrpc":"2.0","method":"record","params":{"v":"b"}}`,
+			`{"jsonrpc":"2.0","method":"log","id":1}`,
+		))
+	})
+	t.Run("notification queue", func(t *testing.T) {
+		t.Parallel()
+		// Ordered notifications bypass the queue and its parallel workers.
+		q := &ws.NotificationQueue{Dir: t.TempDir(), Workers: 4}
+		j := ws.NewJSONRPC2(ws.WithExecution(ws.Sequential), ws.WithNotificationQueue(q))
+		t.Cleanup(func() { _ = j.Shutdown(context.Background()) })
+		recorder(j)
+		assert.Equal(t, []string{"a,b"}, exchange(t, j, 1,
+			`{"jsonrpc":"2.0","method":"record","params":{"v":"a","delay":50}}`,
+			`{"jsonrpc":"2.0","method":"record","params":{"v":"b"}}`,
+			`{"jsonrpc":"2.0","method":"log","id":1}`,
+		))
+	})
+	t.Run("batch", func(t *testing.T) {
+		t.Parallel()
+		j := ws.NewJSONRPC2(ws.WithExecution(ws.Sequential))
+		recorder(j)
+		assert.Equal(t, []string{"", "c"}, exchange(t, j, 2,
+			`[{"jsonrpc":"2.0","method":"record","params":{"v":"a","delay":50},"id":1}]`,
+			`{"jsonrpc":"2.0","method":"record","params":{"v":"c"},"id":2}`,
+		))
+	})
+}
+
+func TestKeyedExecution(t *testing.T) {
+	t.Parallel()
+
+	j := ws.NewJSONRPC2(ws.WithExecution(ws.KeyedSequential(ws.ParamKey("account"))))
+	recorder(j)
+	assert.Equal(t, []string{"y", "x1", "x2", "z"}, exchange(t, j, 4,
+		`{"jsonrpc":"2.0","method":"record","params":{"account":"x","v":"x1","delay":50},"id":1}`,
+		`{"jsonrpc":"2.0","method":"record","params":{"account":"x","v":"x2"},"id":2}`,
+		`{"jsonrpc":"2.0","method":"record","params":{"account":"y","v":"y","delay":10},"id":3}`,
+		// Calls without a key run concurrently, after the others finish here.
+		`{"jsonrpc":"2.0","method":"record","params":{"v":"z","delay":150},"id":4}`,
+	))
+}
+
+func TestWriteQueueFull(t *testing.T) {
+	t.Parallel()
+
+	j := ws.NewJSONRPC2(ws.WithWriteQueue(1, ws.CloseOnFull))
+	j.RegisterMethodContext("flood", func(ctx context.Context, _ *fastjson.Arena, _ *fastjson.Value) (interface{}, error) {
+		for i := 0; i < 10000; i++ {
+			if err := ws.Notify(ctx, "tick", i); err != nil {
+				return nil, err
+			}
+		}
+		return "done", nil
+	})
+
+	h := rpctest.NewJSONRPC2(t, j)
+	h.Write(t, []byte(`{"jsonrpc":"2.0","method":"flood","id":1}`))
+	err := h.WaitClose(t)
+	assert.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation), err)
+}
+
+func TestExecutionQueueFull(t *testing.T) {
+	t.Parallel()
+
+	j := ws.NewJSONRPC2(ws.WithExecution(ws.Sequential), ws.WithWriteQueue(1, ws.CloseOnFull))
+	j.RegisterMethodContext("wait", func(ctx context.Context, _ *fastjson.Arena, _ *fastjson.Value) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	h := rpctest.NewJSONRPC2(t, j)
+	for i := 0; i < 3; i++ {
+		h.Write(t, []byte(`{"jsonrpc":"2.0","method":"wait","id":`+strconv.Itoa(i)+`}`))
+	}
+	err := h.WaitClose(t)
+	var ce *websocket.CloseError
+	require.ErrorAs(t, err, &ce)
+	assert.Equal(t, websocket.ClosePolicyViolation, ce.Code)
+	assert.Equal(t, "execution queue full", ce.Text)
+}
@@ -0,0 +1,114 @@
+package gateway
+
+import (
+	"errors"
+	"maps"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/zc310/fastjsonrpc/internal/jsontime"
+)
+
+// Config is the route table of a Gateway, usually loaded from a JSON file:
+//
+//	{
+//		"timeout": "5s",
+//		"backends": {
+//			"users":  {"url": "http://users:8080/rpc"},
+//			"orders": {"url": "ws://orders:8080/ws", "header": {"Authorization": "Bearer t"}}
+//		},
+//		"routes": [
+//			{"methods": ["user.login"], "backend": "users", "timeout": "30s"},
+//			{"prefix": "user.", "backend": "users"},
+//			{"prefix": "order.", "backend": "orders", "stripPrefix": true}
+//		]
+//	}
+type Config struct {
+	Backends map[string]Backend `json:"backends"`
+	// Routes are matched in order; the first match wins.
+	Routes []Route `json:"routes"`
+	// Timeout applies to routes without their own. Zero means none.
+	Timeout Duration `json:"timeout,omitempty"`
+}
+
+// Backend is a JSON-RPC server. URL schemes http, https, ws and wss are
+// supported by default.
+type Backend struct {
+	URL string `json:"url"`
+	// Header is sent with every request, or with the WebSocket handshake.
+	Header map[string]string `json:"header,omitempty"`
+}
+
+func (p Backend) equal(b Backend) bool {
+	return p.URL == b.URL && maps.Equal(p.Header, b.Header)
+}
+
+// Route sends the calls whose method is in Methods or starts with Prefix
+// to Backend. A route with neither matches every method.
+type Route struct {
+	Methods []string `json:"methods,omitempty"`
+	Prefix  string   `json:"prefix,omitempty"`
+	// StripPrefix removes Prefix from the method name sent to the backend.
+	StripPrefix bool     `json:"stripPrefix,omitempty"`
+	Backend     string   `json:"backend"`
+	Timeout     Duration `json:"timeout,omitempty"`
+}
+
+func (p *Route) match(method string) bool {
+	for _, m := range p.Methods {
+		if m == method {
+			return true
+		}
+	}
+	if p.Prefix != "" {
+		return strings.HasPrefix(method, p.Prefix)
+	}
+	return len(p.Methods) == 0
+}
+
+// Duration is a time.Duration written as a string such as "30s" in JSON.
+type Duration = jsontime.Duration
+
+// Load reads a Config from a JSON file.
+func Load(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := new(Config)
+	if err = json.Unmarshal(b, c); err != nil {
+		return nil, errors.New(path + ": " + err.Error())
+	}
+	if err = c.validate(); err != nil {
+		return nil, errors.New(path + ": " + err.Error())
+	}
+	return c, nil
+}
+
+func (p *Config) validate() error {
+	for name, b := range p.Backends {
+		if b.URL == "" {
+			return errors.New("backend " + name + " has no url")
+		}
+	}
+	for _, r := range p.Routes {
+		if _, ok := p.Backends[r.Backend]; !ok {
+			return errors.New("route to unknown backend " + r.Backend)
+		}
+		if r.Timeout < 0 {
+			return errors.New("route to " + r.Backend + ": negative timeout")
+		}
+	}
+	return nil
+}
+
+// stat returns the modification time and size of path, zero if missing.
+func stat(path string) (time.Time, int64) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, 0
+	}
+	return fi.ModTime(), fi.Size()
+}
@@ -0,0 +1,458 @@
+// Package gateway exposes many JSON-RPC services behind one endpoint.
+//
+// A Gateway routes every call, including each element of a batch, to a
+// backend by method, forwards it over HTTP or WebSocket with the client
+// package, and reassembles batch responses with the ids of the request:
+//
+//	c, _ := gateway.Load("gateway.json")
+//	g, _ := gateway.New(c)
+//	go g.Watch(ctx, "gateway.json", time.Second)
+//	fasthttp.ListenAndServe(":8080", g.Handler)
+package gateway
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fastjson"
+	"github.com/zc310/fastjsonrpc"
+	"github.com/zc310/fastjsonrpc/client"
+)
+
+var (
+	errParse          = []byte(`{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`)
+	errInvalidRequest = []byte(`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`)
+)
+
+// Error codes of calls the gateway could not forward.
+const (
+	CodeMethodNotFound = -32601
+	// CodeBackend reports a backend that failed or timed out; data holds
+	// the reason.
+	CodeBackend = -32004
+)
+
+// Gateway is a fasthttp handler routing calls to backends. It is safe
+// for concurrent use, including Update while serving.
+type Gateway struct {
+	dial    func(name string, b Backend) (client.Transport, error)
+	onError func(error)
+	limits  fastjsonrpc.Limits
+
+	mu    sync.Mutex
+	table atomic.Pointer[table]
+	ids   atomic.Uint64
+
+	parsers fastjson.ParserPool
+	arenas  fastjson.ArenaPool
+}
+
+type table struct {
+	routes   []route
+	backends map[string]*backend
+}
+
+type route struct {
+	Route
+	timeout time.Duration
+	b       *backend
+}
+
+type backend struct {
+	Backend
+	t client.Transport
+}
+
+// Option configures a Gateway.
+type Option func(*Gateway)
+
+// WithTransport replaces the transport dialed for each backend, for
+// example to wrap it in a client.ResilientTransport.
+func WithTransport(dial func(name string, b Backend) (client.Transport, error)) Option {
+	return func(p *Gateway) { p.dial = dial }
+}
+
+// WithErrorHandler receives errors of Watch reloads. The previous route
+// table stays in use after an error.
+func WithErrorHandler(fn func(error)) Option { return func(p *Gateway) { p.onError = fn } }
+
+// WithLimits bounds incoming requests before any call is forwarded. Zero
+// fields are not enforced; a batch larger than MaxBatchSize is rejected
+// as a whole.
+func WithLimits(l fastjsonrpc.Limits) Option { return func(p *Gateway) { p.limits = l } }
+
+// New returns a gateway serving c.
+func New(c *Config, opts ...Option) (*Gateway, error) {
+	p := &Gateway{dial: Dial, onError: func(error) {}}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if err := p.Update(c); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Dial returns an HTTPTransport for http and https backends and a
+// WebSocket connection, redialed when it closes, for ws and wss.
+func Dial(_ string, b Backend) (client.Transport, error) {
+	switch {
+	case strings.HasPrefix(b.URL, "http://"), strings.HasPrefix(b.URL, "https://"):
+		return &client.HTTPTransport{URL: b.URL, Header: b.Header}, nil
+	case strings.HasPrefix(b.URL, "ws://"), strings.HasPrefix(b.URL, "wss://"):
+		var opts []client.WebSocketOption
+		if len(b.Header) > 0 {
+			h := make(map[string][]string, len(b.Header))
+			for k, v := range b.Header {
+				h[k] = []string{v}
+			}
+			opts = append(opts, client.WithHeader(h))
+		}
+		return &client.RedialTransport{Dial: func(ctx context.Context) (client.Transport, error) {
+			return client.DialWebSocket(ctx, b.URL, opts...)
+		}}, nil
+	}
+	return nil, errors.New("unsupported backend url " + b.URL)
+}
+
+// Update replaces the route table. Transports of unchanged backends are
+// kept; those of removed or changed backends are closed, failing the
+// calls still in flight on them.
+func (p *Gateway) Update(c *Config) error {
+	if err := c.validate(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	old := p.table.Load()
+	t := &table{backends: make(map[string]*backend, len(c.Backends))}
+	for name, b := range c.Backends {
+		if o := old.backend(name); o != nil && o.equal(b) {
+			t.backends[name] = o
+			continue
+		}
+		tr, err := p.dial(name, b)
+		if err != nil {
+			t.close(old)
+			return errors.New("backend " + name + ": " + err.Error())
+		}
+		t.backends[name] = &backend{Backend: b, t: tr}
+	}
+	for _, r := range c.Routes {
+		timeout := time.Duration(r.Timeout)
+		if timeout == 0 {
+			timeout = time.Duration(c.Timeout)
+		}
+		t.routes = append(t.routes, route{Route: r, timeout: timeout, b: t.backends[r.Backend]})
+	}
+
+	p.table.Store(t)
+	old.close(t)
+	return nil
+}
+
+func (t *table) backend(name string) *backend {
+	if t == nil {
+		return nil
+	}
+	return t.backends[name]
+}
+
+// close closes the transports of t that next does not use.
+func (t *table) close(next *table) {
+	if t == nil {
+		return
+	}
+	for name, b := range t.backends {
+		if next.backend(name) != b {
+			_ = b.t.Close()
+		}
+	}
+}
+
+func (t *table) match(method string) *route {
+	for i := range t.routes {
+		if t.routes[i].match(method) {
+			return &t.routes[i]
+		}
+	}
+	return nil
+}
+
+// Watch loads the config at path, then reloads it whenever its
+// modification time or size changes, checking every interval, until ctx
+// is done.
+func (p *Gateway) Watch(ctx context.Context, path string, interval time.Duration) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	var mod time.Time
+	var size int64 = -1
+	for {
+		if m, s := stat(path); !m.Equal(mod) || s != size {
+			mod, size = m, s
+			p.reload(path)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+func (p *Gateway) reload(path string) {
+	c, err := Load(path)
+	if err == nil {
+		err = p.Update(c)
+	}
+	if err != nil {
+		p.onError(err)
+	}
+}
+
+// Close closes the transports of all backends.
+func (p *Gateway) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.table.Swap(nil).close(nil)
+	return nil
+}
+
+// Handler serves requests posted over HTTP. Forwarded calls are bounded
+// by route timeouts only.
+func (p *Gateway) Handler(ctx *fasthttp.RequestCtx) {
+	ctx.Response.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	ctx.SetBody(p.Handle(context.Background(), ctx.Request.Body()))
+}
+
+// call is a request element and its response.
+type call struct {
+	item *fastjson.Value
+	// id is nil for notifications.
+	id   *fastjson.Value
+	r    *route
+	resp []byte
+}
+
+// Handle forwards a single or batch request and returns the response,
+// which is nil when only notifications were sent.
+func (p *Gateway) Handle(ctx context.Context, request []byte) []byte {
+	if err := p.limits.Check(request); err != nil {
+		return limitError(err)
+	}
+	pr := p.parsers.Get()
+	defer p.parsers.Put(pr)
+	v, err := pr.ParseBytes(request)
+	if err != nil {
+		return errParse
+	}
+	batch := v.Type() == fastjson.TypeArray
+	items := []*fastjson.Value{v}
+	if batch {
+		if items = v.GetArray(); len(items) == 0 {
+			return errInvalidRequest
+		}
+		if p.limits.MaxBatchSize > 0 && len(items) > p.limits.MaxBatchSize {
+			return limitError(fastjsonrpc.ErrBatchTooLarge)
+		}
+	}
+
+	t := p.table.Load()
+	calls := make([]call, len(items))
+	var groups [][]int
+	for i, item := range items {
+		c := &calls[i]
+		c.item, c.id = item, item.Get("id")
+		method := item.GetStringBytes("method")
+		if item.Type() != fastjson.TypeObject || method == nil {
+			c.id, c.resp = nil, errInvalidRequest
+			continue
+		}
+		if t != nil {
+			c.r = t.match(string(method))
+		}
+		if c.r == nil {
+			if c.id != nil {
+				c.resp = errorResponse(c.id, CodeMethodNotFound, "Method not found", "")
+			}
+			continue
+		}
+		groups = group(groups, calls, i)
+	}
+
+	a := p.arenas.Get()
+	defer p.arenas.Put(a)
+	requests := make([][]byte, len(groups))
+	for g, idx := range groups {
+		requests[g] = p.encode(a, calls, idx, batch)
+	}
+
+	if len(groups) == 1 {
+		p.forward(ctx, calls, groups[0], requests[0])
+	} else {
+		var wg sync.WaitGroup
+		for g, idx := range groups {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				p.forward(ctx, calls, idx, requests[g])
+			}()
+		}
+		wg.Wait()
+	}
+
+	if !batch {
+		return calls[0].resp
+	}
+	var b []byte
+	for _, c := range calls {
+		if c.resp == nil {
+			continue
+		}
+		if b == nil {
+			b = append(b, '[')
+		} else {
+			b = append(b, ',')
+		}
+		b = append(b, c.resp...)
+	}
+	if b != nil {
+		b = append(b, ']')
+	}
+	return b
+}
+
+// group adds call i to the group of its route.
+func group(groups [][]int, calls []call, i int) [][]int {
+	for g, idx := range groups {
+		if calls[idx[0]].r == calls[i].r {
+			groups[g] = append(idx, i)
+			return groups
+		}
+	}
+	return append(groups, []int{i})
+}
+
+// encode writes the calls idx for the backend, replacing ids with
+// gateway ids so that calls of different clients never collide on a
+// shared connection.
+func (p *Gateway) encode(a *fastjson.Arena, calls []call, idx []int, batch bool) []byte {
+	var b []byte
+	if batch {
+		b = append(b, '[')
+	}
+	for n, i := range idx {
+		c := &calls[i]
+		if n > 0 {
+			b = append(b, ',')
+		}
+		if c.id != nil {
+			c.item.Set("id", a.NewNumberString(strconv.FormatUint(p.ids.Add(1), 10)))
+		}
+		if c.r.StripPrefix {
+			method := string(c.item.GetStringBytes("method"))
+			c.item.Set("method", a.NewString(strings.TrimPrefix(method, c.r.Prefix)))
+		}
+		b = c.item.MarshalTo(b)
+	}
+	if batch {
+		b = append(b, ']')
+	}
+	return b
+}
+
+// forward sends the calls idx and stores their responses.
+func (p *Gateway) forward(ctx context.Context, calls []call, idx []int, request []byte) {
+	r := calls[idx[0]].r
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	resp, err := r.b.t.RoundTrip(ctx, request)
+	if err == nil && len(resp) > 0 {
+		err = p.match(calls, idx, resp)
+	}
+
+	reason := "no response"
+	if err != nil {
+		reason = err.Error()
+		if errors.Is(err, context.DeadlineExceeded) {
+			reason = "timeout"
+		}
+	}
+	for _, i := range idx {
+		if c := &calls[i]; c.id != nil && c.resp == nil {
+			c.resp = errorResponse(c.id, CodeBackend, "Backend error", reason)
+		}
+	}
+}
+
+// match assigns the responses in resp to their calls, restoring the ids
+// of the request.
+func (p *Gateway) match(calls []call, idx []int, resp []byte) error {
+	pr := p.parsers.Get()
+	defer p.parsers.Put(pr)
+	v, err := pr.ParseBytes(resp)
+	if err != nil {
+		return errors.New("invalid response: " + err.Error())
+	}
+	items := []*fastjson.Value{v}
+	if v.Type() == fastjson.TypeArray {
+		items = v.GetArray()
+	}
+	for _, item := range items {
+		id := item.Get("id")
+		if id == nil || id.Type() != fastjson.TypeNumber {
+			continue
+		}
+		for _, i := range idx {
+			c := &calls[i]
+			if c.id == nil || c.resp != nil || c.item.Get("id").String() != id.String() {
+				continue
+			}
+			item.Set("id", c.id)
+			c.resp = item.MarshalTo(nil)
+			break
+		}
+	}
+	return nil
+}
+
+// errorResponse returns an error response with id, or null if id is nil.
+func errorResponse(id *fastjson.Value, code int, message, data string) []byte {
+	b := append([]byte(nil), `{"jsonrpc":"2.0","error":{"code":`...)
+	b = strconv.AppendInt(b, int64(code), 10)
+	b = append(b, `,"message":`...)
+	b = appendString(b, message)
+	if data != "" {
+		b = append(b, `,"data":`...)
+		b = appendString(b, data)
+	}
+	b = append(b, `},"id":`...)
+	if id == nil {
+		b = append(b, "null"...)
+	} else {
+		b = id.MarshalTo(b)
+	}
+	return append(b, '}')
+}
+
+func limitError(err error) []byte {
+	return errorResponse(nil, -32600, "Invalid Request", err.Error())
+}
+
+// appendString appends s as a JSON string.
+func appendString(b []byte, s string) []byte {
+	q, _ := json.Marshal(s)
+	return append(b, q...)
+}
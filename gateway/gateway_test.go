@@ -0,0 +1,195 @@
+package gateway_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+	"github.com/valyala/fastjson"
+	"github.com/zc310/fastjsonrpc"
+	"github.com/zc310/fastjsonrpc/client"
+	. "github.com/zc310/fastjsonrpc/gateway"
+	"github.com/zc310/fastjsonrpc/ws"
+)
+
+const config = `{
+	"timeout": "1s",
+	"backends": {
+		"users": {"url": "http://users/rpc"},
+		"calc": {"url": "ws://calc/ws"}
+	},
+	"routes": [
+		{"methods": ["user.slow"], "backend": "users", "timeout": "20ms"},
+		{"prefix": "user.", "backend": "users"},
+		{"prefix": "calc.", "backend": "calc", "stripPrefix": true}
+	]
+}`
+
+func serve(t *testing.T, h fasthttp.RequestHandler) *fasthttputil.InmemoryListener {
+	ln := fasthttputil.NewInmemoryListener()
+	go func() { _ = fasthttp.Serve(ln, h) }()
+	t.Cleanup(func() { _ = ln.Close() })
+	return ln
+}
+
+func newGateway(t *testing.T, path string) *Gateway {
+	var s fastjsonrpc.ServerMap
+	s.RegisterHandler("user.get", func(c *fastjsonrpc.RequestCtx) { c.Result = "user" + c.Params.Get("id").String() })
+	s.RegisterHandler("user.slow", func(c *fastjsonrpc.RequestCtx) { time.Sleep(100 * time.Millisecond) })
+	users := serve(t, s.Handler)
+
+	j := ws.NewJSONRPC2()
+	j.RegisterMethodFunc("add", func(params *fastjson.Value) (interface{}, error) {
+		return params.GetInt("0") + params.GetInt("1"), nil
+	})
+	calc := serve(t, ws.Handler(j, &websocket.FastHTTPUpgrader{}))
+
+	c, err := Load(path)
+	require.NoError(t, err)
+	g, err := New(c, WithTransport(func(name string, b Backend) (client.Transport, error) {
+		switch name {
+		case "users":
+			return &client.HTTPTransport{
+				URL:    b.URL,
+				Client: &fasthttp.Client{Dial: func(string) (net.Conn, error) { return users.Dial() }},
+			}, nil
+		case "calc":
+			return &client.RedialTransport{Dial: func(ctx context.Context) (client.Transport, error) {
+				return client.DialWebSocket(ctx, b.URL, client.WithDialer(&websocket.Dialer{
+					NetDial: func(string, string) (net.Conn, error) { return calc.Dial() },
+				}))
+			}}, nil
+		}
+		return nil, errors.New("unknown backend " + name)
+	}))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = g.Close() })
+	return g
+}
+
+func writeConfig(t *testing.T, path, s string) {
+	require.NoError(t, os.WriteFile(path, []byte(s), 0o644))
+}
+
+func TestGateway(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gateway.json")
+	writeConfig(t, path, config)
+	g := newGateway(t, path)
+	ctx := context.Background()
+
+	handle := func(request string) string { return string(g.Handle(ctx, []byte(request))) }
+
+	assert.JSONEq(t, `{"jsonrpc":"2.0","result":"user7","id":"a"}`,
+		handle(`{"jsonrpc":"2.0","method":"user.get","params":{"id":7},"id":"a"}`))
+	assert.JSONEq(t, `{"jsonrpc":"2.0","result":3,"id":1}`,
+		handle(`{"jsonrpc":"2.0","method":"calc.add","params":[1,2],"id":1}`))
+	assert.Empty(t, handle(`{"jsonrpc":"2.0","method":"calc.add","params":[1,2]}`))
+
+	// Batch elements go to their backends and come back in order, with
+	// their own ids even when they repeat.
+	assert.JSONEq(t, `[
+		{"jsonrpc":"2.0","result":3,"id":1},
+		{"jsonrpc":"2.0","result":"user1","id":1},
+		{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null},
+		{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":2},
+		{"jsonrpc":"2.0","result":7,"id":[]}
+	]`, handle(`[
+		{"jsonrpc":"2.0","method":"calc.add","params":[1,2],"id":1},
+		{"jsonrpc":"2.0","method":"user.get","params":{"id":1},"id":1},
+		{"jsonrpc":"2.0","method":"calc.add","params":[1,1]},
+		{"foo":"boo"},
+		{"jsonrpc":"2.0","method":"missing","id":2},
+		{"jsonrpc":"2.0","method":"calc.add","params":[3,4],"id":[]}
+	]`))
+	assert.Empty(t, handle(`[{"jsonrpc":"2.0","method":"calc.add","params":[1,2]}]`))
+	assert.JSONEq(t, `{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`, handle(`[`))
+	assert.JSONEq(t, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`, handle(`[]`))
+
+	// Route timeouts fail only the calls of that route.
+	assert.JSONEq(t, `[
+		{"jsonrpc":"2.0","error":{"code":-32004,"message":"Backend error","data":"timeout"},"id":1},
+		{"jsonrpc":"2.0","result":"user2","id":2}
+	]`, handle(`[
+		{"jsonrpc":"2.0","method":"user.slow","id":1},
+		{"jsonrpc":"2.0","method":"user.get","params":{"id":2},"id":2}
+	]`))
+
+	req := new(fasthttp.RequestCtx)
+	req.Request.SetBodyString(`{"jsonrpc":"2.0","method":"user.get","params":{"id":3},"id":3}`)
+	g.Handler(req)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","result":"user3","id":3}`, string(req.Response.Body()))
+}
+
+func TestGatewayReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gateway.json")
+	writeConfig(t, path, config)
+	g := newGateway(t, path)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 1)
+	g2, err := New(&Config{}, WithErrorHandler(func(err error) { errs <- err }))
+	require.NoError(t, err)
+	go g2.Watch(ctx, path, 5*time.Millisecond)
+	go g.Watch(ctx, path, 5*time.Millisecond)
+
+	request := []byte(`{"jsonrpc":"2.0","method":"add","params":[1,2],"id":1}`)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":1}`, string(g.Handle(ctx, request)))
+
+	writeConfig(t, path, `{
+		"backends": {"calc": {"url": "ws://calc/ws"}},
+		"routes": [{"backend": "calc"}]
+	}`)
+	require.Eventually(t, func() bool {
+		return string(g.Handle(ctx, request)) == `{"jsonrpc":"2.0","id":1,"result":3}`
+	}, time.Second, 5*time.Millisecond)
+
+	// Invalid configs are reported and the routes kept.
+	writeConfig(t, path, `{"routes": [{"backend": "none"}]}`)
+	select {
+	case err := <-errs:
+		assert.ErrorContains(t, err, "unknown backend none")
+	case <-time.After(time.Second):
+		t.Fatal("no reload error")
+	}
+	assert.JSONEq(t, `{"jsonrpc":"2.0","result":3,"id":1}`, string(g.Handle(ctx, request)))
+}
+
+type failTransport struct{ err error }
+
+func (p failTransport) RoundTrip(context.Context, []byte) ([]byte, error) { return nil, p.err }
+func (p failTransport) Close() error                                      { return nil }
+
+func TestGatewayLimits(t *testing.T) {
+	c := &Config{
+		Backends: map[string]Backend{"b": {URL: "http://b/rpc"}},
+		Routes:   []Route{{Backend: "b"}},
+	}
+	g, err := New(c,
+		WithLimits(fastjsonrpc.Limits{MaxBodySize: 200, MaxBatchSize: 2}),
+		WithTransport(func(string, Backend) (client.Transport, error) {
+			return failTransport{errors.New("bad \x01 \"quote\" é")}, nil
+		}))
+	require.NoError(t, err)
+	handle := func(request string) []byte { return g.Handle(context.Background(), []byte(request)) }
+
+	// Backend errors are escaped as JSON.
+	b := handle(`{"jsonrpc":"2.0","method":"x","id":1}`)
+	require.NoError(t, fastjson.ValidateBytes(b), "%s", b)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","error":{"code":-32004,"message":"Backend error","data":"bad \u0001 \"quote\" é"},"id":1}`, string(b))
+
+	assert.JSONEq(t, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request","data":"batch size limit exceeded"},"id":null}`,
+		string(handle(`[{"jsonrpc":"2.0","method":"x","id":1},{"jsonrpc":"2.0","method":"x","id":2},{"jsonrpc":"2.0","method":"x","id":3}]`)))
+	assert.JSONEq(t, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request","data":"request body too large"},"id":null}`,
+		string(handle(`{"jsonrpc":"2.0","method":"x","params":"`+strings.Repeat("a", 200)+`","id":1}`)))
+}
@@ -0,0 +1,248 @@
+package fastjsonrpc
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/valyala/bytebufferpool"
+	"github.com/valyala/fastjson"
+)
+
+// IdempotencyHeader is the HTTP header carrying the idempotency key of a
+// request. It applies to every call of a batch; calls can carry their own
+// key in params as {"_meta":{"idempotencyKey":"..."}} instead.
+const IdempotencyHeader = "Idempotency-Key"
+
+var (
+	errIdempotencyConflict = NewError(-32003, "Idempotency key reused with different params")
+	errInternalOutcome     = NewError(-32603, "Internal error")
+)
+
+// Idempotency suppresses duplicate executions of calls that carry an
+// idempotency key. The first outcome, result or error, is recorded for
+// Window and returned to repeats of the call with the same method and
+// key. Concurrent duplicates wait for the first call instead of running.
+// A repeat with different params fails with code -32003.
+//
+// Busy and shutting-down errors are not recorded, so that retries of
+// calls rejected before they ran execute normally.
+//
+// Keys are scoped by Principal. Without it any caller reusing a key gets
+// the outcome recorded for it, so keys must then be unguessable.
+type Idempotency struct {
+	Window time.Duration
+	Store  CacheStore
+	// Principal returns the identity keys belong to, such as the
+	// authenticated user. Keys are shared by all callers when nil.
+	Principal func(c *RequestCtx) string
+
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+type flight struct {
+	fingerprint uint64
+	done        chan struct{}
+	outcome     Outcome
+	recorded    bool
+}
+
+// Outcome is the encoded outcome of a call: a result, or an error object
+// when Error is set.
+type Outcome struct {
+	Result []byte
+	Error  []byte
+}
+
+// NewIdempotency returns an Idempotency recording up to maxEntries
+// outcomes for window in an LRU store.
+func NewIdempotency(window time.Duration, maxEntries int) *Idempotency {
+	return &Idempotency{Window: window, Store: NewLRUStore(maxEntries)}
+}
+
+// WithIdempotency suppresses duplicate executions of the registered
+// method, or of every method of the registered service, with p.
+func WithIdempotency(p *Idempotency) Option { return func(o *options) { o.idempotency = p } }
+
+// IdempotencyKey returns the key in params._meta.idempotencyKey, if any.
+func IdempotencyKey(params *fastjson.Value) string {
+	if params == nil {
+		return ""
+	}
+	return string(params.GetStringBytes("_meta", "idempotencyKey"))
+}
+
+// IdempotencyStoreKey returns the key under which the outcome of a call
+// to method with the idempotency key of principal is recorded.
+func IdempotencyStoreKey(method, principal, key string) string {
+	return CacheKeyPrefix(method, principal, key)
+}
+
+// Do runs fn unless the call identified by key already ran, and returns
+// its outcome. fn reports whether its outcome may be recorded. params
+// tell repeats from conflicting reuses of key.
+func (p *Idempotency) Do(key string, params *fastjson.Value, fn func() (Outcome, bool)) (Outcome, error) {
+	fp := fingerprint(params)
+	for {
+		if v, ok := p.Store.Get([]byte(key)); ok {
+			return decodeOutcome(v, fp)
+		}
+
+		p.mu.Lock()
+		if f, ok := p.flights[key]; ok {
+			p.mu.Unlock()
+			<-f.done
+			if !f.recorded {
+				continue
+			}
+			if f.fingerprint != fp {
+				return Outcome{}, errIdempotencyConflict
+			}
+			return f.outcome, nil
+		}
+		// The first call may have finished since the store was read.
+		if v, ok := p.Store.Get([]byte(key)); ok {
+			p.mu.Unlock()
+			return decodeOutcome(v, fp)
+		}
+		f := &flight{fingerprint: fp, done: make(chan struct{})}
+		if p.flights == nil {
+			p.flights = make(map[string]*flight)
+		}
+		p.flights[key] = f
+		p.mu.Unlock()
+
+		p.run(key, f, fn)
+		return f.outcome, nil
+	}
+}
+
+func (p *Idempotency) run(key string, f *flight, fn func() (Outcome, bool)) {
+	defer func() {
+		p.mu.Lock()
+		delete(p.flights, key)
+		p.mu.Unlock()
+		close(f.done)
+	}()
+	f.outcome, f.recorded = fn()
+	if f.recorded {
+		p.Store.Set([]byte(key), encodeOutcome(f.fingerprint, f.outcome), p.Window)
+	}
+}
+
+func fingerprint(params *fastjson.Value) uint64 {
+	h := fnv.New64a()
+	if params != nil {
+		b := bytebufferpool.Get()
+		b.B = appendCanonical(b.B, params)
+		_, _ = h.Write(b.B)
+		bytebufferpool.Put(b)
+	}
+	return h.Sum64()
+}
+
+// encodeOutcome lays out a stored outcome as the params fingerprint, a
+// kind byte, 'r' or 'e', and the encoded result or error.
+func encodeOutcome(fp uint64, o Outcome) []byte {
+	b := make([]byte, 9, 9+len(o.Result)+len(o.Error))
+	binary.BigEndian.PutUint64(b, fp)
+	if o.Error != nil {
+		b[8] = 'e'
+		return append(b, o.Error...)
+	}
+	b[8] = 'r'
+	return append(b, o.Result...)
+}
+
+func decodeOutcome(b []byte, fp uint64) (Outcome, error) {
+	if len(b) < 9 {
+		return Outcome{}, errInternalOutcome
+	}
+	if binary.BigEndian.Uint64(b) != fp {
+		return Outcome{}, errIdempotencyConflict
+	}
+	if b[8] == 'e' {
+		return Outcome{Error: b[9:]}, nil
+	}
+	return Outcome{Result: b[9:]}, nil
+}
+
+func idempotencyHandler(p *Idempotency, h Handler) Handler {
+	return func(c *RequestCtx) {
+		var key string
+		if c.Ctx != nil {
+			key = string(c.Ctx.Request.Header.Peek(IdempotencyHeader))
+		}
+		if key == "" {
+			key = IdempotencyKey(c.Params)
+		}
+		if key == "" || len(c.id) == 0 {
+			h(c)
+			return
+		}
+
+		var principal string
+		if p.Principal != nil {
+			principal = p.Principal(c)
+		}
+		o, err := p.Do(IdempotencyStoreKey(string(c.Method), principal, key), c.Params, func() (Outcome, bool) {
+			h(c)
+			if c.Error != nil {
+				return Outcome{Error: errorObject(c.Error)}, c.Error != errServerBusy && c.Error != errShuttingDown
+			}
+			b, err := c.appendResult(nil)
+			if err != nil {
+				return Outcome{Error: errorObject(err)}, false
+			}
+			return Outcome{Result: append([]byte(nil), b...)}, true
+		})
+		switch {
+		case err != nil:
+			c.Error = err
+		case o.Error != nil:
+			c.Error = o.Error
+		default:
+			c.SetRawResult(append(c.ResultBuffer(), o.Result...))
+		}
+	}
+}
+
+// errorObject encodes err as the error member of a response, as
+// writeError does.
+func errorObject(err any) []byte {
+	switch e := err.(type) {
+	case *Error:
+		b := []byte(`{"code":`)
+		b = strconv.AppendInt(b, int64(e.Code), 10)
+		b = append(b, `,"message":`...)
+		m, _ := json.Marshal(e.Message)
+		b = append(b, m...)
+		if e.Data != nil {
+			var data []byte
+			switch v := e.Data.(type) {
+			case *fastjson.Value:
+				data = v.MarshalTo(nil)
+			case []byte:
+				data = v
+			default:
+				data, _ = json.Marshal(v)
+			}
+			if len(data) > 0 {
+				b = append(append(b, `,"data":`...), data...)
+			}
+		}
+		return append(b, '}')
+	case error:
+		return errorObject(NewError(-32000, e.Error()))
+	case *fastjson.Value:
+		return e.MarshalTo(nil)
+	case []byte:
+		return append([]byte(nil), e...)
+	}
+	b, _ := json.Marshal(err)
+	return b
+}
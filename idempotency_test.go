@@ -0,0 +1,179 @@
+package fastjsonrpc_test
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/pretty"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fastjson"
+	. "github.com/zc310/fastjsonrpc"
+	"github.com/zc310/fastjsonrpc/ws"
+)
+
+func TestIdempotency(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int64
+	release := make(chan struct{})
+	s := new(ServerMap)
+	s.RegisterHandler("pay", func(c *RequestCtx) {
+		n := calls.Add(1)
+		if c.Params.GetInt("amount") == 0 {
+			<-release
+		}
+		if c.Params.GetInt("amount") < 0 {
+			c.Error = NewError(7, "declined")
+			return
+		}
+		c.Result = map[string]int64{"payment": n}
+	}, WithIdempotency(NewIdempotency(time.Minute, 100)))
+
+	call := func(request, key string) string {
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+		if key != "" {
+			ctx.Request.Header.Set(IdempotencyHeader, key)
+		}
+		ctx.Request.SetBodyString(request)
+		s.Handler(ctx)
+		return string(pretty.Ugly(ctx.Response.Body()))
+	}
+
+	assert.Equal(t, `{"jsonrpc":"2.0","result":{"payment":1},"id":1}`, call(`{"jsonrpc":"2.0","method":"pay","params":{"amount":5},"id":1}`, "k1"))
+	assert.Equal(t, `{"jsonrpc":"2.0","result":{"payment":1},"id":2}`, call(`{"jsonrpc":"2.0","method":"pay","params":{"amount":5},"id":2}`, "k1"))
+	assert.Equal(t, `{"jsonrpc":"2.0","error":{"code":-32003,"message":"Idempotency key reused with different params"},"id":3}`,
+		call(`{"jsonrpc":"2.0","method":"pay","params":{"amount":6},"id":3}`, "k1"))
+
+	// Keys in params, errors are recorded too.
+	for i := 4; i < 6; i++ {
+		assert.Equal(t, `{"jsonrpc":"2.0","error":{"code":7,"message":"declined"},"id":`+strconv.Itoa(i)+`}`,
+			call(`{"jsonrpc":"2.0","method":"pay","params":{"amount":-1,"_meta":{"idempotencyKey":"k2"}},"id":`+strconv.Itoa(i)+`}`, ""))
+	}
+	assert.Equal(t, int64(2), calls.Load())
+
+	// Calls without a key run every time.
+	call(`{"jsonrpc":"2.0","method":"pay","params":{"amount":5},"id":6}`, "")
+	assert.Equal(t, int64(3), calls.Load())
+
+	// Concurrent duplicates wait for the first call.
+	var wg sync.WaitGroup
+	responses := make([]string, 5)
+	for i := range responses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			responses[i] = call(`{"jsonrpc":"2.0","method":"pay","params":{"amount":0},"id":0}`, "k3")
+		}()
+	}
+	require.Eventually(t, func() bool { return calls.Load() == 4 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int64(4), calls.Load())
+	for _, r := range responses {
+		assert.Equal(t, `{"jsonrpc":"2.0","result":{"payment":4},"id":0}`, r)
+	}
+}
+
+func TestIdempotencyNotRecorded(t *testing.T) {
+	t.Parallel()
+
+	p := NewIdempotency(time.Minute, 0)
+	var runs int
+	busy := func() (Outcome, bool) { runs++; return Outcome{Error: []byte(`{"code":-32001}`)}, false }
+	ok := func() (Outcome, bool) { runs++; return Outcome{Result: []byte(`1`)}, true }
+
+	o, err := p.Do("m|k", nil, busy)
+	require.NoError(t, err)
+	assert.Equal(t, `{"code":-32001}`, string(o.Error))
+	o, err = p.Do("m|k", nil, ok)
+	require.NoError(t, err)
+	assert.Equal(t, "1", string(o.Result))
+	o, err = p.Do("m|k", nil, ok)
+	require.NoError(t, err)
+	assert.Equal(t, "1", string(o.Result))
+	assert.Equal(t, 2, runs)
+
+	_, err = p.Do("m|k", fastjson.MustParse(`{"a":1}`), ok)
+	var e *Error
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, -32003, e.Code)
+}
+
+func TestIdempotencyWebSocket(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int64
+	j := ws.NewJSONRPC2()
+	j.RegisterMethod("pay", func(_ *fastjson.Arena, params *fastjson.Value) (interface{}, error) {
+		n := calls.Add(1)
+		if params.GetInt("amount") < 0 {
+			return nil, ws.NewRPCError(7, "declined", nil)
+		}
+		return n, nil
+	}, ws.WithIdempotency(NewIdempotency(time.Minute, 100)))
+
+	for i := 0; i < 2; i++ {
+		b, err := j.HandleMessage([]byte(`{"jsonrpc":"2.0","method":"pay","params":{"amount":1,"_meta":{"idempotencyKey":"k"}},"id":` + strconv.Itoa(i) + `}`))
+		require.NoError(t, err)
+		assert.Equal(t, `{"jsonrpc":"2.0","id":`+strconv.Itoa(i)+`,"result":1}`, string(b))
+
+		b, err = j.HandleMessage([]byte(`{"jsonrpc":"2.0","method":"pay","params":{"amount":-1,"_meta":{"idempotencyKey":"e"}},"id":"x"}`))
+		require.NoError(t, err)
+		assert.Equal(t, `{"jsonrpc":"2.0","id":"x","error":{"code":7,"message":"declined"}}`, string(b))
+	}
+	assert.Equal(t, int64(2), calls.Load())
+
+	h := ws.HTTPHandler(j)
+	for i := 0; i < 2; i++ {
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+		ctx.Request.Header.SetContentType("application/json")
+		ctx.Request.Header.Set(IdempotencyHeader, "h")
+		ctx.Request.SetBodyString(`{"jsonrpc":"2.0","method":"pay","params":{"amount":1},"id":1}`)
+		h(ctx)
+		assert.Equal(t, `{"jsonrpc":"2.0","id":1,"result":3}`, string(ctx.Response.Body()))
+	}
+	assert.Equal(t, int64(3), calls.Load())
+}
+
+func TestIdempotencyPrincipal(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int64
+	p := NewIdempotency(time.Minute, 100)
+	p.Principal = func(c *RequestCtx) string { return string(c.Ctx.Request.Header.Peek("X-User")) }
+	s := new(ServerMap)
+	s.RegisterHandler("pay", func(c *RequestCtx) { c.Result = calls.Add(1) }, WithIdempotency(p))
+
+	j := ws.NewJSONRPC2(ws.WithPrincipal(func(ctx *fasthttp.RequestCtx) string { return string(ctx.Request.Header.Peek("X-User")) }))
+	j.RegisterMethod("pay", func(*fastjson.Arena, *fastjson.Value) (interface{}, error) { return calls.Add(1), nil },
+		ws.WithIdempotency(NewIdempotency(time.Minute, 100)))
+
+	for _, h := range []fasthttp.RequestHandler{s.Handler, ws.HTTPHandler(j)} {
+		calls.Store(0)
+		call := func(user string) string {
+			ctx := new(fasthttp.RequestCtx)
+			ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+			ctx.Request.Header.SetContentType("application/json")
+			ctx.Request.Header.Set(IdempotencyHeader, "k")
+			ctx.Request.Header.Set("X-User", user)
+			ctx.Request.SetBodyString(`{"jsonrpc":"2.0","method":"pay","id":1}`)
+			h(ctx)
+			return string(ctx.Response.Body())
+		}
+
+		// A key reused by another principal runs again.
+		assert.Contains(t, call("alice"), `"result":1`)
+		assert.Contains(t, call("bob"), `"result":2`)
+		assert.Contains(t, call("alice"), `"result":1`)
+		assert.Equal(t, int64(2), calls.Load())
+	}
+}
@@ -0,0 +1,52 @@
+// Package inflight counts the calls in progress of a server, so that a
+// graceful shutdown can wait for the last one to end without polling.
+package inflight
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Counter is the number of calls in progress. The zero value is ready to
+// use.
+type Counter struct {
+	n atomic.Int64
+
+	// waiting is set once Idle has been called, so that Add only takes mu
+	// when someone may be waiting.
+	waiting atomic.Bool
+	mu      sync.Mutex
+	idle    chan struct{} // closed when n drops to zero
+}
+
+// Add adds delta to the counter.
+func (p *Counter) Add(delta int64) {
+	if p.n.Add(delta) == 0 && p.waiting.Load() {
+		p.mu.Lock()
+		if p.idle != nil && p.n.Load() == 0 {
+			close(p.idle)
+			p.idle = nil
+		}
+		p.mu.Unlock()
+	}
+}
+
+// Load returns the number of calls in progress.
+func (p *Counter) Load() int64 { return p.n.Load() }
+
+// Idle returns a channel closed once no call is in progress, right away
+// if none is.
+func (p *Counter) Idle() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.waiting.Store(true)
+	if p.idle == nil {
+		p.idle = make(chan struct{})
+	}
+	ch := p.idle
+	if p.n.Load() == 0 {
+		close(p.idle)
+		p.idle = nil
+	}
+	return ch
+}
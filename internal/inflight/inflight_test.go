@@ -0,0 +1,47 @@
+package inflight
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounter(t *testing.T) {
+	var p Counter
+	select {
+	case <-p.Idle():
+	default:
+		t.Fatal("idle channel of an unused counter is open")
+	}
+
+	p.Add(1)
+	p.Add(1)
+	idle := p.Idle()
+	p.Add(-1)
+	select {
+	case <-idle:
+		t.Fatal("idle with a call in progress")
+	case <-time.After(10 * time.Millisecond):
+	}
+	p.Add(-1)
+	<-idle
+	assert.Zero(t, p.Load())
+
+	// Concurrent calls end before the channel is closed.
+	var wg sync.WaitGroup
+	p.Add(100)
+	idle = p.Idle()
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Add(1)
+			p.Add(-2)
+		}()
+	}
+	<-idle
+	assert.Zero(t, p.Load())
+	wg.Wait()
+}
@@ -0,0 +1,24 @@
+// Package jsontime holds time types with a readable JSON form, shared by
+// the config files of the commands and packages of this module.
+package jsontime
+
+import (
+	"time"
+
+	"github.com/goccy/go-json"
+)
+
+// Duration is a time.Duration written as a string such as "30s" in JSON.
+type Duration time.Duration
+
+func (p Duration) MarshalJSON() ([]byte, error) { return json.Marshal(time.Duration(p).String()) }
+
+func (p *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	d, err := time.ParseDuration(s)
+	*p = Duration(d)
+	return err
+}
@@ -0,0 +1,21 @@
+package jsontime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDuration(t *testing.T) {
+	b, err := json.Marshal(Duration(90 * time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, `"1m30s"`, string(b))
+
+	var d Duration
+	assert.NoError(t, json.Unmarshal([]byte(`"250ms"`), &d))
+	assert.Equal(t, Duration(250*time.Millisecond), d)
+	assert.Error(t, json.Unmarshal([]byte(`"soon"`), &d))
+	assert.Error(t, json.Unmarshal([]byte(`30`), &d))
+}
@@ -0,0 +1,474 @@
+package fastjsonrpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/valyala/fastjson"
+	"github.com/zc310/fastjsonrpc/internal/inflight"
+)
+
+var (
+	errJobNotFound    = NewError(-32004, "Job not found")
+	errJobNotFinished = NewError(-32005, "Job not finished")
+	errJobCancelled   = NewError(-32800, "Request cancelled")
+)
+
+// JobState is the state of an asynchronous job.
+type JobState string
+
+const (
+	JobPending   JobState = "pending"
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+	JobCancelled JobState = "cancelled"
+)
+
+// Done reports whether the job finished.
+func (s JobState) Done() bool { return s == JobSucceeded || s == JobFailed || s == JobCancelled }
+
+// Job is a call of an async method. Result or Error is set once the job
+// finished.
+type Job struct {
+	ID       string          `json:"id"`
+	Method   string          `json:"method"`
+	State    JobState        `json:"state"`
+	Progress json.RawMessage `json:"progress,omitempty"`
+	Result   json.RawMessage `json:"result,omitempty"`
+	Error    json.RawMessage `json:"error,omitempty"`
+	Created  time.Time       `json:"created"`
+	Started  *time.Time      `json:"started,omitempty"`
+	Finished *time.Time      `json:"finished,omitempty"`
+}
+
+// JobStore keeps job state. Implementations must be safe for concurrent
+// use and may drop finished jobs according to their retention policy.
+type JobStore interface {
+	Save(j *Job)
+	Load(id string) (*Job, bool)
+}
+
+// Jobs runs the calls of async methods on a pool of Workers goroutines
+// and keeps their state in Store. Up to Queue jobs wait for a worker;
+// further calls fail with "Server busy". Jobs outlive the calls and
+// servers that submitted them: they are cancelled by job.cancel, or by
+// Close when it gives up waiting for them.
+type Jobs struct {
+	Store   JobStore
+	Workers int
+	Queue   int
+
+	once  sync.Once
+	queue chan *task
+
+	mu     sync.Mutex
+	tasks  map[string]*task
+	closed bool
+	// active counts the jobs submitted and not finished, done callbacks
+	// included.
+	active inflight.Counter
+}
+
+type task struct {
+	p      *Jobs
+	job    Job
+	ctx    context.Context
+	cancel context.CancelFunc
+	run    func(ctx context.Context) Outcome
+	done   func(*Job)
+}
+
+type jobKey struct{}
+
+// NewJobs returns a pool of workers goroutines, NumCPU if 0, keeping
+// job state in store.
+func NewJobs(workers int, store JobStore) *Jobs {
+	return &Jobs{Store: store, Workers: workers}
+}
+
+func (p *Jobs) start() {
+	p.once.Do(func() {
+		workers, queue := p.Workers, p.Queue
+		if workers <= 0 {
+			workers = runtime.NumCPU()
+		}
+		if queue <= 0 {
+			queue = 1024
+		}
+		if p.Store == nil {
+			p.Store = NewMemoryJobStore(1000, time.Hour)
+		}
+		p.queue = make(chan *task, queue)
+		p.tasks = make(map[string]*task)
+		for i := 0; i < workers; i++ {
+			go p.work()
+		}
+	})
+}
+
+// Submit queues run as a job of method and returns its id. run gets a
+// context with the values of ctx, cancelled with the job rather than with
+// ctx. done, if not nil, is called with the finished job. Submit fails
+// with "Server shutting down" once Close was called.
+func (p *Jobs) Submit(ctx context.Context, method string, run func(ctx context.Context) Outcome, done func(*Job)) (string, error) {
+	p.start()
+	t := &task{
+		p:    p,
+		job:  Job{ID: newJobID(), Method: method, State: JobPending, Created: time.Now()},
+		run:  run,
+		done: done,
+	}
+	t.ctx, t.cancel = context.WithCancel(context.WithValue(context.WithoutCancel(ctx), jobKey{}, t))
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		t.cancel()
+		return "", errShuttingDown
+	}
+	select {
+	case p.queue <- t:
+	default:
+		p.mu.Unlock()
+		t.cancel()
+		return "", errServerBusy
+	}
+	p.active.Add(1)
+	p.tasks[t.job.ID] = t
+	p.Store.Save(&t.job)
+	p.mu.Unlock()
+	return t.job.ID, nil
+}
+
+func (p *Jobs) work() {
+	for t := range p.queue {
+		p.mu.Lock()
+		if t.job.State != JobPending {
+			// Cancelled while queued.
+			p.mu.Unlock()
+			continue
+		}
+		now := time.Now()
+		t.job.State, t.job.Started = JobRunning, &now
+		p.Store.Save(&t.job)
+		p.mu.Unlock()
+
+		o := p.run(t)
+
+		p.mu.Lock()
+		if t.ctx.Err() != nil && o.Error == nil {
+			o = Outcome{Error: errorObject(errJobCancelled)}
+		}
+		switch {
+		case t.job.State == JobCancelled:
+		case o.Error != nil:
+			t.job.State, t.job.Error = JobFailed, o.Error
+		default:
+			t.job.State, t.job.Result = JobSucceeded, o.Result
+		}
+		p.finish(t)
+		p.mu.Unlock()
+	}
+}
+
+func (p *Jobs) run(t *task) (o Outcome) {
+	defer func() {
+		if recover() != nil {
+			o = Outcome{Error: errorObject(NewError(-32603, "Internal error"))}
+		}
+	}()
+	return t.run(t.ctx)
+}
+
+// finish records the final state of t. It must be called with mu held.
+func (p *Jobs) finish(t *task) {
+	now := time.Now()
+	t.job.Finished = &now
+	t.cancel()
+	delete(p.tasks, t.job.ID)
+	p.Store.Save(&t.job)
+	if t.done == nil {
+		p.active.Add(-1)
+		return
+	}
+	j := t.job
+	go func() {
+		defer p.active.Add(-1)
+		t.done(&j)
+	}()
+}
+
+// Close stops accepting jobs and waits for the queued and running ones to
+// finish. If ctx expires first, the remaining jobs are cancelled and
+// ctx.Err() is returned. Finished jobs can still be queried.
+func (p *Jobs) Close(ctx context.Context) error {
+	p.start()
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-p.active.Idle():
+		return nil
+	case <-ctx.Done():
+		p.mu.Lock()
+		for _, t := range p.tasks {
+			t.cancel()
+		}
+		p.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Status returns the job with id.
+func (p *Jobs) Status(id string) (*Job, error) {
+	p.start()
+	if j, ok := p.Store.Load(id); ok {
+		return j, nil
+	}
+	return nil, errJobNotFound
+}
+
+// Result returns the outcome of the finished job with id.
+func (p *Jobs) Result(id string) (Outcome, error) {
+	j, err := p.Status(id)
+	if err != nil {
+		return Outcome{}, err
+	}
+	if !j.State.Done() {
+		return Outcome{}, &Error{Code: errJobNotFinished.Code, Message: errJobNotFinished.Message, Data: j.State}
+	}
+	if j.Error != nil {
+		return Outcome{Error: j.Error}, nil
+	}
+	return Outcome{Result: j.Result}, nil
+}
+
+// Cancel cancels the job with id and returns its state. A running job
+// ends when its handler returns; a finished job is left unchanged.
+func (p *Jobs) Cancel(id string) (*Job, error) {
+	p.start()
+	p.mu.Lock()
+	if t, ok := p.tasks[id]; ok {
+		pending := t.job.State == JobPending
+		t.job.State, t.job.Error = JobCancelled, errorObject(errJobCancelled)
+		if pending {
+			p.finish(t)
+		} else {
+			t.cancel()
+			p.Store.Save(&t.job)
+		}
+	}
+	p.mu.Unlock()
+	return p.Status(id)
+}
+
+// SetJobProgress records progress, encoded as JSON, on the job running
+// with ctx. It reports whether ctx belongs to a job.
+func SetJobProgress(ctx context.Context, progress any) bool {
+	t, ok := ctx.Value(jobKey{}).(*task)
+	if !ok {
+		return false
+	}
+	b, err := json.Marshal(progress)
+	if err != nil {
+		return false
+	}
+	p := t.p
+	p.mu.Lock()
+	if t.job.State == JobRunning {
+		t.job.Progress = b
+		p.Store.Save(&t.job)
+	}
+	p.mu.Unlock()
+	return true
+}
+
+// JobID returns the job id in params, given as {"id":"..."} or ["..."].
+func JobID(params *fastjson.Value) string {
+	if params == nil {
+		return ""
+	}
+	if params.Type() == fastjson.TypeArray {
+		return string(params.GetStringBytes("0"))
+	}
+	return string(params.GetStringBytes("id"))
+}
+
+// Register serves job.status, job.result and job.cancel on s. Each takes
+// the job id as {"id":"..."} or ["..."].
+func (p *Jobs) Register(s *ServerMap) {
+	s.RegisterHandler("job.status", func(c *RequestCtx) {
+		j, err := p.Status(JobID(c.Params))
+		if err != nil {
+			c.Error = err
+			return
+		}
+		c.Result = j
+	})
+	s.RegisterHandler("job.result", func(c *RequestCtx) {
+		o, err := p.Result(JobID(c.Params))
+		switch {
+		case err != nil:
+			c.Error = err
+		case o.Error != nil:
+			c.Error = []byte(o.Error)
+		default:
+			c.SetRawResult(append(c.ResultBuffer(), o.Result...))
+		}
+	})
+	s.RegisterHandler("job.cancel", func(c *RequestCtx) {
+		j, err := p.Cancel(JobID(c.Params))
+		if err != nil {
+			c.Error = err
+			return
+		}
+		c.Result = j
+	})
+}
+
+func newJobID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// MemoryJobStore keeps jobs in memory. Finished jobs are dropped after
+// Retention, and the oldest of them beyond MaxJobs.
+type MemoryJobStore struct {
+	MaxJobs   int
+	Retention time.Duration
+
+	mu       sync.Mutex
+	jobs     map[string]*Job
+	finished []string
+}
+
+// NewMemoryJobStore returns a store keeping up to maxJobs finished jobs
+// for retention.
+func NewMemoryJobStore(maxJobs int, retention time.Duration) *MemoryJobStore {
+	return &MemoryJobStore{MaxJobs: maxJobs, Retention: retention, jobs: make(map[string]*Job)}
+}
+
+func (p *MemoryJobStore) Save(j *Job) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.jobs == nil {
+		p.jobs = make(map[string]*Job)
+	}
+	old, ok := p.jobs[j.ID]
+	if j.State.Done() && (!ok || !old.State.Done()) {
+		p.finished = append(p.finished, j.ID)
+	}
+	c := *j
+	p.jobs[j.ID] = &c
+	p.prune()
+}
+
+func (p *MemoryJobStore) Load(id string) (*Job, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.prune()
+	j, ok := p.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	c := *j
+	return &c, true
+}
+
+// Len returns the number of jobs kept.
+func (p *MemoryJobStore) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.jobs)
+}
+
+// prune drops expired finished jobs. It must be called with mu held.
+func (p *MemoryJobStore) prune() {
+	now := time.Now()
+	for len(p.finished) > 0 {
+		j := p.jobs[p.finished[0]]
+		over := p.MaxJobs > 0 && len(p.finished) > p.MaxJobs
+		expired := p.Retention > 0 && j.Finished != nil && now.Sub(*j.Finished) > p.Retention
+		if !over && !expired {
+			return
+		}
+		delete(p.jobs, p.finished[0])
+		p.finished = p.finished[1:]
+	}
+}
+
+// Async runs the registered method, or every method of the registered
+// service, as a job on ServerMap.Jobs. A call returns {"jobId":"..."}
+// once params are validated, and job.status, job.result and job.cancel
+// are served alongside. The handler runs without the HTTP request: Ctx
+// is nil and Context returns the job context.
+func Async() Option { return func(o *options) { o.async = true } }
+
+func asyncHandler(p *Jobs, h Handler) Handler {
+	return func(c *RequestCtx) {
+		var params []byte
+		if c.Params != nil {
+			params = c.Params.MarshalTo(nil)
+		}
+		method := string(c.Method)
+		id, err := p.Submit(c.Context(), method, func(ctx context.Context) Outcome {
+			return runJob(ctx, method, params, h)
+		}, nil)
+		if err != nil {
+			c.Error = err
+			return
+		}
+		b := append(c.ResultBuffer(), `{"jobId":"`...)
+		b = append(b, id...)
+		c.SetRawResult(append(b, `"}`...))
+	}
+}
+
+// runJob calls h with params on a context of its own.
+func runJob(ctx context.Context, method string, params []byte, h Handler) Outcome {
+	c := getContext()
+	defer putContext(c)
+	c.ctx = ctx
+	c.Method = []byte(method)
+	c.id = append(c.id[:0], '0')
+	if params == nil {
+		params = null
+	}
+	var err error
+	if c.Params, err = c.pr.ParseBytes(params); err != nil {
+		return Outcome{Error: errorObject(err)}
+	}
+
+	h(c)
+	if c.Error != nil {
+		return Outcome{Error: errorObject(c.Error)}
+	}
+	b, err := c.appendResult(nil)
+	if err != nil {
+		return Outcome{Error: errorObject(err)}
+	}
+	return Outcome{Result: append([]byte(nil), b...)}
+}
+
+// jobs returns the job pool of the server, creating it and serving the
+// job methods on first use.
+func (p *ServerMap) jobs() *Jobs {
+	p.jobsOnce.Do(func() {
+		if p.Jobs == nil {
+			p.Jobs = NewJobs(0, nil)
+		}
+		p.Jobs.Register(p)
+	})
+	return p.Jobs
+}
@@ -0,0 +1,275 @@
+package fastjsonrpc_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/pretty"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+	"github.com/valyala/fastjson"
+	. "github.com/zc310/fastjsonrpc"
+	"github.com/zc310/fastjsonrpc/client"
+	"github.com/zc310/fastjsonrpc/ws"
+)
+
+func TestAsync(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	s := new(ServerMap)
+	s.RegisterHandler("report", func(c *RequestCtx) {
+		SetJobProgress(c.Context(), map[string]int{"done": 1})
+		select {
+		case <-release:
+		case <-c.Context().Done():
+			return
+		}
+		var p struct{ N int }
+		if err := c.ParamsUnmarshal(&p); err != nil {
+			c.Error = err
+			return
+		}
+		c.Result = p.N * 2
+	}, Async(), WithSchema(MustCompileSchema([]byte(`{"type":"object","required":["n"]}`))))
+
+	call := func(request string) string {
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+		ctx.Request.SetBodyString(request)
+		s.Handler(ctx)
+		return string(pretty.Ugly(ctx.Response.Body()))
+	}
+	jobID := func(response string) string {
+		id := fastjson.GetString([]byte(response), "result", "jobId")
+		require.Len(t, id, 32, response)
+		return id
+	}
+
+	// Params are validated before the job is queued.
+	assert.Contains(t, call(`{"jsonrpc":"2.0","method":"report","params":{},"id":1}`), `"code":-32602`)
+
+	id := jobID(call(`{"jsonrpc":"2.0","method":"report","params":{"n":21},"id":1}`))
+	require.Eventually(t, func() bool {
+		return fastjson.GetString([]byte(call(`{"jsonrpc":"2.0","method":"job.status","params":{"id":"`+id+`"},"id":2}`)), "result", "state") == "running"
+	}, time.Second, time.Millisecond)
+	status := call(`{"jsonrpc":"2.0","method":"job.status","params":["` + id + `"],"id":2}`)
+	assert.Contains(t, status, `"method":"report"`)
+	assert.Contains(t, status, `"progress":{"done":1}`)
+	assert.Equal(t, `{"jsonrpc":"2.0","error":{"code":-32005,"message":"Job not finished","data":"running"},"id":3}`,
+		call(`{"jsonrpc":"2.0","method":"job.result","params":{"id":"`+id+`"},"id":3}`))
+
+	close(release)
+	require.Eventually(t, func() bool {
+		return call(`{"jsonrpc":"2.0","method":"job.result","params":{"id":"`+id+`"},"id":4}`) == `{"jsonrpc":"2.0","result":42,"id":4}`
+	}, time.Second, time.Millisecond)
+	assert.Contains(t, call(`{"jsonrpc":"2.0","method":"job.status","params":{"id":"`+id+`"},"id":5}`), `"state":"succeeded"`)
+
+	assert.Equal(t, `{"jsonrpc":"2.0","error":{"code":-32004,"message":"Job not found"},"id":6}`,
+		call(`{"jsonrpc":"2.0","method":"job.result","params":{"id":"x"},"id":6}`))
+}
+
+func TestAsyncCancel(t *testing.T) {
+	t.Parallel()
+
+	s := &ServerMap{Jobs: NewJobs(1, nil)}
+	started := make(chan struct{}, 2)
+	s.RegisterHandler("wait", func(c *RequestCtx) {
+		started <- struct{}{}
+		<-c.Context().Done()
+	}, Async())
+
+	call := func(request string) string {
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Request.SetBodyString(request)
+		s.Handler(ctx)
+		return string(ctx.Response.Body())
+	}
+
+	running := fastjson.GetString([]byte(call(`{"jsonrpc":"2.0","method":"wait","id":1}`)), "result", "jobId")
+	queued := fastjson.GetString([]byte(call(`{"jsonrpc":"2.0","method":"wait","id":1}`)), "result", "jobId")
+	<-started
+
+	// A queued job never runs; a running one sees its context cancelled.
+	assert.Contains(t, call(`{"jsonrpc":"2.0","method":"job.cancel","params":["`+queued+`"],"id":2}`), `"state":"cancelled"`)
+	assert.Contains(t, call(`{"jsonrpc":"2.0","method":"job.cancel","params":["`+running+`"],"id":2}`), `"state":"cancelled"`)
+	require.Eventually(t, func() bool {
+		j, err := s.Jobs.Status(running)
+		return err == nil && j.Finished != nil
+	}, time.Second, time.Millisecond)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","error":{"code":-32800,"message":"Request cancelled"},"id":3}`,
+		call(`{"jsonrpc":"2.0","method":"job.result","params":["`+running+`"],"id":3}`))
+	assert.Len(t, started, 0)
+}
+
+func TestAsyncShutdown(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	s := &ServerMap{Jobs: NewJobs(1, nil)}
+	s.RegisterHandler("wait", func(c *RequestCtx) {
+		select {
+		case <-release:
+			c.Result = "done"
+		case <-c.Context().Done():
+			c.Error = c.Context().Err()
+		}
+	}, Async())
+
+	call := func(request string) string {
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Request.SetBodyString(request)
+		s.Handler(ctx)
+		return string(ctx.Response.Body())
+	}
+	running := fastjson.GetString([]byte(call(`{"jsonrpc":"2.0","method":"wait","id":1}`)), "result", "jobId")
+	queued := fastjson.GetString([]byte(call(`{"jsonrpc":"2.0","method":"wait","id":2}`)), "result", "jobId")
+
+	// Shutdown drains the jobs rather than cancelling them.
+	shutdown := make(chan error)
+	go func() { shutdown <- s.Shutdown(context.Background()) }()
+	select {
+	case err := <-shutdown:
+		t.Fatalf("shutdown returned with jobs running: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	_, err := s.Jobs.Submit(context.Background(), "wait", func(context.Context) Outcome { return Outcome{} }, nil)
+	assert.Error(t, err)
+
+	close(release)
+	require.NoError(t, <-shutdown)
+	for _, id := range []string{running, queued} {
+		o, err := s.Jobs.Result(id)
+		require.NoError(t, err)
+		assert.JSONEq(t, `"done"`, string(o.Result))
+	}
+
+	// Jobs still running when the deadline expires are cancelled.
+	p := NewJobs(1, nil)
+	id, err := p.Submit(context.Background(), "wait", func(ctx context.Context) Outcome {
+		<-ctx.Done()
+		return Outcome{}
+	}, nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, p.Close(ctx))
+	require.NoError(t, p.Close(context.Background()))
+	j, err := p.Status(id)
+	require.NoError(t, err)
+	assert.Equal(t, JobFailed, j.State)
+}
+
+func TestAsyncShutdownWebSocket(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	jobs := NewJobs(1, nil)
+	j := ws.NewJSONRPC2(ws.WithJobs(jobs))
+	j.RegisterMethodContext("wait", func(ctx context.Context, _ *fastjson.Arena, _ *fastjson.Value) (interface{}, error) {
+		select {
+		case <-release:
+			return "done", nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}, ws.Async())
+
+	b, err := j.HandleMessage([]byte(`{"jsonrpc":"2.0","method":"wait","id":1}`))
+	require.NoError(t, err)
+	id := fastjson.GetString(b, "result", "jobId")
+
+	shutdown := make(chan error)
+	go func() { shutdown <- j.Shutdown(context.Background()) }()
+	select {
+	case err := <-shutdown:
+		t.Fatalf("shutdown returned with jobs running: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	require.NoError(t, <-shutdown)
+	job, err := jobs.Status(id)
+	require.NoError(t, err)
+	assert.Equal(t, JobSucceeded, job.State)
+}
+
+func TestMemoryJobStore(t *testing.T) {
+	t.Parallel()
+
+	p := NewMemoryJobStore(2, 20*time.Millisecond)
+	now := time.Now()
+	p.Save(&Job{ID: "running", State: JobRunning})
+	for _, id := range []string{"a", "b", "c"} {
+		p.Save(&Job{ID: id, State: JobSucceeded, Finished: &now})
+	}
+	_, ok := p.Load("a")
+	assert.False(t, ok)
+	j, ok := p.Load("c")
+	require.True(t, ok)
+	assert.Equal(t, JobSucceeded, j.State)
+	assert.Equal(t, 3, p.Len())
+
+	time.Sleep(30 * time.Millisecond)
+	_, ok = p.Load("c")
+	assert.False(t, ok)
+	_, ok = p.Load("running")
+	assert.True(t, ok)
+}
+
+func TestAsyncWebSocket(t *testing.T) {
+	t.Parallel()
+
+	j := ws.NewJSONRPC2()
+	j.RegisterMethodContext("double", func(ctx context.Context, _ *fastjson.Arena, params *fastjson.Value) (interface{}, error) {
+		if params.GetInt("n") < 0 {
+			return nil, ws.NewRPCError(7, "negative", map[string]int{"n": params.GetInt("n")})
+		}
+		return params.GetInt("n") * 2, nil
+	}, ws.Async())
+
+	ln := fasthttputil.NewInmemoryListener()
+	go func() { _ = fasthttp.Serve(ln, ws.Handler(j, &websocket.FastHTTPUpgrader{})) }()
+	t.Cleanup(func() { _ = ln.Close() })
+
+	completed := make(chan Job, 2)
+	tr, err := client.DialWebSocket(context.Background(), "ws://test/ws", client.WithDialer(&websocket.Dialer{
+		NetDial: func(string, string) (net.Conn, error) { return ln.Dial() },
+	}), client.WithNotifyHandler(func(method string, params json.RawMessage) {
+		var job Job
+		if method == ws.JobCompleted && json.Unmarshal(params, &job) == nil {
+			completed <- job
+		}
+	}))
+	require.NoError(t, err)
+	c := client.New(tr)
+	defer c.Close()
+
+	var r struct{ JobID string }
+	require.NoError(t, c.Call(context.Background(), "double", map[string]int{"n": 21}, &r))
+	job := <-completed
+	assert.Equal(t, r.JobID, job.ID)
+	assert.Equal(t, JobSucceeded, job.State)
+	assert.JSONEq(t, `42`, string(job.Result))
+
+	var n int
+	require.NoError(t, c.Call(context.Background(), "job.result", []string{r.JobID}, &n))
+	assert.Equal(t, 42, n)
+
+	require.NoError(t, c.Call(context.Background(), "double", map[string]int{"n": -1}, &r))
+	assert.Equal(t, JobFailed, (<-completed).State)
+	var e *Error
+	require.ErrorAs(t, c.Call(context.Background(), "job.result", []string{r.JobID}, nil), &e)
+	assert.Equal(t, 7, e.Code)
+	assert.Equal(t, map[string]any{"n": float64(-1)}, e.Data)
+
+	// Over HTTP the job runs without a completion notification.
+	b, err := j.HandleMessage([]byte(`{"jsonrpc":"2.0","method":"job.status","params":{"id":"` + r.JobID + `"},"id":1}`))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"state":"failed"`)
+}
@@ -0,0 +1,90 @@
+package fastjsonrpc
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Limiter caps the number of concurrent executions of the handlers it guards.
+// Calls beyond the limit wait up to the configured duration for a free slot
+// and are rejected with a busy error afterwards. A single Limiter may be
+// shared by several methods or services to build a bulkhead.
+type Limiter struct {
+	sem  chan struct{}
+	wait time.Duration
+
+	waiting  atomic.Int64
+	rejected atomic.Int64
+	total    atomic.Int64
+}
+
+// LimiterStats is a snapshot of the Limiter gauges.
+type LimiterStats struct {
+	Limit    int   `json:"limit"`
+	InFlight int   `json:"inFlight"`
+	Waiting  int64 `json:"waiting"`
+	Rejected int64 `json:"rejected"`
+	Total    int64 `json:"total"`
+}
+
+func NewLimiter(limit int, wait time.Duration) *Limiter {
+	if limit <= 0 {
+		limit = 1
+	}
+	return &Limiter{sem: make(chan struct{}, limit), wait: wait}
+}
+
+// Acquire reserves an execution slot. It returns false if no slot became
+// available within the wait duration.
+func (p *Limiter) Acquire() bool {
+	select {
+	case p.sem <- struct{}{}:
+		p.total.Add(1)
+		return true
+	default:
+	}
+	if p.wait <= 0 {
+		p.rejected.Add(1)
+		return false
+	}
+
+	p.waiting.Add(1)
+	t := time.NewTimer(p.wait)
+	defer func() {
+		t.Stop()
+		p.waiting.Add(-1)
+	}()
+
+	select {
+	case p.sem <- struct{}{}:
+		p.total.Add(1)
+		return true
+	case <-t.C:
+		p.rejected.Add(1)
+		return false
+	}
+}
+
+// Release frees a slot reserved by Acquire.
+func (p *Limiter) Release() { <-p.sem }
+
+func (p *Limiter) Stats() LimiterStats {
+	return LimiterStats{
+		Limit:    cap(p.sem),
+		InFlight: len(p.sem),
+		Waiting:  p.waiting.Load(),
+		Rejected: p.rejected.Load(),
+		Total:    p.total.Load(),
+	}
+}
+
+func limitHandler(l *Limiter, h Handler) Handler {
+	return func(c *RequestCtx) {
+		if !l.Acquire() {
+			c.Error = errServerBusy
+			return
+		}
+		defer l.Release()
+		h(c)
+	}
+}
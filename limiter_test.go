@@ -0,0 +1,54 @@
+package fastjsonrpc_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/pretty"
+	"github.com/valyala/fasthttp"
+	. "github.com/zc310/fastjsonrpc"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	s := new(ServerMap)
+	s.RegisterHandler("slow", func(c *RequestCtx) {
+		started <- struct{}{}
+		<-release
+		c.Result = "done"
+	}, WithConcurrency(1, 10*time.Millisecond))
+	s.RegisterHandler("fast", func(c *RequestCtx) { c.Result = "fast" })
+
+	call := func(request string) string {
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+		ctx.Request.SetBodyString(request)
+		s.Handler(ctx)
+		return string(pretty.Ugly(ctx.Response.Body()))
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.Equal(t, `{"jsonrpc":"2.0","result":"done","id":1}`, call(`{"jsonrpc":"2.0","method":"slow","id":1}`))
+	}()
+	<-started
+
+	assert.Equal(t, `{"jsonrpc":"2.0","error":{"code":-32001,"message":"Server busy"},"id":2}`, call(`{"jsonrpc":"2.0","method":"slow","id":2}`))
+	assert.Equal(t, `{"jsonrpc":"2.0","result":"fast","id":3}`, call(`{"jsonrpc":"2.0","method":"fast","id":3}`))
+
+	stats := s.Limiters()["slow"]
+	assert.Equal(t, 1, stats.InFlight)
+	assert.Equal(t, int64(1), stats.Rejected)
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, 0, s.Limiters()["slow"].InFlight)
+}
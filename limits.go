@@ -0,0 +1,98 @@
+package fastjsonrpc
+
+import "errors"
+
+const (
+	defaultMaxBatchSize = 32
+	limitsStackCap      = 32
+)
+
+var (
+	ErrBodyTooLarge  = errors.New("request body too large")
+	ErrDepthExceeded = errors.New("nesting depth limit exceeded")
+	ErrStringTooLong = errors.New("string length limit exceeded")
+	ErrArrayTooLong  = errors.New("array length limit exceeded")
+	ErrBatchTooLarge = errors.New("batch size limit exceeded")
+)
+
+// Limits bounds the size and shape of incoming payloads. Zero fields are
+// not enforced. The checks run on the raw bytes before parsing, so deeply
+// nested or oversized payloads are rejected without building a value tree.
+type Limits struct {
+	MaxBodySize     int // bytes
+	MaxDepth        int // nesting of objects and arrays
+	MaxStringLength int // raw bytes of a single string, keys included
+	MaxArrayLength  int // elements of a single array, batches included
+	MaxBatchSize    int // calls in a batch request
+}
+
+// Check validates b against the limits. It does not validate JSON syntax.
+func (p *Limits) Check(b []byte) error {
+	if p.MaxBodySize > 0 && len(b) > p.MaxBodySize {
+		return ErrBodyTooLarge
+	}
+	if p.MaxDepth <= 0 && p.MaxStringLength <= 0 && p.MaxArrayLength <= 0 {
+		return nil
+	}
+
+	// stack holds the element count of every open array and -1 for objects.
+	var buf [limitsStackCap]int
+	stack := buf[:0]
+	var inString, escaped bool
+	var strStart int
+
+	for i := 0; i < len(b); i++ {
+		ch := b[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+				if p.MaxStringLength > 0 && i-strStart > p.MaxStringLength {
+					return ErrStringTooLong
+				}
+			}
+			continue
+		}
+
+		switch ch {
+		case '"':
+			inString = true
+			strStart = i + 1
+		case '{', '[':
+			if ch == '{' {
+				stack = append(stack, -1)
+			} else {
+				stack = append(stack, 1)
+			}
+			if p.MaxDepth > 0 && len(stack) > p.MaxDepth {
+				return ErrDepthExceeded
+			}
+		case '}', ']':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case ',':
+			if n := len(stack); n > 0 && stack[n-1] > 0 {
+				stack[n-1]++
+				if p.MaxArrayLength > 0 && stack[n-1] > p.MaxArrayLength {
+					return ErrArrayTooLong
+				}
+			}
+		}
+	}
+	if inString && p.MaxStringLength > 0 && len(b)-strStart > p.MaxStringLength {
+		return ErrStringTooLong
+	}
+	return nil
+}
+
+func (p *Limits) maxBatchSize() int {
+	if p.MaxBatchSize > 0 {
+		return p.MaxBatchSize
+	}
+	return defaultMaxBatchSize
+}
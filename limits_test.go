@@ -0,0 +1,57 @@
+package fastjsonrpc_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/pretty"
+	"github.com/valyala/fasthttp"
+	. "github.com/zc310/fastjsonrpc"
+)
+
+func TestLimitsCheck(t *testing.T) {
+	t.Parallel()
+
+	l := Limits{MaxBodySize: 64, MaxDepth: 3, MaxStringLength: 5, MaxArrayLength: 3}
+
+	assert.NoError(t, l.Check([]byte(`{"a":[1,2,3],"b":{"c":"abcde"}}`)))
+	assert.NoError(t, l.Check([]byte(`["a,,,"]`)))
+	assert.NoError(t, l.Check([]byte(`{"k":"\"\""}`)))
+	assert.Equal(t, ErrBodyTooLarge, l.Check([]byte(strings.Repeat(" ", 65))))
+	assert.Equal(t, ErrDepthExceeded, l.Check([]byte(`[[[[1]]]]`)))
+	assert.Equal(t, ErrStringTooLong, l.Check([]byte(`{"a":"abcdef"}`)))
+	assert.Equal(t, ErrArrayTooLong, l.Check([]byte(`[1,2,3,4]`)))
+	assert.Equal(t, ErrDepthExceeded, (&Limits{MaxDepth: 100}).Check([]byte(strings.Repeat("[", 1000))))
+}
+
+func TestLimits(t *testing.T) {
+	t.Parallel()
+
+	s := new(ServerMap)
+	s.Limits = Limits{MaxDepth: 4, MaxBatchSize: 2}
+	s.RegisterHandler("echo", func(c *RequestCtx) { c.Result = c.Params })
+
+	f := func(request, response string) {
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+		ctx.Request.SetBodyString(request)
+
+		s.Handler(ctx)
+
+		assert.Equal(t, string(pretty.Ugly([]byte(response))), string(pretty.Ugly(ctx.Response.Body())))
+	}
+
+	f(
+		`{"jsonrpc":"2.0","method":"echo","params":[[1]],"id":1}`,
+		`{"jsonrpc":"2.0","result":[[1]],"id":1}`,
+	)
+	f(
+		`{"jsonrpc":"2.0","method":"echo","params":[[[[1]]]],"id":1}`,
+		`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request","data":"nesting depth limit exceeded"},"id":null}`,
+	)
+	f(
+		`[{"jsonrpc":"2.0","method":"echo","id":1},{"jsonrpc":"2.0","method":"echo","id":2},{"jsonrpc":"2.0","method":"echo","id":3}]`,
+		`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request","data":"batch size limit exceeded"},"id":null}`,
+	)
+}
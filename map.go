@@ -1,46 +1,153 @@
 package fastjsonrpc
 
 import (
+	"context"
 	"errors"
 	"go/token"
 	"reflect"
-	"strings"
+	"sort"
 	"sync"
+	"sync/atomic"
+
+	"github.com/zc310/fastjsonrpc/internal/inflight"
 )
 
 var typeOfContext = reflect.TypeOf(&RequestCtx{})
 
+// handlerService holds the methods registered with RegisterHandler.
+const handlerService = "~"
+
 type service struct {
-	name   string             // name of service
-	rcvr   reflect.Value      // receiver of methods for the service
-	typ    reflect.Type       // type of the receiver
-	method map[string]Handler // registered methods
+	name      string                // name of service
+	aliases   []string              // additional names of service
+	separator string                // separator between service and method names
+	rcvr      reflect.Value         // receiver of methods for the service
+	typ       reflect.Type          // type of the receiver
+	method    map[string]*rpcMethod // registered methods
+	alias     map[string]*rpcMethod // methods registered under full names
+}
+
+type rpcMethod struct {
+	handler     Handler
+	deprecated  bool
+	deprecation string
+	info        MethodInfo
 }
 
 type ServerMap struct {
-	serviceMap sync.Map // map[string]*service
+	// BatchConcurrency limits the number of goroutines used to execute
+	// the calls of a single batch request. Zero means no limit.
+	BatchConcurrency int
+	// Limits bounds incoming payloads. A zero MaxBatchSize means 32.
+	Limits Limits
+	// VersionHeader names the HTTP header used to negotiate method
+	// versions. A call to "m" carrying the header value "v2" is routed
+	// to "m@v2" when that version is registered.
+	VersionHeader string
+	// Naming and Separator are the defaults for WithNaming and
+	// WithSeparator. Go names joined by "." are used when unset.
+	Naming    NamingStrategy
+	Separator string
+	// Jobs runs the methods registered with Async. A pool of NumCPU
+	// workers is created on first use when nil.
+	Jobs *Jobs
+
+	mu         sync.Mutex
+	serviceMap map[string]*service                   // guarded by mu
+	methods    atomic.Pointer[map[string]*rpcMethod] // rebuilt on every change
+	limiters   sync.Map                              // map[string]*Limiter
+
+	jobsOnce sync.Once
+	ctxOnce  sync.Once
+	ctx      context.Context
+	cancel   context.CancelFunc
+	closing  atomic.Bool
+	inFlight inflight.Counter
+}
+
+func (p *ServerMap) Register(rcvr any, opts ...Option) error {
+	return p.register(rcvr, "", false, false, opts)
 }
 
-func (p *ServerMap) Register(rcvr any) error {
-	return p.register(rcvr, "", false)
+func (p *ServerMap) RegisterName(name string, rcvr any, opts ...Option) error {
+	return p.register(rcvr, name, true, false, opts)
 }
 
-func (p *ServerMap) RegisterName(name string, rcvr any) error {
-	return p.register(rcvr, name, true)
+// Replace registers rcvr like Register, atomically replacing any service
+// already registered under the same name.
+func (p *ServerMap) Replace(rcvr any, opts ...Option) error {
+	return p.register(rcvr, "", false, true, opts)
+}
+
+// ReplaceName registers rcvr like RegisterName, atomically replacing any
+// service already registered under name.
+func (p *ServerMap) ReplaceName(name string, rcvr any, opts ...Option) error {
+	return p.register(rcvr, name, true, true, opts)
+}
+
+// RegisterHandler registers handler for method, replacing any handler
+// already registered under that name.
+func (p *ServerMap) RegisterHandler(method string, handler Handler, opts ...Option) {
+	o := p.newOptions(opts)
+	name := o.versioned(method)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s := p.serviceMap[handlerService]
+	if s == nil {
+		s = &service{name: handlerService, method: make(map[string]*rpcMethod)}
+		p.services()[handlerService] = s
+	}
+	m := o.method(handler)
+	s.method[name] = m
+	for _, alias := range o.aliases {
+		s.method[o.versioned(alias)] = m
+	}
+	p.setLimiter(name, o.limiter)
+	p.rebuild()
 }
-func (p *ServerMap) RegisterHandler(method string, handler Handler) {
-	var s *service
-	t, ok := p.serviceMap.Load("~")
+
+// Unregister removes the service registered under name, including its
+// version suffix if any. It reports whether the service existed.
+func (p *ServerMap) Unregister(name string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.serviceMap[name]; !ok || name == handlerService {
+		return false
+	}
+	delete(p.serviceMap, name)
+	p.limiters.Delete(name)
+	p.rebuild()
+	return true
+}
+
+// UnregisterHandler removes a method registered with RegisterHandler,
+// together with its aliases. It reports whether the method existed.
+func (p *ServerMap) UnregisterHandler(method string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s := p.serviceMap[handlerService]
+	if s == nil {
+		return false
+	}
+	m, ok := s.method[method]
 	if !ok {
-		s = new(service)
-		s.method = make(map[string]Handler)
-		p.serviceMap.Store("~", s)
-	} else {
-		s = t.(*service)
+		return false
 	}
-	s.method[method] = handler
+	for k, v := range s.method {
+		if v == m {
+			delete(s.method, k)
+		}
+	}
+	p.limiters.Delete(method)
+	p.rebuild()
+	return true
 }
-func (p *ServerMap) register(rcvr any, name string, useName bool) error {
+
+func (p *ServerMap) register(rcvr any, name string, useName, replace bool, opts []Option) error {
 	s := new(service)
 	s.typ = reflect.TypeOf(rcvr)
 	s.rcvr = reflect.ValueOf(rcvr)
@@ -54,34 +161,143 @@ func (p *ServerMap) register(rcvr any, name string, useName bool) error {
 	if !useName && !token.IsExported(sname) {
 		return errors.New("rpc.Register: type " + sname + " is not exported")
 	}
-	s.name = sname
+	return p.addService(s, sname, useName, replace, suitableMethods(s), opts)
+}
+
+// RegisterMethods registers a service from a static table of handlers
+// keyed by Go method name, as generated by fastjsonrpc-gen -server. The
+// service and its methods are named as Register names a type called
+// name, without reflection on every call.
+func (p *ServerMap) RegisterMethods(name string, methods map[string]Handler, opts ...Option) error {
+	if !token.IsExported(name) {
+		return errors.New("rpc.RegisterMethods: invalid service name " + name)
+	}
+	return p.addService(new(service), name, false, false, methods, opts)
+}
+
+func (p *ServerMap) addService(s *service, sname string, useName, replace bool, methods map[string]Handler, opts []Option) error {
+	o := p.newOptions(opts)
+	if !useName {
+		sname = o.name(sname)
+	}
+	s.name = o.versioned(sname)
+	for _, alias := range o.aliases {
+		s.aliases = append(s.aliases, o.versioned(alias))
+	}
+	s.separator = o.separator
+
+	s.method = make(map[string]*rpcMethod)
+	s.alias = make(map[string]*rpcMethod)
+	for k, h := range methods {
+		m := o.method(h)
+		if t, ok := o.methodTypes[k]; ok {
+			m.info.Params, m.info.Result = t[0], t[1]
+		}
+		if name, ok := o.methodNames[k]; ok {
+			s.method[name] = m
+		} else {
+			s.method[o.name(k)] = m
+		}
+		for _, alias := range o.methodAliases[k] {
+			s.alias[o.versioned(alias)] = m
+		}
+	}
 
-	s.method = suitableMethods(s)
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-	if _, dup := p.serviceMap.LoadOrStore(sname, s); dup {
-		return errors.New("rpc: service already defined: " + sname)
+	if _, dup := p.serviceMap[s.name]; dup && !replace {
+		return errors.New("rpc: service already defined: " + s.name)
 	}
+	p.services()[s.name] = s
+	p.setLimiter(s.name, o.limiter)
+	p.rebuild()
 	return nil
 }
 
-func (p *ServerMap) getFun(m string) (h Handler) {
-	var serviceName, methodName string
-	dot := strings.LastIndex(m, ".")
-	if dot < 0 {
-		serviceName = "~"
-		methodName = m
+func (p *ServerMap) services() map[string]*service {
+	if p.serviceMap == nil {
+		p.serviceMap = make(map[string]*service)
+	}
+	return p.serviceMap
+}
+
+func (p *ServerMap) setLimiter(name string, l *Limiter) {
+	if l == nil {
+		p.limiters.Delete(name)
 	} else {
-		serviceName = m[:dot]
-		methodName = m[dot+1:]
+		p.limiters.Store(name, l)
 	}
+}
 
-	s, ok := p.serviceMap.Load(serviceName)
-	if !ok {
-		return
+// rebuild publishes a new method table. It must be called with mu held.
+func (p *ServerMap) rebuild() {
+	m := make(map[string]*rpcMethod)
+	for _, s := range p.serviceMap {
+		if s.name == handlerService {
+			for k, v := range s.method {
+				m[k] = v
+			}
+			continue
+		}
+		for _, name := range append([]string{s.name}, s.aliases...) {
+			sname, version := splitVersion(name)
+			for k, v := range s.method {
+				m[joinVersion(sname+s.separator+k, version)] = v
+			}
+		}
+		for k, v := range s.alias {
+			m[k] = v
+		}
+	}
+	p.methods.Store(&m)
+}
+
+// Limiters returns the gauges of every limiter registered with
+// WithLimiter or WithConcurrency, keyed by method or service name.
+func (p *ServerMap) Limiters() map[string]LimiterStats {
+	m := make(map[string]LimiterStats)
+	p.limiters.Range(func(k, v any) bool {
+		m[k.(string)] = v.(*Limiter).Stats()
+		return true
+	})
+	return m
+}
+
+// Methods returns the sorted names of all registered methods.
+func (p *ServerMap) Methods() []string {
+	t := p.methods.Load()
+	if t == nil {
+		return nil
+	}
+	a := make([]string, 0, len(*t))
+	for k := range *t {
+		a = append(a, k)
+	}
+	sort.Strings(a)
+	return a
+}
+
+var methodShuttingDown = &rpcMethod{handler: func(c *RequestCtx) { c.Error = errShuttingDown }}
+
+func (p *ServerMap) getFun(c *RequestCtx) *rpcMethod {
+	if p.closing.Load() {
+		return methodShuttingDown
+	}
+	t := p.methods.Load()
+	if t == nil {
+		return nil
+	}
+	if p.VersionHeader != "" && c.Ctx != nil {
+		if v := c.Ctx.Request.Header.Peek(p.VersionHeader); len(v) > 0 {
+			if _, version := splitVersion(string(c.Method)); version == "" {
+				if m := (*t)[string(c.Method)+versionSeparator+string(v)]; m != nil {
+					return m
+				}
+			}
+		}
 	}
-	svc := s.(*service)
-	h = svc.method[methodName]
-	return
+	return (*t)[string(c.Method)]
 }
 
 func suitableMethods(s *service) map[string]Handler {
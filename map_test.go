@@ -0,0 +1,152 @@
+package fastjsonrpc_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/pretty"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fastjson"
+	. "github.com/zc310/fastjsonrpc"
+)
+
+type Greeter struct{}
+
+func (Greeter) Hello(c *RequestCtx) { c.Result = "hello" }
+
+type GreeterV2 struct{}
+
+func (GreeterV2) Hello(c *RequestCtx) { c.Result = "hello v2" }
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+
+	s := &ServerMap{VersionHeader: "X-Rpc-Version"}
+	assert.NoError(t, s.Register(Greeter{}, Deprecated("use v2")))
+	assert.NoError(t, s.RegisterName("Greeter", GreeterV2{}, WithVersion("v2")))
+	assert.Error(t, s.Register(Greeter{}))
+
+	call := func(request, version string) *fasthttp.RequestCtx {
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+		ctx.Request.Header.Set("X-Rpc-Version", version)
+		ctx.Request.SetBodyString(request)
+		s.Handler(ctx)
+		return ctx
+	}
+	body := func(ctx *fasthttp.RequestCtx) string { return string(pretty.Ugly(ctx.Response.Body())) }
+
+	ctx := call(`{"jsonrpc":"2.0","method":"Greeter.Hello","id":1}`, "")
+	assert.Equal(t, `{"jsonrpc":"2.0","result":"hello","id":1}`, body(ctx))
+	assert.Equal(t, "true", string(ctx.Response.Header.Peek("Deprecation")))
+	assert.Equal(t, `299 - "Greeter.Hello is deprecated: use v2"`, string(ctx.Response.Header.Peek("Warning")))
+
+	ctx = call(`{"jsonrpc":"2.0","method":"Greeter.Hello@v2","id":2}`, "")
+	assert.Equal(t, `{"jsonrpc":"2.0","result":"hello v2","id":2}`, body(ctx))
+	assert.Empty(t, ctx.Response.Header.Peek("Deprecation"))
+
+	ctx = call(`{"jsonrpc":"2.0","method":"Greeter.Hello","id":3}`, "v2")
+	assert.Equal(t, `{"jsonrpc":"2.0","result":"hello v2","id":3}`, body(ctx))
+
+	s.RegisterHandler("toggle", func(c *RequestCtx) { c.Result = 1 })
+	assert.Equal(t, []string{"Greeter.Hello", "Greeter.Hello@v2", "toggle"}, s.Methods())
+
+	assert.True(t, s.UnregisterHandler("toggle"))
+	assert.False(t, s.UnregisterHandler("toggle"))
+	assert.True(t, s.Unregister("Greeter@v2"))
+	assert.Equal(t, []string{"Greeter.Hello"}, s.Methods())
+
+	assert.NoError(t, s.ReplaceName("Greeter", GreeterV2{}))
+	ctx = call(`{"jsonrpc":"2.0","method":"Greeter.Hello","id":4}`, "")
+	assert.Equal(t, `{"jsonrpc":"2.0","result":"hello v2","id":4}`, body(ctx))
+	assert.Empty(t, ctx.Response.Header.Peek("Deprecation"))
+}
+
+type Eth struct{}
+
+func (Eth) GetBalance(c *RequestCtx)    { c.Result = 42 }
+func (Eth) BlockNumber(c *RequestCtx)   { c.Result = 7 }
+func (Eth) SendTransaction(*RequestCtx) {}
+
+func TestNaming(t *testing.T) {
+	t.Parallel()
+
+	s := new(ServerMap)
+	assert.NoError(t, s.Register(Eth{},
+		WithNaming(CamelCase),
+		WithSeparator("_"),
+		WithAlias("Eth"),
+		WithMethodName("SendTransaction", "sendTx"),
+		WithMethodAlias("GetBalance", "balance"),
+	))
+	s.RegisterHandler("net_version", func(c *RequestCtx) { c.Result = "1" }, WithAlias("net.version"))
+
+	assert.Equal(t, []string{
+		"Eth_blockNumber", "Eth_getBalance", "Eth_sendTx",
+		"balance",
+		"eth_blockNumber", "eth_getBalance", "eth_sendTx",
+		"net.version", "net_version",
+	}, s.Methods())
+
+	s2 := &ServerMap{Naming: SnakeCase, Separator: "/"}
+	assert.NoError(t, s2.Register(Eth{}))
+	assert.Equal(t, []string{"eth/block_number", "eth/get_balance", "eth/send_transaction"}, s2.Methods())
+
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+	ctx.Request.SetBodyString(`{"jsonrpc":"2.0","method":"eth/get_balance","id":1}`)
+	s2.Handler(ctx)
+	assert.Equal(t, `{"jsonrpc":"2.0","result":42,"id":1}`, string(pretty.Ugly(ctx.Response.Body())))
+}
+
+type fastPoint struct{ X, Y int }
+
+func (p *fastPoint) UnmarshalFastJSON(v *fastjson.Value) error {
+	if v.Type() != fastjson.TypeObject {
+		return InvalidParams(FieldError{Field: "params", Rule: "type", Message: "must be object"})
+	}
+	p.X, p.Y = v.GetInt("x"), v.GetInt("y")
+	return nil
+}
+
+func (p fastPoint) MarshalFastJSON(dst []byte) []byte {
+	dst = append(dst, `{"x":`...)
+	dst = strconv.AppendInt(dst, int64(p.X), 10)
+	dst = append(dst, `,"y":`...)
+	dst = strconv.AppendInt(dst, int64(p.Y), 10)
+	return append(dst, '}')
+}
+
+func TestRegisterMethods(t *testing.T) {
+	t.Parallel()
+
+	s := new(ServerMap)
+	assert.NoError(t, s.RegisterMethods("Point", map[string]Handler{
+		"Swap": func(c *RequestCtx) {
+			var p fastPoint
+			if c.Error = c.ParamsUnmarshal(&p); c.Error == nil {
+				c.Result = fastPoint{X: p.Y, Y: p.X}
+			}
+		},
+		"Raw": func(c *RequestCtx) {
+			c.SetRawResult(append(c.ResultBuffer(), `[1, 2]`...))
+		},
+	}, WithNaming(SnakeCase)))
+	assert.Error(t, s.RegisterMethods("point", nil))
+	assert.Equal(t, []string{"point.raw", "point.swap"}, s.Methods())
+
+	call := func(request string) string {
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+		ctx.Request.SetBodyString(request)
+		s.Handler(ctx)
+		return string(pretty.Ugly(ctx.Response.Body()))
+	}
+	assert.Equal(t, `{"jsonrpc":"2.0","result":{"x":2,"y":1},"id":1}`,
+		call(`{"jsonrpc":"2.0","method":"point.swap","params":{"x":1,"y":2},"id":1}`))
+	assert.Equal(t, `{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params","data":[{"field":"params","rule":"type","message":"must be object"}]},"id":2}`,
+		call(`{"jsonrpc":"2.0","method":"point.swap","params":[1,2],"id":2}`))
+	assert.Equal(t, `{"jsonrpc":"2.0","result":[1,2],"id":3}`,
+		call(`{"jsonrpc":"2.0","method":"point.raw","id":3}`))
+}
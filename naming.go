@@ -0,0 +1,57 @@
+package fastjsonrpc
+
+import "github.com/iancoleman/strcase"
+
+// NamingStrategy maps a Go identifier to an RPC name.
+type NamingStrategy func(name string) string
+
+var (
+	GoCase    NamingStrategy = func(name string) string { return name } // GetBalance
+	SnakeCase NamingStrategy = strcase.ToSnake                          // get_balance
+	CamelCase NamingStrategy = strcase.ToLowerCamel                     // getBalance
+	KebabCase NamingStrategy = strcase.ToKebab                          // get-balance
+)
+
+const defaultSep = "."
+
+// WithNaming names the service and its methods with n instead of the Go
+// identifiers. Names passed to RegisterName are used verbatim.
+func WithNaming(n NamingStrategy) Option { return func(o *options) { o.naming = n } }
+
+// WithSeparator joins service and method names with sep instead of ".".
+func WithSeparator(sep string) Option { return func(o *options) { o.separator = sep } }
+
+// WithAlias registers additional names for the method, or for the service
+// when used with Register.
+func WithAlias(names ...string) Option {
+	return func(o *options) { o.aliases = append(o.aliases, names...) }
+}
+
+// WithMethodName overrides the name of the Go method goName within its
+// service.
+func WithMethodName(goName, name string) Option {
+	return func(o *options) {
+		if o.methodNames == nil {
+			o.methodNames = make(map[string]string)
+		}
+		o.methodNames[goName] = name
+	}
+}
+
+// WithMethodAlias registers the Go method goName under additional full
+// method names, which are not prefixed by the service name.
+func WithMethodAlias(goName string, names ...string) Option {
+	return func(o *options) {
+		if o.methodAliases == nil {
+			o.methodAliases = make(map[string][]string)
+		}
+		o.methodAliases[goName] = append(o.methodAliases[goName], names...)
+	}
+}
+
+func (o *options) name(n string) string {
+	if o.naming == nil {
+		return n
+	}
+	return o.naming(n)
+}
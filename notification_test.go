@@ -0,0 +1,117 @@
+package fastjsonrpc_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fastjson"
+	"github.com/zc310/fastjsonrpc/ws"
+)
+
+func TestNotificationQueue(t *testing.T) {
+	t.Parallel()
+
+	dead := make(chan ws.DeadLetter, 1)
+	q := &ws.NotificationQueue{
+		Dir:          t.TempDir(),
+		Workers:      2,
+		MaxAttempts:  3,
+		Backoff:      time.Millisecond,
+		OnDeadLetter: func(d ws.DeadLetter) { dead <- d },
+	}
+	j := ws.NewJSONRPC2(ws.WithNotificationQueue(q))
+	done := make(chan int, 2)
+	var flaky atomic.Int32
+	j.RegisterMethodFunc("ok", func(params *fastjson.Value) (interface{}, error) {
+		done <- params.GetInt("n")
+		return nil, nil
+	})
+	j.RegisterMethodFunc("flaky", func(params *fastjson.Value) (interface{}, error) {
+		if flaky.Add(1) < 3 {
+			return nil, errors.New("unavailable")
+		}
+		done <- params.GetInt("n")
+		return nil, nil
+	})
+	j.RegisterMethodFunc("bad", func(*fastjson.Value) (interface{}, error) {
+		panic("boom")
+	})
+	require.NoError(t, j.StartNotificationQueue())
+
+	for _, m := range []string{
+		`{"jsonrpc":"2.0","method":"ok","params":{"n":1}}`,
+		`{"jsonrpc":"2.0","method":"flaky","params":{"n":2}}`,
+		`{"jsonrpc":"2.0","method":"bad","params":[3]}`,
+	} {
+		b, err := j.HandleMessage([]byte(m))
+		require.NoError(t, err)
+		assert.Nil(t, b)
+	}
+
+	assert.ElementsMatch(t, []int{1, 2}, []int{<-done, <-done})
+	assert.EqualValues(t, 3, flaky.Load())
+
+	d := <-dead
+	assert.Equal(t, "bad", d.Method)
+	assert.JSONEq(t, `[3]`, string(d.Params))
+	assert.Equal(t, "panic: boom", d.Error)
+	assert.Equal(t, 3, d.Attempts)
+	require.Eventually(t, func() bool { return q.Pending() == 0 }, time.Second, time.Millisecond)
+
+	letters, err := q.DeadLetters()
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.Equal(t, "bad", letters[0].Method)
+}
+
+func TestNotificationQueueReplay(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	started := make(chan struct{}, 1)
+	j := ws.NewJSONRPC2(ws.WithNotificationQueue(&ws.NotificationQueue{Dir: dir, Workers: 1}))
+	j.RegisterMethodContext("event", func(ctx context.Context, _ *fastjson.Arena, _ *fastjson.Value) (interface{}, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	for _, m := range []string{
+		`{"jsonrpc":"2.0","method":"event","params":{"n":1}}`,
+		`{"jsonrpc":"2.0","method":"event","params":{"n":2}}`,
+	} {
+		_, err := j.HandleMessage([]byte(m))
+		require.NoError(t, err)
+	}
+	<-started
+
+	// Neither notification completes before the deploy.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, j.Shutdown(ctx), context.DeadlineExceeded)
+
+	// A record torn by a crash is ignored.
+	f, err := os.OpenFile(filepath.Join(dir, "notifications.log"), os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"seq":3,"method":"ev`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	q := &ws.NotificationQueue{Dir: dir, Workers: 1}
+	j = ws.NewJSONRPC2(ws.WithNotificationQueue(q))
+	done := make(chan int, 2)
+	j.RegisterMethodFunc("event", func(params *fastjson.Value) (interface{}, error) {
+		done <- params.GetInt("n")
+		return nil, nil
+	})
+	require.NoError(t, j.StartNotificationQueue())
+	assert.Equal(t, []int{1, 2}, []int{<-done, <-done})
+	require.Eventually(t, func() bool { return q.Pending() == 0 }, time.Second, time.Millisecond)
+	require.NoError(t, j.Shutdown(context.Background()))
+}
@@ -0,0 +1,159 @@
+package mock
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/zc310/fastjsonrpc/openrpc"
+)
+
+// maxDepth bounds generated values of recursive schemas.
+const maxDepth = 8
+
+// formats holds the generated values of well-known string formats.
+var formats = map[string]string{
+	"date-time": "2024-01-01T00:00:00Z",
+	"date":      "2024-01-01",
+	"time":      "00:00:00Z",
+	"email":     "user@example.com",
+	"hostname":  "example.com",
+	"ipv4":      "192.0.2.1",
+	"ipv6":      "2001:db8::1",
+	"uri":       "https://example.com/",
+	"uuid":      "00000000-0000-0000-0000-000000000000",
+	"byte":      "AA==",
+}
+
+// generator produces deterministic values that satisfy a schema.
+type generator struct {
+	doc *openrpc.Document
+}
+
+func (g *generator) resolve(s *openrpc.Schema) *openrpc.Schema {
+	for i := 0; s != nil && s.Ref != "" && i < maxDepth; i++ {
+		name, ok := strings.CutPrefix(s.Ref, openrpc.RefPrefix)
+		if !ok || g.doc.Components == nil {
+			return nil
+		}
+		s = g.doc.Components.Schemas[name]
+	}
+	return s
+}
+
+func (g *generator) value(s *openrpc.Schema, depth int) any {
+	s = g.resolve(s)
+	if s == nil || depth > maxDepth {
+		return nil
+	}
+	switch {
+	case s.Default != nil:
+		return s.Default
+	case len(s.Examples) > 0:
+		return s.Examples[0]
+	case len(s.Enum) > 0:
+		return s.Enum[0]
+	case len(s.OneOf) > 0:
+		return g.value(s.OneOf[0], depth+1)
+	case len(s.AnyOf) > 0:
+		return g.value(s.AnyOf[0], depth+1)
+	case len(s.AllOf) > 0:
+		m := make(map[string]any)
+		for _, item := range s.AllOf {
+			if o, ok := g.value(item, depth+1).(map[string]any); ok {
+				for k, v := range o {
+					m[k] = v
+				}
+			}
+		}
+		return m
+	}
+
+	switch typeOf(s) {
+	case "string":
+		return str(s)
+	case "integer":
+		return number(s, 1, true)
+	case "number":
+		return number(s, 1.5, false)
+	case "boolean":
+		return true
+	case "array":
+		n := 1
+		if s.MinItems != nil {
+			n = *s.MinItems
+		}
+		if s.MaxItems != nil && *s.MaxItems < n {
+			n = *s.MaxItems
+		}
+		a := make([]any, n)
+		for i := range a {
+			a[i] = g.value(s.Items, depth+1)
+		}
+		return a
+	case "object":
+		m := make(map[string]any, len(s.Properties))
+		required := make(map[string]bool, len(s.Required))
+		for _, n := range s.Required {
+			required[n] = true
+		}
+		for k, v := range s.Properties {
+			// Optional properties stop the recursion first.
+			if depth < maxDepth/2 || required[k] {
+				m[k] = g.value(v, depth+1)
+			}
+		}
+		if len(s.Properties) == 0 && s.AdditionalProperties != nil {
+			m["key"] = g.value(s.AdditionalProperties, depth+1)
+		}
+		return m
+	}
+	return nil
+}
+
+// typeOf returns the first type of s other than null, inferring objects
+// and arrays from their keywords.
+func typeOf(s *openrpc.Schema) string {
+	types := append([]string(nil), s.Type...)
+	sort.SliceStable(types, func(i, j int) bool { return types[j] == "null" && types[i] != "null" })
+	switch {
+	case len(types) > 0:
+		return types[0]
+	case s.Properties != nil || s.AdditionalProperties != nil:
+		return "object"
+	case s.Items != nil:
+		return "array"
+	}
+	return ""
+}
+
+func str(s *openrpc.Schema) string {
+	v, ok := formats[s.Format]
+	if !ok {
+		v = "string"
+	}
+	if s.MinLength != nil && len(v) < *s.MinLength {
+		v += strings.Repeat("x", *s.MinLength-len(v))
+	}
+	if s.MaxLength != nil && len(v) > *s.MaxLength {
+		v = v[:*s.MaxLength]
+	}
+	return v
+}
+
+// number returns def moved into the bounds of s.
+func number(s *openrpc.Schema, def float64, integer bool) float64 {
+	if s.Minimum != nil && def < *s.Minimum {
+		def = *s.Minimum
+		if integer {
+			def = math.Ceil(def)
+		}
+	}
+	if s.Maximum != nil && def > *s.Maximum {
+		def = *s.Maximum
+		if integer {
+			def = math.Floor(def)
+		}
+	}
+	return def
+}
@@ -0,0 +1,220 @@
+// Package mock serves the methods of an OpenRPC document with synthetic
+// results, so that clients can be built and tested before the server
+// exists.
+//
+// A call returns the result of the example pairing of its method that
+// matches the most params of the call, falling back to the first example,
+// or data generated from the result schema when the method has no
+// examples. The document itself is served as rpc.discover.
+//
+//	d, _ := openrpc.Parse(b)
+//	s := new(fastjsonrpc.ServerMap)
+//	err := mock.Register(s, d)
+package mock
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/goccy/go-json"
+	"github.com/valyala/fastjson"
+	"github.com/zc310/fastjsonrpc"
+	"github.com/zc310/fastjsonrpc/openrpc"
+	"github.com/zc310/fastjsonrpc/ws"
+)
+
+// Mock answers the methods of a document.
+type Mock struct {
+	doc     *openrpc.Document
+	methods map[string]*method
+}
+
+type method struct {
+	m        *openrpc.Method
+	pos      map[string]int // param positions by name
+	examples []example
+	result   []byte // generated from the result schema
+}
+
+type example struct {
+	params map[string][]byte // canonical values by param name
+	result []byte
+}
+
+// New prepares the answers of every method of d.
+func New(d *openrpc.Document) (*Mock, error) {
+	p := &Mock{doc: d, methods: make(map[string]*method, len(d.Methods))}
+	g := &generator{doc: d}
+	for i := range d.Methods {
+		m := &d.Methods[i]
+		if m.Name == "" {
+			return nil, errors.New("mock: method without name")
+		}
+		mm := &method{m: m, pos: make(map[string]int, len(m.Params)), result: []byte("null")}
+		for j, cd := range m.Params {
+			mm.pos[cd.Name] = j
+		}
+		if m.Result != nil {
+			b, err := json.Marshal(g.value(m.Result.Schema, 0))
+			if err != nil {
+				return nil, errors.New("mock: " + m.Name + ": " + err.Error())
+			}
+			mm.result = b
+		}
+		for _, e := range m.Examples {
+			x := example{params: make(map[string][]byte), result: []byte("null")}
+			for _, ep := range e.Params {
+				b, err := canonical(ep.Value)
+				if err != nil {
+					return nil, errors.New("mock: " + m.Name + ": example " + e.Name + ": " + err.Error())
+				}
+				x.params[ep.Name] = b
+			}
+			if e.Result != nil {
+				b, err := json.Marshal(e.Result.Value)
+				if err != nil {
+					return nil, errors.New("mock: " + m.Name + ": example " + e.Name + ": " + err.Error())
+				}
+				x.result = b
+			}
+			mm.examples = append(mm.examples, x)
+		}
+		p.methods[m.Name] = mm
+	}
+	return p, nil
+}
+
+// Methods returns the names of the served methods, in document order.
+func (p *Mock) Methods() []string {
+	a := make([]string, 0, len(p.doc.Methods))
+	for _, m := range p.doc.Methods {
+		a = append(a, m.Name)
+	}
+	return a
+}
+
+// Call returns the encoded result of method for params, which may be nil.
+// Calls missing a required param fail with an Invalid params error.
+func (p *Mock) Call(name string, params *fastjson.Value) ([]byte, error) {
+	m := p.methods[name]
+	if m == nil {
+		return nil, &fastjsonrpc.Error{Code: -32601, Message: "Method not found"}
+	}
+	if err := m.check(params); err != nil {
+		return nil, err
+	}
+	if len(m.examples) == 0 {
+		return m.result, nil
+	}
+	best := &m.examples[0]
+	n := -1
+	for i := range m.examples {
+		if e := &m.examples[i]; len(e.params) > n && m.matches(e, params) {
+			best, n = e, len(e.params)
+		}
+	}
+	return best.result, nil
+}
+
+// check reports the required params missing from params.
+func (p *method) check(params *fastjson.Value) error {
+	var errs []fastjsonrpc.FieldError
+	for i, cd := range p.m.Params {
+		if cd.Required && param(params, i, cd.Name) == nil {
+			errs = append(errs, fastjsonrpc.FieldError{Field: "params." + cd.Name, Rule: "required", Message: "is required"})
+		}
+	}
+	if len(errs) > 0 {
+		return fastjsonrpc.InvalidParams(errs...)
+	}
+	return nil
+}
+
+// param returns the i-th param, passed by position or by name.
+func param(params *fastjson.Value, i int, name string) *fastjson.Value {
+	if params == nil {
+		return nil
+	}
+	switch params.Type() {
+	case fastjson.TypeArray:
+		if a := params.GetArray(); i < len(a) {
+			return a[i]
+		}
+	case fastjson.TypeObject:
+		return params.Get(name)
+	}
+	return nil
+}
+
+// matches reports whether every param of e equals the param of the call
+// with the same name or position.
+func (p *method) matches(e *example, params *fastjson.Value) bool {
+	for name, want := range e.params {
+		i, ok := p.pos[name]
+		if !ok {
+			i = -1
+		}
+		v := param(params, i, name)
+		if v == nil {
+			return false
+		}
+		got, err := canonical(json.RawMessage(v.MarshalTo(nil)))
+		if err != nil || !bytes.Equal(got, want) {
+			return false
+		}
+	}
+	return true
+}
+
+// canonical encodes v with sorted object keys and normalized numbers.
+func canonical(v any) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var x any
+	if err := json.Unmarshal(b, &x); err != nil {
+		return nil, err
+	}
+	return json.Marshal(x)
+}
+
+// Register serves the methods of d, and d itself as rpc.discover, on s.
+func Register(s *fastjsonrpc.ServerMap, d *openrpc.Document) error {
+	p, err := New(d)
+	if err != nil {
+		return err
+	}
+	for _, name := range p.Methods() {
+		s.RegisterHandler(name, func(c *fastjsonrpc.RequestCtx) {
+			b, err := p.Call(name, c.Params)
+			if err != nil {
+				c.Error = err
+				return
+			}
+			c.SetRawResult(append(c.ResultBuffer(), b...))
+		})
+	}
+	s.RegisterHandler(openrpc.DiscoverMethod, func(c *fastjsonrpc.RequestCtx) { c.Result = d })
+	return nil
+}
+
+// RegisterWS serves the methods of d, and d itself as rpc.discover, on j.
+func RegisterWS(j *ws.JSONRPC2, d *openrpc.Document) error {
+	p, err := New(d)
+	if err != nil {
+		return err
+	}
+	for _, name := range p.Methods() {
+		j.RegisterMethodContext(name, func(_ context.Context, _ *fastjson.Arena, params *fastjson.Value) (interface{}, error) {
+			b, err := p.Call(name, params)
+			if err != nil {
+				return nil, err
+			}
+			return b, nil
+		})
+	}
+	j.RegisterMethodFunc(openrpc.DiscoverMethod, func(*fastjson.Value) (interface{}, error) { return d, nil })
+	return nil
+}
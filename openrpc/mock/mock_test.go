@@ -0,0 +1,98 @@
+package mock_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fastjson"
+	"github.com/zc310/fastjsonrpc"
+	"github.com/zc310/fastjsonrpc/openrpc"
+	"github.com/zc310/fastjsonrpc/openrpc/mock"
+	"github.com/zc310/fastjsonrpc/rpctest"
+	"github.com/zc310/fastjsonrpc/ws"
+)
+
+func document(t *testing.T, name string) *openrpc.Document {
+	b, err := os.ReadFile(name)
+	require.NoError(t, err)
+	d, err := openrpc.Parse(b)
+	require.NoError(t, err)
+	return d
+}
+
+func TestCall(t *testing.T) {
+	m, err := mock.New(document(t, "testdata/petstore.json"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"list_pets", "get_pet", "ping"}, m.Methods())
+
+	call := func(method, params string) string {
+		var v *fastjson.Value
+		if params != "" {
+			v = fastjson.MustParse(params)
+		}
+		b, err := m.Call(method, v)
+		require.NoError(t, err)
+		return string(b)
+	}
+	all := `[{"id":1,"name":"Rex"},{"id":2,"name":"Tom"}]`
+	assert.JSONEq(t, all, call("list_pets", ""))
+	assert.JSONEq(t, `[{"id":1,"name":"Rex"}]`, call("list_pets", `{"limit":1}`))
+	assert.JSONEq(t, `[{"id":1,"name":"Rex"}]`, call("list_pets", `[1.0]`))
+	assert.JSONEq(t, all, call("list_pets", `{"limit":5}`))
+	assert.JSONEq(t, `"pong"`, call("ping", ""))
+
+	pet := map[string]any{}
+	require.NoError(t, json.Unmarshal([]byte(call("get_pet", `[7]`)), &pet))
+	assert.Equal(t, float64(100), pet["id"])
+	assert.Equal(t, "stringxx", pet["name"])
+	assert.Equal(t, "00000000-0000-0000-0000-000000000000", pet["tag"])
+	assert.Equal(t, "2024-01-01T00:00:00Z", pet["born"])
+	assert.Equal(t, 0.5, pet["weight"])
+	assert.Equal(t, map[string]any{"key": true}, pet["labels"])
+	assert.Equal(t, "dog", pet["kind"])
+	assert.NotNil(t, pet["owner"])
+
+	_, err = m.Call("get_pet", fastjson.MustParse(`{}`))
+	var e *fastjsonrpc.Error
+	require.ErrorAs(t, err, &e)
+	assert.Equal(t, -32602, e.Code)
+	_, err = m.Call("missing", nil)
+	require.ErrorAs(t, err, &e)
+	assert.Equal(t, -32601, e.Code)
+}
+
+func TestGeneratedDocument(t *testing.T) {
+	// Every method of a document generated from Go types gets a result.
+	d := document(t, "../testdata/openrpc.json")
+	m, err := mock.New(d)
+	require.NoError(t, err)
+	for _, method := range d.Methods {
+		if len(method.Params) > 0 && method.Params[0].Required {
+			continue
+		}
+		_, err := m.Call(method.Name, nil)
+		assert.NoError(t, err, method.Name)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	d := document(t, "testdata/petstore.json")
+
+	s := new(fastjsonrpc.ServerMap)
+	require.NoError(t, mock.Register(s, d))
+	h := rpctest.NewServerMap(t, s)
+	h.Call(t, "list_pets", map[string]int{"limit": 1}).ExpectResult([]map[string]any{{"id": 1, "name": "Rex"}})
+	h.Call(t, "get_pet", nil).ExpectError(-32602)
+	var got openrpc.Document
+	h.Call(t, openrpc.DiscoverMethod, nil).Decode(&got)
+	assert.Equal(t, "Petstore", got.Info.Title)
+
+	j := ws.NewJSONRPC2()
+	require.NoError(t, mock.RegisterWS(j, d))
+	w := rpctest.NewJSONRPC2(t, j)
+	w.Call(t, "ping", nil).ExpectResult("pong")
+	w.Call(t, "get_pet", map[string]int{}).ExpectError(-32602)
+}
@@ -0,0 +1,162 @@
+// Package openrpc describes registered methods as OpenRPC documents
+// (https://spec.open-rpc.org) and serves them through rpc.discover.
+package openrpc
+
+import (
+	"strconv"
+
+	"github.com/goccy/go-json"
+	"github.com/valyala/fastjson"
+	"github.com/zc310/fastjsonrpc"
+	"github.com/zc310/fastjsonrpc/ws"
+)
+
+// Version is the OpenRPC specification version of generated documents.
+const Version = "1.2.6"
+
+// DiscoverMethod is the method name reserved by OpenRPC for service
+// discovery.
+const DiscoverMethod = "rpc.discover"
+
+type Document struct {
+	OpenRPC    string      `json:"openrpc"`
+	Info       Info        `json:"info"`
+	Methods    []Method    `json:"methods"`
+	Components *Components `json:"components,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Param structures of a method.
+const (
+	ByName     = "by-name"
+	ByPosition = "by-position"
+	Either     = "either"
+)
+
+type Method struct {
+	Name           string              `json:"name"`
+	Summary        string              `json:"summary,omitempty"`
+	Params         []ContentDescriptor `json:"params"`
+	Result         *ContentDescriptor  `json:"result,omitempty"`
+	Deprecated     bool                `json:"deprecated,omitempty"`
+	Description    string              `json:"description,omitempty"`
+	ParamStructure string              `json:"paramStructure,omitempty"`
+	Examples       []ExamplePairing    `json:"examples,omitempty"`
+}
+
+// ExamplePairing is an example call: params and the result they produce.
+type ExamplePairing struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Params      []Example `json:"params"`
+	Result      *Example  `json:"result,omitempty"`
+}
+
+type Example struct {
+	Name  string `json:"name"`
+	Value any    `json:"value"`
+}
+
+type ContentDescriptor struct {
+	Name     string  `json:"name"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// New describes methods, as returned by ServerMap.Describe or
+// JSONRPC2.Describe. The rpc.discover method itself is left out.
+func New(info Info, methods []fastjsonrpc.MethodInfo) *Document {
+	g := newGenerator()
+	d := &Document{OpenRPC: Version, Info: info, Methods: []Method{}}
+	for _, m := range methods {
+		if m.Name == DiscoverMethod {
+			continue
+		}
+		d.Methods = append(d.Methods, g.method(m))
+	}
+	if len(g.defs) > 0 {
+		d.Components = &Components{Schemas: g.defs}
+	}
+	return d
+}
+
+// Parse decodes an OpenRPC document.
+func Parse(b []byte) (*Document, error) {
+	d := new(Document)
+	if err := json.Unmarshal(b, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Register serves the document of s as rpc.discover. The document is
+// built on every call, so it follows later registrations.
+func Register(s *fastjsonrpc.ServerMap, info Info) {
+	s.RegisterHandler(DiscoverMethod, func(c *fastjsonrpc.RequestCtx) {
+		c.Result = New(info, s.Describe())
+	})
+}
+
+// RegisterWS serves the document of j as rpc.discover.
+func RegisterWS(j *ws.JSONRPC2, info Info) {
+	j.RegisterMethodFunc(DiscoverMethod, func(*fastjson.Value) (interface{}, error) {
+		return New(info, j.Describe()), nil
+	})
+}
+
+func (g *generator) method(m fastjsonrpc.MethodInfo) Method {
+	r := Method{Name: m.Name, Summary: m.Summary, Deprecated: m.Deprecated, Params: []ContentDescriptor{}}
+	if m.Deprecated && m.Deprecation != "" {
+		r.Description = "Deprecated: " + m.Deprecation
+	}
+
+	switch {
+	case m.Func != nil:
+		f := m.Func
+		if names := f.Names(); len(names) > 0 {
+			for i, t := range f.Args() {
+				r.Params = append(r.Params, ContentDescriptor{Name: names[i], Required: i < f.Required(), Schema: g.schema(t)})
+			}
+			r.ParamStructure = Either
+		} else if args := f.Args(); len(args) == 1 && isStruct(args[0]) {
+			r.Params, r.ParamStructure = g.fields(args[0]), ByName
+		} else {
+			for i, t := range f.Args() {
+				r.Params = append(r.Params, ContentDescriptor{Name: "arg" + strconv.Itoa(i), Required: i < f.Required(), Schema: g.schema(t)})
+			}
+			r.ParamStructure = ByPosition
+		}
+	case m.Params != nil && isStruct(m.Params):
+		r.Params, r.ParamStructure = g.fields(m.Params), ByName
+	case m.Params != nil:
+		r.Params = []ContentDescriptor{{Name: "params", Required: true, Schema: g.schema(m.Params)}}
+		r.ParamStructure = ByPosition
+	case m.Schema != nil && len(m.Schema.Properties) > 0:
+		required := make(map[string]bool)
+		for _, n := range m.Schema.Required {
+			required[n] = true
+		}
+		for _, n := range sortedKeys(m.Schema.Properties) {
+			r.Params = append(r.Params, ContentDescriptor{Name: n, Required: required[n], Schema: fromSchema(m.Schema.Properties[n])})
+		}
+		r.ParamStructure = ByName
+	}
+
+	r.Result = &ContentDescriptor{Name: "result", Schema: &Schema{}}
+	switch {
+	case m.Result != nil:
+		r.Result.Schema = g.schema(m.Result)
+	case m.Func != nil:
+		r.Result.Schema = &Schema{Type: Types{"null"}}
+	}
+	return r
+}
@@ -0,0 +1,100 @@
+package openrpc_test
+
+import (
+	"context"
+	"flag"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/pretty"
+	"github.com/valyala/fasthttp"
+	"github.com/zc310/fastjsonrpc"
+	. "github.com/zc310/fastjsonrpc/openrpc"
+	"github.com/zc310/fastjsonrpc/ws"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+type Args struct {
+	A int `json:"a" validate:"required,min=0"`
+	B int `json:"b,omitempty" validate:"max=100"`
+}
+
+type Reply struct {
+	Sum     int               `json:"sum"`
+	Tags    []string          `json:"tags,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	At      time.Time         `json:"at"`
+	Next    *Reply            `json:"next,omitempty"`
+	Kind    string            `json:"kind" validate:"oneof=add sub"`
+	private int
+}
+
+type Arith int
+
+func (t *Arith) Add(c *fastjsonrpc.RequestCtx)  {}
+func (t *Arith) Ping(c *fastjsonrpc.RequestCtx) {}
+
+func newServer(t *testing.T) *fastjsonrpc.ServerMap {
+	var s fastjsonrpc.ServerMap
+	require.NoError(t, s.Register(new(Arith), fastjsonrpc.WithMethodTypes("Add", Args{}, Reply{})))
+	require.NoError(t, s.RegisterFunc("subtract", func(ctx context.Context, minuend, subtrahend int) (int, error) {
+		return minuend - subtrahend, nil
+	}, fastjsonrpc.WithParamNames("minuend", "subtrahend"), fastjsonrpc.WithSummary("Subtracts two numbers.")))
+	require.NoError(t, s.RegisterFunc("scale", func(v float64, by *float64) float64 { return v }))
+	s.RegisterHandler("sum", func(c *fastjsonrpc.RequestCtx) {}, fastjsonrpc.WithParams(Args{}), fastjsonrpc.WithResult(0),
+		fastjsonrpc.Deprecated("use subtract"))
+	s.RegisterHandler("greet", func(c *fastjsonrpc.RequestCtx) {}, fastjsonrpc.WithSchema(fastjsonrpc.MustCompileSchema(
+		[]byte(`{"type":"object","properties":{"name":{"type":"string","minLength":1}},"required":["name"]}`))))
+	Register(&s, Info{Title: "Test", Version: "1.0.0"})
+	return &s
+}
+
+func TestDiscover(t *testing.T) {
+	s := newServer(t)
+
+	var ctx fasthttp.RequestCtx
+	ctx.Request.SetBodyString(`{"jsonrpc":"2.0","method":"rpc.discover","id":1}`)
+	s.Handler(&ctx)
+
+	var resp struct {
+		Result json.RawMessage `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal(ctx.Response.Body(), &resp))
+	got := pretty.Pretty(resp.Result)
+
+	if *update {
+		require.NoError(t, os.WriteFile("testdata/openrpc.json", got, 0o644))
+	}
+	want, err := os.ReadFile("testdata/openrpc.json")
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got))
+
+	d, err := Parse(got)
+	require.NoError(t, err)
+	assert.Equal(t, Version, d.OpenRPC)
+	assert.Len(t, d.Methods, 6)
+}
+
+func TestWS(t *testing.T) {
+	j := ws.NewJSONRPC2()
+	require.NoError(t, j.RegisterFunc("add", func(a, b int) int { return a + b }))
+	RegisterWS(j, Info{Title: "WS", Version: "1"})
+
+	out, err := j.HandleMessage([]byte(`{"jsonrpc":"2.0","method":"rpc.discover","id":1}`))
+	require.NoError(t, err)
+
+	var resp struct {
+		Result Document `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal(out, &resp))
+	require.Len(t, resp.Result.Methods, 1)
+	m := resp.Result.Methods[0]
+	assert.Equal(t, "add", m.Name)
+	assert.Equal(t, ByPosition, m.ParamStructure)
+	assert.Equal(t, Types{"integer"}, m.Result.Schema.Type)
+}
@@ -0,0 +1,302 @@
+package openrpc
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/valyala/fastjson"
+	"github.com/zc310/fastjsonrpc"
+)
+
+// Schema is a JSON Schema as used by OpenRPC documents.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 Types              `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	Examples             []any              `json:"examples,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+}
+
+// Types accepts both "type":"string" and "type":["string","null"].
+type Types []string
+
+func (p *Types) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		*p = Types{s}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(p))
+}
+
+func (p Types) MarshalJSON() ([]byte, error) {
+	if len(p) == 1 {
+		return json.Marshal(p[0])
+	}
+	return json.Marshal([]string(p))
+}
+
+// RefPrefix prefixes references to component schemas.
+const RefPrefix = "#/components/schemas/"
+
+var (
+	typeOfTime       = reflect.TypeOf(time.Time{})
+	typeOfRawMessage = reflect.TypeOf(json.RawMessage{})
+	typeOfValue      = reflect.TypeOf(fastjson.Value{})
+	typeOfMarshaler  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+type generator struct {
+	defs  map[string]*Schema
+	names map[reflect.Type]string
+}
+
+func newGenerator() *generator {
+	return &generator{defs: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+}
+
+// SchemaOf returns the schema of values of type t, with named struct types
+// inlined.
+func SchemaOf(t reflect.Type) *Schema {
+	g := newGenerator()
+	s := g.schema(t)
+	for s.Ref != "" {
+		s = g.defs[strings.TrimPrefix(s.Ref, RefPrefix)]
+	}
+	return s
+}
+
+func (g *generator) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		if t.Elem() == typeOfValue {
+			return &Schema{}
+		}
+		t = t.Elem()
+	}
+	switch t {
+	case typeOfTime:
+		return &Schema{Type: Types{"string"}, Format: "date-time"}
+	case typeOfRawMessage, typeOfValue:
+		return &Schema{}
+	}
+	if t.Implements(typeOfMarshaler) || reflect.PointerTo(t).Implements(typeOfMarshaler) {
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: Types{"boolean"}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: Types{"integer"}}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: Types{"number"}}
+	case reflect.String:
+		return &Schema{Type: Types{"string"}}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return &Schema{Type: Types{"string"}, Format: "byte"}
+		}
+		return &Schema{Type: Types{"array"}, Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: Types{"object"}, AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		name, ok := g.names[t]
+		if !ok {
+			name = g.name(t)
+			g.names[t] = name
+			g.defs[name] = &Schema{}
+			*g.defs[name] = *g.object(t)
+		}
+		return &Schema{Ref: RefPrefix + name}
+	}
+	return &Schema{}
+}
+
+// name returns a unique component name for t.
+func (g *generator) name(t reflect.Type) string {
+	name := t.Name()
+	if _, dup := g.defs[name]; !dup {
+		return name
+	}
+	pkg := t.PkgPath()
+	pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+	name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	for i := 2; ; i++ {
+		if _, dup := g.defs[name]; !dup {
+			return name
+		}
+		name = t.Name() + strconv.Itoa(i)
+	}
+}
+
+func (g *generator) object(t reflect.Type) *Schema {
+	s := &Schema{Type: Types{"object"}, Properties: make(map[string]*Schema)}
+	for _, d := range g.fields(t) {
+		s.Properties[d.Name] = d.Schema
+		if d.Required {
+			s.Required = append(s.Required, d.Name)
+		}
+	}
+	sort.Strings(s.Required)
+	return s
+}
+
+// fields describes the JSON fields of the struct type t.
+func (g *generator) fields(t reflect.Type) []ContentDescriptor {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var a []ContentDescriptor
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				a = append(a, g.fields(ft)...)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s := g.schema(f.Type)
+		// Fields without omitempty are always encoded.
+		required := applyRules(s, f.Tag.Get("validate")) ||
+			!strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer
+		a = append(a, ContentDescriptor{Name: name, Required: required, Schema: s})
+	}
+	return a
+}
+
+// applyRules adds the constraints of validate tag rules to s and reports
+// whether the field is required.
+func applyRules(s *Schema, tag string) (required bool) {
+	if tag == "" {
+		return false
+	}
+	for _, r := range strings.Split(tag, ",") {
+		rule, arg, _ := strings.Cut(strings.TrimSpace(r), "=")
+		n, err := strconv.ParseFloat(arg, 64)
+		switch rule {
+		case "required":
+			required = true
+		case "min", "max", "len":
+			if err != nil || s.Ref != "" || len(s.Type) != 1 {
+				continue
+			}
+			i := int(n)
+			switch s.Type[0] {
+			case "integer", "number":
+				switch rule {
+				case "min":
+					s.Minimum = &n
+				case "max":
+					s.Maximum = &n
+				}
+			case "string":
+				if rule != "max" {
+					s.MinLength = &i
+				}
+				if rule != "min" {
+					s.MaxLength = &i
+				}
+			case "array", "object":
+				if rule != "max" {
+					s.MinItems = &i
+				}
+				if rule != "min" {
+					s.MaxItems = &i
+				}
+			}
+		case "oneof":
+			for _, w := range strings.Fields(arg) {
+				if len(s.Type) == 1 && (s.Type[0] == "integer" || s.Type[0] == "number") {
+					if f, err := strconv.ParseFloat(w, 64); err == nil {
+						s.Enum = append(s.Enum, f)
+						continue
+					}
+				}
+				s.Enum = append(s.Enum, w)
+			}
+		}
+	}
+	return required
+}
+
+// fromSchema converts a params validation schema.
+func fromSchema(v *fastjsonrpc.Schema) *Schema {
+	if v == nil {
+		return nil
+	}
+	s := &Schema{
+		Type: Types(v.Type), Required: v.Required, Items: fromSchema(v.Items),
+		Minimum: v.Minimum, Maximum: v.Maximum, MinLength: v.MinLength, MaxLength: v.MaxLength,
+		MinItems: v.MinItems, MaxItems: v.MaxItems, Pattern: v.Pattern,
+	}
+	for _, e := range v.Enum {
+		var x any
+		if json.Unmarshal(e, &x) == nil {
+			s.Enum = append(s.Enum, x)
+		}
+	}
+	if len(v.Properties) > 0 {
+		s.Properties = make(map[string]*Schema, len(v.Properties))
+		for k, p := range v.Properties {
+			s.Properties[k] = fromSchema(p)
+		}
+	}
+	return s
+}
+
+func isStruct(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && t != typeOfTime && t != typeOfValue
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	a := make([]string, 0, len(m))
+	for k := range m {
+		a = append(a, k)
+	}
+	sort.Strings(a)
+	return a
+}
@@ -0,0 +1,256 @@
+package typescript
+
+// runtime is the transport code shared by generated clients.
+const runtime = `export interface RpcErrorObject {
+  code: number;
+  message: string;
+  data?: unknown;
+}
+
+export class RpcError extends Error {
+  readonly code: number;
+  readonly data?: unknown;
+
+  constructor(e: RpcErrorObject) {
+    super(e.message);
+    this.name = "RpcError";
+    this.code = e.code;
+    this.data = e.data;
+  }
+}
+
+interface Request {
+  jsonrpc: "2.0";
+  method: string;
+  params?: unknown;
+  id?: number;
+}
+
+interface Response {
+  jsonrpc: "2.0";
+  id: number | string | null;
+  result?: unknown;
+  error?: RpcErrorObject;
+}
+
+function request(method: string, params?: unknown, id?: number): Request {
+  const r: Request = { jsonrpc: "2.0", method };
+  if (params !== undefined) r.params = params;
+  if (id !== undefined) r.id = id;
+  return r;
+}
+
+function positional(...args: unknown[]): unknown[] {
+  while (args.length > 0 && args[args.length - 1] === undefined) args.pop();
+  return args;
+}
+
+function requestIds(text: string): string[] {
+  const msg = JSON.parse(text) as Request | Request[];
+  const items = Array.isArray(msg) ? msg : [msg];
+  return items.filter((r) => r.id !== undefined && r.id !== null).map((r) => String(r.id));
+}
+
+/** Transport sends an encoded request and resolves with the encoded response, or undefined when no response is expected. */
+export interface Transport {
+  send(request: string): Promise<string | undefined>;
+  close?(): void;
+}
+
+export class HttpTransport implements Transport {
+  constructor(
+    private readonly url: string,
+    private readonly headers: Record<string, string> = {},
+  ) {}
+
+  async send(request: string): Promise<string | undefined> {
+    const res = await fetch(this.url, {
+      method: "POST",
+      headers: { "Content-Type": "application/json", ...this.headers },
+      body: request,
+    });
+    const text = await res.text();
+    if (!res.ok && !text.startsWith("{")) throw new Error("jsonrpc: unexpected HTTP status " + res.status);
+    return text.length > 0 ? text : undefined;
+  }
+}
+
+export type NotificationHandler = (params: unknown) => void;
+
+interface Waiter {
+  ids: string[];
+  resolve: (response: string) => void;
+  reject: (err: Error) => void;
+}
+
+/** WebSocketTransport multiplexes calls over one WebSocket and dispatches server notifications to subscribers. */
+export class WebSocketTransport implements Transport {
+  private readonly pending = new Map<string, Waiter>();
+  private readonly handlers = new Map<string, Set<NotificationHandler>>();
+  private readonly ready: Promise<void>;
+  private closed?: Error;
+
+  constructor(private readonly socket: WebSocket) {
+    this.ready = new Promise((resolve, reject) => {
+      if (socket.readyState === WebSocket.OPEN) return resolve();
+      socket.addEventListener("open", () => resolve());
+      socket.addEventListener("error", () => reject(new Error("jsonrpc: connection failed")));
+    });
+    socket.addEventListener("message", (e) => this.receive(String(e.data)));
+    socket.addEventListener("close", () => this.fail(new Error("jsonrpc: connection closed")));
+  }
+
+  static connect(url: string): WebSocketTransport {
+    return new WebSocketTransport(new WebSocket(url));
+  }
+
+  async send(request: string): Promise<string | undefined> {
+    await this.ready;
+    if (this.closed) throw this.closed;
+    const ids = requestIds(request);
+    if (ids.length === 0) {
+      this.socket.send(request);
+      return undefined;
+    }
+    return new Promise((resolve, reject) => {
+      const w: Waiter = { ids, resolve, reject };
+      for (const id of ids) this.pending.set(id, w);
+      this.socket.send(request);
+    });
+  }
+
+  /** subscribe calls handler for every notification of method and returns a function that cancels the subscription. */
+  subscribe(method: string, handler: NotificationHandler): () => void {
+    const set = this.handlers.get(method) ?? new Set<NotificationHandler>();
+    this.handlers.set(method, set);
+    set.add(handler);
+    return () => {
+      set.delete(handler);
+    };
+  }
+
+  close(): void {
+    this.socket.close();
+  }
+
+  private receive(text: string): void {
+    let msg: unknown;
+    try {
+      msg = JSON.parse(text);
+    } catch {
+      return;
+    }
+    const items = (Array.isArray(msg) ? msg : [msg]) as Array<{ id?: number | string | null; method?: unknown; params?: unknown }>;
+    const first = items[0];
+    if (!Array.isArray(msg) && first && typeof first.method === "string") {
+      this.handlers.get(first.method)?.forEach((h) => h(first.params));
+      return;
+    }
+    for (const item of items) {
+      const w = item.id !== undefined && item.id !== null ? this.pending.get(String(item.id)) : undefined;
+      if (w) {
+        for (const id of w.ids) this.pending.delete(id);
+        w.resolve(text);
+        return;
+      }
+    }
+  }
+
+  private fail(err: Error): void {
+    this.closed = err;
+    for (const w of new Set(this.pending.values())) w.reject(err);
+    this.pending.clear();
+  }
+}
+`
+
+// runtimeClient is the client code, which follows the generated Methods
+// class it extends.
+const runtimeClient = `function settle(r: Response | undefined): unknown {
+  if (!r) throw new Error("jsonrpc: no response");
+  if (r.error) throw new RpcError(r.error);
+  return r.result;
+}
+
+export class Client extends Methods {
+  private id = 0;
+
+  constructor(readonly transport: Transport) {
+    super();
+  }
+
+  protected async call<T>(method: string, params?: unknown): Promise<T> {
+    const text = await this.transport.send(JSON.stringify(request(method, params, ++this.id)));
+    return settle(text ? (JSON.parse(text) as Response) : undefined) as T;
+  }
+
+  async notify(method: string, params?: unknown): Promise<void> {
+    await this.transport.send(JSON.stringify(request(method, params)));
+  }
+
+  /** batch returns a Batch whose calls are sent together by Batch.send. */
+  batch(): Batch {
+    return new Batch(this.transport, () => ++this.id);
+  }
+
+  /** subscribe receives notifications of method pushed by the server over a WebSocketTransport. */
+  subscribe(method: string, handler: NotificationHandler): () => void {
+    if (!(this.transport instanceof WebSocketTransport)) throw new Error("jsonrpc: subscriptions need a WebSocketTransport");
+    return this.transport.subscribe(method, handler);
+  }
+
+  close(): void {
+    this.transport.close?.();
+  }
+}
+
+interface Pending {
+  request: Request;
+  resolve?: (result: unknown) => void;
+  reject?: (err: Error) => void;
+}
+
+export class Batch extends Methods {
+  private readonly calls: Pending[] = [];
+
+  constructor(
+    private readonly transport: Transport,
+    private readonly nextId: () => number,
+  ) {
+    super();
+  }
+
+  protected call<T>(method: string, params?: unknown): Promise<T> {
+    return new Promise<T>((resolve, reject) => {
+      this.calls.push({ request: request(method, params, this.nextId()), resolve: resolve as (result: unknown) => void, reject });
+    });
+  }
+
+  notify(method: string, params?: unknown): void {
+    this.calls.push({ request: request(method, params) });
+  }
+
+  /** send sends the queued calls as one batch request and settles their promises. */
+  async send(): Promise<void> {
+    const calls = this.calls.splice(0);
+    if (calls.length === 0) return;
+    let responses: Response[] = [];
+    try {
+      const text = await this.transport.send(JSON.stringify(calls.map((c) => c.request)));
+      if (text) responses = ([] as Response[]).concat(JSON.parse(text) as Response | Response[]);
+    } catch (err) {
+      for (const c of calls) c.reject?.(err as Error);
+      throw err;
+    }
+    const byId = new Map(responses.map((r) => [String(r.id), r]));
+    for (const c of calls) {
+      if (c.request.id === undefined) continue;
+      try {
+        c.resolve?.(settle(byId.get(String(c.request.id))));
+      } catch (err) {
+        c.reject?.(err as Error);
+      }
+    }
+  }
+}
+`
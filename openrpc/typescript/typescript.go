@@ -0,0 +1,314 @@
+// Package typescript generates TypeScript clients from OpenRPC documents.
+//
+// The output declares an interface or type for every component schema, a
+// Client class with one method per RPC method, a Batch class with the
+// same methods for batch requests, and HTTP and WebSocket transports. The
+// WebSocket transport dispatches server notifications to handlers
+// registered with Client.subscribe. Output is deterministic.
+package typescript
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/goccy/go-json"
+	"github.com/iancoleman/strcase"
+	"github.com/zc310/fastjsonrpc/openrpc"
+)
+
+// Generate writes a TypeScript client for d to w.
+func Generate(w io.Writer, d *openrpc.Document) error {
+	var b bytes.Buffer
+	b.WriteString("// Code generated by fastjsonrpc-tsgen. DO NOT EDIT.\n")
+	if d.Info.Title != "" {
+		b.WriteString("// " + d.Info.Title + " " + d.Info.Version + "\n")
+	}
+	b.WriteString("\n/* eslint-disable */\n")
+
+	if d.Components != nil {
+		for _, name := range sortedKeys(d.Components.Schemas) {
+			b.WriteString("\n")
+			writeDeclaration(&b, typeName(name), d.Components.Schemas[name])
+		}
+	}
+
+	methods := append([]openrpc.Method(nil), d.Methods...)
+	sort.Slice(methods, func(i, j int) bool { return methods[i].Name < methods[j].Name })
+	idents := make(map[string]bool)
+	var m bytes.Buffer
+	for _, x := range methods {
+		id := identifier(x.Name, idents)
+		writeMethod(&b, &m, id, x)
+	}
+
+	b.WriteString("\n" + runtime)
+	b.WriteString("\nabstract class Methods {\n  protected abstract call<T>(method: string, params?: unknown): Promise<T>;\n")
+	b.Write(m.Bytes())
+	b.WriteString("}\n\n" + runtimeClient)
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// writeMethod writes the params interface of x, if any, to b and the
+// method itself to m.
+func writeMethod(b, m *bytes.Buffer, id string, x openrpc.Method) {
+	result := tsType(resultSchema(x))
+	var args, params string
+	switch {
+	case x.ParamStructure == "" && len(x.Params) == 0:
+		args, params = "params?: unknown", ", params"
+	case x.ParamStructure == openrpc.ByPosition:
+		var names []string
+		for i, p := range x.Params {
+			name := safeName(p.Name)
+			if jsReserved[name] {
+				name += "_"
+			}
+			opt := "?"
+			// A required param may not follow an optional one.
+			for _, q := range x.Params[i:] {
+				if q.Required {
+					opt = ""
+				}
+			}
+			names = append(names, name)
+			if args != "" {
+				args += ", "
+			}
+			args += name + opt + ": " + tsType(p.Schema)
+		}
+		if len(names) > 0 {
+			params = ", positional(" + strings.Join(names, ", ") + ")"
+		}
+	default:
+		iface := strcase.ToCamel(id) + "Params"
+		s := &openrpc.Schema{Type: openrpc.Types{"object"}, Properties: make(map[string]*openrpc.Schema)}
+		required := false
+		for _, p := range x.Params {
+			s.Properties[p.Name] = p.Schema
+			if p.Required {
+				s.Required = append(s.Required, p.Name)
+				required = true
+			}
+		}
+		b.WriteString("\n")
+		writeDeclaration(b, iface, s)
+		if required {
+			args = "params: " + iface
+		} else {
+			args = "params: " + iface + " = {}"
+		}
+		params = ", params"
+	}
+
+	m.WriteString("\n")
+	writeDoc(m, "  ", x.Summary, x.Description, x.Deprecated)
+	m.WriteString("  " + id + "(" + args + "): Promise<" + result + "> {\n")
+	m.WriteString("    return this.call<" + result + ">(" + quote(x.Name) + params + ");\n  }\n")
+}
+
+func resultSchema(x openrpc.Method) *openrpc.Schema {
+	if x.Result == nil {
+		return nil
+	}
+	return x.Result.Schema
+}
+
+func writeDoc(b *bytes.Buffer, indent, summary, description string, deprecated bool) {
+	deprecation := ""
+	if deprecated && strings.HasPrefix(description, "Deprecated: ") {
+		deprecation, description = " "+strings.TrimPrefix(description, "Deprecated: "), ""
+	}
+	var lines []string
+	for _, s := range []string{summary, description} {
+		if s != "" {
+			lines = append(lines, strings.Split(strings.ReplaceAll(s, "*/", "*\\/"), "\n")...)
+		}
+	}
+	if deprecated {
+		lines = append(lines, "@deprecated"+deprecation)
+	}
+	switch len(lines) {
+	case 0:
+	case 1:
+		b.WriteString(indent + "/** " + lines[0] + " */\n")
+	default:
+		b.WriteString(indent + "/**\n")
+		for _, l := range lines {
+			b.WriteString(indent + " * " + l + "\n")
+		}
+		b.WriteString(indent + " */\n")
+	}
+}
+
+func writeDeclaration(b *bytes.Buffer, name string, s *openrpc.Schema) {
+	writeDoc(b, "", s.Description, "", false)
+	if s.Ref == "" && len(s.Properties) > 0 && len(s.Enum) == 0 && (len(s.Type) == 0 || len(s.Type) == 1 && s.Type[0] == "object") {
+		b.WriteString("export interface " + name + " " + objectType(s, "") + "\n")
+		return
+	}
+	b.WriteString("export type " + name + " = " + tsType(s) + ";\n")
+}
+
+// objectType writes the properties of s as a multi-line object type.
+func objectType(s *openrpc.Schema, indent string) string {
+	required := make(map[string]bool)
+	for _, n := range s.Required {
+		required[n] = true
+	}
+	var b strings.Builder
+	b.WriteString("{\n")
+	for _, k := range sortedKeys(s.Properties) {
+		p := s.Properties[k]
+		if p != nil && p.Description != "" {
+			b.WriteString(indent + "  /** " + strings.ReplaceAll(p.Description, "*/", "*\\/") + " */\n")
+		}
+		b.WriteString(indent + "  " + propertyName(k))
+		if !required[k] {
+			b.WriteString("?")
+		}
+		b.WriteString(": " + tsTypeIndent(p, indent+"  ") + ";\n")
+	}
+	b.WriteString(indent + "}")
+	return b.String()
+}
+
+func tsType(s *openrpc.Schema) string { return tsTypeIndent(s, "") }
+
+func tsTypeIndent(s *openrpc.Schema, indent string) string {
+	if s == nil {
+		return "unknown"
+	}
+	if s.Ref != "" {
+		return typeName(strings.TrimPrefix(s.Ref, openrpc.RefPrefix))
+	}
+	if len(s.Enum) > 0 {
+		var a []string
+		for _, e := range s.Enum {
+			v, _ := json.Marshal(e)
+			a = append(a, string(v))
+		}
+		return strings.Join(a, " | ")
+	}
+	if len(s.Type) == 0 {
+		if len(s.Properties) > 0 {
+			return objectType(s, indent)
+		}
+		return "unknown"
+	}
+	var a []string
+	for _, t := range s.Type {
+		switch t {
+		case "string":
+			a = append(a, "string")
+		case "integer", "number":
+			a = append(a, "number")
+		case "boolean":
+			a = append(a, "boolean")
+		case "null":
+			a = append(a, "null")
+		case "array":
+			a = append(a, "Array<"+tsTypeIndent(s.Items, indent)+">")
+		case "object":
+			switch {
+			case len(s.Properties) > 0:
+				a = append(a, objectType(s, indent))
+			case s.AdditionalProperties != nil:
+				a = append(a, "Record<string, "+tsTypeIndent(s.AdditionalProperties, indent)+">")
+			default:
+				a = append(a, "Record<string, unknown>")
+			}
+		default:
+			a = append(a, "unknown")
+		}
+	}
+	return strings.Join(a, " | ")
+}
+
+// identifier returns a unique lowerCamel method name for an RPC name such
+// as "Arith.Add" or "arith.add@v2".
+func identifier(name string, used map[string]bool) string {
+	id := strcase.ToLowerCamel(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name))
+	if id == "" || unicode.IsDigit(rune(id[0])) {
+		id = "m" + id
+	}
+	base := id
+	for i := 2; used[id] || reserved[id]; i++ {
+		id = base + strconv.Itoa(i)
+	}
+	used[id] = true
+	return id
+}
+
+// reserved holds names taken by the members of Client and Batch.
+var reserved = map[string]bool{
+	"call": true, "notify": true, "batch": true, "subscribe": true, "close": true,
+	"send": true, "transport": true, "constructor": true,
+}
+
+// jsReserved holds the reserved words that may not name a parameter.
+var jsReserved = map[string]bool{
+	"break": true, "case": true, "catch": true, "class": true, "const": true, "continue": true,
+	"debugger": true, "default": true, "delete": true, "do": true, "else": true, "enum": true,
+	"export": true, "extends": true, "false": true, "finally": true, "for": true, "function": true,
+	"if": true, "import": true, "in": true, "instanceof": true, "new": true, "null": true,
+	"return": true, "super": true, "switch": true, "this": true, "throw": true, "true": true,
+	"try": true, "typeof": true, "var": true, "void": true, "while": true, "with": true,
+	"yield": true, "let": true, "static": true, "implements": true, "interface": true,
+	"package": true, "private": true, "protected": true, "public": true, "await": true,
+}
+
+// typeName returns an exported TypeScript type name for a component.
+func typeName(name string) string { return strcase.ToCamel(safeName(name)) }
+
+func safeName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case unicode.IsLetter(r) || r == '_' || r == '$':
+			b.WriteRune(r)
+		case unicode.IsDigit(r):
+			if i == 0 {
+				b.WriteRune('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+func propertyName(k string) string {
+	if k != "" && safeName(k) == k {
+		return k
+	}
+	return quote(k)
+}
+
+func quote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	a := make([]string, 0, len(m))
+	for k := range m {
+		a = append(a, k)
+	}
+	sort.Strings(a)
+	return a
+}
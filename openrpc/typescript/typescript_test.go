@@ -0,0 +1,37 @@
+package typescript_test
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zc310/fastjsonrpc/openrpc"
+	. "github.com/zc310/fastjsonrpc/openrpc/typescript"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGenerate(t *testing.T) {
+	b, err := os.ReadFile("../testdata/openrpc.json")
+	require.NoError(t, err)
+	d, err := openrpc.Parse(b)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, Generate(&out, d))
+	if *update {
+		require.NoError(t, os.WriteFile("testdata/client.ts", out.Bytes(), 0o644))
+	}
+	want, err := os.ReadFile("testdata/client.ts")
+	require.NoError(t, err)
+	assert.Equal(t, string(want), out.String())
+
+	// Output must not depend on the order of methods.
+	d.Methods[0], d.Methods[len(d.Methods)-1] = d.Methods[len(d.Methods)-1], d.Methods[0]
+	var again bytes.Buffer
+	require.NoError(t, Generate(&again, d))
+	assert.Equal(t, out.String(), again.String())
+}
@@ -0,0 +1,117 @@
+package fastjsonrpc
+
+import (
+	"reflect"
+	"time"
+)
+
+// Option configures a method or service at registration time.
+type Option func(*options)
+
+type options struct {
+	limiter     *Limiter
+	cache       *Cache
+	idempotency *Idempotency
+	async       bool
+	jobs        *Jobs
+	version     string
+	deprecated  bool
+	deprecation string
+
+	schema     *Schema
+	validate   bool
+	paramNames []string
+
+	naming        NamingStrategy
+	separator     string
+	aliases       []string
+	methodNames   map[string]string
+	methodAliases map[string][]string
+
+	info        MethodInfo
+	methodTypes map[string][2]reflect.Type
+}
+
+func (p *ServerMap) newOptions(opts []Option) *options {
+	o := &options{naming: p.Naming, separator: p.Separator}
+	if o.separator == "" {
+		o.separator = defaultSep
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.async {
+		o.jobs = p.jobs()
+	}
+	return o
+}
+
+// WithLimiter guards the registered method, or every method of the
+// registered service, with l.
+func WithLimiter(l *Limiter) Option { return func(o *options) { o.limiter = l } }
+
+// WithConcurrency allows at most n concurrent executions and rejects calls
+// that could not start within wait.
+func WithConcurrency(n int, wait time.Duration) Option {
+	return WithLimiter(NewLimiter(n, wait))
+}
+
+// WithVersion registers the method, or every method of the service, as
+// "name@version". Callers select it by that name or through the
+// ServerMap.VersionHeader header.
+func WithVersion(version string) Option { return func(o *options) { o.version = version } }
+
+// Deprecated marks the method, or every method of the service, as
+// deprecated. Responses to deprecated calls carry Deprecation and Warning
+// headers with message.
+func Deprecated(message string) Option {
+	return func(o *options) { o.deprecated, o.deprecation = true, message }
+}
+
+func (o *options) versioned(name string) string { return joinVersion(name, o.version) }
+
+func (o *options) method(h Handler) *rpcMethod {
+	info := o.info
+	info.Schema, info.Deprecated, info.Deprecation = o.schema, o.deprecated, o.deprecation
+	return &rpcMethod{handler: o.wrap(h), deprecated: o.deprecated, deprecation: o.deprecation, info: info}
+}
+
+// WithParamNames declares the names of the arguments of a function
+// registered with RegisterFunc, enabling named params.
+func WithParamNames(names ...string) Option { return func(o *options) { o.paramNames = names } }
+
+// WithSchema validates params against s before the handler runs.
+func WithSchema(s *Schema) Option { return func(o *options) { o.schema = s } }
+
+// WithValidation makes ParamsUnmarshal, and the argument binding of
+// RegisterFunc, check the `validate` struct tags of the decoded params.
+// RegisterFunc fails if the tags use unknown rules.
+func WithValidation() Option { return func(o *options) { o.validate = true } }
+
+func (o *options) wrap(h Handler) Handler {
+	if o.validate {
+		h = validationHandler(h)
+	}
+	if o.schema != nil && o.jobs == nil {
+		h = schemaHandler(o.schema, h)
+	}
+	if o.limiter != nil {
+		h = limitHandler(o.limiter, h)
+	}
+	if o.jobs != nil {
+		// Jobs are limited while they run; params are validated before
+		// the job is queued.
+		h = asyncHandler(o.jobs, h)
+		if o.schema != nil {
+			h = schemaHandler(o.schema, h)
+		}
+	}
+	if o.idempotency != nil {
+		h = idempotencyHandler(o.idempotency, h)
+	}
+	if o.cache != nil {
+		// Hits skip validation and the limiter.
+		h = cacheHandler(o.cache, h)
+	}
+	return h
+}
@@ -0,0 +1,74 @@
+package fastjsonrpc
+
+import (
+	"context"
+
+	"github.com/goccy/go-json"
+	"github.com/valyala/fastjson"
+)
+
+// ProgressMethod is the method of the notifications sent by
+// ReportProgress.
+const ProgressMethod = "$/progress"
+
+// Notifier sends a notification to the caller of a request. Transports
+// able to push messages attach one to the request context with
+// ContextWithNotifier; package ws does so over WebSocket, over SSE
+// responses of its HTTP handler and over stdio with ServeStream.
+type Notifier func(method string, params any) error
+
+// ProgressParams are the params of a $/progress notification.
+type ProgressParams struct {
+	Token json.RawMessage `json:"token"`
+	Value any             `json:"value"`
+}
+
+type (
+	notifierKey      struct{}
+	progressTokenKey struct{}
+)
+
+// ContextWithNotifier returns ctx carrying n.
+func ContextWithNotifier(ctx context.Context, n Notifier) context.Context {
+	return context.WithValue(ctx, notifierKey{}, n)
+}
+
+// ContextWithProgressToken returns ctx carrying the JSON encoded progress
+// token of a call.
+func ContextWithProgressToken(ctx context.Context, token []byte) context.Context {
+	return context.WithValue(ctx, progressTokenKey{}, token)
+}
+
+// ProgressToken returns the string or number in
+// params._meta.progressToken, JSON encoded, or nil.
+func ProgressToken(params *fastjson.Value) []byte {
+	v := params.Get("_meta", "progressToken")
+	if v == nil || (v.Type() != fastjson.TypeString && v.Type() != fastjson.TypeNumber) {
+		return nil
+	}
+	return v.MarshalTo(nil)
+}
+
+// ReportProgress sends value to the caller in a $/progress notification
+// tied to the progress token in ctx, and records it on the job running
+// with ctx, see SetJobProgress. Without a token, or over a transport that
+// cannot notify such as plain HTTP, nothing is sent.
+func ReportProgress(ctx context.Context, value any) error {
+	SetJobProgress(ctx, value)
+	token, _ := ctx.Value(progressTokenKey{}).([]byte)
+	n, _ := ctx.Value(notifierKey{}).(Notifier)
+	if token == nil || n == nil {
+		return nil
+	}
+	return n(ProgressMethod, ProgressParams{Token: token, Value: value})
+}
+
+// Progress reports progress of the call to the caller, tied to the token
+// in params._meta.progressToken. See ReportProgress.
+func (p *RequestCtx) Progress(value any) error {
+	ctx := p.Context()
+	if token := ProgressToken(p.Params); token != nil {
+		ctx = ContextWithProgressToken(ctx, token)
+	}
+	return ReportProgress(ctx, value)
+}
@@ -0,0 +1,205 @@
+package fastjsonrpc_test
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+	"github.com/valyala/fastjson"
+	. "github.com/zc310/fastjsonrpc"
+	"github.com/zc310/fastjsonrpc/client"
+	"github.com/zc310/fastjsonrpc/rpctest"
+	"github.com/zc310/fastjsonrpc/ws"
+)
+
+func TestProgress(t *testing.T) {
+	t.Parallel()
+
+	s := new(ServerMap)
+	s.RegisterHandler("build", func(c *RequestCtx) {
+		// Plain HTTP cannot notify; progress of a job is still recorded.
+		if err := c.Progress(map[string]int{"percent": 50}); err != nil {
+			c.Error = err
+			return
+		}
+		c.Result = "ok"
+	}, Async())
+
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.SetBodyString(`{"jsonrpc":"2.0","method":"build","params":{"_meta":{"progressToken":"t1"}},"id":1}`)
+	s.Handler(ctx)
+	id := fastjson.GetString(ctx.Response.Body(), "result", "jobId")
+	require.Eventually(t, func() bool {
+		j, err := s.Jobs.Status(id)
+		return err == nil && j.State == JobSucceeded
+	}, time.Second, time.Millisecond)
+	j, _ := s.Jobs.Status(id)
+	assert.JSONEq(t, `{"percent":50}`, string(j.Progress))
+
+	var params fastjson.Parser
+	v, _ := params.Parse(`{"_meta":{"progressToken":7}}`)
+	assert.Equal(t, `7`, string(ProgressToken(v)))
+	v, _ = params.Parse(`{"_meta":{"progressToken":{}}}`)
+	assert.Nil(t, ProgressToken(v))
+	assert.Nil(t, ProgressToken(nil))
+}
+
+func TestProgressWebSocket(t *testing.T) {
+	t.Parallel()
+
+	j := ws.NewJSONRPC2()
+	j.RegisterMethodContext("count", func(ctx context.Context, _ *fastjson.Arena, params *fastjson.Value) (interface{}, error) {
+		for i := 1; i <= params.GetInt("n"); i++ {
+			if err := ReportProgress(ctx, i); err != nil {
+				return nil, err
+			}
+		}
+		return "done", nil
+	})
+	j.RegisterMethodContext("job", func(ctx context.Context, _ *fastjson.Arena, _ *fastjson.Value) (interface{}, error) {
+		return nil, ReportProgress(ctx, "half")
+	}, ws.Async())
+
+	h := rpctest.NewJSONRPC2(t, j)
+	// progress returns the $/progress notifications received so far.
+	progress := func() []ProgressParams {
+		var a []ProgressParams
+		for _, n := range h.Notifications() {
+			var p ProgressParams
+			if n.Method == ProgressMethod && json.Unmarshal(n.Params, &p) == nil {
+				a = append(a, p)
+			}
+		}
+		return a
+	}
+
+	h.Call(t, "count", map[string]any{"n": 3, "_meta": map[string]any{"progressToken": "abc"}}).ExpectResult("done")
+	// Progress is written before the response on the same connection.
+	a := progress()
+	require.Len(t, a, 3)
+	for i, p := range a {
+		assert.Equal(t, `"abc"`, string(p.Token))
+		assert.EqualValues(t, i+1, p.Value)
+	}
+
+	// Without a token nothing is sent.
+	h.Call(t, "count", map[string]any{"n": 2}).ExpectResult("done")
+	assert.Len(t, progress(), 3)
+
+	// Async jobs report progress to the connection that started them.
+	h.Call(t, "job", map[string]any{"_meta": map[string]any{"progressToken": 9}})
+	h.WaitNotification(t, ws.JobCompleted)
+	a = progress()
+	require.Len(t, a, 4)
+	assert.Equal(t, `9`, string(a[3].Token))
+	assert.Equal(t, "half", a[3].Value)
+
+	// ws.HTTPHandler calls cannot notify.
+	b, err := j.HandleMessage([]byte(`{"jsonrpc":"2.0","method":"count","params":{"n":1,"_meta":{"progressToken":"abc"}},"id":1}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"done"}`, string(b))
+}
+
+func TestProgressStream(t *testing.T) {
+	t.Parallel()
+
+	j := ws.NewJSONRPC2()
+	j.RegisterMethodContext("count", func(ctx context.Context, _ *fastjson.Arena, params *fastjson.Value) (interface{}, error) {
+		for i := 1; i <= params.GetInt("n"); i++ {
+			if err := ReportProgress(ctx, i); err != nil {
+				return nil, err
+			}
+		}
+		return "done", nil
+	})
+
+	server, conn := net.Pipe()
+	served := make(chan error, 1)
+	go func() {
+		served <- ws.ServeStream(context.Background(), j, server, server)
+		_ = server.Close()
+	}()
+
+	var mu sync.Mutex
+	var a []ProgressParams
+	c := client.New(client.NewStream(conn, func(method string, params json.RawMessage) {
+		var p ProgressParams
+		if method == ProgressMethod && json.Unmarshal(params, &p) == nil {
+			mu.Lock()
+			a = append(a, p)
+			mu.Unlock()
+		}
+	}))
+	defer c.Close()
+
+	var r string
+	require.NoError(t, c.Call(context.Background(), "count", map[string]any{"n": 3, "_meta": map[string]any{"progressToken": "abc"}}, &r))
+	assert.Equal(t, "done", r)
+	// Notifications are written before the response on the same stream.
+	mu.Lock()
+	require.Len(t, a, 3)
+	for i, p := range a {
+		assert.Equal(t, `"abc"`, string(p.Token))
+		assert.EqualValues(t, i+1, p.Value)
+	}
+	mu.Unlock()
+
+	require.NoError(t, c.Close())
+	assert.NoError(t, <-served)
+}
+
+func TestProgressSSE(t *testing.T) {
+	t.Parallel()
+
+	j := ws.NewJSONRPC2()
+	j.RegisterMethodContext("count", func(ctx context.Context, _ *fastjson.Arena, params *fastjson.Value) (interface{}, error) {
+		for i := 1; i <= params.GetInt("n"); i++ {
+			if err := ReportProgress(ctx, i); err != nil {
+				return nil, err
+			}
+		}
+		return "done", nil
+	})
+
+	ln := fasthttputil.NewInmemoryListener()
+	go func() { _ = fasthttp.Serve(ln, ws.HTTPHandler(j)) }()
+	t.Cleanup(func() { _ = ln.Close() })
+	hc := &fasthttp.Client{Dial: func(string) (net.Conn, error) { return ln.Dial() }}
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	req.SetRequestURI("http://test/rpc")
+	req.Header.SetMethod(fasthttp.MethodPost)
+	req.Header.SetContentType("application/json")
+	req.Header.Set(fasthttp.HeaderAccept, "application/json, text/event-stream")
+	req.SetBodyString(`{"jsonrpc":"2.0","method":"count","params":{"n":2,"_meta":{"progressToken":"abc"}},"id":1}`)
+	require.NoError(t, hc.Do(req, resp))
+
+	assert.Equal(t, "text/event-stream", string(resp.Header.ContentType()))
+	// Progress events come before the response event.
+	var data []string
+	for _, line := range strings.Split(string(resp.Body()), "\n") {
+		if s, ok := strings.CutPrefix(line, "data: "); ok {
+			data = append(data, s)
+		}
+	}
+	require.Len(t, data, 3)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","method":"$/progress","params":{"token":"abc","value":1}}`, data[0])
+	assert.JSONEq(t, `{"jsonrpc":"2.0","method":"$/progress","params":{"token":"abc","value":2}}`, data[1])
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"done"}`, data[2])
+
+	// Without text/event-stream the response is plain JSON.
+	req.Header.Set(fasthttp.HeaderAccept, "application/json")
+	require.NoError(t, hc.Do(req, resp))
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"done"}`, string(resp.Body()))
+}
@@ -0,0 +1,100 @@
+package record
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// File is an append-only file rotated by size. When a write would grow it
+// beyond the maximum size, path is renamed to path.1, path.1 to path.2 and
+// so on, keeping at most the configured number of backups.
+type File struct {
+	path    string
+	maxSize int64
+	backups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// OpenFile opens or creates path for appending. A maxSize <= 0 disables
+// rotation.
+func OpenFile(path string, maxSize int64, backups int) (*File, error) {
+	p := &File{path: path, maxSize: maxSize, backups: backups}
+	if err := p.open(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *File) open() error {
+	f, err := os.OpenFile(p.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	p.f, p.size = f, fi.Size()
+	return nil
+}
+
+// Write appends b, rotating first if needed. A single write is never
+// split across files.
+func (p *File) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.f == nil {
+		return 0, os.ErrClosed
+	}
+	if p.maxSize > 0 && p.size > 0 && p.size+int64(len(b)) > p.maxSize {
+		if err := p.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := p.f.Write(b)
+	p.size += int64(n)
+	return n, err
+}
+
+// rotate moves the file aside and opens a new one. The old file stays in
+// use until the new one is open, so a failed rotation loses no writes.
+func (p *File) rotate() error {
+	old := p.f
+	if p.backups > 0 {
+		_ = os.Remove(p.backup(p.backups))
+		for i := p.backups - 1; i > 0; i-- {
+			if err := os.Rename(p.backup(i), p.backup(i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+		if err := os.Rename(p.path, p.backup(1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(p.path); err != nil {
+		return err
+	}
+	if err := p.open(); err != nil {
+		return err
+	}
+	return old.Close()
+}
+
+func (p *File) backup(i int) string { return p.path + "." + strconv.Itoa(i) }
+
+// Close closes the file.
+func (p *File) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.f == nil {
+		return os.ErrClosed
+	}
+	err := p.f.Close()
+	p.f = nil
+	return err
+}
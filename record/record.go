@@ -0,0 +1,208 @@
+// Package record captures JSON-RPC traffic to JSONL files and replays it
+// against a server, reporting the responses that changed.
+//
+// A Recorder wraps an HTTP handler, or is installed on a ws.JSONRPC2 with
+// Recorder.WebSocket, and writes one Entry per request:
+//
+//	f, _ := record.OpenFile("traffic.jsonl", 64<<20, 5)
+//	rec := record.New(f, record.WithHeaders("X-Request-Id"),
+//		record.WithRedaction(record.Redaction{Keys: []string{"password"}}))
+//	fasthttp.ListenAndServe(":8080", rec.Handler(s.Handler))
+//
+// Replay feeds a recording back, in order, and diffs every response
+// against the recorded one:
+//
+//	r, _ := os.Open("traffic.jsonl")
+//	report, err := record.Replay(ctx, r, record.Handler(s.Handler), "result.time")
+package record
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fastjson"
+	"github.com/zc310/fastjsonrpc/ws"
+)
+
+// Transports of recorded entries.
+const (
+	TransportHTTP      = "http"
+	TransportWebSocket = "ws"
+)
+
+// Entry is one recorded request and its response.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// Duration is the time spent handling the request.
+	Duration  time.Duration     `json:"duration"`
+	Transport string            `json:"transport"`
+	Methods   []string          `json:"methods,omitempty"`
+	Header    map[string]string `json:"header,omitempty"`
+	// Request holds the request as sent. A request that is not valid JSON
+	// is stored as a JSON string and Malformed is set; its text is
+	// redacted by key, see Redaction.
+	Request   json.RawMessage `json:"request"`
+	Malformed bool            `json:"malformed,omitempty"`
+	// Response is empty for notifications.
+	Response json.RawMessage `json:"response,omitempty"`
+}
+
+// RequestBytes returns the request as it was sent.
+func (p *Entry) RequestBytes() []byte {
+	if p.Malformed {
+		var s string
+		if json.Unmarshal(p.Request, &s) == nil {
+			return []byte(s)
+		}
+	}
+	return p.Request
+}
+
+// Recorder writes entries as JSON lines to a writer.
+type Recorder struct {
+	w       io.Writer
+	headers []string
+	redact  Redaction
+	onError func(error)
+
+	mu sync.Mutex
+}
+
+// Option configures a Recorder.
+type Option func(*Recorder)
+
+// WithHeaders records the values of the named request headers. Values of
+// headers listed in DefaultRedactedHeaders or Redaction.Headers are
+// replaced by Redacted.
+func WithHeaders(names ...string) Option { return func(p *Recorder) { p.headers = names } }
+
+// WithRedaction sets the rules that hide secrets in recorded headers,
+// requests and responses.
+func WithRedaction(r Redaction) Option {
+	return func(p *Recorder) {
+		p.redact = r
+		p.redact.compile()
+	}
+}
+
+// WithErrorHandler receives the errors of writes to the underlying writer,
+// which are otherwise dropped.
+func WithErrorHandler(fn func(error)) Option { return func(p *Recorder) { p.onError = fn } }
+
+// New returns a Recorder writing to w. Writes are serialized; w receives
+// one complete line per Write call.
+func New(w io.Writer, opts ...Option) *Recorder {
+	p := &Recorder{w: w}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Record redacts e and writes it.
+func (p *Recorder) Record(e *Entry) error {
+	if !json.Valid(e.Request) {
+		e.Request, _ = json.Marshal(string(p.redact.scrub(e.Request)))
+		e.Malformed = true
+	} else {
+		e.Methods = methods(e.Request)
+		e.Request = p.redact.apply(e.Request)
+	}
+	if len(e.Response) > 0 {
+		e.Response = p.redact.apply(e.Response)
+	}
+	if len(e.Header) > 0 {
+		h := make(map[string]string, len(e.Header))
+		for name, v := range e.Header {
+			if p.redact.header(name) {
+				v = Redacted
+			}
+			h[name] = v
+		}
+		e.Header = h
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err = p.w.Write(append(b, '\n'))
+	if err != nil && p.onError != nil {
+		p.onError(err)
+	}
+	return err
+}
+
+// Handler records the requests handled by next, which may be
+// ServerMap.Handler, ws.HTTPHandler or any JSON-RPC HTTP handler.
+func (p *Recorder) Handler(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		start := time.Now()
+		next(ctx)
+
+		e := &Entry{
+			Time:      start,
+			Duration:  time.Since(start),
+			Transport: TransportHTTP,
+			Header:    p.header(func(name string) []byte { return ctx.Request.Header.Peek(name) }),
+			Request:   append([]byte(nil), ctx.Request.Body()...),
+		}
+		if b := ctx.Response.Body(); len(b) > 0 {
+			e.Response = append([]byte(nil), b...)
+		}
+		_ = p.Record(e)
+	}
+}
+
+func (p *Recorder) header(peek func(string) []byte) map[string]string {
+	if len(p.headers) == 0 {
+		return nil
+	}
+	h := make(map[string]string, len(p.headers))
+	for _, name := range p.headers {
+		if v := peek(name); len(v) > 0 {
+			h[name] = string(v)
+		}
+	}
+	return h
+}
+
+// WebSocket returns an option that records the messages handled over
+// WebSocket connections by a ws.JSONRPC2.
+func (p *Recorder) WebSocket() ws.Option {
+	return ws.WithTrace(func(header map[string]string, request, response []byte, start time.Time) {
+		_ = p.Record(&Entry{
+			Time:      start,
+			Duration:  time.Since(start),
+			Transport: TransportWebSocket,
+			Header:    header,
+			Request:   request,
+			Response:  response,
+		})
+	}, p.headers...)
+}
+
+// methods returns the methods called by request.
+func methods(request []byte) []string {
+	v, err := fastjson.ParseBytes(request)
+	if err != nil {
+		return nil
+	}
+	items := []*fastjson.Value{v}
+	if v.Type() == fastjson.TypeArray {
+		items = v.GetArray()
+	}
+	var a []string
+	for _, item := range items {
+		if m := item.GetStringBytes("method"); m != nil {
+			a = append(a, string(m))
+		}
+	}
+	return a
+}
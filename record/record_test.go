@@ -0,0 +1,196 @@
+package record_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/valyala/fastjson"
+	"github.com/zc310/fastjsonrpc"
+	"github.com/zc310/fastjsonrpc/record"
+	"github.com/zc310/fastjsonrpc/rpctest"
+	"github.com/zc310/fastjsonrpc/ws"
+)
+
+type Login struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+func entries(t *testing.T, b []byte) []*record.Entry {
+	var a []*record.Entry
+	for _, line := range bytes.Split(bytes.TrimSpace(b), []byte("\n")) {
+		e := new(record.Entry)
+		require.NoError(t, json.Unmarshal(line, e))
+		a = append(a, e)
+	}
+	return a
+}
+
+func newServer(counter *atomic.Int64) *fastjsonrpc.ServerMap {
+	s := new(fastjsonrpc.ServerMap)
+	s.RegisterHandler("login", func(c *fastjsonrpc.RequestCtx) {
+		c.Result = map[string]any{"token": "secret-" + string(c.Params.GetStringBytes("user")), "time": counter.Add(1)}
+	})
+	s.RegisterHandler("add", func(c *fastjsonrpc.RequestCtx) { c.Result = c.Params.GetInt("a") + c.Params.GetInt("b") })
+	s.RegisterHandler("log", func(c *fastjsonrpc.RequestCtx) {})
+	return s
+}
+
+func TestRecordHTTP(t *testing.T) {
+	var buf bytes.Buffer
+	rec := record.New(&buf,
+		record.WithHeaders("X-Request-Id", "Authorization"),
+		record.WithRedaction(record.Redaction{Paths: []string{"result.token"}, Keys: []string{"PASSWORD"}}))
+
+	var n atomic.Int64
+	h := rpctest.NewHTTP(t, rec.Handler(newServer(&n).Handler))
+	h.Header = map[string]string{"X-Request-Id": "r1", "Authorization": "Bearer x"}
+	h.Call(t, "login", Login{User: "bob", Password: "hunter2"}).ExpectResult(map[string]any{"token": "secret-bob", "time": 1})
+	h.Notify(t, "log", nil)
+	h.Batch(t, rpctest.Request{Method: "add", Params: map[string]int{"a": 1, "b": 2}}, rpctest.Request{Method: "log", Notify: true})
+	h.Send(t, []byte("{\"oops\"\n"))
+
+	a := entries(t, buf.Bytes())
+	require.Len(t, a, 4)
+
+	assert.Equal(t, record.TransportHTTP, a[0].Transport)
+	assert.Equal(t, []string{"login"}, a[0].Methods)
+	assert.Equal(t, map[string]string{"X-Request-Id": "r1", "Authorization": record.Redacted}, a[0].Header)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","method":"login","params":{"user":"bob","password":"[REDACTED]"},"id":1}`, string(a[0].Request))
+	assert.JSONEq(t, `{"jsonrpc":"2.0","result":{"token":"[REDACTED]","time":1},"id":1}`, string(a[0].Response))
+	assert.False(t, a[0].Time.IsZero())
+
+	assert.Empty(t, a[1].Response)
+	assert.Equal(t, []string{"add", "log"}, a[2].Methods)
+	assert.True(t, a[3].Malformed)
+	assert.Equal(t, "{\"oops\"\n", string(a[3].RequestBytes()))
+}
+
+func TestRecordMalformed(t *testing.T) {
+	var buf bytes.Buffer
+	rec := record.New(&buf, record.WithRedaction(record.Redaction{Paths: []string{"params.token"}, Keys: []string{"password"}}))
+
+	for _, body := range []string{
+		`{"method":"login","params":{"user":"bob","Password":"hunter2","token":42},`,
+		`{"method":"login","params":{"password": "hunter2\"x`,
+	} {
+		require.NoError(t, rec.Record(&record.Entry{Request: []byte(body)}))
+	}
+
+	a := entries(t, buf.Bytes())
+	require.Len(t, a, 2)
+	assert.NotContains(t, buf.String(), "hunter2")
+	assert.True(t, a[0].Malformed)
+	assert.Equal(t, `{"method":"login","params":{"user":"bob","Password":"[REDACTED]","token":"[REDACTED]"},`, string(a[0].RequestBytes()))
+	assert.Equal(t, `{"method":"login","params":{"password": "[REDACTED]"`, string(a[1].RequestBytes()))
+}
+
+func TestRecordWebSocket(t *testing.T) {
+	var buf bytes.Buffer
+	rec := record.New(&buf)
+	j := ws.NewJSONRPC2(rec.WebSocket())
+	j.RegisterMethodFunc("double", func(params *fastjson.Value) (interface{}, error) { return params.GetInt("a") * 2, nil })
+
+	h := rpctest.NewJSONRPC2(t, j)
+	h.Call(t, "double", map[string]int{"a": 2}).ExpectResult(4)
+
+	a := entries(t, buf.Bytes())
+	require.Len(t, a, 1)
+	assert.Equal(t, record.TransportWebSocket, a[0].Transport)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","result":4,"id":1}`, string(a[0].Response))
+
+	report, err := record.Replay(context.Background(), &buf, record.JSONRPC2(j))
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%v", report.Mismatches)
+}
+
+func TestReplay(t *testing.T) {
+	var buf bytes.Buffer
+	rec := record.New(&buf)
+	var n atomic.Int64
+	h := rpctest.NewHTTP(t, rec.Handler(newServer(&n).Handler))
+	h.Call(t, "login", Login{User: "bob"})
+	h.Batch(t, rpctest.Request{Method: "add", Params: map[string]int{"a": 1, "b": 2}}, rpctest.Request{Method: "add", Params: map[string]int{"a": 3}})
+	h.Notify(t, "log", nil)
+	h.Send(t, []byte("{"))
+	recording := buf.Bytes()
+
+	ctx := context.Background()
+	var m atomic.Int64
+	m.Store(10)
+	report, err := record.Replay(ctx, bytes.NewReader(recording), record.Handler(newServer(&m).Handler))
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.Entries)
+	require.Len(t, report.Mismatches, 1)
+	assert.Equal(t, 1, report.Mismatches[0].Line)
+	assert.Contains(t, report.Mismatches[0].String(), `"time":1`)
+	assert.Contains(t, report.Mismatches[0].String(), `"time":11`)
+
+	report, err = record.Replay(ctx, bytes.NewReader(recording), record.Handler(newServer(&m).Handler), "result.time")
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%v", report.Mismatches)
+
+	s := newServer(&m)
+	s.RegisterHandler("add", func(c *fastjsonrpc.RequestCtx) { c.Result = 0 })
+	report, err = record.Replay(ctx, bytes.NewReader(recording), record.Handler(s.Handler), "result.time")
+	require.NoError(t, err)
+	require.Len(t, report.Mismatches, 1)
+	assert.Equal(t, 2, report.Mismatches[0].Line)
+
+	_, err = record.Replay(ctx, strings.NewReader("{\n"), record.Handler(s.Handler))
+	assert.Error(t, err)
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traffic.jsonl")
+	f, err := record.OpenFile(path, 250, 2)
+	require.NoError(t, err)
+	rec := record.New(f)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, rec.Record(&record.Entry{Time: time.Unix(0, 0), Request: []byte(`{"method":"m"}`)}))
+	}
+	require.NoError(t, f.Close())
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		b, err := os.ReadFile(name)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(b), 250)
+		assert.NotEmpty(t, entries(t, b))
+	}
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFileRotateError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traffic.jsonl")
+	// A non-empty directory in the way of the backup makes rotation fail.
+	require.NoError(t, os.MkdirAll(filepath.Join(path+".1", "x"), 0o755))
+	f, err := record.OpenFile(path, 10, 1)
+	require.NoError(t, err)
+
+	_, err = f.Write([]byte("0123456789"))
+	require.NoError(t, err)
+	_, err = f.Write([]byte("x"))
+	assert.Error(t, err)
+
+	// The current file is still usable once rotation can succeed.
+	require.NoError(t, os.RemoveAll(path+".1"))
+	_, err = f.Write([]byte("abc"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", string(b))
+	b, err = os.ReadFile(path + ".1")
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(b))
+}
@@ -0,0 +1,202 @@
+package record
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-json"
+)
+
+// Redacted replaces redacted values.
+const Redacted = "[REDACTED]"
+
+// DefaultRedactedHeaders are always redacted when recorded.
+var DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// Redaction hides secrets in recordings.
+//
+// Paths are dot-separated and rooted at a message, such as
+// "params.password" or "result.accounts.*.token", and apply to every
+// message of a batch. A "*" segment matches any object key or array
+// element, and a number matches an array index. Requests that are not
+// valid JSON are redacted by key only, using Keys and the last named
+// segment of every path.
+type Redaction struct {
+	// Headers lists request headers whose values are redacted, in addition
+	// to DefaultRedactedHeaders.
+	Headers []string
+	// Paths lists the values redacted in requests and responses.
+	Paths []string
+	// Keys lists object keys whose values are redacted at any depth,
+	// compared case-insensitively.
+	Keys []string
+
+	scrubber *regexp.Regexp
+}
+
+func (p *Redaction) header(name string) bool {
+	for _, h := range DefaultRedactedHeaders {
+		if strings.EqualFold(h, name) {
+			return true
+		}
+	}
+	for _, h := range p.Headers {
+		if strings.EqualFold(h, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply returns b with the redacted values replaced, or b itself if no
+// value matched.
+func (p *Redaction) apply(b []byte) []byte {
+	if len(p.Paths) == 0 && len(p.Keys) == 0 {
+		return b
+	}
+	v, err := decode(b)
+	if err != nil {
+		return b
+	}
+	changed := false
+	eachMessage(v, func(m any) {
+		for _, path := range p.Paths {
+			changed = edit(m, strings.Split(path, "."), true) || changed
+		}
+		if len(p.Keys) > 0 {
+			changed = p.redactKeys(m) || changed
+		}
+	})
+	if !changed {
+		return b
+	}
+	if out, err := json.Marshal(v); err == nil {
+		return out
+	}
+	return b
+}
+
+// compile prepares the pattern used by scrub.
+func (p *Redaction) compile() {
+	var names []string
+	for _, k := range p.Keys {
+		names = append(names, regexp.QuoteMeta(k))
+	}
+	for _, path := range p.Paths {
+		seg := path[strings.LastIndexByte(path, '.')+1:]
+		if _, err := strconv.Atoi(seg); err != nil && seg != "*" && seg != "" {
+			names = append(names, regexp.QuoteMeta(seg))
+		}
+	}
+	p.scrubber = nil
+	if len(names) > 0 {
+		p.scrubber = regexp.MustCompile(`(?i)("(?:` + strings.Join(names, "|") + `)"\s*:\s*)(?:"(?:[^"\\]|\\.)*"?|[^\s,}\]]*)`)
+	}
+}
+
+// scrub redacts b, which is not valid JSON, in its text: the values of
+// Keys and of the last named segment of Paths are replaced wherever they
+// appear as "name": value, including a string left unterminated.
+func (p *Redaction) scrub(b []byte) []byte {
+	if p.scrubber == nil {
+		return b
+	}
+	return p.scrubber.ReplaceAll(b, []byte(`${1}"`+Redacted+`"`))
+}
+
+func (p *Redaction) redactKeys(v any) bool {
+	changed := false
+	switch x := v.(type) {
+	case map[string]any:
+		for k, item := range x {
+			if p.key(k) {
+				x[k] = Redacted
+				changed = true
+				continue
+			}
+			changed = p.redactKeys(item) || changed
+		}
+	case []any:
+		for _, item := range x {
+			changed = p.redactKeys(item) || changed
+		}
+	}
+	return changed
+}
+
+func (p *Redaction) key(k string) bool {
+	for _, s := range p.Keys {
+		if strings.EqualFold(s, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// decode parses b keeping numbers exact.
+func decode(b []byte) (any, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v any
+	err := d.Decode(&v)
+	return v, err
+}
+
+// eachMessage calls fn with v, or with every element of a batch.
+func eachMessage(v any, fn func(any)) {
+	if a, ok := v.([]any); ok {
+		for _, m := range a {
+			fn(m)
+		}
+		return
+	}
+	fn(v)
+}
+
+// edit replaces the values matched by path with Redacted if redact is set,
+// and removes them otherwise. Removed array elements become null. It
+// reports whether any value matched.
+func edit(v any, path []string, redact bool) bool {
+	if len(path) == 0 {
+		return false
+	}
+	seg, last := path[0], len(path) == 1
+	changed := false
+	switch x := v.(type) {
+	case map[string]any:
+		for k, item := range x {
+			if seg != "*" && seg != k {
+				continue
+			}
+			if !last {
+				changed = edit(item, path[1:], redact) || changed
+				continue
+			}
+			if redact {
+				x[k] = Redacted
+			} else {
+				delete(x, k)
+			}
+			changed = true
+		}
+	case []any:
+		for i, item := range x {
+			if seg != "*" && seg != strconv.Itoa(i) {
+				continue
+			}
+			if !last {
+				changed = edit(item, path[1:], redact) || changed
+				continue
+			}
+			if redact {
+				x[i] = Redacted
+			} else {
+				x[i] = nil
+			}
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,179 @@
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/valyala/fasthttp"
+	"github.com/zc310/fastjsonrpc/client"
+	"github.com/zc310/fastjsonrpc/ws"
+)
+
+// Target executes a recorded request and returns the raw response, or nil
+// if the server sent none.
+type Target func(ctx context.Context, e *Entry) ([]byte, error)
+
+// Handler returns a Target that calls h in process, with the recorded
+// headers that were not redacted.
+func Handler(h fasthttp.RequestHandler) Target {
+	return func(_ context.Context, e *Entry) ([]byte, error) {
+		var ctx fasthttp.RequestCtx
+		ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+		ctx.Request.Header.SetContentType("application/json")
+		for k, v := range e.Header {
+			if v != Redacted {
+				ctx.Request.Header.Set(k, v)
+			}
+		}
+		ctx.Request.SetBody(e.RequestBytes())
+		h(&ctx)
+		if b := ctx.Response.Body(); len(b) > 0 {
+			return append([]byte(nil), b...), nil
+		}
+		return nil, nil
+	}
+}
+
+// JSONRPC2 returns a Target that handles requests with j in process, as
+// messages received over WebSocket.
+func JSONRPC2(j *ws.JSONRPC2) Target {
+	return func(ctx context.Context, e *Entry) ([]byte, error) {
+		return j.HandleMessageContext(ctx, e.RequestBytes())
+	}
+}
+
+// HTTP returns a Target that posts requests to url with c, or a default
+// client if c is nil, forwarding the recorded headers that were not
+// redacted.
+func HTTP(url string, c *fasthttp.Client) Target {
+	return func(ctx context.Context, e *Entry) ([]byte, error) {
+		t := &client.HTTPTransport{URL: url, Client: c, Header: make(map[string]string, len(e.Header))}
+		for k, v := range e.Header {
+			if v != Redacted {
+				t.Header[k] = v
+			}
+		}
+		return t.RoundTrip(ctx, e.RequestBytes())
+	}
+}
+
+// Transport returns a Target that sends requests over t, such as a
+// client.WebSocketTransport.
+func Transport(t client.Transport) Target {
+	return func(ctx context.Context, e *Entry) ([]byte, error) {
+		return t.RoundTrip(ctx, e.RequestBytes())
+	}
+}
+
+// Mismatch is a replayed request whose response differs from the
+// recording.
+type Mismatch struct {
+	// Line is the line of the entry in the recording, starting at 1.
+	Line  int
+	Entry *Entry
+	// Got is the replayed response, and Err the error of the Target.
+	Got json.RawMessage
+	Err error
+	// Want and Have are the compared forms of the recorded and replayed
+	// responses, without the ignored fields.
+	Want, Have string
+}
+
+func (p *Mismatch) String() string {
+	s := "line " + strconv.Itoa(p.Line)
+	if len(p.Entry.Methods) > 0 {
+		s += " (" + strings.Join(p.Entry.Methods, ", ") + ")"
+	}
+	if p.Err != nil {
+		return s + ": " + p.Err.Error()
+	}
+	return s + ":\n\twant " + p.Want + "\n\thave " + p.Have
+}
+
+// Report summarizes a replay.
+type Report struct {
+	Entries    int
+	Mismatches []*Mismatch
+}
+
+// OK reports whether every response matched.
+func (p *Report) OK() bool { return len(p.Mismatches) == 0 }
+
+// Replay sends the entries read from r to target one at a time, in
+// recorded order, and compares each response with the recorded one.
+// Values matched by the ignore paths, with the syntax of Redaction.Paths,
+// are left out of the comparison, as are the order of batch responses and
+// of object keys. An error is returned only if r cannot be read.
+func Replay(ctx context.Context, r io.Reader, target Target, ignore ...string) (*Report, error) {
+	paths := make([][]string, len(ignore))
+	for i, s := range ignore {
+		paths[i] = strings.Split(s, ".")
+	}
+
+	report := new(Report)
+	br := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		b, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(b)) > 0 {
+			e := new(Entry)
+			if err := json.Unmarshal(b, e); err != nil {
+				return report, errors.New("record: line " + strconv.Itoa(line) + ": " + err.Error())
+			}
+			report.Entries++
+
+			got, terr := target(ctx, e)
+			m := &Mismatch{Line: line, Entry: e, Got: got, Err: terr}
+			if terr == nil {
+				m.Want, m.Have = normalize(e.Response, paths), normalize(got, paths)
+			}
+			if terr != nil || m.Want != m.Have {
+				report.Mismatches = append(report.Mismatches, m)
+			}
+		}
+		if err == io.EOF {
+			return report, nil
+		}
+		if err != nil {
+			return report, err
+		}
+		if ctx.Err() != nil {
+			return report, ctx.Err()
+		}
+	}
+}
+
+// normalize returns the compared form of a response: without the ignored
+// values, with sorted object keys and sorted batch elements.
+func normalize(b []byte, ignore [][]string) string {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return ""
+	}
+	v, err := decode(b)
+	if err != nil {
+		return string(b)
+	}
+	eachMessage(v, func(m any) {
+		for _, path := range ignore {
+			edit(m, path, false)
+		}
+	})
+	a, batch := v.([]any)
+	if !batch {
+		out, _ := json.Marshal(v)
+		return string(out)
+	}
+	items := make([]string, len(a))
+	for i, m := range a {
+		out, _ := json.Marshal(m)
+		items[i] = string(out)
+	}
+	sort.Strings(items)
+	return "[" + strings.Join(items, ",") + "]"
+}
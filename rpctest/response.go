@@ -0,0 +1,195 @@
+package rpctest
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/pretty"
+	"github.com/zc310/fastjsonrpc"
+)
+
+var update = flag.Bool("rpctest.update", false, "rewrite the golden files compared by rpctest")
+
+// Response is a parsed response to a single request.
+type Response struct {
+	t testing.TB
+
+	Raw []byte
+	// Status is the HTTP status code, or zero over WebSocket.
+	Status int
+	ID     json.RawMessage
+	Result json.RawMessage
+	Error  *fastjsonrpc.Error
+}
+
+type envelope struct {
+	ID     json.RawMessage    `json:"id"`
+	Result json.RawMessage    `json:"result"`
+	Error  *fastjsonrpc.Error `json:"error"`
+}
+
+func parseResponse(t testing.TB, b []byte, status int) *Response {
+	t.Helper()
+	r := &Response{t: t, Raw: b, Status: status}
+	if len(b) == 0 {
+		t.Fatalf("rpctest: empty response (HTTP status %d)", status)
+	}
+	var e envelope
+	if err := json.Unmarshal(b, &e); err != nil {
+		t.Fatalf("rpctest: invalid response %s: %v", b, err)
+	}
+	r.ID, r.Result, r.Error = e.ID, e.Result, e.Error
+	return r
+}
+
+// ExpectResult asserts that the call succeeded with a result whose JSON
+// encoding equals that of want. A json.RawMessage want is compared as is.
+func (p *Response) ExpectResult(want any) *Response {
+	p.t.Helper()
+	if p.Error != nil {
+		p.t.Errorf("rpctest: expected a result, got error %s", p.Raw)
+		return p
+	}
+	assert.JSONEq(p.t, string(marshal(p.t, want)), string(p.Result), "result of %s", p.Raw)
+	return p
+}
+
+// ExpectError asserts that the call failed with code.
+func (p *Response) ExpectError(code int) *Response {
+	p.t.Helper()
+	if p.Error == nil {
+		p.t.Errorf("rpctest: expected error %d, got %s", code, p.Raw)
+		return p
+	}
+	assert.Equal(p.t, code, p.Error.Code, "error code of %s", p.Raw)
+	return p
+}
+
+// ExpectErrorData asserts that the call failed with code and data whose
+// JSON encoding equals that of want.
+func (p *Response) ExpectErrorData(code int, want any) *Response {
+	p.t.Helper()
+	if p.ExpectError(code); p.Error != nil {
+		assert.JSONEq(p.t, string(marshal(p.t, want)), string(marshal(p.t, p.Error.Data)), "error data of %s", p.Raw)
+	}
+	return p
+}
+
+// ExpectGolden compares the response with testdata/name.golden.
+func (p *Response) ExpectGolden(name string) *Response {
+	p.t.Helper()
+	Golden(p.t, name, p.Raw)
+	return p
+}
+
+// Decode unmarshals the result into v, failing the test on error.
+func (p *Response) Decode(v any) {
+	p.t.Helper()
+	if p.Error != nil {
+		p.t.Fatalf("rpctest: expected a result, got error %s", p.Raw)
+	}
+	if err := json.Unmarshal(p.Result, v); err != nil {
+		p.t.Fatalf("rpctest: decode result %s: %v", p.Result, err)
+	}
+}
+
+func marshal(t testing.TB, v any) []byte {
+	t.Helper()
+	if b, ok := v.(json.RawMessage); ok {
+		return b
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("rpctest: encode %v: %v", v, err)
+	}
+	return b
+}
+
+// Batch is a parsed response to a batch request.
+type Batch struct {
+	t testing.TB
+
+	Raw    []byte
+	Status int
+	// Responses holds the response to each request in request order, nil
+	// for notifications and unanswered requests.
+	Responses []*Response
+}
+
+func parseBatch(t testing.TB, b []byte, status int, ids []string) *Batch {
+	t.Helper()
+	p := &Batch{t: t, Raw: b, Status: status, Responses: make([]*Response, len(ids))}
+	if len(b) == 0 {
+		return p
+	}
+	var a []json.RawMessage
+	if err := json.Unmarshal(b, &a); err != nil {
+		t.Fatalf("rpctest: invalid batch response %s: %v", b, err)
+	}
+	for _, item := range a {
+		r := parseResponse(t, item, status)
+		for i, id := range ids {
+			if id != "" && id == string(r.ID) {
+				p.Responses[i] = r
+			}
+		}
+	}
+	return p
+}
+
+// Get returns the response to the i-th request, failing the test if there
+// is none.
+func (p *Batch) Get(i int) *Response {
+	p.t.Helper()
+	if i >= len(p.Responses) || p.Responses[i] == nil {
+		p.t.Fatalf("rpctest: no response to request %d in %s", i, p.Raw)
+	}
+	return p.Responses[i]
+}
+
+// ExpectLen asserts that the batch response holds n responses.
+func (p *Batch) ExpectLen(n int) *Batch {
+	p.t.Helper()
+	var a []json.RawMessage
+	if len(p.Raw) > 0 {
+		_ = json.Unmarshal(p.Raw, &a)
+	}
+	assert.Len(p.t, a, n, "responses in %s", p.Raw)
+	return p
+}
+
+// ExpectGolden compares the batch response with testdata/name.golden.
+func (p *Batch) ExpectGolden(name string) *Batch {
+	p.t.Helper()
+	Golden(p.t, name, p.Raw)
+	return p
+}
+
+// Golden compares got, pretty-printed, with testdata/name.golden. Run the
+// tests with -rpctest.update to rewrite the file.
+func Golden(t testing.TB, name string, got []byte) {
+	t.Helper()
+	got = pretty.Pretty(got)
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("rpctest: %v (run with -rpctest.update to create it)", err)
+	}
+	if !bytes.Equal(want, got) {
+		assert.Equal(t, string(want), string(got), "golden file %s", path)
+	}
+}
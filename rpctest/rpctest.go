@@ -0,0 +1,375 @@
+// Package rpctest serves a JSON-RPC server in memory for tests and asserts
+// on its responses.
+//
+//	h := rpctest.NewServerMap(t, s)
+//	h.Call(t, "Arith.Add", arith.Args{A: 1, B: 2}).ExpectResult(3)
+//	h.Call(t, "Arith.Divide", arith.Args{A: 1}).ExpectError(1)
+//
+// HTTP harnesses post every message over a fasthttputil.InmemoryListener.
+// WebSocket harnesses keep one connection over such a listener and capture
+// the notifications pushed by the server.
+package rpctest
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/goccy/go-json"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+	"github.com/valyala/fastjson"
+	"github.com/zc310/fastjsonrpc"
+	"github.com/zc310/fastjsonrpc/ws"
+)
+
+// DefaultTimeout bounds each exchange when Harness.Timeout is zero.
+const DefaultTimeout = 5 * time.Second
+
+// Harness exchanges JSON-RPC messages with a server running in memory.
+type Harness struct {
+	// Timeout bounds each exchange and WaitNotification.
+	Timeout time.Duration
+	// Header is added to every HTTP request.
+	Header map[string]string
+
+	ln   *fasthttputil.InmemoryListener
+	http *fasthttp.Client
+	conn *websocket.Conn
+
+	id atomic.Int64
+
+	exchange sync.Mutex // serializes WebSocket exchanges
+
+	mu            sync.Mutex
+	responses     [][]byte // received and not read yet
+	notifications []Notification
+	received      chan struct{} // closed and replaced on every message
+	err           error         // read error of the WebSocket connection
+}
+
+// Notification is a message without id pushed by the server.
+type Notification struct {
+	Method string
+	Params json.RawMessage
+}
+
+// Request is one call of a batch. Notify requests carry no id.
+type Request struct {
+	Method string
+	Params any
+	Notify bool
+}
+
+func serve(t testing.TB, h fasthttp.RequestHandler) *Harness {
+	t.Helper()
+	ln := fasthttputil.NewInmemoryListener()
+	go func() { _ = fasthttp.Serve(ln, h) }()
+	t.Cleanup(func() { _ = ln.Close() })
+	return &Harness{ln: ln, received: make(chan struct{})}
+}
+
+// NewHTTP serves h and posts every message to it.
+func NewHTTP(t testing.TB, h fasthttp.RequestHandler) *Harness {
+	p := serve(t, h)
+	p.http = &fasthttp.Client{Dial: func(string) (net.Conn, error) { return p.ln.Dial() }}
+	return p
+}
+
+// NewServerMap serves s over HTTP.
+func NewServerMap(t testing.TB, s *fastjsonrpc.ServerMap) *Harness { return NewHTTP(t, s.Handler) }
+
+// NewWebSocket serves the WebSocket handler h and connects to it.
+func NewWebSocket(t testing.TB, h fasthttp.RequestHandler) *Harness {
+	t.Helper()
+	p := serve(t, h)
+	d := &websocket.Dialer{NetDial: func(string, string) (net.Conn, error) { return p.ln.Dial() }}
+	conn, _, err := d.Dial("ws://rpctest/", nil)
+	if err != nil {
+		t.Fatalf("rpctest: dial: %v", err)
+	}
+	p.conn = conn
+	go p.read()
+	t.Cleanup(func() { _ = conn.Close() })
+	return p
+}
+
+// NewJSONRPC2 serves j over WebSocket.
+func NewJSONRPC2(t testing.TB, j *ws.JSONRPC2) *Harness {
+	return NewWebSocket(t, ws.Handler(j, &websocket.FastHTTPUpgrader{}))
+}
+
+func (p *Harness) timeout() time.Duration {
+	if p.Timeout > 0 {
+		return p.Timeout
+	}
+	return DefaultTimeout
+}
+
+// read queues incoming messages as responses or captured notifications.
+// It never blocks, so notifications are captured even while responses
+// are left unread.
+func (p *Harness) read() {
+	for {
+		_, b, err := p.conn.ReadMessage()
+		n, notification := parseNotification(b)
+		p.mu.Lock()
+		switch {
+		case err != nil:
+			p.err = err
+		case notification:
+			p.notifications = append(p.notifications, n)
+		default:
+			p.responses = append(p.responses, b)
+		}
+		close(p.received)
+		p.received = make(chan struct{})
+		p.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// parseNotification reports whether b is a notification and decodes it.
+func parseNotification(b []byte) (Notification, bool) {
+	v, err := fastjson.ParseBytes(b)
+	if err != nil || v.Type() != fastjson.TypeObject || !v.Exists("method") || v.Exists("id") {
+		return Notification{}, false
+	}
+	n := Notification{Method: string(v.GetStringBytes("method"))}
+	if params := v.Get("params"); params != nil {
+		n.Params = params.MarshalTo(nil)
+	}
+	return n, true
+}
+
+// Send writes request as is and returns the raw response, which is nil
+// when the server sends none. Over WebSocket it waits for a response; use
+// Notify for notifications.
+func (p *Harness) Send(t testing.TB, request []byte) []byte {
+	t.Helper()
+	b, _ := p.send(t, request, true)
+	return b
+}
+
+// send performs one exchange and returns the response and the HTTP status,
+// which is zero over WebSocket. A WebSocket exchange waits for a response
+// only if wait is set.
+func (p *Harness) send(t testing.TB, request []byte, wait bool) ([]byte, int) {
+	t.Helper()
+	if p.conn == nil {
+		req := fasthttp.AcquireRequest()
+		resp := fasthttp.AcquireResponse()
+		defer func() {
+			fasthttp.ReleaseRequest(req)
+			fasthttp.ReleaseResponse(resp)
+		}()
+		req.SetRequestURI("http://rpctest/")
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.Header.SetContentType("application/json")
+		for k, v := range p.Header {
+			req.Header.Set(k, v)
+		}
+		req.SetBody(request)
+		if err := p.http.DoTimeout(req, resp, p.timeout()); err != nil {
+			t.Fatalf("rpctest: %v", err)
+		}
+		if len(resp.Body()) == 0 {
+			return nil, resp.StatusCode()
+		}
+		return append([]byte(nil), resp.Body()...), resp.StatusCode()
+	}
+
+	p.exchange.Lock()
+	defer p.exchange.Unlock()
+	if err := p.conn.WriteMessage(websocket.TextMessage, request); err != nil {
+		t.Fatalf("rpctest: %v", err)
+	}
+	if !wait {
+		return nil, 0
+	}
+	return p.receive(t, request), 0
+}
+
+// receive returns the next response, failing t if none arrives in time.
+func (p *Harness) receive(t testing.TB, request []byte) []byte {
+	t.Helper()
+	timer := time.NewTimer(p.timeout())
+	defer timer.Stop()
+	for {
+		p.mu.Lock()
+		if len(p.responses) > 0 {
+			b := p.responses[0]
+			p.responses[0] = nil
+			p.responses = p.responses[1:]
+			p.mu.Unlock()
+			return b
+		}
+		ch, err := p.received, p.err
+		p.mu.Unlock()
+		if err != nil {
+			t.Fatalf("rpctest: connection closed: %v", err)
+		}
+
+		select {
+		case <-ch:
+		case <-timer.C:
+			t.Fatalf("rpctest: no response to %s within %s", request, p.timeout())
+		}
+	}
+}
+
+// Write sends request as is over the WebSocket connection without waiting
+// for its response, keeping several calls in flight; Read returns the
+// responses in the order they arrive. Do not call it while a Call is
+// pending.
+func (p *Harness) Write(t testing.TB, request []byte) {
+	t.Helper()
+	if p.conn == nil {
+		t.Fatalf("rpctest: Write needs a WebSocket harness")
+	}
+	p.exchange.Lock()
+	defer p.exchange.Unlock()
+	if err := p.conn.WriteMessage(websocket.TextMessage, request); err != nil {
+		t.Fatalf("rpctest: %v", err)
+	}
+}
+
+// Read returns the next response received by the WebSocket connection,
+// waiting for it if needed.
+func (p *Harness) Read(t testing.TB) []byte {
+	t.Helper()
+	if p.conn == nil {
+		t.Fatalf("rpctest: Read needs a WebSocket harness")
+	}
+	return p.receive(t, []byte("Write"))
+}
+
+func (p *Harness) nextID() json.RawMessage {
+	return strconv.AppendInt(nil, p.id.Add(1), 10)
+}
+
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  any             `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+func encode(t testing.TB, v any) []byte {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("rpctest: encode request: %v", err)
+	}
+	return b
+}
+
+// Call sends a request for method and returns its response. A nil params
+// is omitted.
+func (p *Harness) Call(t testing.TB, method string, params any) *Response {
+	t.Helper()
+	b, status := p.send(t, encode(t, message{JSONRPC: "2.0", Method: method, Params: params, ID: p.nextID()}), true)
+	return parseResponse(t, b, status)
+}
+
+// Notify sends a notification for method. Over HTTP it fails t unless the
+// server answers with an empty body.
+func (p *Harness) Notify(t testing.TB, method string, params any) {
+	t.Helper()
+	b, _ := p.send(t, encode(t, message{JSONRPC: "2.0", Method: method, Params: params}), false)
+	if len(b) > 0 {
+		t.Errorf("rpctest: notification %s answered with %s", method, b)
+	}
+}
+
+// Batch sends reqs as one batch. The server is expected to answer every
+// request that is not a notification.
+func (p *Harness) Batch(t testing.TB, reqs ...Request) *Batch {
+	t.Helper()
+	a := make([]message, len(reqs))
+	ids := make([]string, len(reqs))
+	wait := false
+	for i, r := range reqs {
+		a[i] = message{JSONRPC: "2.0", Method: r.Method, Params: r.Params}
+		if !r.Notify {
+			a[i].ID = p.nextID()
+			ids[i] = string(a[i].ID)
+			wait = true
+		}
+	}
+	b, status := p.send(t, encode(t, a), wait)
+	return parseBatch(t, b, status, ids)
+}
+
+// WaitClose discards the responses received by the WebSocket connection
+// until the server closes it and returns the read error, a
+// *websocket.CloseError when the server sent a close frame.
+func (p *Harness) WaitClose(t testing.TB) error {
+	t.Helper()
+	if p.conn == nil {
+		t.Fatalf("rpctest: WaitClose needs a WebSocket harness")
+	}
+	timer := time.NewTimer(p.timeout())
+	defer timer.Stop()
+	for {
+		p.mu.Lock()
+		p.responses = nil
+		ch, err := p.received, p.err
+		p.mu.Unlock()
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ch:
+		case <-timer.C:
+			t.Fatalf("rpctest: connection still open after %s", p.timeout())
+			return nil
+		}
+	}
+}
+
+// Notifications returns the notifications received so far.
+func (p *Harness) Notifications() []Notification {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Notification(nil), p.notifications...)
+}
+
+// WaitNotification returns the first notification for method received by
+// the WebSocket connection, waiting for it if needed.
+func (p *Harness) WaitNotification(t testing.TB, method string) Notification {
+	t.Helper()
+	timer := time.NewTimer(p.timeout())
+	defer timer.Stop()
+	for {
+		p.mu.Lock()
+		for _, n := range p.notifications {
+			if n.Method == method {
+				p.mu.Unlock()
+				return n
+			}
+		}
+		ch, err := p.received, p.err
+		p.mu.Unlock()
+		if err != nil || p.conn == nil {
+			if err == nil {
+				err = errors.New("not a WebSocket harness")
+			}
+			t.Fatalf("rpctest: no notification %s: %v", method, err)
+		}
+
+		select {
+		case <-ch:
+		case <-timer.C:
+			t.Fatalf("rpctest: no notification %s within %s", method, p.timeout())
+		}
+	}
+}
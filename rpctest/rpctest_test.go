@@ -0,0 +1,115 @@
+package rpctest_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/fasthttp/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fastjson"
+	"github.com/zc310/fastjsonrpc"
+	"github.com/zc310/fastjsonrpc/rpctest"
+	"github.com/zc310/fastjsonrpc/ws"
+)
+
+type Args struct {
+	A int `json:"a" validate:"min=0"`
+	B int `json:"b"`
+}
+
+func TestServerMap(t *testing.T) {
+	s := new(fastjsonrpc.ServerMap)
+	assert.NoError(t, s.RegisterFunc("add", func(a Args) int { return a.A + a.B }, fastjsonrpc.WithValidation()))
+	s.RegisterHandler("fail", func(c *fastjsonrpc.RequestCtx) { c.Error = fastjsonrpc.NewError(7, "failed") })
+	s.RegisterHandler("log", func(c *fastjsonrpc.RequestCtx) {})
+
+	h := rpctest.NewServerMap(t, s)
+	r := h.Call(t, "add", Args{A: 1, B: 2}).ExpectResult(3)
+	assert.Equal(t, fasthttp.StatusOK, r.Status)
+	var n int
+	r.Decode(&n)
+	assert.Equal(t, 3, n)
+
+	h.Call(t, "fail", nil).ExpectError(7)
+	h.Call(t, "add", Args{A: -1}).ExpectErrorData(-32602, []fastjsonrpc.FieldError{
+		{Field: "params.a", Rule: "min", Message: "must be at least 0"},
+	})
+	h.Call(t, "missing", nil).ExpectError(-32601).ExpectGolden("method_not_found")
+	h.Notify(t, "log", "hello")
+
+	b := h.Batch(t,
+		rpctest.Request{Method: "add", Params: Args{A: 1, B: 2}},
+		rpctest.Request{Method: "log", Notify: true},
+		rpctest.Request{Method: "fail"},
+	).ExpectLen(2)
+	b.Get(0).ExpectResult(3)
+	b.Get(2).ExpectError(7)
+	assert.Nil(t, b.Responses[1])
+
+	rpctest.Golden(t, "parse_error", h.Send(t, []byte(`{`)))
+}
+
+func TestJSONRPC2(t *testing.T) {
+	j := ws.NewJSONRPC2()
+	j.RegisterMethodFunc("double", func(params *fastjson.Value) (interface{}, error) {
+		return params.GetInt("a") * 2, nil
+	})
+
+	h := rpctest.NewJSONRPC2(t, j)
+	h.Call(t, "double", Args{A: 4}).ExpectResult(8)
+	h.Call(t, "missing", nil).ExpectError(-32601)
+	h.Notify(t, "double", Args{A: 1})
+	b := h.Batch(t,
+		rpctest.Request{Method: "double", Params: Args{A: 1}},
+		rpctest.Request{Method: "double", Params: Args{A: 2}},
+	).ExpectLen(2)
+	b.Get(0).ExpectResult(2)
+	b.Get(1).ExpectResult(4)
+	assert.Zero(t, b.Status)
+
+	h.Write(t, []byte(`{"jsonrpc":"2.0","method":"double","params":{"a":3},"id":"x"}`))
+	h.Write(t, []byte(`{"jsonrpc":"2.0","method":"double","params":{"a":3},"id":"x"}`))
+	for range 2 {
+		assert.JSONEq(t, `{"jsonrpc":"2.0","result":6,"id":"x"}`, string(h.Read(t)))
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	upgrader := &websocket.FastHTTPUpgrader{}
+	h := rpctest.NewWebSocket(t, func(ctx *fasthttp.RequestCtx) {
+		_ = upgrader.Upgrade(ctx, func(conn *websocket.Conn) {
+			for {
+				_, msg, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				v := fastjson.MustParseBytes(msg)
+				_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"progress","params":{"done":1}}`))
+				_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","result":"ok","id":`+v.Get("id").String()+`}`))
+			}
+		})
+	})
+
+	h.Call(t, "work", nil).ExpectResult("ok")
+	n := h.WaitNotification(t, "progress")
+	assert.JSONEq(t, `{"done":1}`, string(n.Params))
+	assert.Len(t, h.Notifications(), 1)
+}
+
+func TestUnreadResponses(t *testing.T) {
+	upgrader := &websocket.FastHTTPUpgrader{}
+	h := rpctest.NewWebSocket(t, func(ctx *fasthttp.RequestCtx) {
+		_ = upgrader.Upgrade(ctx, func(conn *websocket.Conn) {
+			for i := range 100 {
+				_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","result":"ok","id":`+strconv.Itoa(i)+`}`))
+			}
+			_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"done"}`))
+			_, _, _ = conn.ReadMessage()
+		})
+	})
+
+	// Responses left unread do not hold back notifications.
+	h.WaitNotification(t, "done")
+	assert.JSONEq(t, `{"jsonrpc":"2.0","result":"ok","id":0}`, string(h.Read(t)))
+}
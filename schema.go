@@ -0,0 +1,317 @@
+package fastjsonrpc
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/goccy/go-json"
+	"github.com/valyala/fastjson"
+)
+
+// Schema is a compiled subset of JSON Schema used to validate params
+// before a handler runs. Supported keywords: type, properties, required,
+// additionalProperties (boolean), items, enum, minimum, maximum,
+// minLength, maxLength, minItems, maxItems and pattern.
+type Schema struct {
+	Type                 schemaTypes        `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []json.RawMessage  `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+
+	pattern *regexp.Regexp
+	enum    [][]byte
+}
+
+// schemaTypes accepts both "type":"string" and "type":["string","null"].
+type schemaTypes []string
+
+func (p *schemaTypes) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		*p = schemaTypes{s}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(p))
+}
+
+func (p schemaTypes) MarshalJSON() ([]byte, error) {
+	if len(p) == 1 {
+		return json.Marshal(p[0])
+	}
+	return json.Marshal([]string(p))
+}
+
+// CompileSchema parses a JSON Schema document.
+func CompileSchema(b []byte) (*Schema, error) {
+	s := new(Schema)
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	if err := s.compile(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// MustCompileSchema is like CompileSchema but panics on error.
+func MustCompileSchema(b []byte) *Schema {
+	s, err := CompileSchema(b)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func (p *Schema) compile() (err error) {
+	if p.Pattern != "" {
+		if p.pattern, err = regexp.Compile(p.Pattern); err != nil {
+			return err
+		}
+	}
+	p.enum = p.enum[:0]
+	var pr fastjson.Parser
+	for _, e := range p.Enum {
+		v, err := pr.ParseBytes(e)
+		if err != nil {
+			return err
+		}
+		p.enum = append(p.enum, canonical(v))
+	}
+	for _, s := range p.Properties {
+		if err = s.compile(); err != nil {
+			return err
+		}
+	}
+	if p.Items != nil {
+		return p.Items.compile()
+	}
+	return nil
+}
+
+// Validate checks v, which may be nil for absent params, and returns an
+// Invalid params error listing every failed keyword, or nil.
+func (p *Schema) Validate(v *fastjson.Value) error {
+	var errs []FieldError
+	p.validate(v, paramsRoot, &errs)
+	if len(errs) > 0 {
+		return invalidParams(errs)
+	}
+	return nil
+}
+
+func (p *Schema) validate(v *fastjson.Value, path string, errs *[]FieldError) {
+	add := func(rule, msg string) { *errs = append(*errs, FieldError{Field: path, Rule: rule, Message: msg}) }
+
+	if len(p.Type) > 0 && !p.typeMatches(v) {
+		add("type", "must be "+joinTypes(p.Type))
+		return
+	}
+	if v == nil {
+		return
+	}
+	if len(p.enum) > 0 {
+		c := canonical(v)
+		found := false
+		for _, e := range p.enum {
+			if bytes.Equal(c, e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			add("enum", "must be one of the enumerated values")
+		}
+	}
+
+	switch v.Type() {
+	case fastjson.TypeNumber:
+		f := v.GetFloat64()
+		if p.Minimum != nil && f < *p.Minimum {
+			add("minimum", "must be at least "+formatFloat(*p.Minimum))
+		}
+		if p.Maximum != nil && f > *p.Maximum {
+			add("maximum", "must be at most "+formatFloat(*p.Maximum))
+		}
+	case fastjson.TypeString:
+		s := v.GetStringBytes()
+		n := len([]rune(string(s)))
+		if p.MinLength != nil && n < *p.MinLength {
+			add("minLength", "length must be at least "+strconv.Itoa(*p.MinLength))
+		}
+		if p.MaxLength != nil && n > *p.MaxLength {
+			add("maxLength", "length must be at most "+strconv.Itoa(*p.MaxLength))
+		}
+		if p.pattern != nil && !p.pattern.Match(s) {
+			add("pattern", "must match "+p.Pattern)
+		}
+	case fastjson.TypeArray:
+		a := v.GetArray()
+		if p.MinItems != nil && len(a) < *p.MinItems {
+			add("minItems", "must have at least "+strconv.Itoa(*p.MinItems)+" items")
+		}
+		if p.MaxItems != nil && len(a) > *p.MaxItems {
+			add("maxItems", "must have at most "+strconv.Itoa(*p.MaxItems)+" items")
+		}
+		if p.Items != nil {
+			for i, item := range a {
+				p.Items.validate(item, path+"["+strconv.Itoa(i)+"]", errs)
+			}
+		}
+	case fastjson.TypeObject:
+		o := v.GetObject()
+		for _, name := range p.Required {
+			if o.Get(name) == nil {
+				*errs = append(*errs, FieldError{Field: path + "." + name, Rule: "required", Message: "is required"})
+			}
+		}
+		o.Visit(func(key []byte, item *fastjson.Value) {
+			k := string(key)
+			if s, ok := p.Properties[k]; ok {
+				s.validate(item, path+"."+k, errs)
+			} else if p.AdditionalProperties != nil && !*p.AdditionalProperties {
+				*errs = append(*errs, FieldError{Field: path + "." + k, Rule: "additionalProperties", Message: "is not allowed"})
+			}
+		})
+	}
+}
+
+func (p *Schema) typeMatches(v *fastjson.Value) bool {
+	for _, t := range p.Type {
+		if v == nil {
+			if t == "null" {
+				return true
+			}
+			continue
+		}
+		switch t {
+		case "null":
+			if v.Type() == fastjson.TypeNull {
+				return true
+			}
+		case "boolean":
+			if v.Type() == fastjson.TypeTrue || v.Type() == fastjson.TypeFalse {
+				return true
+			}
+		case "number":
+			if v.Type() == fastjson.TypeNumber {
+				return true
+			}
+		case "integer":
+			if v.Type() == fastjson.TypeNumber && isIntegral(v) {
+				return true
+			}
+		case "string":
+			if v.Type() == fastjson.TypeString {
+				return true
+			}
+		case "array":
+			if v.Type() == fastjson.TypeArray {
+				return true
+			}
+		case "object":
+			if v.Type() == fastjson.TypeObject {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func joinTypes(a []string) string {
+	if len(a) == 1 {
+		return a[0]
+	}
+	return fmt.Sprint(a)
+}
+
+func formatFloat(f float64) string { return strconv.FormatFloat(f, 'g', -1, 64) }
+
+// canonical encodes v with object keys sorted, so that equal JSON values
+// have equal encodings.
+func canonical(v *fastjson.Value) []byte { return appendCanonical(nil, v) }
+
+func appendCanonical(dst []byte, v *fastjson.Value) []byte {
+	switch v.Type() {
+	case fastjson.TypeObject:
+		o := v.GetObject()
+		keys := make([]string, 0, o.Len())
+		o.Visit(func(key []byte, _ *fastjson.Value) { keys = append(keys, string(key)) })
+		sort.Strings(keys)
+		dst = append(dst, '{')
+		for i, k := range keys {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			q, _ := json.Marshal(k)
+			dst = append(dst, q...)
+			dst = append(dst, ':')
+			dst = appendCanonical(dst, o.Get(k))
+		}
+		return append(dst, '}')
+	case fastjson.TypeArray:
+		dst = append(dst, '[')
+		for i, item := range v.GetArray() {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendCanonical(dst, item)
+		}
+		return append(dst, ']')
+	case fastjson.TypeNumber:
+		// Integers are kept as written, so that large ones stay distinct.
+		if b := v.MarshalTo(dst); isInteger(b[len(dst):]) {
+			return b
+		}
+		return strconv.AppendFloat(dst, v.GetFloat64(), 'g', -1, 64)
+	default:
+		return v.MarshalTo(dst)
+	}
+}
+
+// isIntegral reports whether the number v has no fractional part, so
+// that 1.0 and integers beyond int64 are integers too.
+func isIntegral(v *fastjson.Value) bool {
+	if _, err := v.Int64(); err == nil {
+		return true
+	}
+	f := v.GetFloat64()
+	if math.IsInf(f, 0) {
+		return isInteger(v.MarshalTo(nil))
+	}
+	return f == math.Trunc(f)
+}
+
+func isInteger(b []byte) bool {
+	for i, c := range b {
+		if (c < '0' || c > '9') && !(i == 0 && c == '-') {
+			return false
+		}
+	}
+	return len(b) > 0
+}
+
+func schemaHandler(s *Schema, h Handler) Handler {
+	return func(c *RequestCtx) {
+		if err := s.Validate(c.Params); err != nil {
+			c.Error = err
+			return
+		}
+		h(c)
+	}
+}
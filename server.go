@@ -14,8 +14,12 @@ func (p *ServerMap) Handler(ctx *fasthttp.RequestCtx) {
 			_, _ = ctx.Write(errInternal)
 		}
 	}()
+	p.inFlight.Add(1)
+	defer p.inFlight.Add(-1)
+
 	c := getContext()
 	c.Ctx = ctx
+	c.ctx = p.context()
 
 	p.call(ctx, c)
 
@@ -23,8 +27,14 @@ func (p *ServerMap) Handler(ctx *fasthttp.RequestCtx) {
 	putContext(c)
 }
 func (p *ServerMap) call(ctx *fasthttp.RequestCtx, c *RequestCtx) {
+	body := ctx.PostBody()
+	if err := p.Limits.Check(body); err != nil {
+		writeLimitError(c.w, err)
+		return
+	}
+
 	var err error
-	if c.request, err = c.pr.ParseBytes(ctx.PostBody()); err != nil {
+	if c.request, err = c.pr.ParseBytes(body); err != nil {
 		_, _ = c.w.Write(errParse)
 		return
 	}
@@ -32,10 +42,14 @@ func (p *ServerMap) call(ctx *fasthttp.RequestCtx, c *RequestCtx) {
 	if c.request.Type() == fastjson.TypeArray {
 		var a []*fastjson.Value
 		a, _ = c.request.Array()
-		if len(a) > 32 || len(a) == 0 {
+		if len(a) == 0 {
 			_, _ = c.w.Write(errInvalidRequest)
 			return
 		}
+		if len(a) > p.Limits.maxBatchSize() {
+			writeLimitError(c.w, ErrBatchTooLarge)
+			return
+		}
 		p.batch(a, c)
 		return
 	}
@@ -51,13 +65,14 @@ func (p *ServerMap) call(ctx *fasthttp.RequestCtx, c *RequestCtx) {
 		return
 	}
 
-	f := p.getFun(string(c.Method))
+	f := p.getFun(c)
 	if f == nil {
 		c.Error = errMethodNotFound
 		c.writeError(c.w)
 		return
 	}
-	f(c)
+	f.warn(c)
+	f.handler(c)
 
 	if c.Error == nil {
 		c.writeResult(c.w)
@@ -68,28 +83,38 @@ func (p *ServerMap) call(ctx *fasthttp.RequestCtx, c *RequestCtx) {
 func (p *ServerMap) batch(a []*fastjson.Value, ctx *RequestCtx) {
 	bf := getBatchBuffer(len(a))
 
+	var sem chan struct{}
+	if p.BatchConcurrency > 0 && p.BatchConcurrency < len(a) {
+		sem = make(chan struct{}, p.BatchConcurrency)
+	}
+
 	for i, sc := range a {
 		ct := bf.Ct[i]
 		ct.Ctx = ctx.Ctx
+		ct.ctx = ctx.ctx
 
 		ct.setRequest(sc)
 		if ct.request.Type() != fastjson.TypeObject || len(ct.Method) == 0 {
 			_, _ = bf.B[i].Write(errInvalidRequest)
 			continue
 		}
-		f := p.getFun(string(ct.Method))
+		f := p.getFun(ct)
 		if f == nil {
 			ct.Error = errMethodNotFound
 			ct.writeError(bf.B[i])
 			continue
 		}
+		f.warn(ct)
 
 		bf.wg.Add(1)
+		if sem != nil {
+			sem <- struct{}{}
+		}
 
 		go func(index int) {
 			cc := bf.Ct[index]
 
-			f(cc)
+			f.handler(cc)
 
 			if cc.Error == nil {
 				cc.writeResult(bf.B[index])
@@ -97,6 +122,9 @@ func (p *ServerMap) batch(a []*fastjson.Value, ctx *RequestCtx) {
 				cc.writeError(bf.B[index])
 			}
 
+			if sem != nil {
+				<-sem
+			}
 			bf.wg.Done()
 		}(i)
 	}
@@ -0,0 +1,34 @@
+package fastjsonrpc
+
+import (
+	"context"
+)
+
+func (p *ServerMap) context() context.Context {
+	p.ctxOnce.Do(func() { p.ctx, p.cancel = context.WithCancel(context.Background()) })
+	return p.ctx
+}
+
+// Shutdown stops accepting new calls and waits for in-flight calls,
+// batches included, to finish, then closes Jobs and waits for the async
+// jobs. Calls arriving during shutdown fail with a "Server shutting down"
+// error. If ctx expires first, the contexts of the remaining handlers and
+// jobs are cancelled and ctx.Err() is returned.
+func (p *ServerMap) Shutdown(ctx context.Context) error {
+	p.context()
+	p.closing.Store(true)
+	defer p.cancel()
+
+	select {
+	case <-p.inFlight.Idle():
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if p.Jobs != nil {
+		return p.Jobs.Close(ctx)
+	}
+	return nil
+}
+
+// InFlight returns the number of HTTP requests currently being handled.
+func (p *ServerMap) InFlight() int64 { return p.inFlight.Load() }
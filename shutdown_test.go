@@ -0,0 +1,96 @@
+package fastjsonrpc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/pretty"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fastjson"
+	. "github.com/zc310/fastjsonrpc"
+	"github.com/zc310/fastjsonrpc/rpctest"
+	"github.com/zc310/fastjsonrpc/ws"
+)
+
+func TestShutdown(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	s := new(ServerMap)
+	s.RegisterHandler("slow", func(c *RequestCtx) {
+		close(started)
+		select {
+		case <-release:
+			c.Result = "done"
+		case <-c.Context().Done():
+			c.Error = c.Context().Err()
+		}
+	})
+	s.RegisterHandler("echo", func(c *RequestCtx) { c.Result = c.Params })
+
+	call := func(request string) string {
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+		ctx.Request.SetBodyString(request)
+		s.Handler(ctx)
+		return string(pretty.Ugly(ctx.Response.Body()))
+	}
+
+	result := make(chan string)
+	go func() { result <- call(`{"jsonrpc":"2.0","method":"slow","id":1}`) }()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	shutdown := make(chan error)
+	go func() { shutdown <- s.Shutdown(ctx) }()
+
+	assert.Eventually(t, func() bool {
+		return call(`{"jsonrpc":"2.0","method":"echo","id":2}`) ==
+			`{"jsonrpc":"2.0","error":{"code":-32002,"message":"Server shutting down"},"id":2}`
+	}, time.Second, time.Millisecond)
+
+	assert.Equal(t, context.DeadlineExceeded, <-shutdown)
+	assert.Equal(t, `{"jsonrpc":"2.0","error":{"code":-32000,"message":"context canceled"},"id":1}`, <-result)
+	assert.Equal(t, int64(0), s.InFlight())
+	close(release)
+}
+
+func TestShutdownWebSocket(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	j := ws.NewJSONRPC2(ws.WithExecution(ws.Sequential))
+	j.RegisterMethodFunc("slow", func(*fastjson.Value) (interface{}, error) {
+		started <- struct{}{}
+		<-release
+		return "done", nil
+	})
+
+	// The second call waits in the session's lane behind the first one.
+	h := rpctest.NewJSONRPC2(t, j)
+	h.Write(t, []byte(`{"jsonrpc":"2.0","method":"slow","id":1}`))
+	h.Write(t, []byte(`{"jsonrpc":"2.0","method":"slow","id":2}`))
+	<-started
+
+	shutdown := make(chan error)
+	go func() { shutdown <- j.Shutdown(context.Background()) }()
+	select {
+	case err := <-shutdown:
+		t.Fatalf("shutdown returned with calls in flight: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+
+	// Queued calls are answered before the connection is closed.
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":"done"}`, string(h.Read(t)))
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":2,"error":{"code":-32002,"message":"Server shutting down"}}`, string(h.Read(t)))
+	assert.True(t, websocket.IsCloseError(h.WaitClose(t), websocket.CloseGoingAway))
+	assert.NoError(t, <-shutdown)
+}
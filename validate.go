@@ -0,0 +1,291 @@
+package fastjsonrpc
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FieldError describes a single failed validation rule.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+const paramsRoot = "params"
+
+func invalidParams(errs []FieldError) *Error {
+	return &Error{Code: -32602, Message: "Invalid params", Data: errs}
+}
+
+// InvalidParams returns an Invalid params error listing errs, in the form
+// reported by Validate.
+func InvalidParams(errs ...FieldError) *Error { return invalidParams(errs) }
+
+// Validate checks v against its `validate` struct tags and returns an
+// Invalid params error listing every failed rule, or nil. Tags using
+// unknown rules make it fail with the error of CheckRules instead.
+//
+// Supported rules, separated by commas:
+//
+//	required   the value is not the zero value (nil for pointers)
+//	min=N      numbers >= N; strings, slices and maps have length >= N
+//	max=N      numbers <= N; strings, slices and maps have length <= N
+//	len=N      strings, slices and maps have length N
+//	oneof=a b  the value, formatted with %v, is one of the listed words
+//
+// Nested structs, pointers and slices of structs are validated
+// recursively. Field paths use the json tag names.
+func Validate(v any) error {
+	if v == nil || !hasRules(reflect.TypeOf(v)) {
+		return nil
+	}
+	if err := checkRules(reflect.TypeOf(v)); err != nil {
+		return err
+	}
+	var errs []FieldError
+	validateValue(reflect.ValueOf(v), paramsRoot, &errs)
+	if len(errs) > 0 {
+		return invalidParams(errs)
+	}
+	return nil
+}
+
+type fieldRule struct {
+	name  string
+	arg   string
+	value float64
+}
+
+type fieldPlan struct {
+	index int
+	name  string
+	rules []fieldRule
+}
+
+var (
+	planCache  sync.Map // map[reflect.Type][]fieldPlan
+	rulesCache sync.Map // map[reflect.Type]bool
+	checkCache sync.Map // map[reflect.Type]error
+)
+
+// CheckRules reports `validate` struct tags of the type of v, and of the
+// types it contains, that Validate does not support. Call it at startup
+// for params types decoded by handlers registered WithValidation.
+func CheckRules(v any) error {
+	if v == nil {
+		return nil
+	}
+	return checkRules(reflect.TypeOf(v))
+}
+
+func checkRules(t reflect.Type) error {
+	if v, ok := checkCache.Load(t); ok {
+		err, _ := v.(error)
+		return err
+	}
+	err := checkType(t, make(map[reflect.Type]bool))
+	checkCache.Store(t, err)
+	return err
+}
+
+func checkType(t reflect.Type, seen map[reflect.Type]bool) error {
+	if seen[t] {
+		return nil
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return checkType(t.Elem(), seen)
+	case reflect.Struct:
+		for _, f := range structPlan(t) {
+			for _, r := range f.rules {
+				if msg := ruleError(r); msg != "" {
+					return fmt.Errorf("rpc: %s.%s: %s", t.String(), t.Field(f.index).Name, msg)
+				}
+			}
+			if err := checkType(t.Field(f.index).Type, seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ruleError describes what is wrong with r, if anything.
+func ruleError(r fieldRule) string {
+	switch r.name {
+	case "required", "omitempty", "":
+		return ""
+	case "min", "max", "len":
+		if _, err := strconv.ParseFloat(r.arg, 64); err != nil {
+			return "rule " + r.name + " needs a number, got " + strconv.Quote(r.arg)
+		}
+		return ""
+	case "oneof":
+		return ""
+	}
+	return "unknown validate rule " + strconv.Quote(r.name)
+}
+
+// hasRules reports whether values of t may carry validate tags.
+func hasRules(t reflect.Type) bool {
+	if v, ok := rulesCache.Load(t); ok {
+		return v.(bool)
+	}
+	// Store a provisional answer first to terminate on recursive types.
+	rulesCache.Store(t, true)
+	r := false
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		r = hasRules(t.Elem())
+	case reflect.Interface:
+		r = true
+	case reflect.Struct:
+		for _, f := range structPlan(t) {
+			if len(f.rules) > 0 || hasRules(t.Field(f.index).Type) {
+				r = true
+				break
+			}
+		}
+	}
+	rulesCache.Store(t, r)
+	return r
+}
+
+func structPlan(t reflect.Type) []fieldPlan {
+	if v, ok := planCache.Load(t); ok {
+		return v.([]fieldPlan)
+	}
+	var plan []fieldPlan
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+			if n, _, _ := strings.Cut(tag, ","); n != "" {
+				name = n
+			}
+		}
+		p := fieldPlan{index: i, name: name}
+		if tag := f.Tag.Get("validate"); tag != "" {
+			for _, r := range strings.Split(tag, ",") {
+				rule, arg, _ := strings.Cut(strings.TrimSpace(r), "=")
+				value, _ := strconv.ParseFloat(arg, 64)
+				p.rules = append(p.rules, fieldRule{name: rule, arg: arg, value: value})
+			}
+		}
+		plan = append(plan, p)
+	}
+	planCache.Store(t, plan)
+	return plan
+}
+
+func validateValue(v reflect.Value, path string, errs *[]FieldError) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		for _, f := range structPlan(v.Type()) {
+			fv := v.Field(f.index)
+			fpath := path + "." + f.name
+			for _, r := range f.rules {
+				if msg := checkRule(fv, r); msg != "" {
+					*errs = append(*errs, FieldError{Field: fpath, Rule: r.name, Message: msg})
+				}
+			}
+			validateValue(fv, fpath, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), path+"["+strconv.Itoa(i)+"]", errs)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			validateValue(iter.Value(), path+"."+fmt.Sprint(iter.Key().Interface()), errs)
+		}
+	}
+}
+
+func checkRule(v reflect.Value, r fieldRule) string {
+	switch r.name {
+	case "required":
+		if v.IsZero() {
+			return "is required"
+		}
+		return ""
+	case "omitempty", "":
+		return ""
+	}
+
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	switch r.name {
+	case "min", "max", "len":
+		n, isLen, ok := measure(v)
+		if !ok {
+			return "rule " + r.name + " does not apply to " + v.Kind().String()
+		}
+		what := "must be"
+		if isLen {
+			what = "length must be"
+		}
+		switch {
+		case r.name == "min" && n < r.value:
+			return what + " at least " + r.arg
+		case r.name == "max" && n > r.value:
+			return what + " at most " + r.arg
+		case r.name == "len" && n != r.value:
+			return "length must be " + r.arg
+		}
+	case "oneof":
+		s := fmt.Sprint(v.Interface())
+		for _, w := range strings.Fields(r.arg) {
+			if s == w {
+				return ""
+			}
+		}
+		return "must be one of [" + r.arg + "]"
+	}
+	return ""
+}
+
+// measure returns the number checked by min, max and len rules.
+func measure(v reflect.Value) (n float64, isLen bool, ok bool) {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), true, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false, true
+	}
+	return 0, false, false
+}
+
+func validationHandler(h Handler) Handler {
+	return func(c *RequestCtx) {
+		c.validate = true
+		h(c)
+	}
+}
@@ -0,0 +1,136 @@
+package fastjsonrpc_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/pretty"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fastjson"
+	. "github.com/zc310/fastjsonrpc"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	type Item struct {
+		Name string `json:"name" validate:"required"`
+	}
+	type Args struct {
+		A     int    `json:"a" validate:"required,min=1"`
+		B     int    `json:"b" validate:"max=10"`
+		Op    string `json:"op" validate:"oneof=add sub"`
+		Items []Item `json:"items" validate:"max=2"`
+	}
+
+	s := new(ServerMap)
+	s.RegisterHandler("struct", func(c *RequestCtx) {
+		var a Args
+		if c.Error = c.ParamsUnmarshal(&a); c.Error == nil {
+			c.Result = a.A + a.B
+		}
+	}, WithValidation())
+	s.RegisterHandler("plain", func(c *RequestCtx) {
+		// Without WithValidation tags are ignored, including unknown rules.
+		var a struct {
+			Email string `json:"email" validate:"email"`
+		}
+		if c.Error = c.ParamsUnmarshal(&a); c.Error == nil {
+			c.Result = a.Email
+		}
+	})
+	s.RegisterHandler("schema", func(c *RequestCtx) {
+		c.Result = c.Params.GetFloat64("dividend") / c.Params.GetFloat64("divisor")
+	}, WithSchema(MustCompileSchema([]byte(`{
+		"type": "object",
+		"required": ["dividend", "divisor"],
+		"additionalProperties": false,
+		"properties": {
+			"dividend": {"type": "number"},
+			"divisor": {"type": "number", "minimum": 1}
+		}
+	}`))))
+
+	f := func(request, response string) {
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+		ctx.Request.SetBodyString(request)
+
+		s.Handler(ctx)
+
+		assert.Equal(t, string(pretty.Ugly([]byte(response))), string(pretty.Ugly(ctx.Response.Body())))
+	}
+
+	f(
+		`{"jsonrpc":"2.0","method":"struct","params":{"a":1,"b":2,"op":"add","items":[{"name":"x"}]},"id":1}`,
+		`{"jsonrpc":"2.0","result":3,"id":1}`,
+	)
+	f(
+		`{"jsonrpc":"2.0","method":"struct","params":{"b":20,"op":"mul","items":[{},{"name":"y"},{"name":"z"}]},"id":2}`,
+		`{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params","data":[
+			{"field":"params.a","rule":"required","message":"is required"},
+			{"field":"params.a","rule":"min","message":"must be at least 1"},
+			{"field":"params.b","rule":"max","message":"must be at most 10"},
+			{"field":"params.op","rule":"oneof","message":"must be one of [add sub]"},
+			{"field":"params.items","rule":"max","message":"length must be at most 2"},
+			{"field":"params.items[0].name","rule":"required","message":"is required"}
+		]},"id":2}`,
+	)
+	f(
+		`{"jsonrpc":"2.0","method":"plain","params":{"email":"x"},"id":6}`,
+		`{"jsonrpc":"2.0","result":"x","id":6}`,
+	)
+	f(
+		`{"jsonrpc":"2.0","method":"schema","params":{"dividend":9,"divisor":3},"id":3}`,
+		`{"jsonrpc":"2.0","result":3,"id":3}`,
+	)
+	f(
+		`{"jsonrpc":"2.0","method":"schema","params":{"dividend":"9","divisor":0,"x":1},"id":4}`,
+		`{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params","data":[
+			{"field":"params.dividend","rule":"type","message":"must be number"},
+			{"field":"params.divisor","rule":"minimum","message":"must be at least 1"},
+			{"field":"params.x","rule":"additionalProperties","message":"is not allowed"}
+		]},"id":4}`,
+	)
+	f(
+		`{"jsonrpc":"2.0","method":"schema","id":5}`,
+		`{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params","data":[
+			{"field":"params","rule":"type","message":"must be object"}
+		]},"id":5}`,
+	)
+}
+
+func TestCheckRules(t *testing.T) {
+	t.Parallel()
+
+	type Inner struct {
+		N int `validate:"gte=1"`
+	}
+	type Outer struct {
+		Items []Inner
+	}
+	assert.NoError(t, CheckRules(struct {
+		A int `validate:"required,min=1"`
+	}{}))
+	assert.EqualError(t, CheckRules(Outer{}), `rpc: fastjsonrpc_test.Inner.N: unknown validate rule "gte"`)
+	assert.EqualError(t, CheckRules(&struct {
+		A int `validate:"max=x"`
+	}{}), `rpc: struct { A int "validate:\"max=x\"" }.A: rule max needs a number, got "x"`)
+	assert.Equal(t, CheckRules(Outer{}), Validate(&Outer{}))
+}
+
+func TestSchemaInteger(t *testing.T) {
+	t.Parallel()
+
+	s := MustCompileSchema([]byte(`{"type": "integer", "enum": [1, 1e20, 18446744073709551616]}`))
+	for _, v := range []string{`1`, `1.0`, `1e20`, `18446744073709551616`} {
+		assert.NoError(t, s.Validate(fastjson.MustParse(v)), v)
+	}
+	for _, v := range []string{`1.5`, `"1"`, `2`} {
+		assert.Error(t, s.Validate(fastjson.MustParse(v)), v)
+	}
+
+	s = MustCompileSchema([]byte(`{"enum": [{"a\u007f\"b": 1, "c": 2}]}`))
+	assert.NoError(t, s.Validate(fastjson.MustParse(`{"c": 2, "a\u007f\"b": 1}`)))
+	assert.Error(t, s.Validate(fastjson.MustParse(`{"c": 2, "a\"b": 1}`)))
+}
@@ -0,0 +1,37 @@
+package fastjsonrpc
+
+import "strings"
+
+const versionSeparator = "@"
+
+// splitVersion splits "name@v2" into "name" and "v2".
+func splitVersion(name string) (string, string) {
+	if i := strings.LastIndex(name, versionSeparator); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return name, ""
+}
+
+func joinVersion(name, version string) string {
+	if version == "" {
+		return name
+	}
+	return name + versionSeparator + version
+}
+
+func deprecationWarning(name, message string) string {
+	w := `299 - "` + name + ` is deprecated`
+	if message != "" {
+		w += ": " + strings.ReplaceAll(message, `"`, `'`)
+	}
+	return w + `"`
+}
+
+// warn adds deprecation headers to the HTTP response of a deprecated call.
+func (p *rpcMethod) warn(c *RequestCtx) {
+	if !p.deprecated || c.Ctx == nil {
+		return
+	}
+	c.Ctx.Response.Header.Set("Deprecation", "true")
+	c.Ctx.Response.Header.Add("Warning", deprecationWarning(string(c.Method), p.deprecation))
+}
@@ -0,0 +1,103 @@
+package ws
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/valyala/fastjson"
+)
+
+// DefaultCancelMethod 默认的取消请求通知方法名
+const DefaultCancelMethod = "$/cancelRequest"
+
+// WithCancelMethod 设置取消请求的通知方法名，默认 DefaultCancelMethod，为空时禁用。
+// WebSocket 客户端发送 {"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":1}}
+// 取消该连接上 id 为 1 的进行中调用：调用的上下文被取消，并以 ErrRequestCancelled 响应
+func WithCancelMethod(name string) Option {
+	return func(j *JSONRPC2) { j.cancelMethod = name }
+}
+
+// isCancel 判断已解析的消息是否为取消请求通知
+func (j *JSONRPC2) isCancel(v *fastjson.Value) bool {
+	return j.cancelMethod != "" && v.Type() == fastjson.TypeObject && !v.Exists("id") &&
+		string(v.GetStringBytes("method")) == j.cancelMethod
+}
+
+// handleCancel 在读取循环中立即执行取消请求，不等待并发上限或执行通道，
+// 以便取消占满连接的调用；message 不是取消请求时返回 false
+func (j *JSONRPC2) handleCancel(ctx context.Context, message []byte) bool {
+	if j.cancelMethod == "" || !bytes.Contains(message, []byte(j.cancelMethod)) {
+		return false
+	}
+	parser := j.parserPool.Get()
+	defer j.parserPool.Put(parser)
+	v, err := parser.ParseBytes(message)
+	if err != nil || !j.isCancel(v) {
+		return false
+	}
+	_, _ = j.handleValue(ctx, v)
+	return true
+}
+
+// callSet 按 id 记录连接上进行中的调用
+type callSet struct {
+	mu    sync.Mutex
+	calls map[string]*inFlightCall
+}
+
+type inFlightCall struct {
+	cancel    context.CancelFunc
+	cancelled atomic.Bool
+}
+
+// add 记录 id 对应的调用，id 重复时以后到的调用为准
+func (s *callSet) add(id string, c *inFlightCall) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.calls == nil {
+		s.calls = make(map[string]*inFlightCall)
+	}
+	s.calls[id] = c
+}
+
+func (s *callSet) remove(id string, c *inFlightCall) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.calls[id] == c {
+		delete(s.calls, id)
+	}
+}
+
+// cancel 取消 id 对应的调用，调用不存在或已完成时忽略
+func (s *callSet) cancel(id *fastjson.Value) {
+	if id == nil {
+		return
+	}
+	s.mu.Lock()
+	c := s.calls[string(id.MarshalTo(nil))]
+	s.mu.Unlock()
+	if c != nil {
+		c.cancelled.Store(true)
+		c.cancel()
+	}
+}
+
+// cancellable 以可取消的上下文执行调用，被取消的调用以 ErrRequestCancelled 响应
+func (j *JSONRPC2) cancellable(ctx context.Context, calls *callSet, id *fastjson.Value, call func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	key := string(id.MarshalTo(nil))
+	c := new(inFlightCall)
+	ctx, c.cancel = context.WithCancel(ctx)
+	calls.add(key, c)
+	defer func() {
+		calls.remove(key, c)
+		c.cancel()
+	}()
+
+	response, err := call(ctx)
+	if c.cancelled.Load() {
+		return j.createErrorResponse(id, ErrRequestCancelled.Code, ErrRequestCancelled.Message, nil)
+	}
+	return response, err
+}
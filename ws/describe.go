@@ -0,0 +1,58 @@
+package ws
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/zc310/fastjsonrpc"
+)
+
+// WithSummary 设置方法的简要说明
+func WithSummary(summary string) MethodOption {
+	return func(o *methodOptions) { o.info.Summary = summary }
+}
+
+// WithParams 声明参数解码的类型，以该类型的值给出，例如 WithParams(Args{})
+func WithParams(v interface{}) MethodOption {
+	return func(o *methodOptions) { o.info.Params = reflect.TypeOf(v) }
+}
+
+// WithResult 声明结果的类型，以该类型的值给出
+func WithResult(v interface{}) MethodOption {
+	return func(o *methodOptions) { o.info.Result = reflect.TypeOf(v) }
+}
+
+// WithMethodTypes 声明服务中 Go 方法 goName 的参数与结果类型，均可为 nil
+func WithMethodTypes(goName string, params, result interface{}) MethodOption {
+	return func(o *methodOptions) {
+		if o.methodTypes == nil {
+			o.methodTypes = make(map[string][2]reflect.Type)
+		}
+		o.methodTypes[goName] = [2]reflect.Type{reflect.TypeOf(params), reflect.TypeOf(result)}
+	}
+}
+
+// withFunc 记录 RegisterFunc 绑定的函数
+func withFunc(f *fastjsonrpc.Func) MethodOption {
+	return func(o *methodOptions) {
+		o.info.Func = f
+		if o.info.Result == nil {
+			o.info.Result = f.Result()
+		}
+	}
+}
+
+// Describe 返回按名称排序的已注册方法说明，用于生成 OpenRPC 等文档
+func (j *JSONRPC2) Describe() []fastjsonrpc.MethodInfo {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	a := make([]fastjsonrpc.MethodInfo, 0, len(j.methods))
+	for name, m := range j.methods {
+		info := m.info
+		info.Name = name
+		a = append(a, info)
+	}
+	sort.Slice(a, func(i, j int) bool { return a[i].Name < a[j].Name })
+	return a
+}
@@ -21,11 +21,14 @@ func (e *RPCError) Error() string {
 
 // 预定义的错误类型
 var (
-	ErrParseError     = &RPCError{Code: -32700, Message: "Parse error"}
-	ErrInvalidRequest = &RPCError{Code: -32600, Message: "Invalid Request"}
-	ErrMethodNotFound = &RPCError{Code: -32601, Message: "Method not found"}
-	ErrInvalidParams  = &RPCError{Code: -32602, Message: "Invalid params"}
-	ErrInternalError  = &RPCError{Code: -32603, Message: "Internal error"}
+	ErrParseError       = &RPCError{Code: -32700, Message: "Parse error"}
+	ErrInvalidRequest   = &RPCError{Code: -32600, Message: "Invalid Request"}
+	ErrMethodNotFound   = &RPCError{Code: -32601, Message: "Method not found"}
+	ErrInvalidParams    = &RPCError{Code: -32602, Message: "Invalid params"}
+	ErrInternalError    = &RPCError{Code: -32603, Message: "Internal error"}
+	ErrServerBusy       = &RPCError{Code: -32001, Message: "Server busy"}
+	ErrShuttingDown     = &RPCError{Code: -32002, Message: "Server shutting down"}
+	ErrRequestCancelled = &RPCError{Code: -32800, Message: "Request cancelled"}
 )
 
 // NewRPCError 创建新的 RPC 错误
@@ -0,0 +1,179 @@
+package ws
+
+import (
+	"context"
+	"sync"
+
+	"github.com/valyala/fastjson"
+)
+
+// Execution WebSocket 连接上消息的执行方式
+type Execution struct {
+	sequential bool
+	key        func(params *fastjson.Value) string
+}
+
+var (
+	// Concurrent 每条消息在独立的协程中执行，响应与副作用的顺序不确定（默认）
+	Concurrent = Execution{}
+	// Sequential 按接收顺序逐条执行，前一条完成后才执行下一条
+	Sequential = Execution{sequential: true}
+)
+
+// KeyedSequential 键相同的调用按接收顺序逐条执行，键不同的调用并发执行，
+// 不同方法的调用只要键相同同样串行；key 返回空串的调用并发执行
+func KeyedSequential(key func(params *fastjson.Value) string) Execution {
+	return Execution{key: key}
+}
+
+// ParamKey 返回以 params 中 path 处的值为键的函数，用于 KeyedSequential，
+// 例如 ParamKey("account") 串行执行同一账户的调用；数组参数使用下标，例如 ParamKey("0")
+func ParamKey(path ...string) func(params *fastjson.Value) string {
+	return func(params *fastjson.Value) string {
+		v := params.Get(path...)
+		switch {
+		case v == nil:
+			return ""
+		case v.Type() == fastjson.TypeString:
+			return string(v.GetStringBytes())
+		default:
+			return string(v.MarshalTo(nil))
+		}
+	}
+}
+
+func (e Execution) concurrent() bool { return !e.sequential && e.key == nil }
+
+// WithExecution 设置 WebSocket 连接上消息的默认执行方式，方法可通过 WithMethodExecution 覆盖。
+// 批量请求在默认方式不是 Concurrent 时按 Sequential 执行；
+// 非并发执行的通知在调用方的执行顺序中同步完成，$/cancelRequest 始终立即执行
+func WithExecution(e Execution) Option {
+	return func(j *JSONRPC2) {
+		j.execution = e
+		if !e.concurrent() {
+			j.ordered.Store(true)
+		}
+	}
+}
+
+// WithMethodExecution 设置方法在 WebSocket 连接上的执行方式，覆盖 WithExecution
+func WithMethodExecution(e Execution) MethodOption {
+	return func(o *methodOptions) { o.execution = &e }
+}
+
+// Backpressure WebSocket 连接的写队列已满时的处理方式
+type Backpressure int
+
+const (
+	// BlockOnFull 等待写入器腾出空间，处理器随之阻塞；与 WithSessionConcurrency 一起使用时暂停读取新消息（默认）
+	BlockOnFull Backpressure = iota
+	// CloseOnFull 以 1008 关闭码关闭连接，避免慢客户端占用服务端资源
+	CloseOnFull
+)
+
+// WithWriteQueue 设置每个 WebSocket 连接等待写入的响应与通知数上限（默认 100）及队列满时的处理方式；
+// 该上限同时限制每个有序执行通道中等待执行的消息数，通道已满时 BlockOnFull 暂停读取新消息
+func WithWriteQueue(size int, b Backpressure) Option {
+	return func(j *JSONRPC2) { j.writeQueue, j.backpressure = size, b }
+}
+
+// sequentialLane Sequential 方式使用的执行通道，键控通道以 "#" 开头
+const sequentialLane = ""
+
+// laneOf 返回已解析消息的执行通道，ok 为 false 时并发执行
+func (j *JSONRPC2) laneOf(ctx context.Context, v *fastjson.Value) (lane string, ok bool) {
+	if v.Type() == fastjson.TypeArray {
+		return sequentialLane, !j.execution.concurrent()
+	}
+	if j.isCancel(v) {
+		return "", false
+	}
+
+	e := j.execution
+	if m := j.lookup(ctx, string(v.GetStringBytes("method"))); m != nil && m.execution != nil {
+		e = *m.execution
+	}
+	switch {
+	case e.key != nil:
+		if key := e.key(v.Get("params")); key != "" {
+			return "#" + key, true
+		}
+		return "", false
+	case e.sequential:
+		return sequentialLane, true
+	}
+	return "", false
+}
+
+type orderedKey struct{}
+
+// orderedContext 返回标记为有序执行的上下文
+func orderedContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, orderedKey{}, true)
+}
+
+func isOrdered(ctx context.Context) bool {
+	ordered, _ := ctx.Value(orderedKey{}).(bool)
+	return ordered
+}
+
+// lanes 连接上的执行通道，同一通道中的任务按加入顺序逐个执行
+type lanes struct {
+	// limit 每个通道中等待执行的任务数上限
+	limit int
+	// block 通道已满时 run 是否等待
+	block bool
+
+	mu sync.Mutex
+	m  map[string]*lane
+}
+
+type lane struct {
+	tasks   chan func()
+	pending int // 已加入但未执行完的任务数，受 lanes.mu 保护
+}
+
+// run 将 f 加入 name 通道，通道空闲时启动协程执行。通道已满时按 block 等待，
+// 或不加入并返回 false
+func (l *lanes) run(name string, f func()) bool {
+	l.mu.Lock()
+	if l.m == nil {
+		l.m = make(map[string]*lane)
+	}
+	q, ok := l.m[name]
+	if !ok {
+		q = &lane{tasks: make(chan func(), l.limit)}
+		l.m[name] = q
+		go l.drain(name, q)
+	}
+	q.pending++
+	l.mu.Unlock()
+
+	if l.block {
+		q.tasks <- f
+		return true
+	}
+	select {
+	case q.tasks <- f:
+		return true
+	default:
+		l.mu.Lock()
+		q.pending--
+		l.mu.Unlock()
+		return false
+	}
+}
+
+// drain 逐个执行通道中的任务，没有待执行任务时删除通道并退出
+func (l *lanes) drain(name string, q *lane) {
+	for f := range q.tasks {
+		f()
+		l.mu.Lock()
+		if q.pending--; q.pending == 0 {
+			delete(l.m, name)
+			l.mu.Unlock()
+			return
+		}
+		l.mu.Unlock()
+	}
+}
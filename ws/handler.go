@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/valyala/fasthttp"
+	"github.com/zc310/fastjsonrpc"
 )
 
 // isJSONContentType 检查 Content-Type 是否为 application/json
@@ -31,7 +32,8 @@ func isJSONContentType(contentType string) bool {
 	return false
 }
 
-// HTTPHandler 创建 HTTP POST JSON-RPC 处理器
+// HTTPHandler 创建 HTTP POST JSON-RPC 处理器；请求头 Accept 包含 text/event-stream 时以 SSE 响应，
+// 处理器发送的通知（如进度）作为事件先于响应写出
 func HTTPHandler(rpc *JSONRPC2) fasthttp.RequestHandler {
 	return func(ctx *fasthttp.RequestCtx) {
 		// 只处理 POST 请求
@@ -64,7 +66,16 @@ func HTTPHandler(rpc *JSONRPC2) fasthttp.RequestHandler {
 		ctx.Response.Header.Set("Access-Control-Allow-Headers", "Content-Type")
 
 		// 处理 JSON-RPC 请求
-		response, err := rpc.HandleMessage(body)
+		reqCtx := ContextWithIdempotencyKey(rpc.principalContext(rpc.versionContext(rpc.ctx, ctx), ctx), string(ctx.Request.Header.Peek(fastjsonrpc.IdempotencyHeader)))
+		// 接受 text/event-stream 的请求以 SSE 响应，调用期间的通知先于响应送达
+		if acceptsEventStream(ctx) {
+			rpc.serveEventStream(ctx, reqCtx, body)
+			return
+		}
+		if rpc.deprecated.Load() {
+			reqCtx = contextWithWarnings(reqCtx, &ctx.Response.Header)
+		}
+		response, err := rpc.HandleMessageContext(reqCtx, body)
 		if err != nil {
 			slog.Error(fmt.Sprintf("RPC handle error: %v", err))
 			// 创建错误响应
@@ -0,0 +1,123 @@
+package ws
+
+import (
+	"context"
+	"errors"
+
+	"github.com/goccy/go-json"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fastjson"
+	"github.com/zc310/fastjsonrpc"
+)
+
+type idempotencyKey struct{}
+
+// WithIdempotency 使用 p 抑制方法的重复执行：携带相同幂等键的重复调用返回首次调用记录的结果，
+// 并发的重复调用等待首次调用完成。幂等键来自 HTTP 请求头 Idempotency-Key 或参数中的
+// _meta.idempotencyKey，WebSocket 连接只使用后者
+func WithIdempotency(p *fastjsonrpc.Idempotency) MethodOption {
+	return func(o *methodOptions) { o.idempotency = p }
+}
+
+// ContextWithIdempotencyKey 返回携带幂等键的上下文，该键作用于请求中的所有调用
+func ContextWithIdempotencyKey(ctx context.Context, key string) context.Context {
+	if key == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+type principalKey struct{}
+
+// WithPrincipal 设置调用方身份，例如已认证的用户，幂等键按身份隔离，调用方无法取得他人记录的结果。
+// HTTP 请求按请求获取身份，WebSocket 连接使用握手请求
+func WithPrincipal(fn func(ctx *fasthttp.RequestCtx) string) Option {
+	return func(j *JSONRPC2) { j.principal = fn }
+}
+
+// ContextWithPrincipal 返回携带调用方身份的上下文
+func ContextWithPrincipal(ctx context.Context, principal string) context.Context {
+	if principal == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// principalContext 返回携带 req 调用方身份的上下文
+func (j *JSONRPC2) principalContext(ctx context.Context, req *fasthttp.RequestCtx) context.Context {
+	if j.principal == nil {
+		return ctx
+	}
+	return ContextWithPrincipal(ctx, j.principal(req))
+}
+
+// idempotencyStoreKey 返回按方法与调用方身份隔离的幂等键
+func idempotencyStoreKey(ctx context.Context, method, key string) string {
+	principal, _ := ctx.Value(principalKey{}).(string)
+	return fastjsonrpc.IdempotencyStoreKey(method, principal, key)
+}
+
+// idempotencyKeyOf 获取调用的幂等键，参数中的键优先
+func idempotencyKeyOf(ctx context.Context, params *fastjson.Value) string {
+	if key := fastjsonrpc.IdempotencyKey(params); key != "" {
+		return key
+	}
+	key, _ := ctx.Value(idempotencyKey{}).(string)
+	return key
+}
+
+// callIdempotent 按幂等键调用方法，重复调用返回记录的结果
+func (j *JSONRPC2) callIdempotent(ctx context.Context, method *methodEntry, key string, arena *fastjson.Arena, id, params *fastjson.Value) ([]byte, error) {
+	o, err := method.idempotency.Do(key, params, func() (fastjsonrpc.Outcome, bool) {
+		result, err := method.fn(ctx, arena, params)
+		if err != nil {
+			return j.errorOutcome(err)
+		}
+		b, err := j.marshalResult(result)
+		if err != nil {
+			return j.errorOutcome(err)
+		}
+		return fastjsonrpc.Outcome{Result: append([]byte(nil), b...)}, true
+	})
+	if err != nil {
+		var e *fastjsonrpc.Error
+		if errors.As(err, &e) {
+			return j.createErrorResponse(id, e.Code, e.Message, e.Data)
+		}
+		return nil, err
+	}
+	if o.Error == nil {
+		return j.createSuccessResponse(id, o.Result)
+	}
+
+	response := make([]byte, 0, 48+len(o.Error))
+	response = append(response, `{"jsonrpc":"2.0","id":`...)
+	response = id.MarshalTo(response)
+	response = append(response, `,"error":`...)
+	response = append(response, o.Error...)
+	return append(response, '}'), nil
+}
+
+// errorOutcome 编码错误对象；繁忙与停机错误不记录，以便重试时正常执行
+func (j *JSONRPC2) errorOutcome(err error) (fastjsonrpc.Outcome, bool) {
+	e := &RPCError{Code: -32000, Message: err.Error()}
+	var rpcErr *RPCError
+	var fe *fastjsonrpc.Error
+	switch {
+	case errors.As(err, &rpcErr):
+		e = rpcErr
+	case errors.As(err, &fe):
+		e = &RPCError{Code: fe.Code, Message: fe.Message, Data: fe.Data}
+	}
+
+	var data json.RawMessage
+	if e.Data != nil {
+		data, _ = j.marshalData(e.Data)
+	}
+	b, _ := json.Marshal(struct {
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data,omitempty"`
+	}{e.Code, e.Message, data})
+	return fastjsonrpc.Outcome{Error: b}, e.Code != ErrServerBusy.Code && e.Code != ErrShuttingDown.Code
+}
@@ -0,0 +1,128 @@
+package ws
+
+import (
+	"context"
+
+	"github.com/valyala/fastjson"
+	"github.com/zc310/fastjsonrpc"
+)
+
+// JobCompleted 异步任务完成时向发起调用的 WebSocket 连接发送的通知，参数为任务状态
+const JobCompleted = "job.completed"
+
+// WithJobs 设置运行 Async 方法的任务池，默认在首次使用时创建 NumCPU 个工作协程的任务池；
+// Shutdown 关闭任务池并等待其中的任务完成
+func WithJobs(p *fastjsonrpc.Jobs) Option {
+	return func(j *JSONRPC2) { j.jobPool = p }
+}
+
+// Async 以异步任务运行方法：参数校验通过后调用立即返回 {"jobId":"..."}，方法在任务池中执行，
+// 通过 job.status、job.result 与 job.cancel 查询与取消；
+// 经 WebSocket 发起的调用完成时收到 JobCompleted 通知
+func Async() MethodOption {
+	return func(o *methodOptions) { o.async = true }
+}
+
+// methodOptions 创建方法配置项，异步方法使用任务池
+func (j *JSONRPC2) methodOptions(opts []MethodOption) *methodOptions {
+	o := newMethodOptions(opts)
+	if o.async {
+		j.jobs()
+		o.rpc = j
+	}
+	if o.execution != nil && !o.execution.concurrent() {
+		j.ordered.Store(true)
+	}
+	if o.deprecated {
+		j.deprecated.Store(true)
+	}
+	return o
+}
+
+// jobs 返回任务池，首次使用时注册任务方法
+func (j *JSONRPC2) jobs() *fastjsonrpc.Jobs {
+	j.jobOnce.Do(func() {
+		if j.jobPool == nil {
+			j.jobPool = fastjsonrpc.NewJobs(0, nil)
+		}
+		p := j.jobPool
+		j.RegisterMethodFunc("job.status", func(params *fastjson.Value) (interface{}, error) {
+			return p.Status(fastjsonrpc.JobID(params))
+		})
+		j.RegisterMethodFunc("job.result", func(params *fastjson.Value) (interface{}, error) {
+			o, err := p.Result(fastjsonrpc.JobID(params))
+			if err != nil {
+				return nil, err
+			}
+			if o.Error == nil {
+				return o.Result, nil
+			}
+			v, err := fastjson.ParseBytes(o.Error)
+			if err != nil {
+				return nil, ErrInternalError
+			}
+			e := &RPCError{Code: v.GetInt("code"), Message: string(v.GetStringBytes("message"))}
+			if data := v.Get("data"); data != nil {
+				e.Data = data.MarshalTo(nil)
+			}
+			return nil, e
+		})
+		j.RegisterMethodFunc("job.cancel", func(params *fastjson.Value) (interface{}, error) {
+			return p.Cancel(fastjsonrpc.JobID(params))
+		})
+	})
+	return j.jobPool
+}
+
+// async 将方法包装为提交任务，任务不随连接断开或停机而取消，Shutdown 等待其完成
+func (j *JSONRPC2) async(name string, method RPCMethodContext) RPCMethodContext {
+	return func(ctx context.Context, _ *fastjson.Arena, params *fastjson.Value) (interface{}, error) {
+		// 参数所属的解析器在返回后会被复用，需复制一份
+		var raw []byte
+		if params != nil {
+			raw = params.MarshalTo(nil)
+		}
+		// 任务不随连接断开而取消，但仍可经连接发送进度与完成通知
+		parent := j.ctx
+		var done func(*fastjsonrpc.Job)
+		if s := sessionOf(ctx); s != nil {
+			parent = contextWithSession(parent, s)
+			done = func(job *fastjsonrpc.Job) { _ = s.notify(JobCompleted, job) }
+		}
+		if token := fastjsonrpc.ProgressToken(params); token != nil {
+			parent = fastjsonrpc.ContextWithProgressToken(parent, token)
+		}
+		id, err := j.jobPool.Submit(parent, name, func(ctx context.Context) fastjsonrpc.Outcome {
+			return j.runJob(ctx, method, raw)
+		}, done)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{"jobId": id}, nil
+	}
+}
+
+// runJob 在任务上下文中调用方法
+func (j *JSONRPC2) runJob(ctx context.Context, method RPCMethodContext, raw []byte) fastjsonrpc.Outcome {
+	arena := j.arenaPool.Get()
+	defer j.arenaPool.Put(arena)
+
+	var params *fastjson.Value
+	if raw != nil {
+		parser := j.parserPool.Get()
+		defer j.parserPool.Put(parser)
+		params, _ = parser.ParseBytes(raw)
+	}
+
+	result, err := method(ctx, arena, params)
+	if err != nil {
+		o, _ := j.errorOutcome(err)
+		return o
+	}
+	b, err := j.marshalResult(result)
+	if err != nil {
+		o, _ := j.errorOutcome(err)
+		return o
+	}
+	return fastjsonrpc.Outcome{Result: append([]byte(nil), b...)}
+}
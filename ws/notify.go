@@ -0,0 +1,60 @@
+package ws
+
+import (
+	"context"
+	"errors"
+
+	"github.com/goccy/go-json"
+	"github.com/zc310/fastjsonrpc"
+)
+
+// ErrNoSession 调用不在 WebSocket 连接、SSE 响应或 ServeStream 流上，无法推送通知
+var ErrNoSession = errors.New("ws: no WebSocket session")
+
+// ErrSessionClosed 连接或流已关闭
+var ErrSessionClosed = errors.New("ws: session closed")
+
+type sessionKey struct{}
+
+// session 调用所在的 WebSocket 连接、SSE 响应或流
+type session struct {
+	// send 将消息放入写队列，连接关闭时返回 false
+	send func(message []byte) bool
+	// calls 连接上可被取消的进行中调用
+	calls callSet
+}
+
+// contextWithSession 返回携带连接的上下文，fastjsonrpc.ReportProgress 经该连接发送进度通知
+func contextWithSession(ctx context.Context, s *session) context.Context {
+	ctx = context.WithValue(ctx, sessionKey{}, s)
+	return fastjsonrpc.ContextWithNotifier(ctx, s.notify)
+}
+
+func sessionOf(ctx context.Context) *session {
+	s, _ := ctx.Value(sessionKey{}).(*session)
+	return s
+}
+
+// Notify 向调用所在的 WebSocket 连接、SSE 响应或 ServeStream 流发送通知；不在其上时返回 ErrNoSession
+func Notify(ctx context.Context, method string, params interface{}) error {
+	s := sessionOf(ctx)
+	if s == nil {
+		return ErrNoSession
+	}
+	return s.notify(method, params)
+}
+
+func (s *session) notify(method string, params interface{}) error {
+	b, err := json.Marshal(struct {
+		JSONRPC string      `json:"jsonrpc"`
+		Method  string      `json:"method"`
+		Params  interface{} `json:"params,omitempty"`
+	}{"2.0", method, params})
+	if err != nil {
+		return err
+	}
+	if !s.send(b) {
+		return ErrSessionClosed
+	}
+	return nil
+}
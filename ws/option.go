@@ -0,0 +1,163 @@
+package ws
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/valyala/fastjson"
+	"github.com/zc310/fastjsonrpc"
+)
+
+// Option JSONRPC2 配置项
+type Option func(*JSONRPC2)
+
+// WithSessionConcurrency 限制每个 WebSocket 连接同时处理的消息数，
+// 达到上限时暂停读取新消息
+func WithSessionConcurrency(n int) Option {
+	return func(j *JSONRPC2) { j.sessionConcurrency = n }
+}
+
+// WithLimits 限制消息大小、嵌套深度、字符串与数组长度；
+// MaxBodySize 同时作为 WebSocket 连接的读取上限
+func WithLimits(l fastjsonrpc.Limits) Option {
+	return func(j *JSONRPC2) { j.limits = l }
+}
+
+// WithNaming 设置 RegisterObject 等使用的方法与类名命名策略，默认 snake_case
+func WithNaming(n fastjsonrpc.NamingStrategy) Option {
+	return func(j *JSONRPC2) { j.naming = n }
+}
+
+// WithSeparator 设置默认前缀中类名与方法名之间的分隔符，默认 "."
+func WithSeparator(sep string) Option {
+	return func(j *JSONRPC2) { j.separator = sep }
+}
+
+// TraceFunc 接收 WebSocket 连接上处理完成的每条消息，通知的 response 为 nil；
+// header 为握手请求中由 WithTrace 指定的请求头
+type TraceFunc func(header map[string]string, request, response []byte, start time.Time)
+
+// WithTrace 在 WebSocket 连接处理完每条消息后调用 fn，headers 指定需要传给 fn 的握手请求头
+func WithTrace(fn TraceFunc, headers ...string) Option {
+	return func(j *JSONRPC2) { j.trace, j.traceHeaders = fn, headers }
+}
+
+// MethodOption 方法注册配置项
+type MethodOption func(*methodOptions)
+
+type methodOptions struct {
+	limiter     *fastjsonrpc.Limiter
+	idempotency *fastjsonrpc.Idempotency
+	async       bool
+	rpc         *JSONRPC2
+	execution   *Execution
+	version     string
+	deprecated  bool
+	deprecation string
+	schema      *fastjsonrpc.Schema
+	paramNames  []string
+	validate    bool
+
+	aliases       []string
+	methodNames   map[string]string
+	methodAliases map[string][]string
+
+	info        fastjsonrpc.MethodInfo
+	methodTypes map[string][2]reflect.Type
+}
+
+func newMethodOptions(opts []MethodOption) *methodOptions {
+	o := new(methodOptions)
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithLimiter 使用指定的限流器保护方法，多个方法共享同一限流器即构成隔离舱
+func WithLimiter(l *fastjsonrpc.Limiter) MethodOption {
+	return func(o *methodOptions) { o.limiter = l }
+}
+
+// WithConcurrency 限制方法最多 n 个并发执行，等待超过 wait 后返回繁忙错误
+func WithConcurrency(n int, wait time.Duration) MethodOption {
+	return WithLimiter(fastjsonrpc.NewLimiter(n, wait))
+}
+
+// WithAlias 为方法注册额外的名称；用于 RegisterService 时为额外的前缀
+func WithAlias(names ...string) MethodOption {
+	return func(o *methodOptions) { o.aliases = append(o.aliases, names...) }
+}
+
+// WithMethodName 覆盖 Go 方法 goName 在服务中的名称（不含前缀）
+func WithMethodName(goName, name string) MethodOption {
+	return func(o *methodOptions) {
+		if o.methodNames == nil {
+			o.methodNames = make(map[string]string)
+		}
+		o.methodNames[goName] = name
+	}
+}
+
+// WithMethodAlias 为 Go 方法 goName 注册额外的完整方法名（不加前缀）
+func WithMethodAlias(goName string, names ...string) MethodOption {
+	return func(o *methodOptions) {
+		if o.methodAliases == nil {
+			o.methodAliases = make(map[string][]string)
+		}
+		o.methodAliases[goName] = append(o.methodAliases[goName], names...)
+	}
+}
+
+// WithParamNames 声明 RegisterFunc 注册的函数参数名，用于按名称绑定参数
+func WithParamNames(names ...string) MethodOption {
+	return func(o *methodOptions) { o.paramNames = names }
+}
+
+// WithValidation 使 RegisterFunc 注册的函数在绑定参数后检查其 validate 结构体标签，
+// 失败时返回 -32602；标签使用未知规则时 RegisterFunc 返回错误
+func WithValidation() MethodOption {
+	return func(o *methodOptions) { o.validate = true }
+}
+
+// WithSchema 在调用方法前使用 JSON Schema 校验参数，失败时返回 -32602
+func WithSchema(s *fastjsonrpc.Schema) MethodOption {
+	return func(o *methodOptions) { o.schema = s }
+}
+
+// wrap 按配置包装 RPC 方法；异步方法在任务中限流，提交任务前校验参数
+func (o *methodOptions) wrap(name string, method RPCMethodContext) RPCMethodContext {
+	if s := o.schema; s != nil && o.rpc == nil {
+		next := method
+		method = func(ctx context.Context, arena *fastjson.Arena, params *fastjson.Value) (interface{}, error) {
+			if err := s.Validate(params); err != nil {
+				return nil, err
+			}
+			return next(ctx, arena, params)
+		}
+	}
+	if l := o.limiter; l != nil {
+		next := method
+		method = func(ctx context.Context, arena *fastjson.Arena, params *fastjson.Value) (interface{}, error) {
+			if !l.Acquire() {
+				return nil, ErrServerBusy
+			}
+			defer l.Release()
+			return next(ctx, arena, params)
+		}
+	}
+	if o.rpc != nil {
+		method = o.rpc.async(name, method)
+		if s := o.schema; s != nil {
+			next := method
+			method = func(ctx context.Context, arena *fastjson.Arena, params *fastjson.Value) (interface{}, error) {
+				if err := s.Validate(params); err != nil {
+					return nil, err
+				}
+				return next(ctx, arena, params)
+			}
+		}
+	}
+	return method
+}
@@ -0,0 +1,422 @@
+package ws
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/valyala/fastjson"
+)
+
+const (
+	queueLogName      = "notifications.log"
+	deadLetterLogName = "dead-letter.log"
+
+	// 已确认记录超过该数量且多于未完成记录时压缩日志
+	queueCompactAcks = 1024
+)
+
+// NotificationQueue 通知的持久化队列：通知先追加到 Dir 下的预写日志再交给 Workers 个工作协程处理，
+// 方法返回错误或 panic 时按指数退避重试，MaxAttempts 次后写入死信日志。
+// 未完成的通知在重启后重放，因此方法可能被同一通知调用多次，需自行保证幂等
+type NotificationQueue struct {
+	// Dir 日志目录，不存在时创建
+	Dir string
+	// Workers 工作协程数，默认 NumCPU
+	Workers int
+	// Size 等待处理的通知数上限，默认 1024，队列满时暂停读取新消息
+	Size int
+	// MaxAttempts 每条通知的最大执行次数，默认 5；重启后重新计数
+	MaxAttempts int
+	// Backoff 首次重试的等待时间，默认 100ms，此后每次翻倍
+	Backoff time.Duration
+	// MaxBackoff 重试等待时间上限，默认 30s
+	MaxBackoff time.Duration
+	// NoSync 写入通知后不调用 fsync，进程崩溃不丢失通知，但断电可能丢失
+	NoSync bool
+	// OnDeadLetter 通知写入死信日志后调用
+	OnDeadLetter func(DeadLetter)
+
+	mu      sync.Mutex
+	log     *os.File
+	dead    *os.File
+	seq     uint64
+	pending map[uint64]*queuedNotification
+	acks    int
+
+	ch      chan *queuedNotification
+	base    context.Context
+	ctx     context.Context
+	stop    context.CancelFunc
+	handle  func(ctx context.Context, method string, params []byte) error
+	workers sync.WaitGroup
+}
+
+// DeadLetter 超过最大执行次数的通知
+type DeadLetter struct {
+	Method   string          `json:"method"`
+	Params   json.RawMessage `json:"params,omitempty"`
+	Error    string          `json:"error"`
+	Attempts int             `json:"attempts"`
+	Time     time.Time       `json:"time"`
+}
+
+// queueRecord 日志记录，Done 为 true 时确认 Seq 对应的通知
+type queueRecord struct {
+	Seq    uint64          `json:"seq"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Done   bool            `json:"done,omitempty"`
+}
+
+type queuedNotification struct {
+	queueRecord
+	attempts int
+}
+
+// WithNotificationQueue 使用持久化队列处理通知，代替默认的即发即弃协程。
+// 队列在 StartNotificationQueue 或首条通知时打开并重放未完成的通知；
+// 停机开始后不再执行新的通知，剩余通知在重启后重放。
+// 非并发执行（见 WithExecution）的通知不进入队列，在调用方的执行顺序中同步完成
+func WithNotificationQueue(q *NotificationQueue) Option {
+	return func(j *JSONRPC2) { j.queue = q }
+}
+
+// StartNotificationQueue 打开通知队列并重放上次运行未完成的通知，应在注册全部方法后调用；
+// 未配置队列时返回 nil。打开或写入失败时通知退回即发即弃处理，并记录错误日志
+func (j *JSONRPC2) StartNotificationQueue() error {
+	if j.queue == nil {
+		return nil
+	}
+	j.queueOnce.Do(func() {
+		j.queueErr = j.queue.start(j.ctx, j.processNotification)
+	})
+	return j.queueErr
+}
+
+// stopNotificationQueue 停止执行队列中的通知
+func (j *JSONRPC2) stopNotificationQueue() {
+	j.queueOnce.Do(func() { j.queueErr = errors.New("ws: notification queue stopped") })
+	if j.queueErr == nil {
+		j.queue.stop()
+	}
+}
+
+// processNotification 执行队列中的通知
+func (j *JSONRPC2) processNotification(ctx context.Context, name string, raw []byte) error {
+	method := j.lookup(ctx, name)
+	if method == nil {
+		return ErrMethodNotFound
+	}
+
+	arena := j.arenaPool.Get()
+	defer j.arenaPool.Put(arena)
+
+	var params *fastjson.Value
+	if raw != nil {
+		parser := j.parserPool.Get()
+		defer j.parserPool.Put(parser)
+		var err error
+		if params, err = parser.ParseBytes(raw); err != nil {
+			return err
+		}
+	}
+
+	j.inFlight.Add(1)
+	defer j.inFlight.Add(-1)
+	_, err := method.fn(ctx, arena, params)
+	return err
+}
+
+// Pending 获取未完成的通知数，包括等待重试的通知
+func (q *NotificationQueue) Pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// DeadLetters 读取死信日志
+func (q *NotificationQueue) DeadLetters() ([]DeadLetter, error) {
+	b, err := os.ReadFile(filepath.Join(q.Dir, deadLetterLogName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var letters []DeadLetter
+	for _, line := range bytes.Split(b, []byte{'\n'}) {
+		var d DeadLetter
+		if len(line) > 0 && json.Unmarshal(line, &d) == nil {
+			letters = append(letters, d)
+		}
+	}
+	return letters, nil
+}
+
+// start 打开日志，重放未完成的通知并启动工作协程，ctx 取消或调用 stop 时停止
+func (q *NotificationQueue) start(ctx context.Context, handle func(ctx context.Context, method string, params []byte) error) error {
+	if err := os.MkdirAll(q.Dir, 0o755); err != nil {
+		return err
+	}
+	records, err := readQueueLog(filepath.Join(q.Dir, queueLogName))
+	if err != nil {
+		return err
+	}
+	q.pending = make(map[uint64]*queuedNotification, len(records))
+	replay := make([]*queuedNotification, 0, len(records))
+	for _, r := range records {
+		n := &queuedNotification{queueRecord: r}
+		q.pending[r.Seq] = n
+		replay = append(replay, n)
+		q.seq = max(q.seq, r.Seq)
+	}
+	// 重写日志以丢弃已确认的记录与中断写入的末行
+	if err = q.compact(); err != nil {
+		return err
+	}
+	if q.dead, err = os.OpenFile(filepath.Join(q.Dir, deadLetterLogName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+		_ = q.log.Close()
+		return err
+	}
+
+	size, workers := q.Size, q.Workers
+	if size <= 0 {
+		size = 1024
+	}
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	q.ch = make(chan *queuedNotification, size)
+	q.base, q.handle = ctx, handle
+	q.ctx, q.stop = context.WithCancel(ctx)
+	for i := 0; i < workers; i++ {
+		q.workers.Add(1)
+		go q.work()
+	}
+	go func() {
+		for _, n := range replay {
+			q.enqueue(n)
+		}
+	}()
+	go func() {
+		<-q.ctx.Done()
+		q.workers.Wait()
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		_ = q.log.Close()
+		_ = q.dead.Close()
+	}()
+	return nil
+}
+
+// push 将通知写入日志并放入队列，队列满时阻塞
+func (q *NotificationQueue) push(method string, params []byte) error {
+	q.mu.Lock()
+	q.seq++
+	n := &queuedNotification{queueRecord: queueRecord{Seq: q.seq, Method: method, Params: params}}
+	if err := q.append(n.queueRecord, !q.NoSync); err != nil {
+		q.mu.Unlock()
+		return err
+	}
+	q.pending[n.Seq] = n
+	q.mu.Unlock()
+
+	q.enqueue(n)
+	return nil
+}
+
+// enqueue 放入队列，停止时放弃，通知保留在日志中
+func (q *NotificationQueue) enqueue(n *queuedNotification) {
+	select {
+	case q.ch <- n:
+	case <-q.ctx.Done():
+	}
+}
+
+func (q *NotificationQueue) work() {
+	defer q.workers.Done()
+	for {
+		select {
+		case <-q.ctx.Done():
+			return
+		case n := <-q.ch:
+			q.process(n)
+		}
+	}
+}
+
+// process 执行通知，失败时安排重试或写入死信日志
+func (q *NotificationQueue) process(n *queuedNotification) {
+	n.attempts++
+	err := q.call(n)
+	if err == nil {
+		q.ack(n, nil)
+		return
+	}
+	if q.ctx.Err() != nil {
+		// 停止期间失败的通知在重启后重放
+		return
+	}
+
+	maxAttempts := q.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 5
+	}
+	if n.attempts >= maxAttempts {
+		q.ack(n, &DeadLetter{Method: n.Method, Params: n.Params, Error: err.Error(), Attempts: n.attempts, Time: time.Now()})
+		return
+	}
+	time.AfterFunc(q.backoff(n.attempts), func() { q.enqueue(n) })
+}
+
+func (q *NotificationQueue) call(n *queuedNotification) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return q.handle(q.base, n.Method, n.Params)
+}
+
+// backoff 返回第 attempts 次失败后的等待时间
+func (q *NotificationQueue) backoff(attempts int) time.Duration {
+	d, limit := q.Backoff, q.MaxBackoff
+	if d <= 0 {
+		d = 100 * time.Millisecond
+	}
+	if limit <= 0 {
+		limit = 30 * time.Second
+	}
+	for i := 1; i < attempts && d < limit; i++ {
+		d *= 2
+	}
+	return min(d, limit)
+}
+
+// ack 确认通知，dead 不为 nil 时先写入死信日志
+func (q *NotificationQueue) ack(n *queuedNotification, dead *DeadLetter) {
+	if dead != nil {
+		q.mu.Lock()
+		b, _ := json.Marshal(dead)
+		_, err := q.dead.Write(append(b, '\n'))
+		q.mu.Unlock()
+		if err != nil {
+			// 保留在日志中，重启后重放
+			return
+		}
+		if q.OnDeadLetter != nil {
+			q.OnDeadLetter(*dead)
+		}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.pending, n.Seq)
+	if q.append(queueRecord{Seq: n.Seq, Done: true}, false) != nil {
+		return
+	}
+	q.acks++
+	if q.acks >= queueCompactAcks && q.acks > len(q.pending) {
+		_ = q.compact()
+	}
+}
+
+// append 追加一条日志记录，须持有 mu
+func (q *NotificationQueue) append(r queueRecord, sync bool) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err = q.log.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	if sync {
+		return q.log.Sync()
+	}
+	return nil
+}
+
+// compact 将未完成的通知写入新日志并替换旧日志，须持有 mu 或在启动前调用
+func (q *NotificationQueue) compact() error {
+	name := filepath.Join(q.Dir, queueLogName)
+	f, err := os.CreateTemp(q.Dir, queueLogName+".*")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	// 按序号写入以保持重放顺序
+	for _, seq := range slices.Sorted(maps.Keys(q.pending)) {
+		b, _ := json.Marshal(q.pending[seq].queueRecord)
+		_, _ = w.Write(append(b, '\n'))
+	}
+	if err = w.Flush(); err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if q.log != nil {
+		_ = q.log.Close()
+	}
+	q.log, q.acks = f, 0
+	return nil
+}
+
+// readQueueLog 读取日志中未确认的通知，按序号排列；忽略中断写入的末行
+func readQueueLog(name string) ([]queueRecord, error) {
+	f, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []queueRecord
+	done := make(map[uint64]bool)
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var rec queueRecord
+		if json.Unmarshal(line, &rec) != nil {
+			continue
+		}
+		if rec.Done {
+			done[rec.Seq] = true
+		} else {
+			records = append(records, rec)
+		}
+	}
+	pending := records[:0]
+	for _, rec := range records {
+		if !done[rec.Seq] {
+			pending = append(pending, rec)
+		}
+	}
+	return pending, nil
+}
@@ -1,58 +1,174 @@
 package ws
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"unicode"
 	"unicode/utf8"
 
 	"github.com/goccy/go-json"
-	"github.com/iancoleman/strcase"
 	"github.com/valyala/bytebufferpool"
+	"github.com/valyala/fasthttp"
 	"github.com/valyala/fastjson"
+	"github.com/zc310/fastjsonrpc"
+	"github.com/zc310/fastjsonrpc/internal/inflight"
 )
 
 // RPCMethod RPC 方法类型
 type RPCMethod func(arena *fastjson.Arena, params *fastjson.Value) (interface{}, error)
 
+// RPCMethodContext 带上下文的 RPC 方法类型，上下文在连接断开或停机超时时取消
+type RPCMethodContext func(ctx context.Context, arena *fastjson.Arena, params *fastjson.Value) (interface{}, error)
+
 // JSONRPC2 JSON-RPC 2.0 处理器
 type JSONRPC2 struct {
-	methods    map[string]RPCMethod
+	methods    map[string]*methodEntry
+	limiters   map[string]*fastjsonrpc.Limiter
+	services   map[string][]string // 服务前缀 -> 以该前缀注册的方法名
 	mu         sync.RWMutex
 	parserPool fastjson.ParserPool
 	arenaPool  fastjson.ArenaPool
+
+	sessionConcurrency int
+	limits             fastjsonrpc.Limits
+	versionHeader      string
+	principal          func(ctx *fasthttp.RequestCtx) string
+	naming             fastjsonrpc.NamingStrategy
+	separator          string
+	trace              TraceFunc
+	traceHeaders       []string
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	closing  atomic.Bool
+	inFlight inflight.Counter
+	// messages WebSocket 连接与 ServeStream 流上已读取、尚未处理完的消息数，包括在执行通道中排队的消息
+	messages inflight.Counter
+	sessions sessionSet
+
+	jobPool *fastjsonrpc.Jobs
+	jobOnce sync.Once
+
+	cancelMethod string
+	execution    Execution
+	ordered      atomic.Bool
+	deprecated   atomic.Bool
+	writeQueue   int
+	backpressure Backpressure
+
+	queue     *NotificationQueue
+	queueOnce sync.Once
+	queueErr  error
 }
 
 // NewJSONRPC2 创建新的 JSON-RPC 2.0 实例
-func NewJSONRPC2() *JSONRPC2 {
-	return &JSONRPC2{
-		methods: make(map[string]RPCMethod),
-	}
+func NewJSONRPC2(opts ...Option) *JSONRPC2 {
+	j := &JSONRPC2{
+		methods:      make(map[string]*methodEntry),
+		limiters:     make(map[string]*fastjsonrpc.Limiter),
+		naming:       fastjsonrpc.SnakeCase,
+		separator:    ".",
+		cancelMethod: DefaultCancelMethod,
+	}
+	j.ctx, j.cancel = context.WithCancel(context.Background())
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j
 }
 
 // RegisterMethod 注册 RPC 方法
-func (j *JSONRPC2) RegisterMethod(name string, method RPCMethod) {
+func (j *JSONRPC2) RegisterMethod(name string, method RPCMethod, opts ...MethodOption) {
+	j.RegisterMethodContext(name, func(_ context.Context, arena *fastjson.Arena, params *fastjson.Value) (interface{}, error) {
+		return method(arena, params)
+	}, opts...)
+}
+
+// RegisterMethodContext 注册带上下文的 RPC 方法
+func (j *JSONRPC2) RegisterMethodContext(name string, method RPCMethodContext, opts ...MethodOption) {
+	o := j.methodOptions(opts)
+
+	name = o.versioned(name)
+	entry := o.entry(name, method)
+
 	j.mu.Lock()
 	defer j.mu.Unlock()
-	j.methods[name] = method
+	j.methods[name] = entry
+	for _, alias := range o.aliases {
+		j.methods[o.versioned(alias)] = entry
+	}
+	if o.limiter != nil {
+		j.limiters[name] = o.limiter
+	} else {
+		delete(j.limiters, name)
+	}
+}
+
+// RegisterFunc 注册普通 Go 函数，例如 func(ctx context.Context, a int, b string) (int, error)。
+// 数组参数按位置绑定，对象参数按 WithParamNames 声明的名称绑定，末尾的指针参数可省略，
+// 参数不匹配时返回 -32602
+func (j *JSONRPC2) RegisterFunc(name string, fn interface{}, opts ...MethodOption) error {
+	o := newMethodOptions(opts)
+	f, err := fastjsonrpc.NewFunc(fn, o.paramNames...)
+	if err != nil {
+		return err
+	}
+	if o.validate {
+		if err := f.EnableValidation(); err != nil {
+			return err
+		}
+	}
+	j.RegisterMethodContext(name, func(ctx context.Context, _ *fastjson.Arena, params *fastjson.Value) (interface{}, error) {
+		return f.Call(ctx, params)
+	}, append(opts[:len(opts):len(opts)], withFunc(f))...)
+	return nil
 }
 
 // RegisterMethodFunc 注册 RPC 方法（函数适配器）
-func (j *JSONRPC2) RegisterMethodFunc(name string, method func(params *fastjson.Value) (interface{}, error)) {
-	j.mu.Lock()
-	defer j.mu.Unlock()
-	j.methods[name] = func(arena *fastjson.Arena, params *fastjson.Value) (interface{}, error) {
+func (j *JSONRPC2) RegisterMethodFunc(name string, method func(params *fastjson.Value) (interface{}, error), opts ...MethodOption) {
+	j.RegisterMethod(name, func(arena *fastjson.Arena, params *fastjson.Value) (interface{}, error) {
 		return method(params)
+	}, opts...)
+}
+
+// Limiters 获取所有限流器的监控指标，键为方法名或前缀
+func (j *JSONRPC2) Limiters() map[string]fastjsonrpc.LimiterStats {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	m := make(map[string]fastjsonrpc.LimiterStats, len(j.limiters))
+	for name, l := range j.limiters {
+		m[name] = l.Stats()
 	}
+	return m
 }
 
 // HandleMessage 处理 JSON-RPC 消息
 func (j *JSONRPC2) HandleMessage(message []byte) ([]byte, error) {
+	return j.HandleMessageContext(j.ctx, message)
+}
+
+// HandleMessageContext 使用指定上下文处理 JSON-RPC 消息
+func (j *JSONRPC2) HandleMessageContext(ctx context.Context, message []byte) ([]byte, error) {
+	if err := j.limits.Check(message); err != nil {
+		return j.createLimitError(err)
+	}
+	return j.handleMessage(ctx, message)
+}
+
+// handleMessage 处理已通过限制检查的 JSON-RPC 消息
+func (j *JSONRPC2) handleMessage(ctx context.Context, message []byte) ([]byte, error) {
+	j.inFlight.Add(1)
+	defer j.inFlight.Add(-1)
+
 	// 从池中获取解析器和 arena
 	parser := j.parserPool.Get()
 	defer j.parserPool.Put(parser)
@@ -65,23 +181,34 @@ func (j *JSONRPC2) HandleMessage(message []byte) ([]byte, error) {
 		return j.createParseError()
 	}
 
-	return j.handleParsedValue(arena, value)
+	return j.handleParsedValue(ctx, arena, value)
+}
+
+// handleValue 处理读取循环已解析的消息
+func (j *JSONRPC2) handleValue(ctx context.Context, value *fastjson.Value) ([]byte, error) {
+	j.inFlight.Add(1)
+	defer j.inFlight.Add(-1)
+
+	arena := j.arenaPool.Get()
+	defer j.arenaPool.Put(arena)
+
+	return j.handleParsedValue(ctx, arena, value)
 }
 
 // handleParsedValue 处理已解析的 JSON 值
-func (j *JSONRPC2) handleParsedValue(arena *fastjson.Arena, value *fastjson.Value) ([]byte, error) {
+func (j *JSONRPC2) handleParsedValue(ctx context.Context, arena *fastjson.Arena, value *fastjson.Value) ([]byte, error) {
 	switch value.Type() {
 	case fastjson.TypeArray:
-		return j.handleBatchRequest(value)
+		return j.handleBatchRequest(ctx, value)
 	case fastjson.TypeObject:
-		return j.handleSingleRequest(arena, value)
+		return j.handleSingleRequest(ctx, arena, value)
 	default:
 		return j.createErrorResponse(nil, -32600, "Invalid Request", "Request must be object or array")
 	}
 }
 
 // handleBatchRequest 处理批量请求
-func (j *JSONRPC2) handleBatchRequest(batchValue *fastjson.Value) ([]byte, error) {
+func (j *JSONRPC2) handleBatchRequest(ctx context.Context, batchValue *fastjson.Value) ([]byte, error) {
 	array, err := batchValue.Array()
 	if err != nil {
 		return j.createInvalidRequestError()
@@ -91,6 +218,10 @@ func (j *JSONRPC2) handleBatchRequest(batchValue *fastjson.Value) ([]byte, error
 		return j.createInvalidRequestError()
 	}
 
+	if j.limits.MaxBatchSize > 0 && len(array) > j.limits.MaxBatchSize {
+		return j.createLimitError(fastjsonrpc.ErrBatchTooLarge)
+	}
+
 	// 为每个请求创建新的 arena 来处理响应
 	responses := make([][]byte, 0, len(array))
 
@@ -103,7 +234,7 @@ func (j *JSONRPC2) handleBatchRequest(batchValue *fastjson.Value) ([]byte, error
 		}
 
 		itemArena := j.arenaPool.Get()
-		response, err := j.handleSingleRequest(itemArena, item)
+		response, err := j.handleSingleRequest(ctx, itemArena, item)
 		j.arenaPool.Put(itemArena)
 
 		if err != nil {
@@ -165,7 +296,7 @@ func (j *JSONRPC2) createInternalError(id *fastjson.Value, data interface{}) ([]
 }
 
 // handleSingleRequest 处理单个请求
-func (j *JSONRPC2) handleSingleRequest(arena *fastjson.Arena, value *fastjson.Value) ([]byte, error) {
+func (j *JSONRPC2) handleSingleRequest(ctx context.Context, arena *fastjson.Arena, value *fastjson.Value) ([]byte, error) {
 	// 验证 JSON-RPC 版本
 	jsonrpcVal := value.Get("jsonrpc")
 	if jsonrpcVal == nil || jsonrpcVal.Type() != fastjson.TypeString {
@@ -189,28 +320,81 @@ func (j *JSONRPC2) handleSingleRequest(arena *fastjson.Arena, value *fastjson.Va
 		id = value.Get("id")
 	}
 
-	// 处理通知（没有 ID 的请求）
-	if id == nil {
-		j.handleNotification(methodName, value.Get("params"))
+	// 取消同一连接上进行中的调用，停机期间同样有效
+	if id == nil && j.cancelMethod != "" && methodName == j.cancelMethod {
+		if s := sessionOf(ctx); s != nil {
+			s.calls.cancel(value.Get("params", "id"))
+		}
 		return nil, nil
 	}
 
+	// 停机期间拒绝新的调用，通知直接丢弃
+	if j.closing.Load() {
+		if id == nil {
+			return nil, nil
+		}
+		return j.createErrorResponse(id, ErrShuttingDown.Code, ErrShuttingDown.Message, nil)
+	}
+
 	// 查找方法
-	j.mu.RLock()
-	method, exists := j.methods[methodName]
-	j.mu.RUnlock()
+	method := j.lookup(ctx, methodName)
+
+	// 处理通知（没有 ID 的请求）
+	if id == nil {
+		if method != nil {
+			j.handleNotification(ctx, methodVal.GetStringBytes(), method.fn, value.Get("params"))
+		}
+		return nil, nil
+	}
 
-	if !exists {
+	if method == nil {
 		return j.createMethodNotFoundError(id)
 	}
 
-	// 调用方法
-	result, err := method(arena, value.Get("params"))
+	// WebSocket 连接上的调用可被取消
+	if s := sessionOf(ctx); s != nil && j.cancelMethod != "" {
+		return j.cancellable(ctx, &s.calls, id, func(ctx context.Context) ([]byte, error) {
+			return j.invoke(ctx, arena, method, methodName, id, value.Get("params"))
+		})
+	}
+	return j.invoke(ctx, arena, method, methodName, id, value.Get("params"))
+}
+
+// invoke 调用方法并创建响应，携带幂等键的调用最多执行一次
+func (j *JSONRPC2) invoke(ctx context.Context, arena *fastjson.Arena, method *methodEntry, methodName string, id, params *fastjson.Value) ([]byte, error) {
+	if token := fastjsonrpc.ProgressToken(params); token != nil {
+		ctx = fastjsonrpc.ContextWithProgressToken(ctx, token)
+	}
+	var key string
+	if method.idempotency != nil {
+		key = idempotencyKeyOf(ctx, params)
+	}
+	var response []byte
+	var err error
+	if key != "" {
+		response, err = j.callIdempotent(ctx, method, idempotencyStoreKey(ctx, methodName, key), arena, id, params)
+	} else {
+		response, err = j.call(ctx, method.fn, arena, id, params)
+	}
+	if err == nil && method.deprecated {
+		warnDeprecated(ctx, method.name, method.deprecation)
+	}
+	return response, err
+}
+
+// call 调用方法并创建响应
+func (j *JSONRPC2) call(ctx context.Context, method RPCMethodContext, arena *fastjson.Arena, id, params *fastjson.Value) ([]byte, error) {
+	result, err := method(ctx, arena, params)
 	if err != nil {
 		var rpcErr *RPCError
 		if errors.As(err, &rpcErr) {
 			return j.createErrorResponse(id, rpcErr.Code, rpcErr.Message, rpcErr.Data)
 		}
+		// 兼容 fastjsonrpc.Error，例如参数校验错误
+		var e *fastjsonrpc.Error
+		if errors.As(err, &e) {
+			return j.createErrorResponse(id, e.Code, e.Message, e.Data)
+		}
 		return j.createErrorResponse(id, -32000, err.Error(), nil)
 	}
 
@@ -218,21 +402,51 @@ func (j *JSONRPC2) handleSingleRequest(arena *fastjson.Arena, value *fastjson.Va
 	return j.createSuccessResponse(id, result)
 }
 
-// handleNotification 处理通知
-func (j *JSONRPC2) handleNotification(methodName string, params *fastjson.Value) {
-	j.mu.RLock()
-	method, exists := j.methods[methodName]
-	j.mu.RUnlock()
-
-	if exists {
-		go func() {
-			// 为异步处理创建新的 arena
-			asyncArena := j.arenaPool.Get()
-			defer j.arenaPool.Put(asyncArena)
+// handleNotification 处理通知，配置了持久化队列时写入队列
+func (j *JSONRPC2) handleNotification(ctx context.Context, name []byte, method RPCMethodContext, params *fastjson.Value) {
+	// 有序执行的通知在当前协程中完成，保证副作用的顺序；不进入队列，队列的工作协程会并发执行
+	if isOrdered(ctx) {
+		arena := j.arenaPool.Get()
+		defer j.arenaPool.Put(arena)
+		_, _ = method(j.ctx, arena, params)
+		return
+	}
+
+	// 参数所属的解析器在返回后会被复用，需复制一份
+	var raw []byte
+	if params != nil {
+		raw = params.MarshalTo(nil)
+	}
+	if j.queue != nil {
+		// 队列不可用时退回即发即弃处理，记录日志以便发现持久化失效
+		err := j.StartNotificationQueue()
+		if err == nil {
+			if err = j.queue.push(j.resolve(ctx, string(name)), raw); err == nil {
+				return
+			}
+		}
+		slog.Error("Notification queue unavailable, notification is not durable",
+			"method", string(name),
+			"error", err,
+		)
+	}
+	j.inFlight.Add(1)
+	go func() {
+		defer j.inFlight.Add(-1)
+
+		// 为异步处理创建新的 arena
+		asyncArena := j.arenaPool.Get()
+		defer j.arenaPool.Put(asyncArena)
+
+		var p *fastjson.Value
+		if raw != nil {
+			parser := j.parserPool.Get()
+			defer j.parserPool.Put(parser)
+			p, _ = parser.ParseBytes(raw)
+		}
 
-			_, _ = method(asyncArena, params)
-		}()
-	}
+		_, _ = method(j.ctx, asyncArena, p)
+	}()
 }
 
 // createSuccessResponse 创建成功响应
@@ -366,6 +580,11 @@ func (j *JSONRPC2) createInvalidRequestError() ([]byte, error) {
 	return []byte(`{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"Invalid Request"}}`), nil
 }
 
+// createLimitError 创建超出请求限制的错误响应
+func (j *JSONRPC2) createLimitError(err error) ([]byte, error) {
+	return j.createErrorResponse(nil, ErrInvalidRequest.Code, ErrInvalidRequest.Message, err.Error())
+}
+
 // createMethodNotFoundError 创建方法未找到错误响应
 func (j *JSONRPC2) createMethodNotFoundError(id *fastjson.Value) ([]byte, error) {
 	buf := bytebufferpool.Get()
@@ -390,6 +609,19 @@ func (j *JSONRPC2) createMethodNotFoundError(id *fastjson.Value) ([]byte, error)
 
 // RegisterObject 注册对象的所有公开方法（仅支持新签名）
 func (j *JSONRPC2) RegisterObject(obj interface{}, prefix ...string) error {
+	var methodPrefix string
+	if len(prefix) > 0 {
+		methodPrefix = prefix[0]
+	}
+	return j.RegisterService(obj, methodPrefix)
+}
+
+// RegisterService 注册对象的所有公开方法，并对每个方法应用配置项；
+// 前缀为空时使用命名策略转换的类名加分隔符（默认 snake_case 与 "."）。
+// 配置限流器时所有方法共享同一限流器
+func (j *JSONRPC2) RegisterService(obj interface{}, prefix string, opts ...MethodOption) error {
+	o := j.methodOptions(opts)
+
 	j.mu.Lock()
 	defer j.mu.Unlock()
 
@@ -397,17 +629,11 @@ func (j *JSONRPC2) RegisterObject(obj interface{}, prefix ...string) error {
 	objValue := reflect.ValueOf(obj)
 
 	// 确定前缀
-	var methodPrefix string
-	if len(prefix) > 0 && prefix[0] != "" {
-		methodPrefix = prefix[0]
-	} else {
-		// 使用小写类名作为前缀
-		typeName := objType.String()
-		if idx := strings.LastIndex(typeName, "."); idx != -1 {
-			typeName = typeName[idx+1:]
-		}
-		methodPrefix = strcase.ToSnake(typeName) + "."
+	methodPrefix := prefix
+	if methodPrefix == "" {
+		methodPrefix = j.defaultPrefix(objType)
 	}
+	prefixes := append([]string{methodPrefix}, o.aliases...)
 
 	// 遍历所有方法
 	for i := 0; i < objType.NumMethod(); i++ {
@@ -429,14 +655,31 @@ func (j *JSONRPC2) RegisterObject(obj interface{}, prefix ...string) error {
 			continue
 		}
 
-		// 构建完整方法名
-		methodName := methodPrefix + strcase.ToSnake(method.Name)
+		// 构建方法名，可被 WithMethodName 覆盖
+		name, ok := o.methodNames[method.Name]
+		if !ok {
+			name = j.naming(method.Name)
+		}
 
 		// 创建方法包装器
-		wrapper := j.createNewMethodWrapper(objValue, method)
+		entry := o.entry(o.versioned(methodPrefix+name), j.createNewMethodWrapper(objValue, method))
+		if t, ok := o.methodTypes[method.Name]; ok {
+			entry.info.Params, entry.info.Result = t[0], t[1]
+		}
 
-		// 注册方法
-		j.methods[methodName] = wrapper
+		// 注册方法及其别名
+		for _, p := range prefixes {
+			j.methods[o.versioned(p+name)] = entry
+			j.addService(p, o.versioned(p+name))
+		}
+		for _, alias := range o.methodAliases[method.Name] {
+			j.methods[o.versioned(alias)] = entry
+			j.addService(methodPrefix, o.versioned(alias))
+		}
+	}
+
+	if o.limiter != nil {
+		j.limiters[methodPrefix] = o.limiter
 	}
 
 	return nil
@@ -455,11 +698,7 @@ func (j *JSONRPC2) RegisterObjectMethods(obj interface{}, methodNames []string,
 	if len(prefix) > 0 && prefix[0] != "" {
 		methodPrefix = prefix[0]
 	} else {
-		typeName := objType.String()
-		if idx := strings.LastIndex(typeName, "."); idx != -1 {
-			typeName = typeName[idx+1:]
-		}
-		methodPrefix = strcase.ToSnake(typeName) + "."
+		methodPrefix = j.defaultPrefix(objType)
 	}
 
 	for _, methodName := range methodNames {
@@ -474,21 +713,31 @@ func (j *JSONRPC2) RegisterObjectMethods(obj interface{}, methodNames []string,
 		}
 
 		// 构建完整方法名
-		fullMethodName := methodPrefix + strcase.ToSnake(methodName)
+		fullMethodName := methodPrefix + j.naming(methodName)
 
 		// 创建方法包装器
 		wrapper := j.createNewMethodWrapper(objValue, method)
 
 		// 注册方法
-		j.methods[fullMethodName] = wrapper
+		j.methods[fullMethodName] = &methodEntry{fn: wrapper}
+		j.addService(methodPrefix, fullMethodName)
 	}
 
 	return nil
 }
 
+// defaultPrefix 使用命名策略转换的类名加分隔符作为默认前缀
+func (j *JSONRPC2) defaultPrefix(objType reflect.Type) string {
+	typeName := objType.String()
+	if idx := strings.LastIndex(typeName, "."); idx != -1 {
+		typeName = typeName[idx+1:]
+	}
+	return j.naming(typeName) + j.separator
+}
+
 // createNewMethodWrapper 创建新签名方法包装器
-func (j *JSONRPC2) createNewMethodWrapper(objValue reflect.Value, method reflect.Method) RPCMethod {
-	return func(arena *fastjson.Arena, params *fastjson.Value) (interface{}, error) {
+func (j *JSONRPC2) createNewMethodWrapper(objValue reflect.Value, method reflect.Method) RPCMethodContext {
+	return func(_ context.Context, arena *fastjson.Arena, params *fastjson.Value) (interface{}, error) {
 		// 调用对象方法（新签名）
 		args := []reflect.Value{objValue, reflect.ValueOf(arena), reflect.ValueOf(params)}
 		results := method.Func.Call(args)
@@ -0,0 +1,99 @@
+package ws
+
+import (
+	"context"
+	"sync"
+
+	"github.com/fasthttp/websocket"
+	"github.com/zc310/fastjsonrpc/internal/inflight"
+)
+
+// sessionSet 活跃的 WebSocket 连接集合
+type sessionSet struct {
+	mu    sync.Mutex
+	conns map[*websocket.Conn]func() // 连接 -> 请求优雅关闭连接
+	// active 连接数，全部连接结束时发出信号
+	active inflight.Counter
+}
+
+func (s *sessionSet) add(conn *websocket.Conn, goAway func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conns == nil {
+		s.conns = make(map[*websocket.Conn]func())
+	}
+	s.conns[conn] = goAway
+	s.active.Add(1)
+}
+
+func (s *sessionSet) remove(conn *websocket.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.conns[conn]; ok {
+		delete(s.conns, conn)
+		s.active.Add(-1)
+	}
+}
+
+// each 对每个连接执行 f
+func (s *sessionSet) each(f func(conn *websocket.Conn, goAway func())) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn, goAway := range s.conns {
+		f(conn, goAway)
+	}
+}
+
+// Shutdown 优雅停机：停止接受新的调用和连接，等待进行中的调用及连接上排队的消息完成，
+// 关闭任务池并等待异步任务完成，然后各 WebSocket 连接写出排队的响应后发送带原因的关闭帧，并等待连接结束。
+// ctx 到期时取消所有处理器的上下文，强制关闭剩余连接并返回 ctx.Err()
+func (j *JSONRPC2) Shutdown(ctx context.Context) error {
+	j.closing.Store(true)
+	if j.queue != nil {
+		j.stopNotificationQueue()
+	}
+
+	// 排队的消息执行时计入进行中的调用，两者都为零时才算处理完成
+	for j.messages.Load() != 0 || j.inFlight.Load() != 0 {
+		if !wait(ctx, &j.messages) || !wait(ctx, &j.inFlight) {
+			return j.abort(ctx)
+		}
+	}
+
+	// 异步任务完成后再关闭连接，以便发送完成通知
+	if j.jobPool != nil {
+		if err := j.jobPool.Close(ctx); err != nil {
+			return j.abort(ctx)
+		}
+	}
+
+	j.sessions.each(func(_ *websocket.Conn, goAway func()) { goAway() })
+	if !wait(ctx, &j.sessions.active) {
+		return j.abort(ctx)
+	}
+	j.cancel()
+	return nil
+}
+
+// wait 等待 c 归零，ctx 先到期时返回 false
+func wait(ctx context.Context, c *inflight.Counter) bool {
+	select {
+	case <-c.Idle():
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// abort 取消所有处理器的上下文并强制关闭剩余连接
+func (j *JSONRPC2) abort(ctx context.Context) error {
+	j.cancel()
+	j.sessions.each(func(conn *websocket.Conn, _ func()) {
+		closeWithReason(conn, websocket.CloseGoingAway, "server shutting down")
+		_ = conn.Close()
+	})
+	return ctx.Err()
+}
+
+// InFlight 获取正在处理的调用数（包括通知）
+func (j *JSONRPC2) InFlight() int64 { return j.inFlight.Load() }
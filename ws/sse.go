@@ -0,0 +1,75 @@
+package ws
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/goccy/go-json"
+	"github.com/valyala/fasthttp"
+)
+
+// acceptsEventStream 检查请求是否接受 text/event-stream 响应
+func acceptsEventStream(ctx *fasthttp.RequestCtx) bool {
+	return bytes.Contains(ctx.Request.Header.Peek(fasthttp.HeaderAccept), []byte("text/event-stream"))
+}
+
+// serveEventStream 以 SSE 响应 HTTP POST 请求：调用期间处理器经 Notify、fastjsonrpc.ReportProgress
+// 发送的通知依次作为 message 事件写出，最后一个事件为响应；客户端断开时取消调用的上下文
+func (j *JSONRPC2) serveEventStream(ctx *fasthttp.RequestCtx, reqCtx context.Context, body []byte) {
+	// 响应体在处理器返回后才写出，请求体届时可能已被复用
+	body = append([]byte(nil), body...)
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx.SetContentType("text/event-stream")
+	ctx.Response.Header.Set("Cache-Control", "no-cache")
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		reqCtx, cancel := context.WithCancel(reqCtx)
+		defer cancel()
+
+		// 写入由响应与通知共享；响应写出后异步任务的通知被丢弃
+		var mu sync.Mutex
+		closed := false
+		send := func(message []byte) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			if closed {
+				return false
+			}
+			if err := writeEvent(w, message); err != nil {
+				closed = true
+				cancel()
+				return false
+			}
+			return true
+		}
+		reqCtx = contextWithSession(reqCtx, &session{send: send})
+
+		response, err := j.HandleMessageContext(reqCtx, body)
+		if err != nil {
+			slog.Error("RPC handle error",
+				"error", err,
+				"message", json.RawMessage(body),
+				"operation", "handle_message",
+			)
+			response, err = j.createErrorResponse(nil, -32603, "Internal error", err.Error())
+		}
+		if err == nil && response != nil {
+			send(response)
+		}
+
+		mu.Lock()
+		closed = true
+		mu.Unlock()
+	})
+}
+
+// writeEvent 写出一个 message 事件并刷新，data 为不含换行的 JSON
+func writeEvent(w *bufio.Writer, data []byte) error {
+	_, _ = w.WriteString("event: message\ndata: ")
+	_, _ = w.Write(data)
+	_, _ = w.WriteString("\n\n")
+	return w.Flush()
+}
@@ -0,0 +1,125 @@
+package ws
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/goccy/go-json"
+	"github.com/valyala/fastjson"
+)
+
+// ServeStream 在按行分隔的 JSON 流上提供 JSON-RPC 服务，例如以 ServeStream(ctx, rpc, os.Stdin, os.Stdout)
+// 提供 stdio 传输，或在 TCP、Unix 连接上提供服务。每行为一条请求或批量请求，
+// 响应及处理器经 Notify、fastjsonrpc.ReportProgress 发送的通知逐行写入 w。
+// 消息按 WithExecution 执行，支持 $/cancelRequest；r 读到末尾后等待进行中的调用完成并返回，
+// ctx 取消或停机超时时取消处理器的上下文
+func ServeStream(ctx context.Context, rpc *JSONRPC2, r io.Reader, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(rpc.ctx, cancel)
+	defer stop()
+
+	// 写入由响应与通知共享，逐条加锁写出；返回后异步任务的通知被丢弃
+	var mu sync.Mutex
+	var writeErr error
+	closed := false
+	send := func(message []byte) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return false
+		}
+		if writeErr == nil {
+			_, writeErr = w.Write(append(message[:len(message):len(message)], '\n'))
+		}
+		return writeErr == nil
+	}
+	ctx = contextWithSession(ctx, &session{send: send})
+
+	queueSize := rpc.writeQueue
+	if queueSize <= 0 {
+		queueSize = 100
+	}
+	ordered := lanes{limit: queueSize, block: true}
+	var wg sync.WaitGroup
+
+	handle := func(ctx context.Context, msg []byte, parsed *fastjson.Value, parser *fastjson.Parser) {
+		defer wg.Done()
+		defer rpc.messages.Add(-1)
+
+		var response []byte
+		var err error
+		if parsed != nil {
+			response, err = rpc.handleValue(ctx, parsed)
+			rpc.parserPool.Put(parser)
+		} else {
+			response, err = rpc.handleMessage(ctx, msg)
+		}
+		if err != nil {
+			slog.Error("RPC handle error",
+				"error", err,
+				"message", json.RawMessage(msg),
+				"operation", "handle_message",
+			)
+			if response, err = rpc.createErrorResponse(nil, -32603, "Internal error", err.Error()); err != nil {
+				return
+			}
+		}
+		if response != nil {
+			send(response)
+		}
+	}
+
+	br := bufio.NewReader(r)
+	var readErr error
+	for readErr == nil {
+		var line []byte
+		line, readErr = br.ReadBytes('\n')
+		message := bytes.TrimSpace(line)
+		if len(message) == 0 {
+			continue
+		}
+
+		// 超出限制的消息以错误响应，流上的其他消息不受影响
+		if err := rpc.limits.Check(message); err != nil {
+			if response, err := rpc.createLimitError(err); err == nil {
+				send(response)
+			}
+			continue
+		}
+		if rpc.handleCancel(ctx, message) {
+			continue
+		}
+
+		wg.Add(1)
+		rpc.messages.Add(1)
+		if rpc.ordered.Load() {
+			parser := rpc.parserPool.Get()
+			if v, err := parser.ParseBytes(message); err == nil {
+				if lane, ok := rpc.laneOf(ctx, v); ok {
+					ctx := orderedContext(ctx)
+					ordered.run(lane, func() { handle(ctx, message, v, parser) })
+				} else {
+					go handle(ctx, message, v, parser)
+				}
+				continue
+			}
+			rpc.parserPool.Put(parser)
+		}
+		go handle(ctx, message, nil, nil)
+	}
+	wg.Wait()
+
+	mu.Lock()
+	closed = true
+	mu.Unlock()
+	if !errors.Is(readErr, io.EOF) {
+		return readErr
+	}
+	return writeErr
+}
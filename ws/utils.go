@@ -218,5 +218,5 @@ func (j *JSONRPC2) RegisterTestService(prefix ...string) {
 		return testService.Echo(nil, params)
 	})
 
-	slog.Info("Test service registered with prefix: ", servicePrefix)
+	slog.Info("Test service registered", "prefix", servicePrefix)
 }
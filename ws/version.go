@@ -0,0 +1,198 @@
+package ws
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/valyala/fasthttp"
+	"github.com/zc310/fastjsonrpc"
+)
+
+// versionSeparator 版本号分隔符，例如 "user.get@v2"
+const versionSeparator = "@"
+
+// methodEntry 已注册的方法
+type methodEntry struct {
+	name        string
+	fn          RPCMethodContext
+	deprecated  bool
+	deprecation string
+	info        fastjsonrpc.MethodInfo
+	idempotency *fastjsonrpc.Idempotency
+	execution   *Execution
+}
+
+type versionKey struct{}
+
+// WithVersionHeader 设置用于协商方法版本的 HTTP 头；WebSocket 连接使用握手请求中的该头。
+// 例如头的值为 "v2" 时，对 "m" 的调用在 "m@v2" 已注册时路由到 "m@v2"
+func WithVersionHeader(name string) Option {
+	return func(j *JSONRPC2) { j.versionHeader = name }
+}
+
+// WithVersion 以 "name@version" 注册方法
+func WithVersion(version string) MethodOption {
+	return func(o *methodOptions) { o.version = version }
+}
+
+// DeprecationMethod 调用已弃用方法时，在响应之前经 WebSocket 连接发送的通知方法
+const DeprecationMethod = "$/deprecated"
+
+// DeprecationParams DeprecationMethod 通知的参数
+type DeprecationParams struct {
+	Method  string `json:"method"`
+	Message string `json:"message"`
+}
+
+// Deprecated 标记方法已弃用：成功的调用经 WebSocket 连接收到 DeprecationMethod 通知，
+// 经 HTTPHandler 的响应附带 Deprecation 与 Warning 头，响应体不变
+func Deprecated(message string) MethodOption {
+	return func(o *methodOptions) { o.deprecated, o.deprecation = true, message }
+}
+
+// ContextWithVersion 返回携带协商版本的上下文
+func ContextWithVersion(ctx context.Context, version string) context.Context {
+	if version == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, versionKey{}, version)
+}
+
+// versionContext 从请求头读取协商版本
+func (j *JSONRPC2) versionContext(ctx context.Context, req *fasthttp.RequestCtx) context.Context {
+	if j.versionHeader == "" {
+		return ctx
+	}
+	return ContextWithVersion(ctx, string(req.Request.Header.Peek(j.versionHeader)))
+}
+
+// versioned 构建带版本的方法名
+func (o *methodOptions) versioned(name string) string {
+	if o.version == "" {
+		return name
+	}
+	return name + versionSeparator + o.version
+}
+
+// entry 按配置创建方法条目
+func (o *methodOptions) entry(name string, method RPCMethodContext) *methodEntry {
+	info := o.info
+	info.Schema, info.Deprecated, info.Deprecation = o.schema, o.deprecated, o.deprecation
+	return &methodEntry{name: name, fn: o.wrap(name, method), deprecated: o.deprecated, deprecation: o.deprecation, info: info, idempotency: o.idempotency, execution: o.execution}
+}
+
+// lookup 查找方法，优先使用上下文中协商的版本
+func (j *JSONRPC2) lookup(ctx context.Context, name string) *methodEntry {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	if version, _ := ctx.Value(versionKey{}).(string); version != "" && !strings.Contains(name, versionSeparator) {
+		if m, ok := j.methods[name+versionSeparator+version]; ok {
+			return m
+		}
+	}
+	return j.methods[name]
+}
+
+// resolve 返回 lookup 找到的方法的注册名（包含版本后缀）
+func (j *JSONRPC2) resolve(ctx context.Context, name string) string {
+	if version, _ := ctx.Value(versionKey{}).(string); version != "" && !strings.Contains(name, versionSeparator) {
+		j.mu.RLock()
+		defer j.mu.RUnlock()
+		if _, ok := j.methods[name+versionSeparator+version]; ok {
+			return name + versionSeparator + version
+		}
+	}
+	return name
+}
+
+// UnregisterMethod 注销方法（名称包含版本后缀）及其别名，返回方法是否存在
+func (j *JSONRPC2) UnregisterMethod(name string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	m, ok := j.methods[name]
+	if !ok {
+		return false
+	}
+	for k, v := range j.methods {
+		if v == m {
+			delete(j.methods, k)
+			j.removeService(k)
+		}
+	}
+	delete(j.limiters, name)
+	return true
+}
+
+// addService 记录以 prefix 注册的方法名，供 UnregisterService 使用
+func (j *JSONRPC2) addService(prefix, name string) {
+	if j.services == nil {
+		j.services = make(map[string][]string)
+	}
+	j.services[prefix] = append(j.services[prefix], name)
+}
+
+// removeService 从服务索引中删除方法名
+func (j *JSONRPC2) removeService(name string) {
+	for prefix, names := range j.services {
+		names = slices.DeleteFunc(names, func(n string) bool { return n == name })
+		if len(names) == 0 {
+			delete(j.services, prefix)
+		} else {
+			j.services[prefix] = names
+		}
+	}
+}
+
+// UnregisterService 注销以 prefix 注册的服务的所有方法（包括别名），返回注销的方法数；
+// prefix 也可以省略末尾的分隔符，例如 "user" 注销以 "user." 注册的服务
+func (j *JSONRPC2) UnregisterService(prefix string) int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, ok := j.services[prefix]; !ok {
+		prefix += j.separator
+	}
+	var n int
+	for _, name := range j.services[prefix] {
+		if _, ok := j.methods[name]; ok {
+			delete(j.methods, name)
+			n++
+		}
+	}
+	delete(j.services, prefix)
+	delete(j.limiters, prefix)
+	return n
+}
+
+type warningsKey struct{}
+
+// warnings 收集 HTTP 请求中已弃用调用的警告
+type warnings struct {
+	mu     sync.Mutex
+	header *fasthttp.ResponseHeader
+}
+
+func contextWithWarnings(ctx context.Context, h *fasthttp.ResponseHeader) context.Context {
+	return context.WithValue(ctx, warningsKey{}, &warnings{header: h})
+}
+
+// warnDeprecated 提示调用方 name 已弃用：WebSocket 连接上发送通知，HTTP 请求中设置响应头
+func warnDeprecated(ctx context.Context, name, message string) {
+	if s := sessionOf(ctx); s != nil {
+		_ = s.notify(DeprecationMethod, DeprecationParams{Method: name, Message: message})
+	}
+	if w, _ := ctx.Value(warningsKey{}).(*warnings); w != nil {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		warning := name + " is deprecated"
+		if message != "" {
+			warning += ": " + message
+		}
+		w.header.Set("Deprecation", "true")
+		w.header.Add("Warning", `299 - "`+strings.ReplaceAll(warning, `"`, `'`)+`"`)
+	}
+}
@@ -0,0 +1,68 @@
+package ws_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fastjson"
+	"github.com/zc310/fastjsonrpc/rpctest"
+	"github.com/zc310/fastjsonrpc/ws"
+)
+
+type Users struct{}
+
+func (Users) Get(*fastjson.Arena, *fastjson.Value) (interface{}, error) { return "user", nil }
+
+type UsersAdmin struct{}
+
+func (UsersAdmin) Get(*fastjson.Arena, *fastjson.Value) (interface{}, error) { return "admin", nil }
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+
+	j := ws.NewJSONRPC2()
+	assert.NoError(t, j.RegisterService(Users{}, "", ws.Deprecated("use users.find")))
+	assert.NoError(t, j.RegisterService(UsersAdmin{}, ""))
+
+	// Deprecation is announced in a notification; the response is unchanged.
+	h := rpctest.NewJSONRPC2(t, j)
+	r := h.Call(t, "users.get", nil).ExpectResult("user")
+	assert.JSONEq(t, `{"jsonrpc":"2.0","result":"user","id":1}`, string(r.Raw))
+	n := h.WaitNotification(t, ws.DeprecationMethod)
+	assert.JSONEq(t, `{"method":"users.get","message":"use users.find"}`, string(n.Params))
+
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+	ctx.Request.Header.SetContentType("application/json")
+	ctx.Request.SetBodyString(`{"jsonrpc":"2.0","method":"users.get","id":1}`)
+	ws.HTTPHandler(j)(ctx)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","result":"user","id":1}`, string(ctx.Response.Body()))
+	assert.Equal(t, "true", string(ctx.Response.Header.Peek("Deprecation")))
+	assert.Equal(t, `299 - "users.get is deprecated: use users.find"`, string(ctx.Response.Header.Peek("Warning")))
+
+	// A service is removed by its prefix, with or without the separator,
+	// leaving services whose names merely start with it.
+	assert.Equal(t, 1, j.UnregisterService("users"))
+	assert.Equal(t, 0, j.UnregisterService("users."))
+	h.Call(t, "users.get", nil).ExpectError(-32601)
+	h.Call(t, "users_admin.get", nil).ExpectResult("admin")
+	assert.Equal(t, 1, j.UnregisterService("users_admin."))
+}
+
+func TestUnregisterMethod(t *testing.T) {
+	t.Parallel()
+
+	j := ws.NewJSONRPC2()
+	assert.NoError(t, j.RegisterService(Users{}, "", ws.WithAlias("people.")))
+
+	// Unregistering a method removes its aliases and its place in the service.
+	assert.True(t, j.UnregisterMethod("users.get"))
+	assert.False(t, j.UnregisterMethod("people.get"))
+	assert.Equal(t, 0, j.UnregisterService("users"))
+	assert.Equal(t, 0, j.UnregisterService("people"))
+
+	h := rpctest.NewJSONRPC2(t, j)
+	h.Call(t, "users.get", nil).ExpectError(-32601)
+	h.Call(t, "people.get", nil).ExpectError(-32601)
+}
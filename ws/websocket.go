@@ -1,6 +1,7 @@
 package ws
 
 import (
+	"context"
 	"errors"
 	"log/slog"
 	"time"
@@ -10,13 +11,44 @@ import (
 	"github.com/fasthttp/websocket"
 	"github.com/goccy/go-json"
 	"github.com/valyala/fasthttp"
+	"github.com/valyala/fastjson"
 )
 
 // Handler 创建 WebSocket JSON-RPC 处理器
 func Handler(rpc *JSONRPC2, upgrader *websocket.FastHTTPUpgrader) fasthttp.RequestHandler {
 	return func(ctx *fasthttp.RequestCtx) {
+		// 停机期间拒绝新连接
+		if rpc.closing.Load() {
+			ctx.SetStatusCode(fasthttp.StatusServiceUnavailable)
+			ctx.SetBodyString("Server shutting down")
+			return
+		}
+
+		// 握手请求头在升级后不再可用，提前读取协商版本与调用方身份
+		baseCtx := rpc.principalContext(rpc.versionContext(rpc.ctx, ctx), ctx)
+		var traceHeader map[string]string
+		if rpc.trace != nil && len(rpc.traceHeaders) > 0 {
+			traceHeader = make(map[string]string, len(rpc.traceHeaders))
+			for _, name := range rpc.traceHeaders {
+				if v := ctx.Request.Header.Peek(name); len(v) > 0 {
+					traceHeader[name] = string(v)
+				}
+			}
+		}
+
 		err := upgrader.Upgrade(ctx, func(ws *websocket.Conn) {
 			startTime := time.Now()
+
+			// 会话上下文：连接断开或停机超时时取消
+			sessionCtx, cancel := context.WithCancel(baseCtx)
+			// 停机时由写入器在写出排队的响应后发送关闭帧
+			goingAway := make(chan struct{})
+			rpc.sessions.add(ws, sync.OnceFunc(func() { close(goingAway) }))
+			defer func() {
+				cancel()
+				rpc.sessions.remove(ws)
+			}()
+
 			defer func() {
 				slog.Info("WebSocket session ended",
 					"event", "session_end",
@@ -34,33 +66,100 @@ func Handler(rpc *JSONRPC2, upgrader *websocket.FastHTTPUpgrader) fasthttp.Reque
 				"connected_at", startTime.Format(time.RFC3339),
 			)
 
+			if rpc.limits.MaxBodySize > 0 {
+				ws.SetReadLimit(int64(rpc.limits.MaxBodySize))
+			}
+
 			// 使用 WaitGroup 来管理所有处理 goroutine
 			var wg sync.WaitGroup
 			// 用于在连接关闭时通知所有 goroutine
 			done := make(chan struct{})
 			// 用于发送响应（保证写入顺序）
-			responseChan := make(chan []byte, 100)
+			queueSize := rpc.writeQueue
+			if queueSize <= 0 {
+				queueSize = 100
+			}
+			responseChan := make(chan []byte, queueSize)
+			var overflow sync.Once
+			// closeOnFull 队列已满时关闭连接，读取循环随之结束
+			closeOnFull := func(queue string) {
+				overflow.Do(func() {
+					slog.Warn("WebSocket "+queue+" full",
+						"remote_addr", ws.RemoteAddr(),
+						"size", queueSize,
+					)
+					closeWithReason(ws, websocket.ClosePolicyViolation, queue+" full")
+					_ = ws.Close()
+				})
+			}
+			// send 将响应或通知放入写队列，连接关闭时返回 false
+			send := func(message []byte) bool {
+				select {
+				case <-done:
+					return false
+				default:
+				}
+				if rpc.backpressure == CloseOnFull {
+					select {
+					case responseChan <- message:
+						return true
+					default:
+						closeOnFull("write queue")
+						return false
+					}
+				}
+				select {
+				case responseChan <- message:
+					return true
+				case <-done:
+					return false
+				}
+			}
+			// 处理器通过上下文向连接推送通知
+			sessionCtx = contextWithSession(sessionCtx, &session{send: send})
+			// 有序执行的消息按通道排队，通道长度与写队列相同
+			ordered := lanes{limit: queueSize, block: rpc.backpressure != CloseOnFull}
+			// 限制单连接并发处理数
+			var sem chan struct{}
+			if rpc.sessionConcurrency > 0 {
+				sem = make(chan struct{}, rpc.sessionConcurrency)
+			}
 
 			// 启动响应写入器
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
+				write := func(response []byte) bool {
+					err := ws.WriteMessage(websocket.TextMessage, response)
+					slog.Debug("WebSocket message sent",
+						"message", json.RawMessage(response),
+						"remote_addr", ws.RemoteAddr(),
+						"message_type", "text",
+					)
+					if err != nil {
+						slog.Error("WebSocket write error",
+							"error", err,
+							"remote_addr", ws.RemoteAddr(),
+						)
+						return false
+					}
+					return true
+				}
 				for {
 					select {
 					case response := <-responseChan:
-						err := ws.WriteMessage(websocket.TextMessage, response)
-						slog.Debug("WebSocket message sent",
-							"message", json.RawMessage(response),
-							"remote_addr", ws.RemoteAddr(),
-							"message_type", "text",
-						)
-						if err != nil {
-							slog.Error("WebSocket write error",
-								"error", err,
-								"remote_addr", ws.RemoteAddr(),
-							)
+						if !write(response) {
 							return
 						}
+					case <-goingAway:
+						// 写出已排队的响应与通知，再发送关闭帧
+						for len(responseChan) > 0 {
+							if !write(<-responseChan) {
+								return
+							}
+						}
+						closeWithReason(ws, websocket.CloseGoingAway, "server shutting down")
+						goingAway = nil
 					case <-done:
 						slog.Info("WebSocket write loop stopped",
 							"remote_addr", ws.RemoteAddr(),
@@ -71,6 +170,9 @@ func Handler(rpc *JSONRPC2, upgrader *websocket.FastHTTPUpgrader) fasthttp.Reque
 				}
 			}()
 
+			// 导致连接关闭的限制错误
+			var rejected error
+
 			// 主循环读取消息
 			for {
 				_, message, err := ws.ReadMessage()
@@ -90,13 +192,49 @@ func Handler(rpc *JSONRPC2, upgrader *websocket.FastHTTPUpgrader) fasthttp.Reque
 					"remote_addr", ws.RemoteAddr(),
 				)
 
-				// 为每个消息启动一个 goroutine 处理
-				wg.Add(1)
-				go func(msg []byte) {
+				// 超出限制的消息：返回错误并关闭连接
+				if err := rpc.limits.Check(message); err != nil {
+					slog.Warn("WebSocket message rejected",
+						"error", err,
+						"remote_addr", ws.RemoteAddr(),
+					)
+					rejected = err
+					break
+				}
+
+				// 取消请求立即执行，不等待并发上限或执行通道
+				start := time.Now()
+				if rpc.handleCancel(sessionCtx, message) {
+					if rpc.trace != nil {
+						rpc.trace(traceHeader, message, nil, start)
+					}
+					continue
+				}
+
+				// 达到并发上限时阻塞读取，形成背压
+				if sem != nil {
+					sem <- struct{}{}
+				}
+
+				// 为每个消息启动一个 goroutine 处理，有序执行的消息在所属通道中排队
+				// parsed 为读取循环已解析的消息，处理后归还 parser
+				handle := func(ctx context.Context, msg []byte, parsed *fastjson.Value, parser *fastjson.Parser) {
 					defer wg.Done()
+					defer rpc.messages.Add(-1)
+					if sem != nil {
+						defer func() { <-sem }()
+					}
 
 					// 处理 JSON-RPC 请求
-					response, err := rpc.HandleMessage(msg)
+					start := time.Now()
+					var response []byte
+					var err error
+					if parsed != nil {
+						response, err = rpc.handleValue(ctx, parsed)
+						rpc.parserPool.Put(parser)
+					} else {
+						response, err = rpc.handleMessage(ctx, msg)
+					}
 					if err != nil {
 						slog.Error("RPC handle error",
 							"error", err,
@@ -106,9 +244,10 @@ func Handler(rpc *JSONRPC2, upgrader *websocket.FastHTTPUpgrader) fasthttp.Reque
 						)
 						errorArena := rpc.arenaPool.Get()
 						if errorResponse, err := rpc.createErrorResponse(nil, -32603, "Internal error", err.Error()); err == nil {
-							select {
-							case responseChan <- errorResponse:
-							case <-done:
+							if rpc.trace != nil {
+								rpc.trace(traceHeader, msg, errorResponse, start)
+							}
+							if !send(errorResponse) {
 								// 连接已关闭，丢弃响应
 								slog.Warn("RPC error response discarded",
 									"reason", "connection_closed",
@@ -121,30 +260,69 @@ func Handler(rpc *JSONRPC2, upgrader *websocket.FastHTTPUpgrader) fasthttp.Reque
 						return
 					}
 
+					if rpc.trace != nil {
+						rpc.trace(traceHeader, msg, response, start)
+					}
+
 					// 如果是通知，不需要响应
 					if response == nil {
 						return
 					}
 
-					// 发送响应到写入器
-					select {
-					case responseChan <- response:
-					case <-done:
-						// 连接已关闭，丢弃响应
+					// 发送响应到写入器，连接已关闭时丢弃
+					send(response)
+				}
+
+				// 计入排队等待执行的消息，停机时等待其处理完成
+				wg.Add(1)
+				rpc.messages.Add(1)
+				if rpc.ordered.Load() {
+					// 解析一次，用于选择执行通道并交给处理器
+					parser := rpc.parserPool.Get()
+					if v, err := parser.ParseBytes(message); err == nil {
+						lane, ok := rpc.laneOf(sessionCtx, v)
+						if !ok {
+							go handle(sessionCtx, message, v, parser)
+							continue
+						}
+						ctx := orderedContext(sessionCtx)
+						msg := message
+						if ordered.run(lane, func() { handle(ctx, msg, v, parser) }) {
+							continue
+						}
+						// 执行通道已满
+						rpc.parserPool.Put(parser)
+						if sem != nil {
+							<-sem
+						}
+						rpc.messages.Add(-1)
+						wg.Done()
+						closeOnFull("execution queue")
+						break
 					}
-				}(message)
+					rpc.parserPool.Put(parser)
+				}
+				go handle(sessionCtx, message, nil, nil)
 			}
 
-			// 关闭连接，通知所有 goroutine
+			// 关闭连接，通知所有 goroutine 并取消处理器上下文
 			close(done)
+			cancel()
 			// 等待所有处理完成
 			wg.Wait()
+
+			if rejected != nil {
+				if errorResponse, err := rpc.createLimitError(rejected); err == nil {
+					_ = ws.WriteMessage(websocket.TextMessage, errorResponse)
+				}
+				closeWithReason(ws, websocket.ClosePolicyViolation, rejected.Error())
+			}
 		})
 
 		if err != nil {
 			var handshakeError websocket.HandshakeError
 			if errors.As(err, &handshakeError) {
-				slog.Error("WebSocket handshake error:", err)
+				slog.Error("WebSocket handshake error", "error", err)
 			}
 
 			ctx.SetStatusCode(fasthttp.StatusBadRequest)
@@ -152,3 +330,10 @@ func Handler(rpc *JSONRPC2, upgrader *websocket.FastHTTPUpgrader) fasthttp.Reque
 		}
 	}
 }
+
+// closeWithReason 发送带原因的关闭帧
+func closeWithReason(ws *websocket.Conn, code int, reason string) {
+	_ = ws.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, reason),
+		time.Now().Add(time.Second))
+}